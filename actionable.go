@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+)
+
+// accessWrite is W_OK for access(2), which the syscall package does not name.
+const accessWrite = 2
+
+// queueFile finds the file of e below the queue directory dir. Postfix
+// hashes some queues into one or two levels of subdirectories, so those
+// are searched as well. It returns "" if the file cannot be found, which
+// includes not being allowed to look.
+func queueFile(dir string, e queueEntry) string {
+	for _, pattern := range []string{"", "?", filepath.Join("?", "?")} {
+		matches, _ := filepath.Glob(filepath.Join(dir, e.Queue, pattern, e.ID))
+		if len(matches) > 0 {
+			return matches[0]
+		}
+	}
+	return ""
+}
+
+// canActOn reports whether delete and hold can work on e: the session is
+// not read-only and the user may change the directory holding its queue
+// file (postsuper removes and renames the file). The files of other hosts
+// cannot be checked from here. The files are looked up once a listing, see
+// writableEntries.
+func (m *model) canActOn(e queueEntry) bool {
+	if m.readOnly || e.Host != "" {
+		return !m.readOnly
+	}
+	if m.writable == nil {
+		m.writable = writableEntries(m.allEntries)
+	}
+	return m.writable[entryKey(e)]
+}
+
+// writableEntries finds the entries of this machine among entries whose
+// queue file the user may change, by entryKey. Finding a file takes up to
+// three globs, too many to repeat for every filter change.
+func writableEntries(entries []queueEntry) map[string]bool {
+	writable := map[string]bool{}
+	dir := queueDirectory()
+	for _, e := range entries {
+		if e.Host != "" {
+			continue
+		}
+		if path := queueFile(dir, e); path != "" && syscall.Access(filepath.Dir(path), accessWrite) == nil {
+			writable[entryKey(e)] = true
+		}
+	}
+	return writable
+}
+
+// toggleActionable shows only the entries the user can act on, or all of
+// them again.
+func (m *model) toggleActionable() {
+	m.actionableOnly = !m.actionableOnly
+	m.applyFilter()
+	switch {
+	case !m.actionableOnly:
+		m.status = fmt.Sprintf("showing all messages, %d of %d shown", len(m.entries), len(m.allEntries))
+	case len(m.entries) == 0 && m.readOnly:
+		m.status = "no message can be acted on: " + m.readOnlyReason
+	default:
+		m.status = fmt.Sprintf("%d of %d messages can be acted on", len(m.entries), len(m.allEntries))
+	}
+}
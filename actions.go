@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// action is a queue operation the user can trigger from the TUI.
+type action int
+
+const (
+	actionDelete action = iota
+	actionHold
+	actionRelease
+	actionRequeue
+	actionExpire
+	actionFlush
+	actionClearCorrupt
+)
+
+// allActions lists every action in the order it is shown to the user.
+var allActions = []action{actionDelete, actionHold, actionRelease, actionRequeue, actionExpire, actionFlush, actionClearCorrupt}
+
+// String returns the config name of the action.
+func (a action) String() string {
+	switch a {
+	case actionDelete:
+		return "delete"
+	case actionHold:
+		return "hold"
+	case actionRelease:
+		return "release"
+	case actionRequeue:
+		return "requeue"
+	case actionExpire:
+		return "expire"
+	case actionFlush:
+		return "flush"
+	case actionClearCorrupt:
+		return "clear-corrupt"
+	}
+	return fmt.Sprintf("action(%d)", int(a))
+}
+
+// parseAction maps a config name back to its action.
+func parseAction(s string) (action, bool) {
+	for _, a := range allActions {
+		if a.String() == s {
+			return a, true
+		}
+	}
+	return 0, false
+}
+
+// perEntry reports whether the action works on a single queue ID
+// (as opposed to the whole queue).
+func (a action) perEntry() bool {
+	return a != actionFlush && a != actionClearCorrupt
+}
+
+// command returns the external command implementing the action for id on
+// host ("" for this machine), bound to ctx; nil if the mail server cannot
+// run it, see mta.go.
+func (a action) command(ctx context.Context, host, id string) *exec.Cmd {
+	if !backend.supports(a) {
+		return nil
+	}
+	return backend.act(ctx, host, a, id)
+}
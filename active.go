@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Messages in the active queue are being delivered by Postfix right now. A
+// delete or hold of one races with that delivery: the message may be gone
+// (delivered) before the action gets to it, or deleted halfway through its
+// recipients. The confirmation says so, and 's' in it leaves those
+// messages out.
+
+// racesDelivery reports whether a on an active message may race with its
+// delivery.
+func racesDelivery(a action) bool {
+	return a == actionDelete || a == actionHold
+}
+
+// activeTargets returns the messages about to be confirmed that are in the
+// active queue, nil when the action does not race with delivery.
+func (m model) activeTargets() []string {
+	if !racesDelivery(m.confirmAction) {
+		return nil
+	}
+	active := map[string]bool{}
+	for _, e := range m.entries {
+		if e.Queue == "active" {
+			active[e.ID] = true
+		}
+	}
+	var ids []string
+	for _, id := range m.confirmedIDs() {
+		if active[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// activeWarning is the line the confirmation adds for messages in the
+// active queue, "" when there are none.
+func (m model) activeWarning() string {
+	active := m.activeTargets()
+	switch {
+	case len(active) == 0:
+		return ""
+	case len(m.confirmedIDs()) == 1:
+		return warningStyle.Render("in the active queue, being delivered now: Postfix may deliver it before the " + m.confirmAction.String())
+	}
+	return warningStyle.Render(fmt.Sprintf("%d of them in the active queue, being delivered now; 's' leaves them out", len(active)))
+}
+
+// skipActiveTargets drops the messages in the active queue from the
+// confirmation. It reports false when nothing is left to confirm.
+func (m *model) skipActiveTargets() bool {
+	active := m.activeTargets()
+	if len(active) == 0 {
+		return true
+	}
+	skip := map[string]bool{}
+	for _, id := range active {
+		skip[id] = true
+	}
+	ids := slices.DeleteFunc(m.confirmedIDs(), func(id string) bool { return skip[id] })
+	if len(ids) == 0 {
+		m.status = "nothing left: all of them are in the active queue"
+		return false
+	}
+	m.confirmIDs, m.confirmSummary = ids, ""
+	m.status = fmt.Sprintf("%d messages in the active queue left out", len(active))
+	return true
+}
+
+// activeNotice is appended to the status of an unconfirmed action on a
+// message in the active queue.
+func (m model) activeNotice(a action) string {
+	if !racesDelivery(a) || m.selected >= len(m.entries) || m.entries[m.selected].Queue != "active" {
+		return ""
+	}
+	return " (it was in the active queue: Postfix may have delivered it first)"
+}
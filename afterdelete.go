@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Listing a queue of 100k messages takes seconds, too long to wait for
+// after every 'd'. With after_delete = "remove" a deleted message is only
+// taken out of the list; the queue is listed again deferred_refresh after
+// the last delete, on ctrl+l, or when a bulk operation ends.
+
+// Values of after_delete.
+const (
+	afterDeleteRefresh = "refresh" // list the queue again, the default
+	afterDeleteRemove  = "remove"  // remove the message from the list
+)
+
+// afterDeleteModes are the valid values of after_delete.
+var afterDeleteModes = []string{afterDeleteRefresh, afterDeleteRemove}
+
+// deferredRefreshMsg lists the queue after the deletes up to seq.
+type deferredRefreshMsg struct {
+	seq int
+}
+
+// removeDeleted takes the deleted entry out of the list and the queue
+// counts, and schedules the listing that catches up with the queue.
+func (m *model) removeDeleted(entry queueEntry) tea.Cmd {
+	for i, e := range m.allEntries {
+		if e.ID == entry.ID && e.Host == entry.Host {
+			m.allEntries = append(m.allEntries[:i:i], m.allEntries[i+1:]...)
+			break
+		}
+	}
+	m.pruneMarks()
+	m.pruneBookmarks()
+	m.pruneTrash()
+	m.applyFilter()
+	m.selectAfterAction()
+	m.showDetailsPlaceholder()
+
+	m.unlisted++
+	if m.cfg.DeferredRefresh <= 0 {
+		return nil
+	}
+	seq := m.unlisted
+	return tea.Tick(m.cfg.DeferredRefresh, func(time.Time) tea.Msg { return deferredRefreshMsg{seq} })
+}
+
+// deferredRefresh lists the queue unless another delete came since msg was
+// scheduled, or a listing already caught up.
+func (m *model) deferredRefresh(msg deferredRefreshMsg) tea.Cmd {
+	if m.unlisted == 0 || msg.seq != m.unlisted {
+		return nil
+	}
+	return runMailqCmd
+}
+
+// unlistedChip tells how many deletes the list shows without a listing
+// since, "" when it is up to date.
+func (m model) unlistedChip() string {
+	if m.unlisted == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[%d deleted since listing, ctrl+l lists]", m.unlisted)
+}
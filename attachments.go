@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Whether a message has attachments takes its body: the MIME structure is
+// walked for parts with a file name or "Content-Disposition: attachment".
+// The filter term attach:yes (or attach:no) fetches in the background the
+// bodies of the messages the rest of the filter does not rule out, and the
+// list is filtered again every attachRefresh answers and after the last;
+// the details of a message tell it for free. The answer is kept by queue
+// ID for the session, and the list flags messages with attachments with a
+// paperclip.
+
+// Values of model.attachments besides "" (fetching).
+const (
+	attachYes     = "yes"
+	attachNo      = "no"
+	attachUnknown = "?" // the message could not be read or parsed
+)
+
+// attachmentMsg delivers whether a message has attachments; cancelled
+// when its job was dropped from the pool.
+type attachmentMsg struct {
+	id, has   string
+	cancelled bool
+}
+
+// attachRefresh is how many answers arrive between two filterings of the
+// list while attach: is in the filter.
+const attachRefresh = 50
+
+// maxMIMEDepth is how deep nested multiparts are walked.
+const maxMIMEDepth = 8
+
+// mimeHeader is what the walk needs of mail.Header and the header of a
+// multipart.Part.
+type mimeHeader interface {
+	Get(key string) string
+}
+
+// attachmentsOf tells from postcat output whether the message has
+// attachments, attachUnknown when it has no content or cannot be parsed.
+func attachmentsOf(postcat string) string {
+	headers, body, ok := splitMessage(postcat)
+	if !ok {
+		return attachUnknown
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(headers + "\n" + body))
+	if err != nil {
+		return attachUnknown
+	}
+	has, err := hasAttachment(msg.Header, msg.Body, 0)
+	switch {
+	case has:
+		return attachYes
+	case err != nil:
+		return attachUnknown
+	}
+	return attachNo
+}
+
+// hasAttachment reports whether the part with header h and body is or
+// contains an attachment.
+func hasAttachment(h mimeHeader, body io.Reader, depth int) (bool, error) {
+	if disp, params, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil {
+		if strings.EqualFold(disp, "attachment") || params["filename"] != "" {
+			return true, nil
+		}
+	}
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		// no or a broken Content-Type: text/plain by RFC 2045
+		return false, nil
+	}
+	if params["name"] != "" || (depth > 0 && mediaType == "message/rfc822") {
+		return true, nil
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") || depth >= maxMIMEDepth {
+		return false, nil
+	}
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if has, err := hasAttachment(p.Header, p, depth+1); has || err != nil {
+			return has, err
+		}
+	}
+}
+
+// fetchAttachments drops what is known of messages that left the queue
+// and, while the filter asks for attachments, fetches the messages whose
+// attachments decide whether the filter shows them, at background
+// priority.
+func (m *model) fetchAttachments() tea.Cmd {
+	listed := map[string]bool{}
+	for _, e := range m.allEntries {
+		listed[e.ID] = true
+	}
+	for id, has := range m.attachments {
+		if !listed[id] {
+			if has == "" {
+				m.attachPending-- // its answer no longer counts
+			}
+			delete(m.attachments, id)
+		}
+	}
+	if !m.filter.uses("attach") {
+		return nil
+	}
+	if m.attachments == nil {
+		m.attachments = map[string]string{}
+	}
+	var cmds []tea.Cmd
+	hits, now := 0, time.Now()
+	for _, e := range m.allEntries {
+		if _, ok := m.attachments[e.ID]; ok || e.Queue == "corrupt" {
+			hits++
+			continue
+		}
+		if _, known := m.matches(m.filter, e, now); known {
+			// the other terms decide without the attachments
+			continue
+		}
+		m.attachments[e.ID] = "" // requested
+		m.attachPending++
+		id, host := e.ID, e.Host
+		cmd := m.pool.submit(id, prioBackground, func(ctx context.Context) tea.Msg {
+			ctx, cancel := commandContext(ctx, "postcat")
+			defer cancel()
+			out, err := runOutput(backend.show(ctx, host, id, false))
+			if err != nil {
+				return attachmentMsg{id: id, has: attachUnknown}
+			}
+			return attachmentMsg{id: id, has: attachmentsOf(string(out))}
+		})
+		cmds = append(cmds, func() tea.Msg {
+			if msg := cmd(); msg != nil {
+				return msg
+			}
+			return attachmentMsg{id: id, cancelled: true}
+		})
+	}
+	logCache("attachments", hits, len(cmds))
+	return tea.Batch(cmds...)
+}
+
+// noteAttachments records what msg tells about the attachments of a
+// message and updates the list, filtering it again now and then while the
+// fetched answers arrive.
+func (m *model) noteAttachments(msg attachmentMsg) {
+	if m.attachments == nil {
+		m.attachments = map[string]string{}
+	}
+	has, requested := m.attachments[msg.id]
+	fetched := requested && has == ""
+	if fetched {
+		m.attachPending--
+		m.attachArrived++
+	}
+	if msg.cancelled {
+		if fetched {
+			delete(m.attachments, msg.id) // fetched again when asked
+		}
+	} else {
+		m.attachments[msg.id] = msg.has
+		m.anyAttachment = m.anyAttachment || msg.has == attachYes
+	}
+	if !m.filter.uses("attach") {
+		m.syncLeft()
+		return
+	}
+	if fetched && m.attachPending > 0 && m.attachArrived < attachRefresh {
+		return
+	}
+	m.attachArrived = 0
+	m.applyFilter()
+}
+
+// attachChip is the paperclip in the list row of a message with
+// attachments, blank for the others once any message was found to have
+// some.
+func (m model) attachChip(id string) string {
+	if !m.anyAttachment {
+		return ""
+	}
+	if m.attachments[id] == attachYes {
+		return "📎 "
+	}
+	return "   "
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// With auto_refresh set, or changed in the settings (','), the queue is
+// listed again that often, as ctrl+l lists it. A listing that finds the
+// queue unchanged keeps the selection, the scroll position and the
+// details, and with stable_order a changed one keeps the selected message.
+// Nothing is listed while a dialog or a bulk operation is open, and a
+// listing less than half the interval old, after an action, counts as the
+// refresh.
+
+// autoRefreshValues are the intervals the settings offer.
+var autoRefreshValues = []string{"off", "10s", "30s", "1m", "5m"}
+
+// autoRefreshMsg lists the queue if seq is still the current interval.
+type autoRefreshMsg struct {
+	seq int
+}
+
+// autoRefreshTick schedules the next refresh, nil while off.
+func (m model) autoRefreshTick() tea.Cmd {
+	if m.cfg.AutoRefresh <= 0 {
+		return nil
+	}
+	seq := m.autoRefreshSeq
+	return tea.Tick(m.cfg.AutoRefresh, func(time.Time) tea.Msg { return autoRefreshMsg{seq} })
+}
+
+// restartAutoRefresh drops the refresh scheduled and schedules one with
+// the interval now set.
+func (m *model) restartAutoRefresh() tea.Cmd {
+	m.autoRefreshSeq++
+	return m.autoRefreshTick()
+}
+
+// autoRefresh lists the queue for msg unless the session is busy, and
+// schedules the next refresh.
+func (m *model) autoRefresh(msg autoRefreshMsg) tea.Cmd {
+	if msg.seq != m.autoRefreshSeq {
+		return nil
+	}
+	next := m.autoRefreshTick()
+	if m.showWarning || m.bulk != nil || m.popupView() != "" || time.Since(m.listedAt) < m.cfg.AutoRefresh/2 {
+		return next
+	}
+	return tea.Batch(runMailqCmd, next)
+}
+
+// formatInterval writes an auto_refresh interval the way the settings
+// offer them: "off", "30s", "5m", "1h30m".
+func formatInterval(d time.Duration) string {
+	if d <= 0 {
+		return "off"
+	}
+	s := d.Round(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
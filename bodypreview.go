@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"mime"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The preview column shows the first line of a message's text, like mutt's
+// index, or its subject when the body is not plain text. Only the rows in
+// view are read, through the worker pool at background priority, and a
+// message whose details were shown gives its preview without another
+// postcat. body_preview = false turns the column off for sites where
+// message content must not be on the screen.
+
+// previewMsg delivers the preview of a message; cancelled when its job
+// was dropped from the pool.
+type previewMsg struct {
+	id, text  string
+	cancelled bool
+}
+
+// messagePreview returns the first non-empty line of the text body of a
+// postcat (or exim -Mvc) output, or the decoded subject when the body is
+// encoded, multipart or empty.
+func messagePreview(out string) string {
+	_, body, ok := splitMessage(out)
+	if !ok {
+		_, body, _ = strings.Cut(out, "\n\n")
+	}
+	h := messageHeaders(out)
+	subject := decodeHeader(h.Get("Subject"))
+	if t := h.Get("Content-Type"); t != "" {
+		if mediaType, _, err := mime.ParseMediaType(t); err != nil || !strings.HasPrefix(mediaType, "text/") {
+			return subject
+		}
+	}
+	if strings.EqualFold(strings.TrimSpace(h.Get("Content-Transfer-Encoding")), "base64") {
+		return subject
+	}
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "="))
+		if line == "" {
+			continue
+		}
+		return strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return ' '
+			}
+			return r
+		}, line)
+	}
+	return subject
+}
+
+// prunePreviews drops the previews of messages that left the queue, on a
+// new listing.
+func (m *model) prunePreviews() {
+	if len(m.previews) == 0 {
+		return
+	}
+	listed := map[string]bool{}
+	for _, e := range m.allEntries {
+		listed[e.ID] = true
+	}
+	for id := range m.previews {
+		if !listed[id] {
+			delete(m.previews, id)
+		}
+	}
+}
+
+// fetchPreviews reads the previews of the rows in view not known yet.
+func (m *model) fetchPreviews() tea.Cmd {
+	if !m.showsColumn("preview") {
+		return nil
+	}
+	if m.previews == nil {
+		m.previews = map[string]string{}
+	}
+	var cmds []tea.Cmd
+	first := min(m.left.YOffset, len(m.entries))
+	last := min(first+m.left.Height, len(m.entries))
+	for _, e := range m.entries[first:last] {
+		if _, ok := m.previews[e.ID]; ok || e.Queue == "corrupt" {
+			continue
+		}
+		m.previews[e.ID] = "" // requested
+		id, host := e.ID, e.Host
+		cmd := m.pool.submit(id, prioBackground, func(ctx context.Context) tea.Msg {
+			ctx, cancel := commandContext(ctx, "postcat")
+			defer cancel()
+			out, err := runOutput(backend.show(ctx, host, id, false))
+			if ctx.Err() == context.Canceled {
+				return previewMsg{id: id, cancelled: true}
+			}
+			if err != nil {
+				return previewMsg{id: id, text: "?"}
+			}
+			return previewMsg{id: id, text: messagePreview(string(out))}
+		})
+		cmds = append(cmds, func() tea.Msg {
+			if msg := cmd(); msg != nil {
+				return msg
+			}
+			return previewMsg{id: id, cancelled: true}
+		})
+	}
+	logCache("previews", last-first-len(cmds), len(cmds))
+	return tea.Batch(cmds...)
+}
+
+// notePreview keeps a preview read in the background.
+func (m *model) notePreview(msg previewMsg) {
+	if _, ok := m.previews[msg.id]; !ok {
+		return // left the queue meanwhile
+	}
+	if msg.cancelled {
+		// read again when it comes into view
+		delete(m.previews, msg.id)
+		return
+	}
+	m.previews[msg.id] = msg.text
+	m.syncLeft()
+}
+
+// previewColumn is the preview column for id.
+func (m model) previewColumn(id string) string {
+	text, ok := m.previews[id]
+	if ok && text == "" {
+		text = "…"
+	}
+	return text
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Bookmarks ('m') flag the few entries being worked on in a long list so
+// ']' and '[' can jump between them. They are kept by queue ID like marks
+// and dropped when the message leaves the queue.
+
+// bookmarkChar flags a bookmarked entry in the list.
+const bookmarkChar = "◆"
+
+// toggleBookmark sets or clears the bookmark of the selected entry.
+func (m *model) toggleBookmark() {
+	id := m.entries[m.selected].ID
+	if m.bookmarks[id] {
+		delete(m.bookmarks, id)
+		m.status = "bookmark removed from " + id
+	} else {
+		if m.bookmarks == nil {
+			m.bookmarks = map[string]bool{}
+		}
+		m.bookmarks[id] = true
+		m.status = "bookmarked " + id
+	}
+	m.syncLeft()
+}
+
+// bookmarkColumn is the list column showing the bookmark of id; empty while
+// there are no bookmarks so the list keeps its width.
+func (m model) bookmarkColumn(id string) string {
+	switch {
+	case len(m.bookmarks) == 0:
+		return ""
+	case m.bookmarks[id]:
+		return bookmarkChar
+	}
+	return " "
+}
+
+// jumpBookmark selects the next (dir 1) or previous (dir -1) bookmarked
+// entry in the list, wrapping around at the ends.
+func (m *model) jumpBookmark(dir int) tea.Cmd {
+	n := len(m.entries)
+	if len(m.bookmarks) == 0 || n == 0 {
+		m.status = "no bookmarks, 'm' sets one"
+		return nil
+	}
+	for step := 1; step <= n; step++ {
+		i := ((m.selected+dir*step)%n + n) % n
+		if m.bookmarks[m.entries[i].ID] {
+			m.recordJump()
+			m.selected = i
+			m.syncLeft()
+			m.status = fmt.Sprintf("bookmark %s", m.entries[i].ID)
+			return m.runPostcatCmd(m.entries[i].ID)
+		}
+	}
+	m.status = "no bookmarked message is shown"
+	return nil
+}
+
+// pruneBookmarks drops the bookmarks of messages that are no longer in the
+// queue.
+func (m *model) pruneBookmarks() {
+	present := make(map[string]bool, len(m.allEntries))
+	for _, e := range m.allEntries {
+		present[e.ID] = true
+	}
+	for id := range m.bookmarks {
+		if !present[id] {
+			delete(m.bookmarks, id)
+		}
+	}
+}
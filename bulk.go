@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bulkProgressMsg reports how many IDs of a bulk operation are processed.
+type bulkProgressMsg struct {
+	done, total    int
+	batch, batches int
+	failedBatches  int
+	pacing         bulkConfig
+}
+
+// bulkDoneMsg is sent once a bulk operation has finished or was cancelled.
+type bulkDoneMsg struct {
+	result bulkResult
+}
+
+// bulkResult summarizes a bulk operation.
+type bulkResult struct {
+	action   action
+	total    int      // IDs submitted
+	affected int      // sum of the counts postsuper reported
+	summary  []string // postsuper summary lines, e.g. "Requeued: 12 messages"
+	failures []error  // one entry per failed batch
+	err      error    // set when the operation was cancelled
+
+	failedIDs int // IDs of failed batches not handled, or not reached
+	protected int // IDs left out as protected, see protect.go
+	maildrop  int // IDs left out for waiting in maildrop, see maildrop.go
+}
+
+// String renders the result as a single status line.
+func (r bulkResult) String() string {
+	s := fmt.Sprintf("%s: %d of %d messages", r.action, r.affected, r.total)
+	if len(r.summary) > 0 {
+		s = strings.Join(r.summary, ", ")
+	}
+	if len(r.failures) > 0 {
+		s += fmt.Sprintf(" (%d batches failed, first: %v)", len(r.failures), firstLine(r.failures[0].Error()))
+	}
+	if n := r.missing(); n > 0 {
+		if r.action == actionDelete {
+			s += fmt.Sprintf(", %d already gone", n)
+		} else {
+			s += fmt.Sprintf(", %d unchanged (gone, or already where they were to go)", n)
+		}
+	}
+	if r.protected > 0 {
+		s += fmt.Sprintf(", %d protected left out", r.protected)
+	}
+	if r.maildrop > 0 {
+		s += fmt.Sprintf(", %d in maildrop left out (waiting for pickup)", r.maildrop)
+	}
+	if r.err != nil {
+		s += " (" + r.err.Error() + ")"
+	}
+	return s
+}
+
+// failed reports whether anything went wrong.
+func (r bulkResult) failed() bool {
+	return r.err != nil || len(r.failures) > 0
+}
+
+// missing is the number of IDs neither handled nor failed: the messages
+// that were no longer in the queue, most likely delivered meanwhile.
+func (r bulkResult) missing() int {
+	return max(r.total-r.affected-r.failedIDs, 0)
+}
+
+// bulkOp is a running bulk operation as seen by the TUI.
+type bulkOp struct {
+	action  action
+	ids     []string
+	done    int
+	total   int
+	updates chan tea.Msg
+	cancel  context.CancelFunc
+}
+
+// postsuperFlags maps the per-entry actions to their postsuper switch.
+var postsuperFlags = map[action]string{
+	actionDelete:  "-d",
+	actionHold:    "-h",
+	actionRelease: "-H",
+	actionRequeue: "-r",
+	actionExpire:  "-e",
+}
+
+// postsuperSummary matches the final line postsuper prints, e.g.
+// "postsuper: Requeued: 12 messages".
+var postsuperSummary = regexp.MustCompile(`(Deleted|Requeued|Placed on hold|Released from hold|Expired): (\d+) messages?`)
+
+// runBulk applies a to the ids on host ("" for this machine) by feeding them to "postsuper <flag> -"
+// (or what the mail server has instead, see mta.go) in batches of pacing.BatchSize, sleeping pacing.Pause between two batches.
+// A failing batch is recorded and the remaining ones still run; only
+// cancelling ctx stops the operation early. progress is called after every
+// batch.
+func runBulk(ctx context.Context, host string, a action, ids []string, pacing bulkConfig, progress func(bulkProgressMsg)) bulkResult {
+	res := bulkResult{action: a, total: len(ids)}
+	pacing = pacing.forAction(a)
+	if _, ok := postsuperFlags[a]; !ok || !backend.supports(a) {
+		res.err = fmt.Errorf("%s cannot be run in bulk", a)
+		res.failedIDs = len(ids)
+		return res
+	}
+	size := pacing.BatchSize
+	if size < 1 {
+		size = len(ids)
+	}
+	batches := (len(ids) + size - 1) / size
+
+	counts := map[string]int{}
+	var order []string
+	for batch, start := 0, 0; start < len(ids); batch, start = batch+1, start+size {
+		if batch > 0 && pacing.Pause > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(pacing.Pause):
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			res.err = fmt.Errorf("cancelled after %d of %d messages", start, len(ids))
+			res.failedIDs += len(ids) - start
+			break
+		}
+		end := start + size
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		bctx, cancel := commandContext(ctx, "postsuper")
+		cmd := backend.bulk(bctx, host, a, ids[start:end])
+		out, err := runCombinedOutput(cmd)
+		if errors.Is(bctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%w after %s", ErrTimeout, currentSettings().timeouts.Commands)
+		}
+		cancel()
+		batchCounts := backend.count(out, a, ids[start:end])
+		handled := 0
+		for _, verb := range slices.Sorted(maps.Keys(batchCounts)) {
+			if _, seen := counts[verb]; !seen {
+				order = append(order, verb)
+			}
+			counts[verb] += batchCounts[verb]
+			res.affected += batchCounts[verb]
+			handled += batchCounts[verb]
+		}
+		if err != nil && !errors.Is(err, ErrTimeout) && classify(err, string(out)) == ErrNotFound {
+			// some IDs were gone; they count as missing, not as failed
+			err = nil
+		}
+		if err != nil {
+			res.failedIDs += max(end-start-handled, 0)
+			res.failures = append(res.failures, fmt.Errorf("batch %d (IDs %d-%d): %s: %w\nOutput:\n%s",
+				batch+1, start+1, end, a, err, strings.TrimSpace(string(out))))
+		}
+		if progress != nil {
+			progress(bulkProgressMsg{
+				done: end, total: len(ids),
+				batch: batch + 1, batches: batches,
+				failedBatches: len(res.failures),
+				pacing:        pacing,
+			})
+		}
+	}
+
+	for _, verb := range order {
+		res.summary = append(res.summary, fmt.Sprintf("%s: %d messages", verb, counts[verb]))
+	}
+	return res
+}
+
+// String renders the progress for the status line, including the pacing.
+func (p bulkProgressMsg) String() string {
+	s := fmt.Sprintf("%d/%d messages (%d%%), batch %d/%d of %d",
+		p.done, p.total, 100*p.done/maxInt(p.total, 1), p.batch, p.batches, p.pacing.BatchSize)
+	if p.pacing.Pause > 0 {
+		s += fmt.Sprintf(", %s pause", p.pacing.Pause)
+	}
+	if p.pacing.RequeueRate > 0 {
+		s += fmt.Sprintf(", at most %d/s", p.pacing.RequeueRate)
+	}
+	if p.failedBatches > 0 {
+		s += fmt.Sprintf(", %d failed", p.failedBatches)
+	}
+	return s + " — 'x' to cancel"
+}
+
+// forAction is the pacing of a bulk a: requeues are limited to
+// RequeueRate messages a second by batches of at most that many and a
+// pause long enough after each, so that a mail server that just came back
+// is not hit by all of them at once. RequeueRate is cleared for the other
+// actions.
+func (b bulkConfig) forAction(a action) bulkConfig {
+	if a != actionRequeue || b.RequeueRate <= 0 {
+		b.RequeueRate = 0
+		return b
+	}
+	b.BatchSize = max(min(b.BatchSize, b.RequeueRate), 1)
+	b.Pause = max(b.Pause, time.Duration(b.BatchSize)*time.Second/time.Duration(b.RequeueRate))
+	return b
+}
+
+// firstLine returns s up to the first newline.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// bulkTargets returns all entries, or only the deferred ones.
+func bulkTargets(entries []queueEntry, deferredOnly bool) []queueEntry {
+	var targets []queueEntry
+	for _, e := range entries {
+		if deferredOnly && e.Queue != "deferred" {
+			continue
+		}
+		targets = append(targets, e)
+	}
+	return targets
+}
+
+// bulkPlan is what a bulk action does with the messages it was given: the
+// entries postsuper runs on, each on its own host, and those it handles
+// apart or leaves out. startBulk runs it, the preview shows it and
+// -dry-run prints it.
+type bulkPlan struct {
+	action    action
+	targets   []queueEntry // run in batches, see runBulkHosts
+	maildrop  []queueEntry // deleted one at a time, see deleteMaildrops
+	leftOut   []queueEntry // in maildrop, where action cannot run
+	protected []queueEntry // left out of a delete, see protect.go
+}
+
+// planBulk sorts the messages of a bulk a: protected ones (matching
+// filters) are left out of a delete, messages in maildrop out of every
+// other action.
+func planBulk(a action, entries []queueEntry, filters []listFilter, env filterEnv) bulkPlan {
+	p := bulkPlan{action: a}
+	for _, e := range entries {
+		switch {
+		case e.Queue == "maildrop" && a != actionDelete:
+			p.leftOut = append(p.leftOut, e)
+		case a == actionDelete && protectedBy(filters, env, e) != "":
+			p.protected = append(p.protected, e)
+		case e.Queue == "maildrop":
+			// postsuper - does not look there
+			p.maildrop = append(p.maildrop, e)
+		default:
+			p.targets = append(p.targets, e)
+		}
+	}
+	return p
+}
+
+// planBulk is the plan of a bulk a on targets in this session.
+func (m model) planBulk(a action, targets []queueEntry) bulkPlan {
+	filters, _ := parseProtect(m.cfg.Protect) // validated with the config
+	return planBulk(a, targets, filters, m.protectEnv())
+}
+
+// acted are the messages the plan acts on.
+func (p bulkPlan) acted() []queueEntry {
+	return append(slices.Clip(p.targets), p.maildrop...)
+}
+
+// queueIDs are the queue IDs of entries.
+func queueIDs(entries []queueEntry) []string {
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+// nothingToDo says why the plan acts on no message, "" if it acts on some.
+func (p bulkPlan) nothingToDo() string {
+	switch {
+	case len(p.targets)+len(p.maildrop) > 0:
+		return ""
+	case len(p.protected) > 0:
+		return fmt.Sprintf("%s: nothing to do, all %d messages are protected", p.action, len(p.protected))
+	case len(p.leftOut) > 0:
+		return fmt.Sprintf("%s: nothing to do, all %d messages are in maildrop waiting for pickup", p.action, len(p.leftOut))
+	}
+	return fmt.Sprintf("%s: nothing to do", p.action)
+}
+
+// run carries out the plan, see runBulkHosts.
+func (p bulkPlan) run(ctx context.Context, pacing bulkConfig, progress func(bulkProgressMsg)) bulkResult {
+	hosts, groups := hostGroups(p.targets)
+	res := runBulkHosts(ctx, p.action, hosts, groups, pacing, progress)
+	if len(p.maildrop) > 0 && res.err == nil {
+		deleteMaildrops(ctx, &res, p.maildrop)
+	}
+	res.protected, res.maildrop = len(p.protected), len(p.leftOut)
+	return res
+}
+
+// startBulk runs a bulk operation on targets in the background and returns
+// the command that delivers its first update. Each entry is acted on on
+// its own host.
+func (m *model) startBulk(a action, targets []queueEntry) tea.Cmd {
+	plan := m.planBulk(a, targets)
+	if reason := plan.nothingToDo(); reason != "" {
+		m.status = reason
+		return nil
+	}
+	ids := queueIDs(plan.acted())
+	if len(plan.protected) > 0 {
+		m.status = fmt.Sprintf("delete: 0/%d messages, %d protected left out", len(ids), len(plan.protected))
+		logger.Info("protected messages left out", "messages", len(plan.protected), "ids", strings.Join(queueIDs(plan.protected), " "))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	op := &bulkOp{
+		action:  a,
+		ids:     ids,
+		total:   len(ids),
+		updates: make(chan tea.Msg, 1),
+		cancel:  cancel,
+	}
+	m.bulk = op
+	if a == actionDelete {
+		m.captureDeletes(plan.acted()...)
+	}
+	if m.filter.active() {
+		// which query picked the messages, for the log file
+		logger.Info("bulk operation started", "action", a, "messages", len(ids), "filter", m.filter.expr)
+	} else {
+		logger.Info("bulk operation started", "action", a, "messages", len(ids))
+	}
+
+	go func() {
+		res := plan.run(ctx, m.cfg.Bulk, func(p bulkProgressMsg) {
+			select {
+			case op.updates <- p:
+			default:
+				// the UI still has an older update pending; skip this one
+			}
+		})
+		op.updates <- bulkDoneMsg{result: res}
+		close(op.updates)
+	}()
+	return waitForBulk(op.updates)
+}
+
+// waitForBulk returns the next update of a running bulk operation.
+func waitForBulk(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-updates
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// Scopes of the requeue dialog, switched with tab.
+const (
+	requeueDeferred = iota // the deferred queue
+	requeueAll             // every queue
+	requeueShown           // the shown messages, with a filter or hidden queues
+)
+
+// openRequeueDialog shows the typed-"yes" confirmation for requeueing the
+// whole queue, or the shown part of it.
+func (m *model) openRequeueDialog() {
+	if m.bulk != nil {
+		m.status = "a bulk operation is already running"
+		return
+	}
+	m.requeueInput = textinput.New()
+	m.requeueInput.Placeholder = "yes"
+	m.requeueInput.CharLimit = 3
+	m.requeueInput.Focus()
+	m.requeueScope = requeueDeferred
+	m.dialog = &confirmDialog{
+		question: model.requeueQuestion,
+		width:    44,
+		options: []dialogOption{
+			{keys: []string{"esc", "ctrl+c"}},
+			{keys: []string{"tab"}, stay: true, run: func(m *model) tea.Cmd {
+				scopes := requeueShown
+				if len(m.visibleEntries()) != len(m.allEntries) || m.filter.active() {
+					scopes++
+				}
+				m.requeueScope = (m.requeueScope + 1) % scopes
+				return nil
+			}},
+		},
+		typed: (*model).typeRequeue,
+	}
+}
+
+// requeueTargets are the entries of the scope chosen in the requeue dialog.
+func (m model) requeueTargets() []queueEntry {
+	if m.requeueScope == requeueShown {
+		return bulkTargets(m.visibleEntries(), false)
+	}
+	return bulkTargets(m.allEntries, m.requeueScope == requeueDeferred)
+}
+
+// typeRequeue types into the "yes" of the requeue dialog; enter after it
+// starts the requeue.
+func (m *model) typeRequeue(msg tea.KeyMsg) tea.Cmd {
+	if msg.String() != "enter" {
+		var cmd tea.Cmd
+		m.requeueInput, cmd = m.requeueInput.Update(msg)
+		return cmd
+	}
+	if strings.ToLower(m.requeueInput.Value()) != "yes" {
+		return nil
+	}
+	m.dialog = nil
+	targets := m.requeueTargets()
+	if len(targets) == 0 {
+		m.status = "requeue: nothing to do"
+		return nil
+	}
+	if m.requeueScope == requeueShown && m.filter.active() {
+		logger.Info("requeue by filter", "filter", m.filter.expr, "messages", len(targets))
+	}
+	m.status = fmt.Sprintf("requeue: 0/%d messages", len(targets))
+	return m.startBulk(actionRequeue, targets)
+}
+
+// requeueQuestion is the question of the requeue dialog.
+func (m model) requeueQuestion() string {
+	scope := "deferred queue only"
+	switch m.requeueScope {
+	case requeueAll:
+		scope = "all queues"
+	case requeueShown:
+		scope = "the shown messages: " + m.shownScope()
+	}
+	rate := ""
+	if r := m.cfg.Bulk.RequeueRate; r > 0 {
+		rate = fmt.Sprintf("\npaced: at most %d messages a second", r)
+	}
+	return fmt.Sprintf("Requeue %d messages\nscope: %s ([TAB] to change)%s\n\nType yes to confirm, esc to cancel:\n%s",
+		len(m.requeueTargets()), scope, rate, m.requeueInput.View())
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// With -check postdel is a monitoring plugin: it lists the queue once,
+// compares it with the thresholds and prints one line in the format of
+// Nagios plugins (also read by Icinga, Naemon, Zabbix' agent, ...), with
+// the exit status telling the state.
+
+// Exit statuses of a monitoring plugin.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+// checkStates are the names of the exit statuses, for the output.
+var checkStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// checkThresholds are the limits of -check; 0 disables one.
+type checkThresholds struct {
+	warnCount, critCount int
+	warnAge              time.Duration
+}
+
+// runCheck lists the queue, prints the status line and returns the exit
+// status.
+func runCheck(t checkThresholds) int {
+	entries, err := listQueue()
+	if err != nil {
+		// also when only some hosts failed: the counts would be too low
+		printCheckUnknown("cannot list the queue: " + err.Error())
+		return checkUnknown
+	}
+	line, state := checkStatus(entries, t, time.Now())
+	fmt.Println(line)
+	return state
+}
+
+// printCheckUnknown prints the status line of a check that could not tell,
+// why on one line.
+func printCheckUnknown(why string) {
+	fmt.Printf("MAILQ UNKNOWN - %s\n", strings.ReplaceAll(strings.TrimSpace(why), "\n", " "))
+}
+
+// fatal ends postdel on an error before it got to work: on stderr with the
+// exit status code, or with -check as the UNKNOWN status line, which is
+// what a monitoring system reads, and its exit status.
+func fatal(check bool, code int, a ...any) {
+	why := strings.TrimSuffix(fmt.Sprintln(a...), "\n")
+	if check {
+		printCheckUnknown(why)
+		os.Exit(checkUnknown)
+	}
+	fmt.Fprintln(os.Stderr, "Error:", why)
+	os.Exit(code)
+}
+
+// checkRequested tells whether args ask for -check, for when they cannot
+// be parsed.
+func checkRequested(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name, value, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "check" && value != "false" && value != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStatus evaluates entries against t and renders the status line:
+// the state, what caused it, and the counts as performance data.
+func checkStatus(entries []queueEntry, t checkThresholds, now time.Time) (string, int) {
+	perQueue := map[string]int{}
+	var oldest time.Time
+	for _, e := range entries {
+		perQueue[e.Queue]++
+		if !e.Arrival.IsZero() && (oldest.IsZero() || e.Arrival.Before(oldest)) {
+			oldest = e.Arrival
+		}
+	}
+	var age time.Duration
+	if !oldest.IsZero() {
+		age = now.Sub(oldest)
+	}
+
+	state := checkOK
+	var problems []string
+	raise := func(to int, problem string) {
+		state = maxInt(state, to)
+		problems = append(problems, problem)
+	}
+	switch n := len(entries); {
+	case t.critCount > 0 && n >= t.critCount:
+		raise(checkCritical, fmt.Sprintf("%d messages (critical at %d)", n, t.critCount))
+	case t.warnCount > 0 && n >= t.warnCount:
+		raise(checkWarning, fmt.Sprintf("%d messages (warning at %d)", n, t.warnCount))
+	}
+	if t.warnAge > 0 && age >= t.warnAge {
+		raise(checkWarning, fmt.Sprintf("oldest message %s old (warning at %s)", formatAge(age), formatAge(t.warnAge)))
+	}
+
+	summary := fmt.Sprintf("%d messages", len(entries))
+	if len(entries) > 0 && age > 0 {
+		summary += fmt.Sprintf(", oldest %s old", formatAge(age))
+	}
+	if len(problems) > 0 {
+		summary = strings.Join(problems, ", ")
+	}
+
+	// performance data: label=value[unit];warn;crit;min;max
+	threshold := func(n int) string {
+		if n <= 0 {
+			return ""
+		}
+		return fmt.Sprint(n)
+	}
+	perf := []string{fmt.Sprintf("messages=%d;%s;%s;0;", len(entries), threshold(t.warnCount), threshold(t.critCount))}
+	warnAge := ""
+	if t.warnAge > 0 {
+		warnAge = fmt.Sprint(int(t.warnAge.Seconds()))
+	}
+	perf = append(perf, fmt.Sprintf("oldest=%ds;%s;;0;", int(age.Seconds()), warnAge))
+	for _, q := range queueNames {
+		perf = append(perf, fmt.Sprintf("%s=%d;;;0;", q, perQueue[q]))
+	}
+	return fmt.Sprintf("MAILQ %s - %s | %s", checkStates[state], summary, strings.Join(perf, " ")), state
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// [classify] command names a spam classifier, e.g. spamc -c or rspamc,
+// that gets a message on stdin (the headers and the body, as 'E' saves them
+// but without the X-Postdel headers) and prints its verdict. score_pattern
+// finds the score in the output, its first group; the line holding it is
+// shown as the verdict. A non-zero exit is not a failure as long as a score
+// is found, spamc -c exits 1 for spam. 'K' classifies the selected message,
+// or the selected ones; the score column classifies the rows in view in
+// the background, through the worker pool like the preview column. The
+// scores are kept by queue ID for the session, and score:>=5 in the '/'
+// prompt, -sort -score and 'o' work with them, so a spam run above a
+// threshold is selected and deleted like any filtered list: while they
+// do, every listed message without a score is classified in the
+// background, at most classifyJobs at a time, and the list is filtered
+// and sorted again every classifyRefresh verdicts and after the last.
+
+// classifyConfig is the [classify] section.
+type classifyConfig struct {
+	Command      []string      `toml:"command"`       // empty disables 'K' and the score column
+	ScorePattern string        `toml:"score_pattern"` // regexp, its first group is the score
+	Timeout      time.Duration `toml:"timeout"`
+}
+
+// defaultScorePattern takes the first number of the output, which is the
+// score for spamc -c ("7.3/5.0"); rspamc needs `Score: (-?[\d.]+)`.
+const defaultScorePattern = `(-?\d+(?:\.\d+)?)`
+
+// enabled reports whether a classifier is configured.
+func (c classifyConfig) enabled() bool {
+	return len(c.Command) > 0
+}
+
+// validate checks the [classify] section.
+func (c classifyConfig) validate() error {
+	re, err := regexp.Compile(c.ScorePattern)
+	if err != nil {
+		return fmt.Errorf("classify.score_pattern: %v", err)
+	}
+	if re.NumSubexp() < 1 {
+		return fmt.Errorf("classify.score_pattern needs a group around the score")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("classify.timeout must be positive")
+	}
+	return nil
+}
+
+// classification is the verdict on a message.
+type classification struct {
+	pending bool
+	score   float64
+	line    string // the output line holding the score
+	err     string // why there is no score
+}
+
+// classifyMsg delivers the verdict on a message; report puts it into the
+// status line, for 'K'. cancelled when its job was dropped from the pool.
+type classifyMsg struct {
+	id        string
+	c         classification
+	report    bool
+	cancelled bool
+}
+
+// classifyJobs is how many background classifications are in the worker
+// pool at most, the others wait in a backlog.
+const classifyJobs = 16
+
+// classifyRefresh is how many verdicts arrive between two filterings of
+// the list while the filter or the sort use the scores.
+const classifyRefresh = 50
+
+// classifyEntries classifies entries through the worker pool at prio, or
+// at background priority through the backlog. Those with a verdict or one
+// coming are skipped unless again is set.
+func (m *model) classifyEntries(entries []queueEntry, prio int, again bool) tea.Cmd {
+	if m.scores == nil {
+		m.scores = map[string]classification{}
+	}
+	var cmds []tea.Cmd
+	for _, e := range entries {
+		if _, ok := m.scores[e.ID]; (ok && !again) || e.Queue == "corrupt" {
+			continue
+		}
+		m.scores[e.ID] = classification{pending: true}
+		if prio == prioBackground {
+			m.scoreBacklog = append(m.scoreBacklog, e)
+			continue
+		}
+		cmds = append(cmds, m.submitClassify(e, prio, again))
+	}
+	return tea.Batch(append(cmds, m.submitScores())...)
+}
+
+// submitClassify submits the classification of e to the pool.
+func (m *model) submitClassify(e queueEntry, prio int, report bool) tea.Cmd {
+	id, host, cfg := e.ID, e.Host, m.cfg.Classify
+	cmd := m.pool.submit(id, prio, func(ctx context.Context) tea.Msg {
+		return classifyMsg{id: id, c: classifyMessage(ctx, cfg, host, id), report: report}
+	})
+	return func() tea.Msg {
+		if msg := cmd(); msg != nil {
+			return msg
+		}
+		return classifyMsg{id: id, report: report, cancelled: true}
+	}
+}
+
+// submitScores moves classifications from the backlog to the pool, up to
+// classifyJobs at a time. Those no longer pending are dropped.
+func (m *model) submitScores() tea.Cmd {
+	var cmds []tea.Cmd
+	for m.scoreJobs < classifyJobs && len(m.scoreBacklog) > 0 {
+		e := m.scoreBacklog[0]
+		m.scoreBacklog = m.scoreBacklog[1:]
+		if !m.scores[e.ID].pending {
+			continue
+		}
+		m.scoreJobs++
+		cmds = append(cmds, m.submitClassify(e, prioBackground, false))
+	}
+	if len(m.scoreBacklog) == 0 {
+		m.scoreBacklog = nil
+	}
+	return tea.Batch(cmds...)
+}
+
+// classifyMessage reads the message id and runs the classifier on it.
+func classifyMessage(ctx context.Context, cfg classifyConfig, host, id string) classification {
+	pctx, cancel := commandContext(ctx, "postcat")
+	defer cancel()
+	out, err := runOutput(backend.show(pctx, host, id, false))
+	if err != nil {
+		return classification{err: "cannot read the message: " + firstLine(err.Error())}
+	}
+	headers, body, err := messageOf(string(out))
+	if err != nil {
+		return classification{err: err.Error()}
+	}
+	cctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(cctx, cfg.Command[0], cfg.Command[1:]...)
+	cmd.Stdin = strings.NewReader(headers + "\n" + body)
+	verdict, err := runOutput(cmd)
+	if cctx.Err() != nil {
+		return classification{err: fmt.Sprintf("%s: no answer after %s", cfg.Command[0], cfg.Timeout)}
+	}
+	score, line, ok := parseScore(regexp.MustCompile(cfg.ScorePattern), verdict)
+	if !ok {
+		var exit *exec.ExitError
+		if err != nil && !errors.As(err, &exit) {
+			return classification{err: fmt.Sprintf("%s: %v", cfg.Command[0], err)}
+		}
+		return classification{err: fmt.Sprintf("%s: no score in %q", cfg.Command[0], truncate(firstLine(string(verdict)), 40))}
+	}
+	return classification{score: score, line: line}
+}
+
+// parseScore finds the score in the classifier's output with re and
+// returns it with the line it is on.
+func parseScore(re *regexp.Regexp, out []byte) (float64, string, bool) {
+	loc := re.FindSubmatchIndex(out)
+	if loc == nil || loc[2] < 0 {
+		return 0, "", false
+	}
+	score, err := strconv.ParseFloat(string(out[loc[2]:loc[3]]), 64)
+	if err != nil {
+		return 0, "", false
+	}
+	start := bytes.LastIndexByte(out[:loc[0]], '\n') + 1
+	end := len(out)
+	if i := bytes.IndexByte(out[loc[0]:], '\n'); i >= 0 {
+		end = loc[0] + i
+	}
+	return score, strings.TrimSpace(string(out[start:end])), true
+}
+
+// classifySelected is 'K': the selected messages, or the one under the
+// cursor, are classified (again) ahead of the background work.
+func (m *model) classifySelected() tea.Cmd {
+	if !m.cfg.Classify.enabled() {
+		m.status = "no classifier configured, see [classify] command"
+		return nil
+	}
+	var targets []queueEntry
+	for _, e := range m.entries {
+		if m.marked[e.ID] {
+			targets = append(targets, e)
+		}
+	}
+	if len(targets) == 0 && m.selected < len(m.entries) {
+		targets = m.entries[m.selected : m.selected+1]
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	m.status = fmt.Sprintf("classifying %d messages…", len(targets))
+	cmd := m.classifyEntries(targets, prioPrefetch, true)
+	m.syncLeft()
+	return cmd
+}
+
+// fetchScores classifies every listed message while the filter or the
+// sort use the scores, else the rows in view while the score column is
+// shown.
+func (m *model) fetchScores() tea.Cmd {
+	if !m.cfg.Classify.enabled() {
+		return nil
+	}
+	if m.filter.uses("score") || m.sortsByScore() {
+		return m.classifyEntries(m.allEntries, prioBackground, false)
+	}
+	if !m.showsColumn("score") {
+		return nil
+	}
+	first := min(m.left.YOffset, len(m.entries))
+	last := min(first+m.left.Height, len(m.entries))
+	return m.classifyEntries(m.entries[first:last], prioBackground, false)
+}
+
+// pruneScores drops the verdicts on messages that left the queue, on a
+// new listing.
+func (m *model) pruneScores() {
+	if len(m.scores) == 0 {
+		return
+	}
+	listed := make(map[string]bool, len(m.allEntries))
+	for _, e := range m.allEntries {
+		listed[e.ID] = true
+	}
+	for id := range m.scores {
+		if !listed[id] {
+			delete(m.scores, id)
+		}
+	}
+}
+
+// noteScore keeps a verdict, submits the next from the backlog and, when
+// the filter or the sort use the scores, rebuilds the list now and then.
+func (m *model) noteScore(msg classifyMsg) tea.Cmd {
+	var next tea.Cmd
+	if !msg.report {
+		m.scoreJobs--
+		next = m.submitScores()
+	}
+	if _, ok := m.scores[msg.id]; !ok {
+		return next // left the queue meanwhile
+	}
+	if msg.cancelled {
+		// classified again when asked
+		delete(m.scores, msg.id)
+		return next
+	}
+	m.scores[msg.id] = msg.c
+	if msg.report {
+		if msg.c.err != "" {
+			m.status = msg.id + ": " + msg.c.err
+		} else {
+			m.status = fmt.Sprintf("%s: score %.1f — %s", msg.id, msg.c.score, msg.c.line)
+		}
+	}
+	if m.filter.uses("score") || m.sortsByScore() {
+		m.scoreArrived++
+		if m.scoreArrived < classifyRefresh && (m.scoreJobs > 0 || len(m.scoreBacklog) > 0) {
+			return next
+		}
+		m.scoreArrived = 0
+		m.applyFilter() // stamps the scores
+		return next
+	}
+	stampScores(m.allEntries, m.scores)
+	stampScores(m.entries, m.scores)
+	m.syncLeft()
+	return next
+}
+
+// stampScores sets the scores known of entries.
+func stampScores(entries []queueEntry, scores map[string]classification) {
+	for i := range entries {
+		c, ok := scores[entries[i].ID]
+		entries[i].Score, entries[i].scoreKnown = c.score, ok && !c.pending && c.err == ""
+	}
+}
+
+// sortsByScore reports whether the list is sorted by the scores.
+func (m model) sortsByScore() bool {
+	if m.sort.key == "score" {
+		return true
+	}
+	for _, o := range m.cfg.sortThen() {
+		if o.key == "score" {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreColumn is the score column for id.
+func (m model) scoreColumn(id string) string {
+	c, ok := m.scores[id]
+	switch {
+	case !ok:
+		return ""
+	case c.pending:
+		return "…"
+	case c.err != "":
+		return "?"
+	}
+	return strconv.FormatFloat(c.score, 'f', 1, 64)
+}
+
+// parseScoreRange parses the value of a score: term: >=5, >5, <=0, <0 or
+// a range 2..5, with decimals and negative scores.
+func parseScoreRange(s string) (func(float64) bool, error) {
+	if lo, hi, ok := strings.Cut(s, ".."); ok {
+		a, errA := strconv.ParseFloat(lo, 64)
+		b, errB := strconv.ParseFloat(hi, 64)
+		if errA != nil || errB != nil {
+			return nil, fmt.Errorf("expected a range of scores like 2..5")
+		}
+		if a > b {
+			return nil, fmt.Errorf("empty range")
+		}
+		return func(v float64) bool { return v >= a && v <= b }, nil
+	}
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if v, ok := strings.CutPrefix(s, op); ok {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid score %q", v)
+			}
+			switch op {
+			case ">=":
+				return func(v float64) bool { return v >= n }, nil
+			case "<=":
+				return func(v float64) bool { return v <= n }, nil
+			case ">":
+				return func(v float64) bool { return v > n }, nil
+			}
+			return func(v float64) bool { return v < n }, nil
+		}
+	}
+	return nil, fmt.Errorf("expected >N, <N or N..M")
+}
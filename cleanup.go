@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// 'W' walks through a bulk cleanup for those who do not know the filter
+// syntax: the criteria (sender, age, size, queue) with the number of
+// messages they match as they are typed, a sample of those messages, the
+// action and the typed "yes". The criteria are turned into a filter
+// expression, shown all the way so it can be reused with '/', and matched
+// like any filter; the action runs as a bulk operation like alt+d, with
+// its progress, protected messages and report. esc cancels at every step,
+// shift+tab goes back one.
+
+// Steps of the cleanup wizard.
+const (
+	cleanupCriteria = iota
+	cleanupReview
+	cleanupAction
+	cleanupConfirm
+)
+
+// cleanupSample is how many of the matched messages the review shows.
+const cleanupSample = 8
+
+// cleanupFields are the criteria, each the value of a filter term: the
+// sender pattern of from:, the ages and sizes of age: and size: (">=" is
+// added to a bare value) and the queue of queue:.
+var cleanupFields = []struct{ key, label, placeholder string }{
+	{"from", "sender", "spam.example or /regexp/"},
+	{"age", "older than", "2d, or a range 1d..3d"},
+	{"size", "larger than", "1M, or <10K"},
+	{"queue", "queue", strings.Join(queueNames, ", ")},
+}
+
+// cleanupActions are the actions the wizard offers.
+var cleanupActions = []action{actionDelete, actionHold, actionExpire}
+
+// cleanupWizard is the state of 'W'.
+type cleanupWizard struct {
+	step   int
+	inputs []textinput.Model // one per cleanupFields
+	field  int               // the input with the focus
+
+	expr string   // the filter of the criteria
+	err  string   // why expr does not parse
+	ids  []string // the messages expr matches, in queue order
+
+	action  int             // index into cleanupActions
+	confirm textinput.Model // the typed "yes"
+}
+
+// openCleanup starts the cleanup wizard.
+func (m *model) openCleanup() {
+	if m.refuseReadOnly() {
+		return
+	}
+	if m.bulk != nil {
+		m.status = "a bulk operation is already running"
+		return
+	}
+	w := &cleanupWizard{}
+	for _, f := range cleanupFields {
+		in := textinput.New()
+		in.Prompt = fmt.Sprintf("%-12s ", f.label+":")
+		in.Placeholder = f.placeholder
+		w.inputs = append(w.inputs, in)
+	}
+	w.inputs[0].Focus()
+	m.cleanup = w
+	m.dialog = &confirmDialog{
+		question: model.cleanupView,
+		width:    64,
+		options: []dialogOption{
+			{keys: []string{"esc", "ctrl+c"}, run: func(m *model) tea.Cmd {
+				m.cleanup = nil
+				m.status = "cleanup cancelled"
+				return nil
+			}},
+			{keys: []string{"shift+tab"}, stay: true, run: func(m *model) tea.Cmd {
+				if m.cleanup.step > cleanupCriteria {
+					m.cleanup.step--
+				}
+				return nil
+			}},
+		},
+		typed: (*model).typeCleanup,
+	}
+	m.matchCleanup()
+}
+
+// cleanupExpr turns the criteria into a filter expression.
+func cleanupExpr(values []string) (string, error) {
+	var terms []string
+	for i, f := range cleanupFields {
+		v := strings.TrimSpace(values[i])
+		if v == "" {
+			continue
+		}
+		if strings.ContainsAny(v, " \t()\"") {
+			// quoted, the term would be a word to search for
+			return "", fmt.Errorf("%s: no blanks, quotes or parentheses", f.label)
+		}
+		if (f.key == "age" || f.key == "size") && !strings.ContainsAny(v[:1], "<>") && !strings.Contains(v, "..") {
+			v = ">=" + v
+		}
+		terms = append(terms, f.key+":"+v)
+	}
+	return strings.Join(terms, " "), nil
+}
+
+// matchCleanup builds the expression of the criteria and collects the
+// messages it matches, from all of the listing.
+func (m *model) matchCleanup() {
+	w := m.cleanup
+	values := make([]string, len(w.inputs))
+	for i, in := range w.inputs {
+		values[i] = in.Value()
+	}
+	w.err, w.ids = "", nil
+	var err error
+	if w.expr, err = cleanupExpr(values); err != nil {
+		w.err = err.Error()
+		return
+	}
+	if w.expr == "" {
+		return
+	}
+	f, _, err := parseFilterInput(w.expr)
+	if err != nil {
+		w.err = err.Error()
+		return
+	}
+	if f.uses("retry") {
+		m.wantRetryTimes()
+	}
+	now := time.Now()
+	for _, e := range m.allEntries {
+		if ok, _ := m.matches(f, e, now); ok {
+			w.ids = append(w.ids, e.ID)
+		}
+	}
+}
+
+// typeCleanup handles the keys of the wizard's dialog other than esc and
+// shift+tab: the criteria, the choice of the action and the typed "yes".
+func (m *model) typeCleanup(msg tea.KeyMsg) tea.Cmd {
+	w := m.cleanup
+	switch w.step {
+	case cleanupCriteria:
+		switch msg.String() {
+		case "enter":
+			switch {
+			case w.expr == "":
+				m.status = "give at least one criterion"
+			case w.err != "":
+				m.status = w.err
+			case len(w.ids) == 0:
+				m.status = "the criteria match no message"
+			default:
+				w.step = cleanupReview
+			}
+			return nil
+		case "tab", "down", "up":
+			w.inputs[w.field].Blur()
+			if msg.String() == "up" {
+				w.field = (w.field + len(w.inputs) - 1) % len(w.inputs)
+			} else {
+				w.field = (w.field + 1) % len(w.inputs)
+			}
+			return w.inputs[w.field].Focus()
+		}
+		var cmd tea.Cmd
+		w.inputs[w.field], cmd = w.inputs[w.field].Update(msg)
+		m.matchCleanup()
+		return tea.Batch(cmd, m.fetchRetryTimes())
+	case cleanupReview:
+		if msg.String() == "enter" {
+			w.step = cleanupAction
+		}
+		return nil
+	case cleanupAction:
+		switch msg.String() {
+		case "left", "up":
+			w.action = (w.action + len(cleanupActions) - 1) % len(cleanupActions)
+		case "right", "down", "tab":
+			w.action = (w.action + 1) % len(cleanupActions)
+		case "enter":
+			if a := cleanupActions[w.action]; !backend.supports(a) {
+				m.status = unsupported(a)
+				return nil
+			}
+			w.step = cleanupConfirm
+			w.confirm = textinput.New()
+			w.confirm.Placeholder = "yes"
+			w.confirm.CharLimit = 3
+			return w.confirm.Focus()
+		}
+		return nil
+	}
+	if msg.String() == "enter" {
+		if strings.ToLower(w.confirm.Value()) != "yes" {
+			return nil
+		}
+		return m.runCleanup()
+	}
+	var cmd tea.Cmd
+	w.confirm, cmd = w.confirm.Update(msg)
+	return cmd
+}
+
+// runCleanup closes the wizard and starts the bulk operation on the
+// messages matched, with -trash a delete into the trash as with alt+d.
+func (m *model) runCleanup() tea.Cmd {
+	w := m.cleanup
+	m.cleanup, m.dialog = nil, nil
+	if m.bulk != nil {
+		m.status = "a bulk operation is already running"
+		return nil
+	}
+	a := cleanupActions[w.action]
+	if a == actionDelete && m.cfg.Trash {
+		a = actionHold
+		m.addToTrash(w.ids...)
+	}
+	logger.Info("cleanup", "action", a, "filter", w.expr, "messages", len(w.ids))
+	m.status = fmt.Sprintf("%s: 0/%d messages", a, len(w.ids))
+	return m.startBulk(a, listedTargets(m.allEntries, w.ids))
+}
+
+// cleanupView is the text of the wizard's dialog.
+func (m model) cleanupView() string {
+	w := m.cleanup
+	var sb strings.Builder
+	steps := []string{"criteria", "review", "action", "confirm"}
+	for i, s := range steps {
+		if i == w.step {
+			s = "[" + s + "]"
+		}
+		steps[i] = s
+	}
+	fmt.Fprintf(&sb, "Cleanup: %s\n\n", strings.Join(steps, " › "))
+	switch w.step {
+	case cleanupCriteria:
+		for _, in := range w.inputs {
+			sb.WriteString(in.View() + "\n")
+		}
+		sb.WriteString("\n")
+		switch {
+		case w.err != "":
+			sb.WriteString(warningStyle.Render(w.err) + "\n")
+		case w.expr != "":
+			fmt.Fprintf(&sb, "matches %d of %d messages\n", len(w.ids), len(m.allEntries))
+		default:
+			sb.WriteString("fill in at least one criterion\n")
+		}
+	case cleanupReview:
+		fmt.Fprintf(&sb, "%d messages, the first of them:\n", len(w.ids))
+		entries := map[string]queueEntry{}
+		for _, e := range m.allEntries {
+			entries[e.ID] = e
+		}
+		now := time.Now()
+		for _, id := range w.ids[:min(len(w.ids), cleanupSample)] {
+			e := entries[id]
+			age := "?"
+			if !e.Arrival.IsZero() {
+				age = formatAge(now.Sub(e.Arrival))
+			}
+			fmt.Fprintf(&sb, "  %-12s %-8s %5s %8s  %s\n", id, e.Queue, age, formatSize(e.Size), truncate(e.senderLabel(), 30))
+		}
+		if len(w.ids) > cleanupSample {
+			fmt.Fprintf(&sb, "  … and %d more\n", len(w.ids)-cleanupSample)
+		}
+	case cleanupAction:
+		var choices []string
+		for i, a := range cleanupActions {
+			label := a.String()
+			if !backend.supports(a) {
+				label = disabledStyle.Render(label)
+			}
+			if i == w.action {
+				label = selectedStyle.Render("> " + label)
+			} else {
+				label = "  " + label
+			}
+			choices = append(choices, label)
+		}
+		fmt.Fprintf(&sb, "What to do with the %d messages:\n%s\n", len(w.ids), strings.Join(choices, "\n"))
+		if cleanupActions[w.action] == actionDelete && m.cfg.Trash {
+			sb.WriteString("(with -trash: put on hold into the trash)\n")
+		}
+	case cleanupConfirm:
+		fmt.Fprintf(&sb, "%s %d messages.\n\nType yes to confirm:\n%s\n", cleanupActions[w.action], len(w.ids), w.confirm.View())
+	}
+	fmt.Fprintf(&sb, "\nfilter: %s\n", w.expr)
+	switch w.step {
+	case cleanupCriteria:
+		sb.WriteString("tab/up/down field, enter next, esc cancel")
+	case cleanupConfirm:
+		sb.WriteString("enter run, shift+tab back, esc cancel")
+	default:
+		sb.WriteString("enter next, shift+tab back, esc cancel")
+	}
+	return sb.String()
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Exit codes shared by the TUI and the subcommands. exitNoPostfix (3) is
+// in postfix.go.
+const (
+	exitOK    = 0 // normal quit / command succeeded
+	exitError = 1 // fatal error (mailq missing, permission denied, …)
+	exitUsage = 2 // invalid flags or arguments
+)
+
+// Exit codes of the subcommands that change the queue, telling scripts how
+// much of the operation worked; all of it is exitOK.
+const (
+	exitPartial = 4 // some messages were handled, others failed or were skipped
+	exitFailed  = 5 // no message could be handled
+	exitNothing = 6 // no message matched, nothing was done
+	exitMissing = 7 // some or all were no longer in the queue, the others were handled
+)
+
+// exitCode tells how the bulk operation went, see exitPartial.
+func (r bulkResult) exitCode() int {
+	return outcomeExitCode(r.affected, r.missing(), r.failedIDs)
+}
+
+// outcomeExitCode tells how a subcommand went from the number of messages
+// handled, no longer in the queue and failed (or skipped).
+func outcomeExitCode(done, missing, failed int) int {
+	switch {
+	case failed > 0 && done == 0:
+		return exitFailed
+	case failed > 0:
+		return exitPartial
+	case missing > 0:
+		return exitMissing
+	case done == 0:
+		return exitNothing
+	}
+	return exitOK
+}
+
+// runCLI executes a non-interactive subcommand and returns the exit code.
+func runCLI(cfg config, flags cliFlags, args []string) int {
+	switch args[0] {
+	case "requeue-all":
+		if cfg.ReadOnly {
+			fmt.Fprintln(os.Stderr, "requeue-all: refused in read-only mode")
+			return exitError
+		}
+		return cliRequeueAll(cfg, flags, args[1:])
+	case "delete":
+		if cfg.ReadOnly {
+			fmt.Fprintln(os.Stderr, "delete: refused in read-only mode")
+			return exitError
+		}
+		return cliDelete(cfg, flags, args[1:])
+	case "list":
+		return cliList(cfg, flags, args[1:])
+	case "compare":
+		return cliCompare(args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+	return exitUsage
+}
+
+// cliRequeueAll implements "postdel requeue-all": requeue every message
+// (or every deferred one, or those of -queue) and optionally flush the
+// queue afterwards. Messages in maildrop are left out. -dry-run prints the
+// messages instead.
+func cliRequeueAll(cfg config, flags cliFlags, args []string) int {
+	fs := flag.NewFlagSet("requeue-all", flag.ContinueOnError)
+	deferredOnly := fs.Bool("deferred", false, "only requeue messages in the deferred queue")
+	queue := fs.String("queue", flags.queue, "only requeue messages in the queues in `list`, e.g. hold or deferred,hold")
+	flush := fs.Bool("flush", false, "flush the queue afterwards")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	dryRun := fs.Bool("dry-run", false, "print the messages it would requeue, and do nothing")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	hidden, ok := cliQueueView(fs, *queue)
+	if !ok {
+		return exitUsage
+	}
+
+	entries, ok := cliListQueue()
+	if !ok {
+		return exitError
+	}
+	entries, _ = splitHidden(entries, hidden)
+	plan := planBulk(actionRequeue, bulkTargets(entries, *deferredOnly), nil, filterEnv{})
+	if *dryRun {
+		return printDryRun(plan)
+	}
+	if len(plan.targets) == 0 {
+		fmt.Println("Nothing to requeue.")
+		return exitNothing
+	}
+
+	if !*yes && !confirmTyped(fmt.Sprintf("Requeue %d messages? Type yes to confirm: ", len(plan.targets))) {
+		fmt.Fprintln(os.Stderr, "Aborted.")
+		return exitError
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	res := plan.run(ctx, cfg.Bulk, func(p bulkProgressMsg) {
+		fmt.Fprintf(os.Stderr, "\rrequeue: %d/%d messages, batch %d/%d", p.done, p.total, p.batch, p.batches)
+	})
+	fmt.Fprintln(os.Stderr)
+	for _, err := range res.failures {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+	fmt.Println(res)
+	if code := res.exitCode(); code != exitOK {
+		return code
+	}
+
+	if *flush {
+		for _, host := range listedHosts() {
+			if out, err := runCombinedOutput(actionFlush.command(context.Background(), host, "")); err != nil {
+				fmt.Fprintf(os.Stderr, "Error flushing the queue: %v\n%s", err, out)
+				return exitPartial // requeued, but not flushed
+			}
+		}
+		fmt.Println("Queue flushed.")
+	}
+	return exitOK
+}
+
+// cliDelete implements "postdel delete": delete the queue IDs read from
+// stdin, one per line, so other tools can feed postdel in a pipeline.
+// Lines that are not queue IDs are reported and skipped, as are protected
+// messages (see protect.go), which are not counted, and with -hosts IDs
+// listed on several hosts, which count as failed. With -queue, messages
+// in the other queues are reported and skipped like the protected ones.
+// The last line on stdout counts the outcome for scripts,
+// "deleted=12 missing=3 failed=0"; skipped lines count as failed.
+// -dry-run prints the messages it would delete instead.
+func cliDelete(cfg config, flags cliFlags, args []string) int {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "print the messages it would delete, and do nothing")
+	queue := fs.String("queue", flags.queue, "only delete messages in the queues in `list`, e.g. hold or deferred,hold")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: postdel delete [-dry-run] [-queue LIST] < queue-ids")
+		return exitUsage
+	}
+	hidden, ok := cliQueueView(fs, *queue)
+	if !ok {
+		return exitUsage
+	}
+
+	var ids []string
+	invalid := 0
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(os.Stdin)
+	for n := 1; scanner.Scan(); n++ {
+		id := strings.TrimSpace(scanner.Text())
+		// accept the marked IDs of mailq output as well
+		id = strings.TrimRight(id, "*!")
+		switch {
+		case id == "":
+		case !looksLikeQueueID(id):
+			fmt.Fprintf(os.Stderr, "line %d: not a queue ID: %q\n", n, id)
+			invalid++
+		case !seen[id]:
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading stdin:", err)
+		return exitError
+	}
+	if len(ids) == 0 {
+		fmt.Println("Nothing to delete.")
+		fmt.Printf("deleted=0 missing=0 failed=%d\n", invalid)
+		return outcomeExitCode(0, 0, invalid)
+	}
+
+	var targets []queueEntry
+	unlisted, skipped := 0, 0
+	if len(queueHosts) > 0 || len(cfg.Protect) > 0 || hidden != nil || *dryRun {
+		// the IDs only say which message, the listing says on which host,
+		// in which queue and whether it is protected
+		entries, ok := cliListQueue()
+		if !ok {
+			return exitError
+		}
+		var unknown, ambiguous []string
+		targets, unknown, ambiguous = resolveIDs(entries, ids)
+		if len(queueHosts) == 0 {
+			// postsuper tells which are gone
+			for _, id := range unknown {
+				targets = append(targets, queueEntry{ID: id})
+			}
+			unknown = nil
+		}
+		if len(unknown) > 0 {
+			fmt.Fprintf(os.Stderr, "%d IDs skipped (not in the queue of any host): %s\n", len(unknown), strings.Join(unknown, " "))
+			unlisted = len(unknown)
+		}
+		if len(ambiguous) > 0 {
+			fmt.Fprintf(os.Stderr, "%d IDs skipped (in the queue of several hosts, which one is meant is unknown): %s\n", len(ambiguous), strings.Join(ambiguous, " "))
+			skipped = len(ambiguous)
+		}
+		var other []queueEntry
+		if targets, other = splitHidden(targets, hidden); len(other) > 0 {
+			fmt.Fprintf(os.Stderr, "%d IDs skipped (not in the queues of -queue): %s\n", len(other), strings.Join(queueIDs(other), " "))
+		}
+	} else {
+		for _, id := range ids {
+			targets = append(targets, queueEntry{ID: id})
+		}
+	}
+	filters, _ := parseProtect(cfg.Protect) // validated with the config
+	plan := planBulk(actionDelete, targets, filters, filterEnv{now: time.Now()})
+	if *dryRun {
+		return printDryRun(plan)
+	}
+	if len(plan.protected) > 0 {
+		fmt.Fprintf(os.Stderr, "%d IDs skipped (protected): %s\n", len(plan.protected), strings.Join(queueIDs(plan.protected), " "))
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	res := plan.run(ctx, cfg.Bulk, func(bulkProgressMsg) {})
+	for _, err := range res.failures {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+	fmt.Println(res)
+	if invalid > 0 {
+		fmt.Fprintf(os.Stderr, "%d lines skipped (not queue IDs)\n", invalid)
+	}
+	missing, failed := res.missing()+unlisted, res.failedIDs+invalid+skipped
+	fmt.Printf("deleted=%d missing=%d failed=%d\n", res.affected, missing, failed)
+	return outcomeExitCode(res.affected, missing, failed)
+}
+
+// resolveIDs finds the listed entries of ids, and returns the IDs no host
+// lists and those several hosts list apart: a bare ID does not say which
+// of those messages is meant.
+func resolveIDs(entries []queueEntry, ids []string) (targets []queueEntry, unknown, ambiguous []string) {
+	listedOn := map[string][]queueEntry{}
+	for _, e := range listedTargets(entries, ids) {
+		listedOn[e.ID] = append(listedOn[e.ID], e)
+	}
+	for _, id := range ids {
+		switch on := listedOn[id]; len(on) {
+		case 0:
+			unknown = append(unknown, id)
+		case 1:
+			targets = append(targets, on[0])
+		default:
+			ambiguous = append(ambiguous, id)
+		}
+	}
+	return targets, unknown, ambiguous
+}
+
+// printDryRun prints what plan would do, for -dry-run: the messages it
+// acts on as TSV like "postdel list", and on stderr those it leaves out.
+func printDryRun(plan bulkPlan) int {
+	acted := plan.acted()
+	fmt.Print(entriesTSV(acted))
+	if len(plan.protected) > 0 {
+		fmt.Fprintf(os.Stderr, "%d protected left out: %s\n", len(plan.protected), strings.Join(queueIDs(plan.protected), " "))
+	}
+	if len(plan.leftOut) > 0 {
+		fmt.Fprintf(os.Stderr, "%d in maildrop left out: %s\n", len(plan.leftOut), strings.Join(queueIDs(plan.leftOut), " "))
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d messages (dry run, nothing done)\n", plan.action, len(acted))
+	if len(acted) == 0 {
+		return exitNothing
+	}
+	return exitOK
+}
+
+// cliQueueView parses the -queue of a subcommand into the queues it hides,
+// nil for none; false after reporting a mistake.
+func cliQueueView(fs *flag.FlagSet, queue string) (map[string]bool, bool) {
+	if queue == "" {
+		return nil, true
+	}
+	hidden, err := parseQueueView(queue)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: -queue: %v\n", fs.Name(), err)
+		fs.Usage()
+		return nil, false
+	}
+	return hidden, true
+}
+
+// splitHidden splits entries into those of the queues shown and those of
+// the queues hidden. Unlisted entries, whose queue is unknown, are shown.
+func splitHidden(entries []queueEntry, hidden map[string]bool) (shown, other []queueEntry) {
+	for _, e := range entries {
+		if e.Queue != "" && hidden[e.Queue] {
+			other = append(other, e)
+		} else {
+			shown = append(shown, e)
+		}
+	}
+	return shown, other
+}
+
+// cliList implements "postdel list": the queue as TSV on stdout, the same
+// columns as the 'c' clipboard export. -min-size, -max-size, -older-than
+// and -newer-than leave out messages outside the bounds and those of
+// unknown size or arrival, -preset those not matching a filter preset.
+// -sort orders the output like 'o' orders the list, -queue leaves out the
+// other queues. -preset, -sort, -queue and the size bounds default to the
+// global flags.
+func cliList(cfg config, flags cliFlags, args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	preset := fs.String("preset", flags.preset, "only list messages matching the filter preset `name`")
+	sortBy := fs.String("sort", flags.sort, "sort by `key`: "+strings.Join(sortKeys, ", ")+"; -key for descending")
+	minSize := fs.String("min-size", flags.minSize, "only list messages of at least `size` (K, M and G suffixes)")
+	maxSize := fs.String("max-size", flags.maxSize, "only list messages of at most `size`")
+	queue := fs.String("queue", flags.queue, "only list the queues in `list`, e.g. hold or deferred,hold")
+	olderThan := fs.String("older-than", "", "only list messages older than `age` (s, m, h and d units)")
+	newerThan := fs.String("newer-than", "", "only list messages newer than `age`")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return exitUsage
+	}
+	terms, err := boundTerms(append(sizeBounds(*minSize, *maxSize),
+		flagBound{"older-than", *olderThan, "age:>"}, flagBound{"newer-than", *newerThan, "age:<"}))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "list:", err)
+		return exitUsage
+	}
+	var order sortOrder
+	if *sortBy != "" {
+		if order, err = parseSortOrder(*sortBy); err != nil {
+			fmt.Fprintln(os.Stderr, "list: -sort:", err)
+			return exitUsage
+		}
+	}
+	hidden, ok := cliQueueView(fs, *queue)
+	if !ok {
+		return exitUsage
+	}
+	if *preset != "" {
+		f, err := cfg.presetFilter(*preset)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "list:", err)
+			return exitUsage
+		}
+		terms = append(terms, "("+f.expr+")")
+	}
+	f, _, _ := parseFilterInput(strings.Join(terms, " "))
+
+	entries, ok := cliListQueue()
+	if !ok {
+		return exitError
+	}
+	unknown, now := 0, time.Now()
+	entries = slices.DeleteFunc(entries, func(e queueEntry) bool {
+		if hidden[e.Queue] {
+			return true
+		}
+		r := f.eval(filterEnv{now: now}, e)
+		if r == filterUnknown {
+			unknown++
+		}
+		return r != filterYes
+	})
+	if sortsByAttempts(order, cfg.sortThen()) {
+		estimateAttempts(entries, retryTimesOf(entries))
+	}
+	sortEntries(entries, order, cfg.sortThen())
+	fmt.Print(entriesTSV(entries))
+	if unknown > 0 {
+		fmt.Fprintf(os.Stderr, "%d messages of unknown %s left out\n", unknown, f.unknownNoun())
+	}
+	return exitOK
+}
+
+// cliListQueue lists the queue for a subcommand. Hosts that cannot be
+// listed are reported on stderr; ok is false when nothing could be listed.
+func cliListQueue() (entries []queueEntry, ok bool) {
+	entries, err := listQueue()
+	if partialListing(err) {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+		return entries, true
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error running mailq:", err)
+		return nil, false
+	}
+	return entries, true
+}
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmTyped asks on stderr and reads a line from stdin; only "yes" counts.
+func confirmTyped(prompt string) bool {
+	fmt.Fprint(os.Stderr, prompt)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(line), "yes")
+}
+
+// interruptContext is cancelled by the first SIGINT/SIGTERM; after that the
+// default signal handling is restored so a second signal kills the process.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOutcomeExitCode(t *testing.T) {
+	tests := []struct {
+		done, missing, failed int
+		want                  int
+	}{
+		{3, 0, 0, exitOK},
+		{2, 1, 0, exitMissing},
+		{0, 3, 0, exitMissing},
+		{0, 0, 0, exitNothing},
+		{2, 0, 1, exitPartial},
+		{2, 1, 1, exitPartial},
+		{0, 0, 3, exitFailed},
+		{0, 2, 1, exitFailed},
+	}
+	for _, tt := range tests {
+		if got := outcomeExitCode(tt.done, tt.missing, tt.failed); got != tt.want {
+			t.Errorf("outcomeExitCode(%d, %d, %d) = %d, want %d", tt.done, tt.missing, tt.failed, got, tt.want)
+		}
+	}
+}
+
+// stubPostsuper makes the bulk commands of the backend read the IDs, print
+// output and exit with status, as postsuper would.
+func stubPostsuper(t *testing.T, output string, status int) {
+	t.Helper()
+	saved := backend
+	t.Cleanup(func() { backend = saved })
+	backend.bulk = func(ctx context.Context, host string, a action, ids []string) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, "sh", "-c", `cat >/dev/null; printf '%s' "$1"; exit "$2"`,
+			"postsuper", output, strconv.Itoa(status))
+		cmd.Stdin = strings.NewReader(strings.Join(ids, "\n") + "\n")
+		return cmd
+	}
+}
+
+// runDelete runs "postdel delete" on input and returns its exit code and
+// the last line it printed.
+func runDelete(t *testing.T, input string) (int, string) {
+	t.Helper()
+	dir := t.TempDir()
+	stdin := filepath.Join(dir, "stdin")
+	if err := os.WriteFile(stdin, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]**os.File{"stdin": &os.Stdin, "stdout": &os.Stdout, "stderr": &os.Stderr}
+	for name, f := range files {
+		flag := os.O_RDONLY
+		if name != "stdin" {
+			flag = os.O_WRONLY | os.O_CREATE
+		}
+		opened, err := os.OpenFile(filepath.Join(dir, name), flag, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := *f
+		*f = opened
+		defer func() {
+			*f = saved
+			opened.Close()
+		}()
+	}
+	code := cliDelete(defaultConfig(), cliFlags{}, nil)
+	out, err := os.ReadFile(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	return code, lines[len(lines)-1]
+}
+
+func TestCLIDeleteOutcome(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string // of the stubbed postsuper
+		status int
+		code   int
+		line   string
+	}{
+		{"all deleted", "4ABC1\n4ABC2*\n4ABC3!\n", "postsuper: Deleted: 3 messages\n", 0,
+			exitOK, "deleted=3 missing=0 failed=0"},
+		{"some missing", "4ABC1\n4ABC2\n4ABC3\n", "postsuper: Deleted: 2 messages\n", 0,
+			exitMissing, "deleted=2 missing=1 failed=0"},
+		{"all missing", "4ABC1\n4ABC2\n4ABC3\n", "", 0,
+			exitMissing, "deleted=0 missing=3 failed=0"},
+		{"failed batch", "4ABC1\n4ABC2\n4ABC3\n", "postsuper: fatal: queue directory: Permission denied\n", 1,
+			exitFailed, "deleted=0 missing=0 failed=3"},
+		{"lines skipped", "4ABC1\nnot-an-id\n4ABC2\n", "postsuper: Deleted: 2 messages\n", 0,
+			exitPartial, "deleted=2 missing=0 failed=1"},
+		{"nothing given", "\n", "", 0,
+			exitNothing, "deleted=0 missing=0 failed=0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubPostsuper(t, tt.output, tt.status)
+			code, line := runDelete(t, tt.input)
+			if code != tt.code || line != tt.line {
+				t.Errorf("got exit %d and %q, want exit %d and %q", code, line, tt.code, tt.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// The columns of the list are configurable: which ones and in what order,
+// under columns in the config file or with the chooser ('L'), which saves
+// the choice to layout.toml next to the config file. The export is not
+// affected, it always has every field. The width of the list pane is a
+// percentage of the terminal (split, '<' and '>') or, by default, what
+// the columns want.
+
+// listColumn is a column the list can show.
+type listColumn struct {
+	name  string
+	title string // in the header row
+	width int    // wanted width
+	min   int    // narrowest it gets before it is left out
+	value func(m model, e queueEntry, now time.Time) string
+
+	address bool // cut with ellipsizeAddress, keeping the domain
+	dim     bool // rendered faint, see bodypreview.go
+}
+
+// listColumns are the columns in order of priority: when the pane is too
+// narrow, the last ones are shrunk and then left out first.
+var listColumns = []listColumn{
+	{name: "id", title: "ID", width: 14, min: 10, value: func(_ model, e queueEntry, _ time.Time) string {
+		return e.ID
+	}},
+	{name: "queue", title: "queue", width: 8, min: 3, value: func(_ model, e queueEntry, _ time.Time) string {
+		return e.Queue
+	}},
+	{name: "size", title: "size", width: 6, min: 6, value: func(_ model, e queueEntry, _ time.Time) string {
+		if e.Size <= 0 {
+			return "?"
+		}
+		return shortSize(e.Size)
+	}},
+	{name: "age", title: "age", width: 4, min: 4, value: func(_ model, e queueEntry, now time.Time) string {
+		if e.Arrival.IsZero() {
+			return "?"
+		}
+		return formatAge(now.Sub(e.Arrival))
+	}},
+	{name: "tries", title: "tries", width: 5, min: 3, value: func(_ model, e queueEntry, _ time.Time) string {
+		if !e.attemptsKnown {
+			return "?"
+		}
+		return strconv.Itoa(e.Attempts)
+	}},
+	{name: "host", title: "host", width: 10, min: 4, value: func(_ model, e queueEntry, _ time.Time) string {
+		return shortHost(e.Host)
+	}},
+	{name: "type", title: "type", width: 9, min: 5, value: func(m model, e queueEntry, _ time.Time) string {
+		return m.typeColumn(e.ID)
+	}},
+	{name: "sender", title: "sender", width: 24, min: 8, address: true, value: func(_ model, e queueEntry, _ time.Time) string {
+		return e.senderLabel()
+	}},
+	{name: "score", title: "score", width: 6, min: 4, value: func(m model, e queueEntry, _ time.Time) string {
+		return m.scoreColumn(e.ID)
+	}},
+	{name: "preview", title: "preview", width: 30, min: 10, dim: true, value: func(m model, e queueEntry, _ time.Time) string {
+		return m.previewColumn(e.ID)
+	}},
+	{name: "rcpt", title: "recipient", width: 24, min: 8, address: true, value: func(_ model, e queueEntry, _ time.Time) string {
+		switch len(e.Recipients) {
+		case 0:
+			return ""
+		case 1:
+			return e.Recipients[0]
+		}
+		return fmt.Sprintf("%s +%d", e.Recipients[0], len(e.Recipients)-1)
+	}},
+}
+
+// columnNames are the names the columns setting takes.
+func columnNames() []string {
+	var names []string
+	for _, c := range listColumns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// checkColumns validates a columns setting.
+func checkColumns(names []string) error {
+	if !slices.Contains(names, "id") {
+		return fmt.Errorf("columns: id is missing")
+	}
+	for i, name := range names {
+		if !slices.Contains(columnNames(), name) {
+			return fmt.Errorf("columns: unknown column %q, expected %s", name, strings.Join(columnNames(), ", "))
+		}
+		if slices.Contains(names[:i], name) {
+			return fmt.Errorf("columns: %s is listed twice", name)
+		}
+	}
+	return nil
+}
+
+// column looks up a column by name.
+func column(name string) listColumn {
+	i := slices.IndexFunc(listColumns, func(c listColumn) bool { return c.name == name })
+	c := listColumns[i]
+	if name == "id" && detectedPostfix.longIDs {
+		c.width = longIDWidth
+	}
+	return c
+}
+
+// longIDWidth fits the long queue IDs of enable_long_queue_ids.
+const longIDWidth = 17
+
+// shownColumns are the configured columns, plus the host with -hosts and
+// the value sorted by, both after the ID, if they are not configured.
+func (m model) shownColumns() []string {
+	names := slices.Clone(m.columns)
+	after := slices.Index(names, "id") + 1
+	if name, ok := sortColumns[m.sort.key]; ok && !slices.Contains(names, name) {
+		names = slices.Insert(names, after, name)
+	}
+	if len(queueHosts) > 0 && !slices.Contains(names, "host") {
+		names = slices.Insert(names, after, "host")
+	}
+	if !m.cfg.BodyPreview {
+		names = slices.DeleteFunc(names, func(name string) bool { return name == "preview" })
+	}
+	return names
+}
+
+// showsColumn reports whether the list shows column name.
+func (m model) showsColumn(name string) bool {
+	return slices.Contains(m.shownColumns(), name)
+}
+
+// wantedWidth is how wide the columns would like the list pane to be.
+func (m model) wantedWidth() int {
+	w := m.rowPrefixWidth() - 1
+	for _, name := range m.shownColumns() {
+		w += column(name).width + 1
+	}
+	return w
+}
+
+// rowPrefixWidth is the width of what precedes the columns in a row: the
+// cursor, the mark and the bookmark, tag, attachment and no-recipients
+// flags.
+func (m model) rowPrefixWidth() int {
+	w := 2 + lipgloss.Width(m.bookmarkColumn("")+m.tagChip("")+m.attachChip(""))
+	if m.anyNoRecipients() {
+		w++
+	}
+	return w
+}
+
+// anyNoRecipients reports whether an entry without recipients is listed,
+// see noRecipients; only then the list has a column flagging them.
+func (m model) anyNoRecipients() bool {
+	return slices.ContainsFunc(m.entries, queueEntry.noRecipients)
+}
+
+// fitColumns fits the shown columns into the list pane. Too wide, the
+// columns of the lowest priority shrink to their minimum and then go;
+// spare room goes to the last column.
+func (m model) fitColumns() ([]listColumn, []int) {
+	var cols []listColumn
+	for _, name := range m.shownColumns() {
+		cols = append(cols, column(name))
+	}
+	avail := m.left.Width - m.rowPrefixWidth()
+	widths := make([]int, len(cols))
+	total := -1
+	for i, c := range cols {
+		widths[i] = c.width
+		total += c.width + 1
+	}
+	// indices from the lowest priority up
+	order := make([]int, len(cols))
+	for i := range order {
+		order[i] = i
+	}
+	priority := func(i int) int { return slices.Index(columnNames(), cols[i].name) }
+	slices.SortFunc(order, func(a, b int) int { return priority(b) - priority(a) })
+	for _, i := range order {
+		if total <= avail {
+			break
+		}
+		cut := min(widths[i]-cols[i].min, total-avail)
+		widths[i] -= cut
+		total -= cut
+	}
+	for _, i := range order {
+		if total <= avail || cols[i].name == "id" {
+			break
+		}
+		total -= widths[i] + 1
+		widths[i] = 0
+	}
+	for i := len(cols) - 1; i >= 0; i-- {
+		if widths[i] > 0 {
+			widths[i] += max(avail-total, 0)
+			break
+		}
+	}
+	return cols, widths
+}
+
+// listRow renders the columns of e. Values that do not fit are cut with
+// an ellipsis; entryLine and the details show them in full.
+func (m model) listRow(e queueEntry, cols []listColumn, widths []int, now time.Time) string {
+	var cells []string
+	for i, c := range cols {
+		if widths[i] == 0 {
+			continue
+		}
+		value := c.value(m, e, now)
+		if c.address {
+			value = ellipsizeAddress(value, widths[i])
+		} else {
+			value = truncate(value, widths[i])
+		}
+		cell := padRight(value, widths[i])
+		if c.dim {
+			cell = disabledStyle.Render(cell)
+		}
+		cells = append(cells, cell)
+	}
+	return strings.TrimRight(strings.Join(cells, " "), " ")
+}
+
+// listHeader is the row above the list naming its columns, with an arrow
+// at the one the list is sorted by.
+func (m model) listHeader() string {
+	cols, widths := m.fitColumns()
+	cells := []string{strings.Repeat(" ", m.rowPrefixWidth()-1)}
+	for i, c := range cols {
+		if widths[i] == 0 {
+			continue
+		}
+		title := truncate(c.title, widths[i])
+		if sortColumns[m.sort.key] == c.name {
+			// the title gives way to the arrow
+			title = truncate(c.title, max(widths[i]-2, 1)) + " " + m.sort.arrow()
+		}
+		cells = append(cells, padRight(truncate(title, widths[i]), widths[i]))
+	}
+	return disabledStyle.Render(truncate(strings.Join(cells, " "), m.left.Width))
+}
+
+// headerColumnAt is the column whose header is at cell x of the list
+// pane's content, as listHeader lays them out; "" between or beyond them.
+func (m model) headerColumnAt(x int) string {
+	cols, widths := m.fitColumns()
+	start := m.rowPrefixWidth()
+	for i, c := range cols {
+		if widths[i] == 0 {
+			continue
+		}
+		if x >= start && x < start+widths[i] {
+			return c.name
+		}
+		start += widths[i] + 1
+	}
+	return ""
+}
+
+// entryReasonShown is how much of the deferral reason entryLines shows.
+const entryReasonShown = 60
+
+// entryLines show the selected entry in full below the panes, however the
+// list cuts it: ID, queue, size, age, sender, the number of recipients and
+// the beginning of the reason. What does not fit on one line wraps to a
+// second one at a field. Nothing is shown for an empty list.
+func (m model) entryLines() []string {
+	if m.selected >= len(m.entries) {
+		return nil
+	}
+	e := m.entries[m.selected]
+	fields := []string{e.ID, e.Queue}
+	if e.Host != "" {
+		fields = append(fields, e.Host)
+	}
+	if e.Size > 0 {
+		fields = append(fields, formatSize(e.Size))
+	}
+	if !e.Arrival.IsZero() {
+		fields = append(fields, formatAge(time.Since(e.Arrival))+" old")
+	}
+	if retry := m.countdownField(); retry != "" {
+		fields = append(fields, retry)
+	}
+	fields = append(fields, "from "+e.senderLabel(), fmt.Sprintf("%d recipients", len(e.Recipients)))
+	if e.Reason != "" {
+		fields = append(fields, truncate(e.Reason, entryReasonShown))
+	}
+	const sep = "  "
+	first := fields[0]
+	for i, f := range fields[1:] {
+		if ansi.StringWidth(first+sep+f) > m.termWidth {
+			return []string{first, truncate(strings.Join(fields[i+1:], sep), m.termWidth)}
+		}
+		first += sep + f
+	}
+	return []string{first}
+}
+
+// layoutFile is the layout saved from the session, see savedLayoutPath.
+type layoutFile struct {
+	Columns []string `toml:"columns"`
+	Split   int      `toml:"split,omitempty"`
+}
+
+// The split stays within these percentages, and '<' and '>' change it by
+// splitStep.
+const (
+	minSplit  = 10
+	maxSplit  = 90
+	splitStep = 5
+)
+
+// parseSplit parses a split such as "30%" or "30".
+func parseSplit(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	if err != nil || n < minSplit || n > maxSplit {
+		return 0, fmt.Errorf("invalid split %q, expected a percentage from %d%% to %d%%", s, minSplit, maxSplit)
+	}
+	return n, nil
+}
+
+// listWidth is the width of the list pane: the split of the terminal, or
+// as wide as the columns want within 26 cells and half the terminal.
+func (m model) listWidth() int {
+	if m.split > 0 {
+		return max(m.termWidth*m.split/100, 20)
+	}
+	return max(min(m.wantedWidth(), m.termWidth/2), 26)
+}
+
+// resizeList widens (dir 1) or narrows (dir -1) the list pane by
+// splitStep percent and saves the split to the layout file.
+func (m *model) resizeList(dir int) {
+	split := m.split
+	if split == 0 && m.termWidth > 0 {
+		// start from the width the columns gave it
+		split = (m.left.Width + 2) * 100 / m.termWidth
+		split -= split % splitStep
+	}
+	m.split = min(max(split+dir*splitStep, minSplit), maxSplit)
+	m.resizePanes()
+	m.syncLeft()
+	m.status = fmt.Sprintf("list pane %d%% of the width", m.split)
+	if err := m.saveLayout(); err != nil {
+		m.status += " (not saved: " + err.Error() + ")"
+	} else {
+		m.status += ", saved to " + savedLayoutPath(m.flags.configPath)
+	}
+}
+
+// savedLayoutPath is the file the column chooser saves to.
+func savedLayoutPath(configPath string) string {
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "layout.toml")
+}
+
+// addSavedLayout applies the layout saved in path to c; it is the later
+// choice and wins over the config file. A missing file is not an error.
+func (c *config) addSavedLayout(path string) error {
+	if path == "" {
+		return nil
+	}
+	var saved layoutFile
+	_, err := toml.DecodeFile(path, &saved)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err == nil && saved.Columns != nil {
+		err = checkColumns(saved.Columns)
+	}
+	if err == nil && saved.Split != 0 {
+		_, err = parseSplit(strconv.Itoa(saved.Split))
+	}
+	if err != nil {
+		return fmt.Errorf("layout %s: %w", path, err)
+	}
+	if saved.Columns != nil {
+		c.Columns = saved.Columns
+	}
+	if saved.Split != 0 {
+		c.Split = saved.Split
+	}
+	return nil
+}
+
+// saveLayout writes the layout of the session to the layout file.
+func (m model) saveLayout() error {
+	path := savedLayoutPath(m.flags.configPath)
+	if path == "" {
+		return fmt.Errorf("no config directory")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = toml.NewEncoder(f).Encode(layoutFile{Columns: m.columns, Split: m.split})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// columnChooser is the dialog picking the columns ('L').
+type columnChooser struct {
+	names  []string // every column, the shown ones first in their order
+	on     map[string]bool
+	cursor int
+}
+
+// openColumnChooser shows the column chooser with the current columns.
+func (m *model) openColumnChooser() {
+	ch := &columnChooser{names: slices.Clone(m.columns), on: map[string]bool{}}
+	for _, name := range m.columns {
+		ch.on[name] = true
+	}
+	for _, name := range columnNames() {
+		if !ch.on[name] && (name != "preview" || m.cfg.BodyPreview) {
+			ch.names = append(ch.names, name)
+		}
+	}
+	m.chooser = ch
+}
+
+// updateColumnChooser handles keys while the column chooser is open.
+func (m model) updateColumnChooser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	ch := m.chooser
+	move := func(to int) {
+		if to >= 0 && to < len(ch.names) {
+			ch.names[ch.cursor], ch.names[to] = ch.names[to], ch.names[ch.cursor]
+			ch.cursor = to
+		}
+	}
+	switch msg.String() {
+	case "esc", "ctrl+c", "L":
+		m.chooser = nil
+	case "up", "k":
+		ch.cursor = max(ch.cursor-1, 0)
+	case "down", "j":
+		ch.cursor = min(ch.cursor+1, len(ch.names)-1)
+	case "shift+up", "K":
+		move(ch.cursor - 1)
+	case "shift+down", "J":
+		move(ch.cursor + 1)
+	case "space", " ":
+		if name := ch.names[ch.cursor]; name != "id" {
+			ch.on[name] = !ch.on[name]
+		}
+	case "enter":
+		m.chooser = nil
+		var columns []string
+		for _, name := range ch.names {
+			if ch.on[name] {
+				columns = append(columns, name)
+			}
+		}
+		return m, m.setColumns(columns)
+	}
+	return m, nil
+}
+
+// setColumns shows columns in the list and saves them to the layout file.
+func (m *model) setColumns(columns []string) tea.Cmd {
+	m.columns = columns
+	m.resizePanes()
+	m.syncLeft()
+	m.status = "columns: " + strings.Join(columns, ", ")
+	if err := m.saveLayout(); err != nil {
+		m.status += " (not saved: " + err.Error() + ")"
+	} else {
+		m.status += ", saved to " + savedLayoutPath(m.flags.configPath)
+	}
+	return tea.Batch(m.fetchContentTypes(), m.fetchPreviews(), m.fetchScores(), m.fetchRetryTimes())
+}
+
+// columnChooserView renders the column chooser centered on the screen.
+func (m model) columnChooserView() string {
+	ch := m.chooser
+	var sb strings.Builder
+	sb.WriteString("list columns\n\n")
+	for i, name := range ch.names {
+		box := "[ ]"
+		if ch.on[name] {
+			box = "[x]"
+		}
+		line := box + " " + padRight(name, 8) + " " + column(name).title
+		if i == ch.cursor {
+			line = selectedStyle.Render("> ") + line
+		} else {
+			line = "  " + line
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\nspace show/hide, K/J move up/down, enter apply and save, esc cancel")
+	return m.dialogBox(52, lipgloss.Top, sb.String())
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// command is an entry of the command registry. The key map, the key hints
+// below the panes and the command palette are all built from it.
+type command struct {
+	keys        []string // the first key is the one shown
+	title       string   // shown in the palette
+	hint        string   // short label for the key hints, "" to leave it out
+	destructive bool     // changes the queue, refused in read-only sessions
+	perEntry    bool     // needs a selected entry
+	menu        bool     // offered by the entry menu (enter in the list), see entrymenu.go
+	run         func(m *model) tea.Cmd
+}
+
+// commands is the registry; filled in init because some commands refer back
+// to it (the palette).
+var commands []command
+
+func init() {
+	commands = []command{
+		{keys: []string{"tab"}, title: "switch pane", hint: "focus", run: func(m *model) tea.Cmd {
+			m.cycleFocus()
+			return nil
+		}},
+		{keys: []string{"d"}, title: "delete message", hint: "delete", destructive: true, perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.requestAction(actionDelete)
+		}},
+		{keys: []string{"h"}, title: "put message on hold", hint: "hold", destructive: true, perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.requestAction(actionHold)
+		}},
+		{keys: []string{"u"}, title: "release message from hold", hint: "release", destructive: true, perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.requestAction(actionRelease)
+		}},
+		{keys: []string{"r"}, title: "requeue message", hint: "requeue", destructive: true, perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.requestAction(actionRequeue)
+		}},
+		{keys: []string{"e"}, title: "expire message: return it to the sender", destructive: true, perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.requestAction(actionExpire)
+		}},
+		{keys: []string{"i"}, title: "deliver message now (postqueue -i)", hint: "deliver", destructive: true, perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.deliverSelected()
+		}},
+		{keys: []string{"R"}, title: "requeue all messages", hint: "requeue all", destructive: true, run: func(m *model) tea.Cmd {
+			m.openRequeueDialog()
+			return nil
+		}},
+		{keys: []string{"f"}, title: "flush the queue", hint: "flush", destructive: true, run: func(m *model) tea.Cmd {
+			return m.requestAction(actionFlush)
+		}},
+		{keys: []string{"D"}, title: "empty the trash: delete the messages in it", destructive: true, run: func(m *model) tea.Cmd {
+			return m.requestEmptyTrash()
+		}},
+		{keys: []string{"b"}, title: "show/hide the trash", run: func(m *model) tea.Cmd {
+			m.toggleTrash()
+			return nil
+		}},
+		{keys: []string{"C"}, title: "clear corrupt queue", destructive: true, run: func(m *model) tea.Cmd {
+			return m.requestAction(actionClearCorrupt)
+		}},
+		{keys: []string{"."}, title: "repeat last action", hint: "repeat", destructive: true, perEntry: true, run: func(m *model) tea.Cmd {
+			if !m.hasLastAction {
+				m.status = "no delete/hold/release/requeue to repeat yet"
+				return nil
+			}
+			return m.requestAction(m.lastAction)
+		}},
+		{keys: []string{"V"}, title: "visual mode: select a range", hint: "range", perEntry: true, run: func(m *model) tea.Cmd {
+			m.toggleVisual()
+			return nil
+		}},
+		{keys: []string{"g", "home"}, title: "first message", perEntry: true, run: func(m *model) tea.Cmd { return m.selectEntry(0) }},
+		{keys: []string{"G", "end"}, title: "last message", perEntry: true, run: func(m *model) tea.Cmd { return m.selectEntry(len(m.entries) - 1) }},
+		{keys: []string{"shift+down"}, title: "extend range down", perEntry: true, run: func(m *model) tea.Cmd { return m.extendVisual(1) }},
+		{keys: []string{"shift+up"}, title: "extend range up", perEntry: true, run: func(m *model) tea.Cmd { return m.extendVisual(-1) }},
+		{keys: []string{"space", " "}, title: "select/unselect message", perEntry: true, run: func(m *model) tea.Cmd { return m.toggleMark() }},
+		{keys: []string{"ctrl+a"}, title: "select all shown messages", run: func(m *model) tea.Cmd {
+			m.markAll()
+			return nil
+		}},
+		{keys: []string{"-"}, title: "select none", run: func(m *model) tea.Cmd {
+			m.clearMarks()
+			return nil
+		}},
+		{keys: []string{"*"}, title: "invert selection among the shown messages", run: func(m *model) tea.Cmd {
+			m.invertMarks()
+			return nil
+		}},
+		{keys: []string{"m"}, title: "bookmark message", hint: "bookmark", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			m.toggleBookmark()
+			return nil
+		}},
+		{keys: []string{"M"}, title: "mark for review (listed on quit)", hint: "review", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			m.toggleReview()
+			return nil
+		}},
+		{keys: []string{"]"}, title: "next bookmark", run: func(m *model) tea.Cmd { return m.jumpBookmark(1) }},
+		{keys: []string{"["}, title: "previous bookmark", run: func(m *model) tea.Cmd { return m.jumpBookmark(-1) }},
+		{keys: []string{"alt+left"}, title: "back to the message before the jump", run: func(m *model) tea.Cmd { return m.jumpBack(-1) }},
+		{keys: []string{"alt+right"}, title: "forward again after alt+left", run: func(m *model) tea.Cmd { return m.jumpBack(1) }},
+		{keys: []string{"t"}, title: "tag message (cycle tags)", hint: "tag", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			m.cycleTag()
+			return nil
+		}},
+		{keys: []string{"="}, title: "mark for diff / diff with marked", perEntry: true, menu: true, run: func(m *model) tea.Cmd { return m.markForDiff() }},
+		{keys: []string{"H"}, title: "compare headers of the selection", run: func(m *model) tea.Cmd { return m.startHeaderCompare() }},
+		{keys: []string{"alt+d"}, title: "delete all shown messages (the filtered list)", destructive: true, run: func(m *model) tea.Cmd {
+			return m.requestDeleteShown()
+		}},
+		{keys: []string{"W"}, title: "cleanup wizard: pick messages by sender, age, size and queue, then act on them", destructive: true, run: func(m *model) tea.Cmd {
+			m.openCleanup()
+			return nil
+		}},
+		{keys: []string{"X"}, title: "delete the messages of a failed bulk delete again", destructive: true, run: func(m *model) tea.Cmd {
+			return m.retryFailedDeletes()
+		}},
+		{keys: []string{"x"}, title: "cancel bulk operation", run: func(m *model) tea.Cmd {
+			if m.bulk != nil {
+				m.bulk.cancel()
+				m.status = fmt.Sprintf("%s: cancelling…", m.bulk.action)
+			}
+			return nil
+		}},
+		{keys: []string{"l"}, title: "load details again", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			m.rightRaw = "Loading details…"
+			m.right.SetContent(m.rightRaw)
+			cmd := m.runPostcatCmd(m.entries[m.selected].ID)
+			m.syncLeft()
+			return cmd
+		}},
+		{keys: []string{"c"}, title: "copy list as TSV", run: func(m *model) tea.Cmd {
+			rows := m.visibleEntries()
+			copyToClipboard(entriesTSV(rows))
+			m.status = fmt.Sprintf("copied %d entries as TSV to the clipboard", len(rows))
+			return nil
+		}},
+		{keys: []string{"Y"}, title: "copy the queue IDs of the shown messages", run: func(m *model) tea.Cmd {
+			rows := m.visibleEntries()
+			if len(rows) == 0 {
+				m.status = "no message is shown, nothing copied"
+				return nil
+			}
+			copyToClipboard(entryIDs(rows))
+			m.status = fmt.Sprintf("copied %d queue IDs to the clipboard (%s)", len(rows), m.shownScope())
+			return nil
+		}},
+	}
+	for i, name := range queueNames {
+		n := i + 1
+		commands = append(commands, command{
+			keys:  []string{fmt.Sprint(n)},
+			title: "show/hide " + name + " queue",
+			run:   func(m *model) tea.Cmd { return m.toggleQueue(n) },
+		})
+	}
+	commands = append(commands, []command{
+		{keys: []string{"w"}, title: "show the raw queue file", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.showRawFile()
+		}},
+		{keys: []string{"L"}, title: "choose the list columns", run: func(m *model) tea.Cmd {
+			m.openColumnChooser()
+			return nil
+		}},
+		{keys: []string{"Z"}, title: "zoom the details to the full width", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			m.toggleZoom()
+			return nil
+		}},
+		{keys: []string{"|"}, title: "two or three panes (list, headers, body)", run: func(m *model) tea.Cmd {
+			return m.toggleThreePanes()
+		}},
+		{keys: []string{"<"}, title: "narrow the list pane", run: func(m *model) tea.Cmd {
+			m.resizeList(-1)
+			return nil
+		}},
+		{keys: []string{">"}, title: "widen the list pane", run: func(m *model) tea.Cmd {
+			m.resizeList(1)
+			return nil
+		}},
+		{keys: []string{"p"}, title: "peek at the headers in a popup", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.openPeek()
+		}},
+		{keys: []string{"alt+l"}, title: "show the message's lines of the mail log", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.openMailLog()
+		}},
+		{keys: []string{"J"}, title: "cluster the messages by subject", run: func(m *model) tea.Cmd {
+			return m.openSubjectClusters()
+		}},
+		{keys: []string{"alt+r"}, title: "group the deferred messages by destination", run: func(m *model) tea.Cmd {
+			m.openDestinations()
+			return nil
+		}},
+		{keys: []string{"?"}, title: "help: mail server and keys", run: func(m *model) tea.Cmd {
+			m.showHelp()
+			return nil
+		}},
+		{keys: []string{"S"}, title: "show what changed since the session started", run: func(m *model) tea.Cmd {
+			m.showSessionDiff()
+			return nil
+		}},
+		{keys: []string{"alt+s"}, title: "take a snapshot of the queue and save it", run: func(m *model) tea.Cmd {
+			m.takeSnapshot()
+			return nil
+		}},
+		{keys: []string{"alt+c"}, title: "compare the queue with the snapshot", run: func(m *model) tea.Cmd {
+			m.showSnapshotDiff()
+			return nil
+		}},
+		{keys: []string{"P"}, title: "show the Postfix version, queue directory and queue settings", run: func(m *model) tea.Cmd {
+			return m.openServerInfo()
+		}},
+		{keys: []string{"K"}, title: "classify with the spam classifier", hint: "classify", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			return m.classifySelected()
+		}},
+		{keys: []string{"y"}, title: "copy the queue ID", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			id := m.entries[m.selected].ID
+			copyToClipboard(id)
+			m.status = "copied " + id + " to the clipboard"
+			return nil
+		}},
+		{keys: []string{"E"}, title: "save the message as .eml", perEntry: true, menu: true, run: func(m *model) tea.Cmd {
+			m.openExportPrompt()
+			return nil
+		}},
+		{keys: []string{"I"}, title: "select messages by pasted queue IDs", run: func(m *model) tea.Cmd {
+			m.openPasteIDsPrompt()
+			return nil
+		}},
+		{keys: []string{"A"}, title: "show only messages I can act on", run: func(m *model) tea.Cmd {
+			m.toggleActionable()
+			return nil
+		}},
+		{keys: []string{"T"}, title: "show/hide the content-type column", run: func(m *model) tea.Cmd {
+			return m.toggleContentType()
+		}},
+		{keys: []string{"o"}, title: "sort by the next key (arrival, age, size, sender)", run: func(m *model) tea.Cmd {
+			m.cycleSort()
+			return tea.Batch(m.fetchRetryTimes(), m.fetchScores())
+		}},
+		{keys: []string{"O"}, title: "reverse the sort", run: func(m *model) tea.Cmd {
+			m.reverseSort()
+			return nil
+		}},
+		{keys: []string{"alt+h"}, title: "sort by the header's next column, each way in turn", run: func(m *model) tea.Cmd {
+			return m.nextSortColumn()
+		}},
+		{keys: []string{"F"}, title: "pick a filter preset", run: func(m *model) tea.Cmd {
+			m.openPresetPicker()
+			return nil
+		}},
+		{keys: []string{"alt+f"}, title: "save the filter as a preset", run: func(m *model) tea.Cmd {
+			m.openSavePresetPrompt()
+			return nil
+		}},
+		{keys: []string{"z"}, title: "filter by size", run: func(m *model) tea.Cmd {
+			m.openSizeFilterPrompt()
+			return nil
+		}},
+		{keys: []string{"/"}, title: "find in all messages", hint: "find", run: func(m *model) tea.Cmd {
+			m.openSearchPrompt()
+			return nil
+		}},
+		{keys: []string{"n"}, title: "next search hit", run: func(m *model) tea.Cmd { return m.stepSearch(1) }},
+		{keys: []string{"N"}, title: "previous search hit", run: func(m *model) tea.Cmd { return m.stepSearch(-1) }},
+		{keys: []string{"ctrl+l"}, title: "list the queue again", run: func(m *model) tea.Cmd {
+			m.status = "listing the queue…"
+			return runMailqCmd
+		}},
+		{keys: []string{"ctrl+r"}, title: "reload config file", run: func(m *model) tea.Cmd {
+			return m.reloadConfig()
+		}},
+		{keys: []string{","}, title: "settings: change options for the session or save them", run: func(m *model) tea.Cmd {
+			m.openSettings()
+			return nil
+		}},
+		{keys: []string{"ctrl+t"}, title: "triage: decide on one message after the other", destructive: true, run: func(m *model) tea.Cmd {
+			return m.startTriage()
+		}},
+		{keys: []string{"ctrl+e"}, title: "show the errors of the session", run: func(m *model) tea.Cmd {
+			m.openErrorLog()
+			return nil
+		}},
+		{keys: []string{"ctrl+d"}, title: "toggle worker pool statistics", run: func(m *model) tea.Cmd {
+			m.showDebug = !m.showDebug
+			return nil
+		}},
+		{keys: []string{"ctrl+o"}, title: "show/hide the commands postdel runs", run: func(m *model) tea.Cmd {
+			m.toggleShowCommands()
+			return nil
+		}},
+		{keys: []string{"alt+o"}, title: "list the last commands run", run: func(m *model) tea.Cmd {
+			m.showCommandHistory()
+			return nil
+		}},
+		{keys: []string{"ctrl+p"}, title: "command palette", hint: "commands", run: func(m *model) tea.Cmd {
+			m.openPalette()
+			return nil
+		}},
+		{keys: []string{"q", "esc", "ctrl+c"}, title: "quit", hint: "quit", run: func(m *model) tea.Cmd {
+			next, cmd := m.requestQuit()
+			*m = next.(model)
+			return cmd
+		}},
+	}...)
+}
+
+// commandForKey looks up the command bound to key.
+func commandForKey(key string) (command, bool) {
+	for _, c := range commands {
+		for _, k := range c.keys {
+			if k == key {
+				return c, true
+			}
+		}
+	}
+	return command{}, false
+}
+
+// keyActions are the queue actions of the per-entry keys.
+var keyActions = map[string]action{
+	"d": actionDelete,
+	"h": actionHold,
+	"u": actionRelease,
+	"r": actionRequeue,
+	"e": actionExpire,
+}
+
+// disabledReason tells why c cannot run right now, or "" if it can.
+func (m model) disabledReason(c command) string {
+	switch {
+	case c.destructive && m.readOnly:
+		return m.readOnlyReason
+	case c.destructive && len(m.entries) == 0:
+		return "the list is empty"
+	case c.perEntry && m.selected >= len(m.entries):
+		return "no message selected"
+	}
+	if a, ok := keyActions[c.keys[0]]; ok && !backend.supports(a) {
+		return unsupported(a)
+	}
+	return ""
+}
+
+// runCommand runs c unless the current context forbids it.
+func (m *model) runCommand(c command) tea.Cmd {
+	if reason := m.disabledReason(c); reason != "" {
+		m.status = reason
+		return nil
+	}
+	return c.run(m)
+}
+
+// keyHints is the key help below the status line.
+func (m model) keyHints() string {
+	var hints []string
+	for _, c := range commands {
+		if c.hint == "" || (c.destructive && (m.readOnly || len(m.entries) == 0)) {
+			continue
+		}
+		key := "'" + c.keys[0] + "'"
+		if len(c.keys[0]) > 1 {
+			key = "[" + strings.ToUpper(c.keys[0]) + "]"
+		}
+		hints = append(hints, key+" "+c.hint)
+	}
+	line := strings.Join(hints, ", ") + "."
+	if m.readOnly {
+		line = "READ-ONLY  " + line
+	}
+	return line
+}
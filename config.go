@@ -0,0 +1,509 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// config holds all user-tunable settings. It is built from the defaults,
+// the config file and finally the command line flags.
+type config struct {
+	// Confirm maps an action name to when it asks before running, see
+	// confirm.go.
+	Confirm map[string]confirmPolicy `toml:"confirm"`
+
+	Bulk bulkConfig `toml:"bulk"`
+
+	// Workers limits how many background commands run at the same time.
+	Workers int `toml:"workers"`
+
+	// PostfixDir is where mailq, postcat, postsuper and postqueue live.
+	// Empty means $PATH, then the usual sbin directories.
+	PostfixDir string `toml:"postfix_dir"`
+
+	// MTA is the mail server whose queue is managed, "postfix" or "exim";
+	// empty to use the one installed. See mta.go.
+	MTA string `toml:"mta"`
+
+	// ListCommand lists the Postfix queue with "showq" (the socket),
+	// "json" (postqueue -j), "mailq" or "postqueue" (postqueue -p); empty
+	// tries them in this order. See listCommands.
+	ListCommand string `toml:"list_command"`
+
+	// ReadOnly disables all actions that change the queue, in the TUI and
+	// in the subcommands.
+	ReadOnly bool `toml:"read_only"`
+
+	Hook hookConfig `toml:"hook"`
+
+	// Classify is the spam classifier of 'K' and the score column, see
+	// classify.go.
+	Classify classifyConfig `toml:"classify"`
+
+	// Warning is the screen shown before the queue, see warning.go.
+	Warning warningConfig `toml:"warning"`
+
+	Keys keysConfig `toml:"keys"`
+
+	Timeouts timeoutConfig `toml:"timeouts"`
+
+	// Autoload is what the details show of the selected message as soon
+	// as the list is (re)loaded; with "off" postcat runs only on
+	// navigation.
+	Autoload autoloadMode `toml:"autoload"`
+
+	// Hosts are the machines whose queues are listed over ssh instead of
+	// the local one.
+	Hosts []string `toml:"hosts"`
+
+	// Tags are the session tags 't' cycles through.
+	Tags []string `toml:"tags"`
+
+	// Presets are named filter expressions, picked with 'F' or -preset;
+	// see presets.go.
+	Presets map[string]string `toml:"presets"`
+
+	// ContentTypeColumn shows the content-type column from the start, see
+	// contenttype.go; the same as "type" in Columns.
+	ContentTypeColumn bool `toml:"content_type_column"`
+
+	// Columns are the columns of the list in their order, see columns.go.
+	Columns []string `toml:"columns"`
+
+	// Split is the width of the list pane in percent of the terminal, 0
+	// to fit the columns; see columns.go.
+	Split int `toml:"split"`
+
+	// ThreePanes splits the details into the headers and the body on
+	// terminals wide enough, see layout.go; '|' toggles it.
+	ThreePanes bool `toml:"three_panes"`
+
+	// SafeDelete puts messages on hold and checks that they are held
+	// before deleting them, see safedelete.go.
+	SafeDelete bool `toml:"safe_delete"`
+
+	// Trash makes 'd' hold messages and collect them in the trash, which
+	// 'D' deletes; see trash.go.
+	Trash bool `toml:"trash"`
+
+	// AfterDelete is what happens after a single delete: "refresh" lists
+	// the queue again, "remove" only takes the message out of the list and
+	// lists the queue DeferredRefresh after the last delete (0: only on
+	// ctrl+l or after a bulk operation). See afterdelete.go.
+	AfterDelete     string        `toml:"after_delete"`
+	DeferredRefresh time.Duration `toml:"deferred_refresh"`
+
+	// ServeToken is the token -serve-actions requests must carry,
+	// $POSTDEL_SERVE_TOKEN wins over it; see serve.go.
+	ServeToken string `toml:"serve_token"`
+
+	// BodyPreview allows the preview column of the first body line; false
+	// keeps message content out of the list. See bodypreview.go.
+	BodyPreview bool `toml:"body_preview"`
+
+	// TerminalTitle names the session and its deferred count in the
+	// terminal title; read at startup only. See termtitle.go.
+	TerminalTitle bool `toml:"terminal_title"`
+
+	// StableOrder keeps the order of the previous listing and the
+	// selection across refreshes, see stable.go.
+	StableOrder bool `toml:"stable_order"`
+
+	// AutoRefresh lists the queue again this often, 0 never; see
+	// autorefresh.go.
+	AutoRefresh time.Duration `toml:"auto_refresh"`
+
+	// Colors is the color depth: "auto" as detected, "truecolor", "256",
+	// "16" or "none"; see theme.go.
+	Colors string `toml:"colors"`
+
+	// Plain draws the interface without colors, borders and popups drawn
+	// over the panes; read at startup only. See plain.go.
+	Plain bool `toml:"plain"`
+
+	// RefreshOnFocus lists the queue again when the terminal gets the
+	// focus back, for terminals that report it; read at startup only.
+	RefreshOnFocus bool `toml:"refresh_on_focus"`
+
+	// Mouse lets the border between the panes be dragged and the wheel
+	// scroll; read at startup only. See mouse.go.
+	Mouse bool `toml:"mouse"`
+
+	// ConfirmQuit is when 'q' asks before quitting: "never", "always" or
+	// "changes" (after an action changed the queue this session, or while
+	// messages are selected). See quit.go.
+	ConfirmQuit string `toml:"confirm_quit"`
+
+	// ShowCommands echoes every command postdel runs below the status
+	// line, see transparency.go; ctrl+o toggles it.
+	ShowCommands bool `toml:"show_commands"`
+
+	// ShowLatency times the programs postdel runs and shows the last
+	// duration of each in the status line, see latency.go.
+	ShowLatency bool `toml:"show_latency"`
+
+	// LineEndings is how the details show CRLF and bare CR line endings:
+	// "lf" (the default), "visible" or "raw"; see lineendings.go.
+	LineEndings string `toml:"line_endings"`
+
+	// Label is shown in the header line on a badge of LabelColor (a
+	// terminal color number or "#rrggbb"), e.g. "PROD-MX1"; see header.go.
+	Label      string `toml:"label"`
+	LabelColor string `toml:"label_color"`
+
+	// Protect lists filter expressions of messages that bulk deletes leave
+	// out and single deletes ask twice for; see protect.go.
+	Protect []string `toml:"protect"`
+
+	// LogFile is the log of -log-file when the flag is not given, "" for
+	// none; see log.go.
+	LogFile string `toml:"log_file"`
+
+	// ReviewFile is where the review list ('M') is written as TSV on quit,
+	// "" to only print it; see review.go.
+	ReviewFile string `toml:"review_file"`
+
+	// MailLog is the mail log alt+l searches, "" for the usual files of
+	// the mail server; see maillog.go.
+	MailLog string `toml:"mail_log"`
+
+	// DiskWarn is the percentage of free space or inodes on the spool below
+	// which the header shows them as a warning, 0 never; see diskspace.go.
+	DiskWarn int `toml:"disk_warn"`
+
+	// SortThen orders the messages that are equal by the sort key ("size",
+	// "-size", ...), one key after the other; see sort.go.
+	SortThen []string `toml:"sort_then"`
+}
+
+// autoloadMode is how much of the selected message is loaded after the
+// list was (re)loaded.
+type autoloadMode string
+
+const (
+	autoloadOff     autoloadMode = "off"
+	autoloadHeaders autoloadMode = "headers" // postcat -h
+	autoloadFull    autoloadMode = "full"
+)
+
+// autoloadModes are the valid values of autoload.
+var autoloadModes = []string{string(autoloadOff), string(autoloadHeaders), string(autoloadFull)}
+
+// UnmarshalTOML takes a mode, or true and false for full and off as
+// before there were modes.
+func (a *autoloadMode) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case bool:
+		*a = autoloadOff
+		if v {
+			*a = autoloadFull
+		}
+		return nil
+	case string:
+		if slices.Contains(autoloadModes, v) {
+			*a = autoloadMode(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("autoload must be one of %s (or true, false)", strings.Join(autoloadModes, ", "))
+}
+
+// bulkConfig paces bulk operations so postsuper does not compete with the
+// queue manager on a loaded server.
+type bulkConfig struct {
+	BatchSize int           `toml:"batch_size"` // queue IDs per postsuper run
+	Pause     time.Duration `toml:"pause"`      // sleep between two batches
+
+	// RequeueRate limits bulk requeues to this many messages a second,
+	// 0 for no limit; see bulkConfig.forAction.
+	RequeueRate int `toml:"requeue_rate"`
+
+	// Preview opens the confirmation of a bulk delete with the list of
+	// its messages, see preview.go.
+	Preview bool `toml:"preview"`
+}
+
+// defaultConfig returns the built-in settings: only delete is confirmed.
+func defaultConfig() config {
+	c := config{
+		Confirm:  map[string]confirmPolicy{},
+		Bulk:     bulkConfig{BatchSize: 500, Preview: true},
+		Workers:  4,
+		Autoload: autoloadFull,
+		Tags:     []string{"spam", "legit", "ask-customer"},
+		Timeouts: timeoutConfig{Postcat: 30 * time.Second, Commands: 2 * time.Minute},
+		Keys:     keysConfig{SequenceTimeout: 800 * time.Millisecond},
+		Hook:     hookConfig{Actions: []string{"delete", "hold", "requeue"}, Timeout: 10 * time.Second},
+		SortThen: []string{"arrival"},
+		Warning:  warningConfig{Show: warningUnprivileged},
+		Classify: classifyConfig{ScorePattern: defaultScorePattern, Timeout: 30 * time.Second},
+		Columns:  []string{"id", "queue"},
+
+		AfterDelete:     afterDeleteRefresh,
+		DeferredRefresh: 30 * time.Second,
+		Colors:          "auto",
+		ConfirmQuit:     confirmQuitNever,
+		LineEndings:     lineEndingsLF,
+		DiskWarn:        10,
+		BodyPreview:     true,
+		TerminalTitle:   true,
+		StableOrder:     true,
+	}
+	for _, a := range allActions {
+		c.Confirm[a.String()] = confirmSingle
+		if a == actionDelete || a == actionExpire || a == actionClearCorrupt {
+			c.Confirm[a.String()] = confirmAlways
+		}
+	}
+	return c
+}
+
+// defaultConfigPath returns ~/.config/postdel/config.toml (or the XDG equivalent).
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "postdel", "config.toml")
+}
+
+// loadConfig reads path on top of the defaults. A missing file is not an error.
+func loadConfig(path string) (config, error) {
+	c := defaultConfig()
+	if path == "" {
+		return c, nil
+	}
+	_, err := toml.DecodeFile(path, &c)
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaultConfig(), nil
+	}
+	if err != nil {
+		return defaultConfig(), fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return defaultConfig(), fmt.Errorf("config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// validate checks the values that the TOML decoder cannot check by itself.
+func (c config) validate() error {
+	for name := range c.Confirm {
+		if _, ok := parseAction(name); !ok {
+			return fmt.Errorf("unknown action %q in [confirm]", name)
+		}
+	}
+	if c.Bulk.BatchSize < 1 {
+		return fmt.Errorf("bulk.batch_size must be at least 1")
+	}
+	if c.Workers < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+	if c.ListCommand != "" && !slices.Contains(listCommands, c.ListCommand) {
+		return fmt.Errorf("list_command must be one of %s, or empty to try them in turn", strings.Join(listCommands, ", "))
+	}
+	if !slices.Contains(afterDeleteModes, c.AfterDelete) {
+		return fmt.Errorf("after_delete must be one of %s", strings.Join(afterDeleteModes, ", "))
+	}
+	if !slices.Contains(confirmQuitModes, c.ConfirmQuit) {
+		return fmt.Errorf("confirm_quit must be one of %s", strings.Join(confirmQuitModes, ", "))
+	}
+	if !slices.Contains(lineEndingsModes, c.LineEndings) {
+		return fmt.Errorf("line_endings must be one of %s", strings.Join(lineEndingsModes, ", "))
+	}
+	if c.DeferredRefresh < 0 {
+		return fmt.Errorf("deferred_refresh must not be negative")
+	}
+	if c.AutoRefresh < 0 {
+		return fmt.Errorf("auto_refresh must not be negative")
+	}
+	if !slices.Contains(colorModes, c.Colors) {
+		return fmt.Errorf("colors must be one of %s", strings.Join(colorModes, ", "))
+	}
+	if c.MTA != "" {
+		if _, err := selectMTA(c.MTA, false); err != nil {
+			return fmt.Errorf("mta: %w", err)
+		}
+	}
+	if c.Bulk.Pause < 0 {
+		return fmt.Errorf("bulk.pause must not be negative")
+	}
+	if c.Bulk.RequeueRate < 0 {
+		return fmt.Errorf("bulk.requeue_rate must not be negative")
+	}
+	for _, name := range c.Hook.Actions {
+		if a, ok := parseAction(name); !ok || !a.perEntry() {
+			return fmt.Errorf("unknown action %q in hook.actions", name)
+		}
+	}
+	if c.Timeouts.Postcat < 0 || c.Timeouts.Commands < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	if c.Keys.SequenceTimeout < 0 {
+		return fmt.Errorf("keys.sequence_timeout must not be negative")
+	}
+	if err := c.Classify.validate(); err != nil {
+		return err
+	}
+	if err := c.Warning.validate(); err != nil {
+		return err
+	}
+	if c.Hook.Timeout <= 0 {
+		return fmt.Errorf("hook.timeout must be positive")
+	}
+	if c.Hook.Threshold < 0 {
+		return fmt.Errorf("hook.threshold must not be negative")
+	}
+	if len(c.Hook.OnThreshold) > 0 && c.Hook.Threshold == 0 {
+		return fmt.Errorf("hook.on_threshold needs hook.threshold")
+	}
+	for _, h := range c.Hosts {
+		if h == "" || strings.HasPrefix(h, "-") || strings.ContainsAny(h, " \t") {
+			return fmt.Errorf("invalid host %q in hosts", h)
+		}
+	}
+	for _, t := range c.Tags {
+		if t == "" || strings.ContainsAny(t, " \t") {
+			return fmt.Errorf("invalid tag %q: tags are single words", t)
+		}
+	}
+	if err := checkColumns(c.Columns); err != nil {
+		return err
+	}
+	if _, err := parseProtect(c.Protect); err != nil {
+		return fmt.Errorf("protect: %w", err)
+	}
+	if c.DiskWarn < 0 || c.DiskWarn > 100 {
+		return errors.New("disk_warn must be between 0 and 100 percent")
+	}
+	if c.Split != 0 && (c.Split < minSplit || c.Split > maxSplit) {
+		return fmt.Errorf("split must be between %d and %d percent", minSplit, maxSplit)
+	}
+	for _, s := range c.SortThen {
+		if _, err := parseSortOrder(s); err != nil {
+			return fmt.Errorf("sort_then: %w", err)
+		}
+	}
+	for name, expr := range c.Presets {
+		if _, err := parsePreset(name, expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// print writes c in the config file format.
+func (c config) print(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(c)
+}
+
+// setConfirmList replaces the confirmation settings with a comma separated
+// list of action names, as given to --confirm: those always ask, the others
+// only for several messages. "none" lets all single messages through.
+func (c *config) setConfirmList(list string) error {
+	next := map[string]confirmPolicy{}
+	for _, a := range allActions {
+		next[a.String()] = confirmSingle
+	}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "none" {
+			continue
+		}
+		if name == "all" {
+			for k := range next {
+				next[k] = confirmAlways
+			}
+			continue
+		}
+		if _, ok := parseAction(name); !ok {
+			return fmt.Errorf("unknown action %q", name)
+		}
+		next[name] = confirmAlways
+	}
+	c.Confirm = next
+	return nil
+}
+
+// globalsMu guards the settings reloadConfig puts into package variables
+// (queueHosts, listCommand, postfixDir, commandTimeouts, safeDelete,
+// protectExprs).
+// The interface, which is the only one to set them, reads them directly;
+// commands, pool workers, bulk operations and -serve take a snapshot with
+// currentSettings and run with that, so a reload never waits for them.
+var globalsMu sync.RWMutex
+
+// settings is a snapshot of the package variables reloadConfig sets.
+type settings struct {
+	hosts       []string
+	listCommand string
+	postfixDir  string
+	timeouts    timeoutConfig
+	safeDelete  bool
+	protect     []string
+}
+
+// currentSettings takes a snapshot of the settings under globalsMu.
+func currentSettings() settings {
+	globalsMu.RLock()
+	defer globalsMu.RUnlock()
+	return settings{
+		hosts:       queueHosts,
+		listCommand: listCommand,
+		postfixDir:  postfixDir,
+		timeouts:    commandTimeouts,
+		safeDelete:  safeDelete,
+		protect:     protectExprs,
+	}
+}
+
+// reloadConfig re-reads the config file (plus flags) and applies it to the
+// running session, the layout and the auto refresh included; the settings
+// read at startup only, such as mouse and plain, wait for the next start.
+// On errors the previous settings stay in effect.
+func (m *model) reloadConfig() tea.Cmd {
+	c, err := m.flags.load()
+	if err != nil {
+		m.status = "config not reloaded: " + err.Error()
+		return nil
+	}
+	plain := m.cfg.Plain
+	m.cfg = c
+	m.cfg.Plain = plain
+	if !plain {
+		setColors(c.Colors)
+	}
+	m.columns, m.split, m.threePanes = c.Columns, c.Split, c.ThreePanes
+	if c.ContentTypeColumn && !slices.Contains(m.columns, "type") {
+		m.columns = append(slices.Clone(m.columns), "type")
+	}
+	m.resizePanes()
+	m.applyFilter() // sort_then may have changed
+	m.pool.setLimit(c.Workers)
+	globalsMu.Lock()
+	postfixDir = c.PostfixDir
+	listCommand = c.ListCommand
+	commandTimeouts = c.Timeouts
+	queueHosts = c.Hosts
+	safeDelete = c.SafeDelete
+	protectExprs = c.Protect
+	globalsMu.Unlock()
+	forgetQueueDirectory()
+	if c.ReadOnly && !m.readOnly {
+		// read-only can be switched on by a reload, but never off
+		m.readOnly, m.readOnlyReason = true, readOnlyRequested
+	}
+	m.status = "config reloaded from " + m.flags.configPath
+	return m.restartAutoRefresh()
+}
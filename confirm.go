@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Every action that changes the queue asks, or not, as its entry in
+// [confirm] says. The policies are decided here and only here: whatever
+// key or dialog led to an action, it goes through needsConfirm.
+
+// confirmPolicy is when an action asks before running.
+type confirmPolicy string
+
+const (
+	confirmNever  confirmPolicy = "never"  // not even for several messages
+	confirmSingle confirmPolicy = "single" // one message right away, several after asking
+	confirmAlways confirmPolicy = "always"
+)
+
+var confirmPolicies = []string{string(confirmNever), string(confirmSingle), string(confirmAlways)}
+
+// UnmarshalTOML takes a policy, or true and false for always and single
+// as before there were policies.
+func (p *confirmPolicy) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case bool:
+		*p = confirmSingle
+		if v {
+			*p = confirmAlways
+		}
+		return nil
+	case string:
+		if slices.Contains(confirmPolicies, v) {
+			*p = confirmPolicy(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("[confirm] values must be one of %s (or true, false)", strings.Join(confirmPolicies, ", "))
+}
+
+// needsConfirm reports whether a on n messages asks first. Whole-queue
+// actions (flush, clear-corrupt, n is 0) always ask, whatever the policy.
+func (c config) needsConfirm(a action, n int) bool {
+	if !a.perEntry() || n == 0 {
+		return true
+	}
+	switch c.Confirm[a.String()] {
+	case confirmNever:
+		return false
+	case confirmAlways:
+		return true
+	}
+	return n > 1
+}
+
+// confirmHint tells in the help when the action of c asks, "" for keys
+// that run no action.
+func (m model) confirmHint(c command) string {
+	var a action
+	several := false
+	switch c.keys[0] {
+	case "d":
+		a = actionDelete
+	case "h":
+		a = actionHold
+	case "u":
+		a = actionRelease
+	case "r":
+		a = actionRequeue
+	case "e":
+		a = actionExpire
+	case "D", "X":
+		a, several = actionDelete, true
+	case "f", "C", "R", "alt+d":
+		return "asks"
+	default:
+		return ""
+	}
+	switch policy := m.cfg.Confirm[a.String()]; {
+	case policy == confirmNever:
+		return "never asks"
+	case policy == confirmAlways || several:
+		return "asks"
+	}
+	return "asks for several messages"
+}
+
+// askOrRun opens the confirmation of a on ids (the selection, a range,
+// the trash; summary describes them for the dialog) or runs it right away
+// if the policy of a lets it.
+func (m *model) askOrRun(a action, ids []string, summary string) tea.Cmd {
+	m.confirmAction = a
+	m.confirmIDs, m.confirmSummary = ids, summary
+	if m.cfg.needsConfirm(a, len(ids)) {
+		return m.openActionDialog()
+	}
+	return m.confirmYes()
+}
+
+// reselectConfirmed selects the entry the dialog asked about again, by its
+// ID, in case a listing came in while it was open. It refuses when the
+// message is gone or no longer the one shown: another message may have
+// taken its place under the cursor, or its queue ID was reused.
+func (m *model) reselectConfirmed() error {
+	asked := m.confirmEntry
+	m.confirmEntry = queueEntry{}
+	for i, e := range m.entries {
+		if e.ID != asked.ID || e.Host != asked.Host {
+			continue
+		}
+		if e.Sender != asked.Sender || e.Size != asked.Size {
+			return fmt.Errorf("%s changed while you were asked (now %s, %s), nothing was done — check it and try again", asked.ID, e.senderLabel(), formatSize(e.Size))
+		}
+		m.selected = i
+		m.syncLeft()
+		return nil
+	}
+	return fmt.Errorf("%s is no longer listed, nothing was done", asked.ID)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// stubBackend replaces the mail server of the session, until the test
+// ends, by Postfix with commands that do nothing; the actions run are
+// recorded as "delete 4ABC2".
+func stubBackend(t *testing.T) *[]string {
+	t.Helper()
+	acted := &[]string{}
+	saved := backend
+	t.Cleanup(func() { backend = saved })
+	backend = postfixMTA
+	backend.list = func(string) ([]queueEntry, error) { return nil, nil }
+	backend.show = func(ctx context.Context, host, id string, headersOnly bool) *exec.Cmd {
+		return exec.CommandContext(ctx, "true")
+	}
+	backend.act = func(ctx context.Context, host string, a action, id string) *exec.Cmd {
+		*acted = append(*acted, a.String()+" "+id)
+		return exec.CommandContext(ctx, "true")
+	}
+	backend.count = func(out []byte, a action, ids []string) map[string]int {
+		n := map[string]int{}
+		for _, id := range ids {
+			n[id] = 1
+		}
+		return n
+	}
+	return acted
+}
+
+// testModel is a session of a terminal of 120x40 that has listed entries.
+// Its pool is stopped when the test ends.
+func testModel(t *testing.T, entries []queueEntry) model {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.Confirm = map[string]confirmPolicy{"delete": confirmAlways}
+	cfg.Autoload = autoloadOff
+	p := newPool(1)
+	t.Cleanup(func() {
+		p.cancelWhere(func(string, int) bool { return true })
+		for {
+			if running, queued, _ := p.stats(); running == 0 && queued == 0 {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+	var tm tea.Model = model{cfg: cfg, pool: p, startedAt: time.Now()}
+	tm, _ = tm.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	tm, _ = tm.Update(mailqIDsMsg(entries))
+	return tm.(model)
+}
+
+// press sends key to m.
+func press(m model, key tea.KeyMsg) (model, tea.Cmd) {
+	tm, cmd := m.Update(key)
+	return tm.(model), cmd
+}
+
+// runes is the key of s typed.
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// run runs cmd and the commands it batches, and returns their messages.
+func run(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var msgs []tea.Msg
+		for _, c := range batch {
+			msgs = append(msgs, run(c)...)
+		}
+		return msgs
+	}
+	return []tea.Msg{msg}
+}
+
+// confirming reports whether the confirmation of an action is open.
+func confirming(m model) bool {
+	return m.dialog != nil
+}
+
+func TestRefreshWhileConfirming(t *testing.T) {
+	a := queueEntry{ID: "4ABC1", Queue: "deferred", Sender: "alice@example.com", Size: 1000, Recipients: []string{"x@example.net"}}
+	b := queueEntry{ID: "4ABC2", Queue: "deferred", Sender: "bob@example.com", Size: 2000, Recipients: []string{"y@example.net"}}
+	c := queueEntry{ID: "4ABC3", Queue: "deferred", Sender: "carol@example.com", Size: 3000, Recipients: []string{"z@example.net"}}
+	reused := b
+	reused.Sender, reused.Size = "mallory@example.org", 512
+
+	tests := []struct {
+		name    string
+		refresh []queueEntry // the listing arriving while the dialog is open
+		refusal string       // in the status after 'y', "" when b is deleted
+	}{
+		{"moved down", []queueEntry{c, a, b}, ""},
+		{"cursor row taken", []queueEntry{a, c}, "no longer listed"},
+		{"ID reused", []queueEntry{a, reused}, "changed while you were asked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acted := stubBackend(t)
+			m := testModel(t, []queueEntry{a, b})
+			m, _ = press(m, tea.KeyMsg{Type: tea.KeyDown})
+			if got := m.entries[m.selected].ID; got != b.ID {
+				t.Fatalf("selected %s, want %s", got, b.ID)
+			}
+			m, _ = press(m, runes("d"))
+			m, _ = press(m, runes("d"))
+			if !confirming(m) || m.confirmEntry.ID != b.ID {
+				t.Fatalf("no dialog about %s: %s", b.ID, m.status)
+			}
+
+			tm, _ := m.Update(mailqIDsMsg(tt.refresh))
+			m = tm.(model)
+			if !confirming(m) {
+				t.Fatal("the listing closed the dialog")
+			}
+
+			m, cmd := press(m, runes("y"))
+			if tt.refusal == "" {
+				run(cmd)
+				if want := "delete " + b.ID; len(*acted) != 1 || (*acted)[0] != want {
+					t.Errorf("ran %q, want %q", *acted, want)
+				}
+				return
+			}
+			if !strings.Contains(m.status, tt.refusal) {
+				t.Errorf("status %q, want one saying %q", m.status, tt.refusal)
+			}
+			if cmd != nil || len(*acted) > 0 {
+				t.Errorf("ran %q after the refusal", *acted)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"mime"
+	"net/mail"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The content-type column ('T') shows the top-level Content-Type of every
+// message, so attachment-laden spam stands out without opening each one.
+// It needs only the headers ("postcat -h"), fetched in the background for
+// the rows in view as they come into view, and kept by queue ID for the
+// session.
+
+// contentTypeMsg delivers the column label of a message.
+type contentTypeMsg struct {
+	id, label string
+}
+
+// contentTypeLabel condenses the Content-Type and Content-Transfer-Encoding
+// of h to a short label: "plain", "html", "multi", "app", with ",b64" or
+// ",qp" for encoded bodies. "?" means the headers could not be read.
+func contentTypeLabel(h mail.Header) string {
+	if h == nil {
+		return "?"
+	}
+	mediaType := "text/plain" // the default of RFC 2045
+	if v := h.Get("Content-Type"); v != "" {
+		t, _, err := mime.ParseMediaType(v)
+		if err != nil {
+			return "?"
+		}
+		mediaType = t
+	}
+	typ, sub, _ := strings.Cut(mediaType, "/")
+	var label string
+	switch typ {
+	case "text":
+		label = sub
+	case "multipart":
+		label = "multi"
+	case "application":
+		label = "app"
+	case "message":
+		label = "msg"
+	default:
+		label = typ
+	}
+	if len(label) > 5 {
+		label = label[:5]
+	}
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		label += ",b64"
+	case "quoted-printable":
+		label += ",qp"
+	}
+	return label
+}
+
+// toggleContentType shows or hides the content-type column, for the
+// session; the column chooser ('L') saves it.
+func (m *model) toggleContentType() tea.Cmd {
+	if i := slices.Index(m.columns, "type"); i >= 0 {
+		m.columns = slices.Delete(slices.Clone(m.columns), i, i+1)
+	} else {
+		m.columns = append(slices.Clone(m.columns), "type")
+	}
+	m.resizePanes()
+	m.syncLeft()
+	if !m.showsColumn("type") {
+		m.status = "content-type column hidden"
+		return nil
+	}
+	m.status = "content-type column shown, fetching the headers in the background"
+	return m.fetchContentTypes()
+}
+
+// pruneContentTypes drops the labels of messages that left the queue, on
+// a new listing.
+func (m *model) pruneContentTypes() {
+	if len(m.contentTypes) == 0 {
+		return
+	}
+	listed := map[string]bool{}
+	for _, e := range m.allEntries {
+		listed[e.ID] = true
+	}
+	for id := range m.contentTypes {
+		if !listed[id] {
+			delete(m.contentTypes, id)
+		}
+	}
+}
+
+// fetchContentTypes fetches the headers of the rows in view not labelled
+// yet, at background priority.
+func (m *model) fetchContentTypes() tea.Cmd {
+	if !m.showsColumn("type") {
+		return nil
+	}
+	if m.contentTypes == nil {
+		m.contentTypes = map[string]string{}
+	}
+	var cmds []tea.Cmd
+	hits := 0
+	first := min(m.left.YOffset, len(m.entries))
+	last := min(first+m.left.Height, len(m.entries))
+	for _, e := range m.entries[first:last] {
+		if _, ok := m.contentTypes[e.ID]; ok || e.Queue == "corrupt" {
+			hits++
+			continue
+		}
+		m.contentTypes[e.ID] = "" // requested
+		id, host := e.ID, e.Host
+		cmds = append(cmds, m.pool.submit(id, prioBackground, func(ctx context.Context) tea.Msg {
+			ctx, cancel := commandContext(ctx, "postcat")
+			defer cancel()
+			out, err := runOutput(backend.show(ctx, host, id, true))
+			if err != nil {
+				return contentTypeMsg{id: id, label: "?"}
+			}
+			return contentTypeMsg{id: id, label: contentTypeLabel(postcatHeaders(string(out)))}
+		}))
+	}
+	logCache("content types", hits, len(cmds))
+	return tea.Batch(cmds...)
+}
+
+// typeColumn is the content-type column for id.
+func (m model) typeColumn(id string) string {
+	label, ok := m.contentTypes[id]
+	if ok && label == "" {
+		label = "…"
+	}
+	return label
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Postfix moves queue files it cannot parse to the corrupt queue. mailq
+// and postqueue -j do not list it and postcat -q does not search it, so
+// postdel reads the directory itself (which needs root).
+
+var (
+	queueDirMu sync.Mutex
+	queueDir   string // queue_directory, "" until asked; see queueDirectory
+)
+
+// queueDirectory returns Postfix's queue_directory, asking postconf once a
+// session (and again after a config reload, see forgetQueueDirectory).
+func queueDirectory() string {
+	queueDirMu.Lock()
+	defer queueDirMu.Unlock()
+	if queueDir != "" {
+		return queueDir
+	}
+	queueDir = "/var/spool/postfix"
+	out, err := runOutput(postfixCommand(context.Background(), "postconf", "-h", "queue_directory"))
+	if dir := strings.TrimSpace(string(out)); err == nil && dir != "" {
+		queueDir = dir
+	}
+	return queueDir
+}
+
+// forgetQueueDirectory has queueDirectory ask postconf again, which
+// postfix_dir may have changed.
+func forgetQueueDirectory() {
+	queueDirMu.Lock()
+	queueDir = ""
+	queueDirMu.Unlock()
+}
+
+// corruptDir returns the directory of the corrupt queue.
+func corruptDir() string {
+	return filepath.Join(queueDirectory(), "corrupt")
+}
+
+// listCorrupt returns the files of the corrupt queue as entries. Errors
+// (usually: not root) leave the corrupt queue out of the listing.
+func listCorrupt() []queueEntry {
+	dir := corruptDir()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var entries []queueEntry
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		entries = append(entries, queueEntry{ID: f.Name(), Queue: "corrupt", Size: info.Size(), Arrival: info.ModTime()})
+	}
+	return entries
+}
+
+// inspectCorrupt returns what can be shown of a corrupt queue file: the
+// postcat output if postcat can still make sense of it, otherwise the
+// printable parts of the raw file.
+func inspectCorrupt(ctx context.Context, id string) tea.Msg {
+	path := filepath.Join(corruptDir(), id)
+	ctx, cancel := commandContext(ctx, "postcat")
+	defer cancel()
+	cmd := postfixCommand(ctx, "postcat", path)
+	out, err := runOutput(cmd)
+	if err == nil {
+		return postcatMsg{id: id, text: "corrupt queue file " + path + "\n\n" + string(out)}
+	}
+	raw, rerr := os.ReadFile(path)
+	if rerr != nil {
+		return errorMsg(commandError(ctx, cmd, err, nil))
+	}
+	return postcatMsg{id: id, text: fmt.Sprintf("corrupt queue file %s\npostcat failed (%v), printable contents:\n\n%s",
+		path, commandError(ctx, cmd, err, nil), printableDump(raw))}
+}
+
+// printableDump replaces the record bytes of a raw queue file so the text
+// parts stay readable.
+func printableDump(raw []byte) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' || unicode.IsPrint(r) {
+			return r
+		}
+		return '.'
+	}, strings.ToValidUTF8(string(raw), "."))
+}
+
+// deleteCorrupt removes one file of the corrupt queue.
+func deleteCorrupt(id string) error {
+	if strings.ContainsRune(id, filepath.Separator) {
+		return fmt.Errorf("invalid queue ID %q", id)
+	}
+	return os.Remove(filepath.Join(corruptDir(), id))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The entry line counts down to the next delivery attempt of the selected
+// message, "retry in 3m07s", once a second, to decide between waiting and
+// requeueing it. The time comes from the queue file like the Retry: line
+// of the details (see timing.go), so only deferred messages of this
+// machine have it. The ticks stop as soon as another message is selected
+// or the attempt is due.
+
+// countdown is the next attempt of the selected message.
+type countdown struct {
+	id   string
+	next time.Time
+	seq  int // of the running ticks, older ones stop
+}
+
+// countdownMsg is a tick of the countdown seq.
+type countdownMsg struct {
+	seq int
+}
+
+// startCountdown counts down to next for the message id, which was just
+// shown; a zero next stops any countdown.
+func (m *model) startCountdown(id string, next time.Time) tea.Cmd {
+	m.countdown.seq++
+	m.countdown.id, m.countdown.next = id, next
+	if next.IsZero() || !next.After(time.Now()) {
+		return nil
+	}
+	return countdownTick(m.countdown.seq)
+}
+
+// countdownTick schedules the next tick of seq.
+func countdownTick(seq int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return countdownMsg{seq} })
+}
+
+// tickCountdown keeps ticking while the message counted down to is
+// selected and its attempt lies ahead; the tick itself redraws the entry
+// line.
+func (m *model) tickCountdown(msg countdownMsg) tea.Cmd {
+	if msg.seq != m.countdown.seq || !m.countingDown() || !m.countdown.next.After(time.Now()) {
+		return nil
+	}
+	return countdownTick(msg.seq)
+}
+
+// countingDown reports whether the countdown is that of the selected
+// message.
+func (m model) countingDown() bool {
+	return m.countdown.id != "" && m.selected < len(m.entries) && m.entries[m.selected].ID == m.countdown.id &&
+		!m.countdown.next.IsZero()
+}
+
+// countdownField is the countdown for the entry line, "" without one.
+func (m model) countdownField() string {
+	if !m.countingDown() {
+		return ""
+	}
+	left := time.Until(m.countdown.next).Round(time.Second)
+	if left <= 0 {
+		return "retry due"
+	}
+	return "retry in " + formatCountdown(left)
+}
+
+// formatCountdown renders d to the second below an hour, "4m07s", and to
+// the minute above, "2h05m".
+func formatCountdown(d time.Duration) string {
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%02ds", int(d/time.Minute), int(d%time.Minute/time.Second))
+	default:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A panic in Update or View is caught before Bubble Tea's own handler: the
+// running bulk operation is cancelled, and a crash file with the stack and
+// what the session was doing is written under the cache directory. The
+// panic then goes on to Bubble Tea, which restores the terminal, and main
+// prints the path of the crash file. The file holds queue IDs and the
+// filter, but no addresses or message content.
+
+// bulkCancelWait is how long a crash waits for the cancelled bulk operation
+// to report what it did.
+const bulkCancelWait = 5 * time.Second
+
+// crashPath is the crash file written, "" while nothing crashed.
+var crashPath string
+
+// recoverCrash is deferred by Update and View: it records a panic and
+// panics again.
+func (m *model) recoverCrash() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	path, err := writeCrash(m.crashReport(r, stack))
+	if err != nil {
+		logger.Error("crash file not written", "err", err)
+	} else {
+		crashPath = path
+	}
+	logger.Error("panic", "panic", fmt.Sprint(r), "crash_file", path)
+	panic(r)
+}
+
+// crashReport renders the crash file: the panic, what was running and
+// selected, and the stack.
+func (m *model) crashReport(r any, stack []byte) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "postdel crashed at %s: %v\n\n", time.Now().Format(time.RFC3339), r)
+	fmt.Fprintf(&sb, "pid %d, started %s, args %q\n", os.Getpid(), m.startedAt.Format(time.RFC3339), os.Args[1:])
+	if len(m.entries) > 0 && m.selected < len(m.entries) {
+		fmt.Fprintf(&sb, "selected: %s (%s)\n", m.entries[m.selected].ID, m.entries[m.selected].Queue)
+	}
+	if m.filter.active() {
+		fmt.Fprintf(&sb, "filter: %s\n", m.filter.expr)
+	}
+	fmt.Fprintf(&sb, "sort: %s\n", m.sort)
+	if len(m.marked) > 0 {
+		fmt.Fprintf(&sb, "marked: %s\n", strings.Join(slices.Sorted(maps.Keys(m.marked)), " "))
+	}
+	if !m.listedAt.IsZero() {
+		fmt.Fprintf(&sb, "listed at %s: %d messages, %d shown\n", m.listedAt.Format("15:04:05"), len(m.allEntries), len(m.entries))
+	}
+	sb.WriteString("\n" + m.crashOperations() + "\n")
+	sb.WriteString("stack:\n")
+	sb.Write(stack)
+	return sb.String()
+}
+
+// crashOperations cancels the running bulk operation and tells what became
+// of it and of the deletes not yet confirmed by a listing.
+func (m *model) crashOperations() string {
+	var sb strings.Builder
+	if op := m.bulk; op != nil {
+		fmt.Fprintf(&sb, "bulk %s of %d messages running, %d done; cancelled\n", op.action, op.total, op.done)
+		op.cancel()
+		select {
+		case <-time.After(bulkCancelWait):
+			fmt.Fprintf(&sb, "it did not stop within %s: list the queue to see which messages are left\n", bulkCancelWait)
+		case msg := <-op.updates:
+			if done, ok := msg.(bulkDoneMsg); ok {
+				fmt.Fprintf(&sb, "result: %s\n", done.result)
+			} else {
+				// a progress update, or the result was taken by the TUI
+				sb.WriteString("result unknown: list the queue to see which messages are left\n")
+			}
+		}
+		fmt.Fprintf(&sb, "IDs: %s\n", strings.Join(op.ids, " "))
+	} else {
+		sb.WriteString("no bulk operation running\n")
+	}
+	if len(m.pendingDeletes) > 0 {
+		ids := make([]string, 0, len(m.pendingDeletes))
+		for _, e := range m.pendingDeletes {
+			ids = append(ids, e.ID)
+		}
+		slices.Sort(ids)
+		fmt.Fprintf(&sb, "deletes issued, not yet seen gone in a listing: %s\n", strings.Join(ids, " "))
+	}
+	return sb.String()
+}
+
+// writeCrash writes report to a new file in the cache directory and
+// returns its path.
+func writeCrash(report string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "crash-"+time.Now().Format("20060102-150405")+".txt")
+	return path, os.WriteFile(path, []byte(report), 0o600)
+}
+
+// cacheDir returns postdel's directory in the cache directory, or in the
+// temporary directory without one, creating it.
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "postdel")
+	return dir, os.MkdirAll(dir, 0o700)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// 'i' asks for the delivery of the selected message right now with
+// postqueue -i (Postfix 2.4 and later): the flush service moves it from the
+// deferred queue into the incoming one, and the queue manager tries it at
+// once, without waiting for its retry time and without a flush of the
+// whole queue ('f') hammering every destination that is down. Only
+// deferred messages can be scheduled that way. Where postqueue -i is not
+// there, an older Postfix whose postqueue does not know -i (exit status 64,
+// remembered for the session), the message is requeued instead, as 'r'
+// does, and the status line says so; ? shows which one 'i' does. A flush
+// service that is down is an error like any other, 'i' tries again next
+// time. A scheduled message counts as requeued in the summary and runs the
+// hook of requeue. Exim's requeue, exim -M, delivers at once anyway and is
+// what 'i' runs.
+
+// exUsage is the exit status of postqueue for an option it does not know.
+const exUsage = 64 // EX_USAGE
+
+// deliverFallback tells why 'i' requeues rather than running postqueue -i,
+// "" when it runs postqueue -i.
+func (m model) deliverFallback() string {
+	switch {
+	case backend.deliver == nil:
+		return backend.title + " delivers requeued messages at once"
+	case m.noDeliver != "":
+		return m.noDeliver
+	case len(queueHosts) == 0 && detectedPostfix.version != "" && !detectedPostfix.hasDeliver():
+		return "postqueue -i needs Postfix 2.4 or later, this is " + detectedPostfix.version
+	}
+	return ""
+}
+
+// deliverSelected is 'i': the selected message, or the one the details
+// show, is delivered now.
+func (m *model) deliverSelected() tea.Cmd {
+	if m.refuseReadOnly() || len(m.entries) == 0 {
+		return nil
+	}
+	if err := m.targetDisplayed(); err != nil {
+		m.status = err.Error()
+		return nil
+	}
+	if reason := m.deliverFallback(); reason != "" {
+		return m.deliverByRequeue(reason)
+	}
+	e := m.entries[m.selected]
+	switch e.Queue {
+	case "deferred":
+	case "hold":
+		m.status = e.ID + " is on hold, release it with 'u' first"
+		return nil
+	case "active", "incoming":
+		m.status = e.ID + " is in the " + e.Queue + " queue, it is being delivered already"
+		return nil
+	default:
+		m.status = fmt.Sprintf("%s is in the %s queue, postqueue -i only schedules deferred messages", e.ID, e.Queue)
+		return nil
+	}
+	ctx, cancel := commandContext(context.Background(), "postqueue")
+	cmd := backend.deliver(ctx, e.Host, e.ID)
+	out, err := runCombinedOutput(cmd)
+	err = commandError(ctx, cmd, err, out)
+	cancel()
+	var ce *cmdError
+	if errors.As(err, &ce) && ce.exitCode() == exUsage {
+		m.noDeliver = "postqueue -i failed: " + firstLine(ce.output)
+		if ce.output == "" {
+			m.noDeliver = fmt.Sprintf("postqueue -i failed with exit status %d", ce.exitCode())
+		}
+		logger.Warn("immediate delivery not available, requeueing instead", "id", e.ID, "err", err)
+		return m.deliverByRequeue(m.noDeliver)
+	}
+	if err != nil {
+		return m.handleError(err)
+	}
+	logger.Info("delivery scheduled", "id", e.ID, "host", e.Host)
+	m.recordChange(actionRequeue, 1)
+	m.status = e.ID + " scheduled for delivery now (postqueue -i)"
+	return tea.Batch(runMailqCmd, m.cfg.Hook.run(actionRequeue, e))
+}
+
+// deliverByRequeue requeues the selected message in place of postqueue -i,
+// saying why in the status line.
+func (m *model) deliverByRequeue(reason string) tea.Cmd {
+	m.status = reason + "; requeueing " + m.entries[m.selected].ID + " instead"
+	return m.requestAction(actionRequeue)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// alt+r groups the queue by the destination its deferral reason names,
+// the relay or MX that did not take the message ("connect to
+// mx.example.com[192.0.2.1]:25: Connection timed out"), so one broken
+// destination shows up as one row with hundreds of messages. Reasons
+// without a host go into an "unknown" row. The reason is the first delay
+// reason of the message's recipients as listed; postqueue -j gives one per
+// recipient, mailq one per message.
+
+// destUnknown is the row, and the dest: value, of messages whose reason
+// names no destination.
+const destUnknown = "unknown"
+
+// destPatterns find the host in the deferral reasons of the Postfix SMTP
+// client and of the DNS lookups before it.
+var destPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:connect to|host|with) ([a-z0-9][a-z0-9.-]*)\[`),
+	regexp.MustCompile(`(?i)\bname=([a-z0-9][a-z0-9.-]*) type=`),
+	regexp.MustCompile(`(?i)\bdomain ([a-z0-9][a-z0-9.-]*) not found`),
+}
+
+// reasonDestination returns the host a deferral reason names, lower case,
+// or destUnknown.
+func reasonDestination(reason string) string {
+	for _, re := range destPatterns {
+		if m := re.FindStringSubmatch(reason); m != nil {
+			return strings.ToLower(strings.TrimSuffix(m[1], "."))
+		}
+	}
+	return destUnknown
+}
+
+// destinationTable is the table in the details pane.
+type destinationTable struct {
+	rows   []destinationRow
+	cursor int
+}
+
+// destinationRow is the deferred messages of one destination.
+type destinationRow struct {
+	dest   string
+	ids    []string
+	size   int64
+	reason string // the most frequent reason, with its count
+	count  int
+}
+
+// openDestinations shows the destinations of the deferred messages.
+func (m *model) openDestinations() {
+	m.destView = &destinationTable{}
+	m.subjectView = nil
+	m.hdrCompare = nil
+	m.focus = 1
+	m.buildDestinations()
+	m.showDestinations()
+}
+
+// buildDestinations groups the messages with a reason by destination, the
+// largest groups first and unknown last.
+func (m *model) buildDestinations() {
+	v := m.destView
+	byDest := map[string]*destinationRow{}
+	reasons := map[string]map[string]int{}
+	for _, e := range m.allEntries {
+		if e.Reason == "" {
+			continue
+		}
+		dest := reasonDestination(e.Reason)
+		r := byDest[dest]
+		if r == nil {
+			r = &destinationRow{dest: dest}
+			byDest[dest] = r
+			reasons[dest] = map[string]int{}
+		}
+		r.ids = append(r.ids, e.ID)
+		r.size += e.Size
+		reasons[dest][e.Reason]++
+	}
+	v.rows = v.rows[:0]
+	for dest, r := range byDest {
+		for reason, n := range reasons[dest] {
+			if n > r.count || n == r.count && reason < r.reason {
+				r.reason, r.count = reason, n
+			}
+		}
+		v.rows = append(v.rows, *r)
+	}
+	slices.SortFunc(v.rows, func(a, b destinationRow) int {
+		switch {
+		case (a.dest == destUnknown) != (b.dest == destUnknown):
+			if a.dest == destUnknown {
+				return 1
+			}
+			return -1
+		case len(a.ids) != len(b.ids):
+			return len(b.ids) - len(a.ids)
+		}
+		return strings.Compare(a.dest, b.dest)
+	})
+	v.cursor = min(v.cursor, max(len(v.rows)-1, 0))
+}
+
+// showDestinations renders the table in the details pane.
+func (m *model) showDestinations() {
+	v := m.destView
+	var sb strings.Builder
+	sb.WriteString("Destinations of the deferred messages — up/down choose one, enter selects its messages, / filters them\n\n")
+	for i, r := range v.rows {
+		line := fmt.Sprintf("  %5d× %9s  %s", len(r.ids), formatSize(r.size), r.dest)
+		if i == v.cursor {
+			line = selectedStyle.Render(">" + line[1:])
+		}
+		sb.WriteString(line + "\n")
+		fmt.Fprintf(&sb, "           %d× %s\n", r.count, truncate(r.reason, max(m.right.Width-14, 20)))
+	}
+	if len(v.rows) == 0 {
+		sb.WriteString("  no message has a deferral reason\n")
+	}
+	m.rightRaw = sb.String()
+	m.rightMarks = nil
+	m.right.SetContent(m.rightRaw)
+	if line := 2*v.cursor + 2; line >= m.right.YOffset+m.right.Height || line < m.right.YOffset {
+		m.right.SetYOffset(line - m.right.Height/2)
+	}
+}
+
+// updateDestinations handles the details-pane keys while the table is
+// shown.
+func (m *model) updateDestinations(key string) (bool, tea.Cmd) {
+	v := m.destView
+	switch key {
+	case "up":
+		v.cursor = max(v.cursor-1, 0)
+	case "down":
+		v.cursor = min(v.cursor+1, max(len(v.rows)-1, 0))
+	case "enter", "/":
+		if v.cursor >= len(v.rows) {
+			return true, nil
+		}
+		r := v.rows[v.cursor]
+		m.destView = nil
+		if key == "/" {
+			f, _, err := parseFilterInput("dest:" + r.dest)
+			if err != nil {
+				m.status = err.Error()
+				return true, nil
+			}
+			m.focus = 0
+			return true, m.applyPromptInput(f, "")
+		}
+		return true, m.selectIDs(r.ids, fmt.Sprintf("selected the %d messages for %s, d/h/u/r act on all of them", len(r.ids), r.dest))
+	default:
+		return false, nil
+	}
+	m.showDestinations()
+	return true, nil
+}
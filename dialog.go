@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// A confirmation dialog is a question over the panes and the keys that
+// answer it. The model holds at most one, in m.dialog; while it is open it
+// gets every key, and keys it does not list do nothing or go to what it
+// types into, such as the "yes" of a bulk requeue or the cleanup wizard's
+// criteria.
+
+// dialogOption is an answer of a dialog.
+type dialogOption struct {
+	keys []string               // lower case; an upper case key counts as its lower case one
+	run  func(m *model) tea.Cmd // nil only closes the dialog
+	stay bool                   // the dialog stays open, e.g. behind a popup run opened
+}
+
+// confirmDialog is an open dialog.
+type confirmDialog struct {
+	question func(m model) string // rendered anew each time, answers may change it
+	width    int                  // of the box, 0 to fit the question
+	options  []dialogOption
+	typed    func(m *model, msg tea.KeyMsg) tea.Cmd // the keys options do not list; nil drops them
+}
+
+// updateDialog answers the open dialog with the key of msg.
+func (m model) updateDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := strings.ToLower(msg.String())
+	for _, o := range m.dialog.options {
+		if !slices.Contains(o.keys, key) {
+			continue
+		}
+		if !o.stay {
+			m.dialog = nil
+		}
+		if o.run == nil {
+			return m, nil
+		}
+		return m, o.run(&m)
+	}
+	if m.dialog.typed != nil {
+		return m, m.dialog.typed(&m, msg)
+	}
+	return m, nil
+}
+
+// dialogView renders the open dialog centered on the screen.
+func (m model) dialogView() string {
+	width := m.dialog.width
+	if width <= 0 {
+		width = dialogBoxStyle.GetWidth()
+	}
+	return m.dialogBox(width, lipgloss.Center, m.dialog.question(m))
+}
+
+// openActionDialog asks whether to run confirmAction on the selected
+// message, the confirmIDs or the whole queue. A bulk delete shows its
+// preview first, unless bulk.preview is off.
+func (m *model) openActionDialog() tea.Cmd {
+	m.dialog = &confirmDialog{
+		question: model.actionQuestion,
+		options: []dialogOption{
+			{keys: []string{"y"}, run: (*model).confirmYes},
+			{keys: []string{"v"}, stay: true, run: func(m *model) tea.Cmd {
+				if m.confirmIDs != nil {
+					return m.openBulkPreview()
+				}
+				return nil
+			}},
+			{keys: []string{"s"}, stay: true, run: func(m *model) tea.Cmd {
+				if !m.skipActiveTargets() {
+					m.cancelAction()
+				}
+				return nil
+			}},
+			{keys: []string{"n", "enter", "esc", "ctrl+c"}, run: func(m *model) tea.Cmd {
+				m.cancelAction()
+				return nil
+			}},
+		},
+	}
+	if m.cfg.Bulk.Preview && m.confirmIDs != nil && (m.confirmAction == actionDelete || m.confirmTrash) {
+		return m.openBulkPreview()
+	}
+	return nil
+}
+
+// cancelAction closes the action dialog without running anything.
+func (m *model) cancelAction() {
+	m.dialog = nil
+	m.confirmIDs, m.confirmSummary, m.confirmTrash = nil, "", false
+	m.confirmEntry, m.confirmShown = queueEntry{}, ""
+}
+
+// actionQuestion is the question of the action dialog.
+func (m model) actionQuestion() string {
+	verb := m.confirmAction.String()
+	if m.confirmTrash {
+		verb = "move to the trash (hold)"
+	}
+	question := "really " + verb + " [y/N]?"
+	switch {
+	case m.confirmEntry.ID != "":
+		question = fmt.Sprintf("really %s %s (%s) [y/N]?", verb, m.confirmEntry.ID, truncate(m.confirmEntry.senderLabel(), 30))
+	case m.confirmShown != "":
+		question = fmt.Sprintf("really %s all %d shown messages (%s) [y/N]? v previews", verb, len(m.confirmIDs), m.confirmShown)
+	case m.confirmSummary != "":
+		question = fmt.Sprintf("really %s the selection (%s) [y/N]? v previews", verb, m.confirmSummary)
+	case m.confirmIDs != nil:
+		question = fmt.Sprintf("really %s %d messages [y/N]? v previews", verb, len(m.confirmIDs))
+	case m.confirmAction == actionFlush:
+		question = "really flush the whole queue [y/N]?"
+	case m.confirmAction == actionClearCorrupt:
+		question = "really delete every file of the corrupt queue [y/N]?"
+	}
+	if warning := m.activeWarning(); warning != "" {
+		question += "\n" + warning
+	}
+	if warning := m.protectedWarning(); warning != "" {
+		question += "\n" + warning
+	}
+	return question
+}
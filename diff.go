@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// diffMaxLines is the size up to which whole messages are diffed; larger
+// ones are compared on their header sections only.
+const diffMaxLines = 1500
+
+var (
+	diffDelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	diffAddStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+)
+
+// diffMsg carries the postcat outputs of two entries to compare.
+type diffMsg struct {
+	a, b         string // queue IDs
+	textA, textB string
+	err          error
+}
+
+// markForDiff remembers the selected entry; on the second entry it loads
+// both and shows their diff in the details pane.
+func (m *model) markForDiff() tea.Cmd {
+	e := m.entries[m.selected]
+	id := e.ID
+	switch {
+	case m.diffFirst == nil:
+		m.diffFirst = &e
+		m.status = fmt.Sprintf("%s marked for diff, press = on another message", id)
+		return nil
+	case m.diffFirst.ID == id && m.diffFirst.Host == e.Host:
+		m.diffFirst = nil
+		m.status = "diff mark removed"
+		return nil
+	}
+	a, hostA, hostB := m.diffFirst.ID, m.diffFirst.Host, e.Host
+	m.diffFirst = nil
+	m.rightRaw = "Loading both messages…"
+	m.right.SetContent(m.rightRaw)
+	return m.pool.submit(id, prioSelected, func(ctx context.Context) tea.Msg {
+		ctx, cancel := commandContext(ctx, "postcat")
+		defer cancel()
+		msg := diffMsg{a: a, b: id}
+		var out []byte
+		cmd := backend.show(ctx, hostA, a, false)
+		if out, msg.err = runOutput(cmd); msg.err != nil {
+			msg.err = commandError(ctx, cmd, msg.err, nil)
+			return msg
+		}
+		msg.textA = string(out)
+		cmd = backend.show(ctx, hostB, id, false)
+		if out, msg.err = runOutput(cmd); msg.err != nil {
+			msg.err = commandError(ctx, cmd, msg.err, nil)
+			return msg
+		}
+		msg.textB = string(out)
+		return msg
+	})
+}
+
+// showDiff renders msg side by side in the details pane.
+func (m *model) showDiff(msg diffMsg) {
+	if msg.err != nil {
+		m.status = "diff: " + firstLine(msg.err.Error())
+		return
+	}
+	a, b := diffBody(msg.textA), diffBody(msg.textB)
+	notice := ""
+	if len(a) > diffMaxLines || len(b) > diffMaxLines {
+		a, b = diffHeaderLines(a), diffHeaderLines(b)
+		notice = warningStyle.Render("Large messages: only the headers are compared.") + "\n\n"
+	}
+	ops := diffLines(a, b)
+	changed := 0
+	for _, op := range ops {
+		if op.kind != ' ' {
+			changed++
+		}
+	}
+
+	half := maxInt((m.right.Width-3)/2, 10)
+	var sb strings.Builder
+	sb.WriteString(notice)
+	sb.WriteString(padRight(cutWidth(msg.a, half), half) + " │ " + cutWidth(msg.b, half) + "\n")
+	sb.WriteString(strings.Repeat("─", half) + "─┼─" + strings.Repeat("─", half) + "\n")
+	for _, op := range ops {
+		left, right := padRight(cutWidth(op.a, half), half), cutWidth(op.b, half)
+		switch op.kind {
+		case '-':
+			left, right = diffDelStyle.Render(left), ""
+		case '+':
+			left, right = strings.Repeat(" ", half), diffAddStyle.Render(right)
+		}
+		sb.WriteString(left + " │ " + right + "\n")
+	}
+	m.rightRaw = sb.String()
+	m.rightMarks = nil
+	m.right.SetContent(m.rightRaw)
+	m.right.GotoTop()
+	m.status = fmt.Sprintf("diff %s ↔ %s: %d lines differ, select a message to leave", msg.a, msg.b, changed)
+}
+
+// diffBody returns the lines of a postcat output without the envelope
+// records, which differ in every message (queue ID, times).
+func diffBody(postcat string) []string {
+	if i := strings.Index(postcat, "*** MESSAGE CONTENTS"); i >= 0 {
+		postcat = postcat[i:]
+		if nl := strings.IndexByte(postcat, '\n'); nl >= 0 {
+			postcat = postcat[nl+1:]
+		}
+	}
+	return strings.Split(strings.TrimRight(postcat, "\n"), "\n")
+}
+
+// diffHeaderLines cuts lines after the header section.
+func diffHeaderLines(lines []string) []string {
+	for i, l := range lines {
+		if l == "" {
+			return lines[:i]
+		}
+	}
+	return lines
+}
+
+// diffOp is one line of a diff: ' ' in both, '-' only in a, '+' only in b.
+type diffOp struct {
+	kind rune
+	a, b string
+}
+
+// diffLines computes a line diff from the longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], b[j]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i], ""})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', "", b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i], ""})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', "", b[j]})
+	}
+	return ops
+}
+
+// cutWidth shortens s to at most width cells.
+func cutWidth(s string, width int) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	if r := []rune(s); len(r) > width {
+		s = string(r[:width])
+	}
+	for lipgloss.Width(s) > width {
+		r := []rune(s)
+		s = string(r[:len(r)-1])
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// A full spool is a common cause of queue trouble, so the title bar shows
+// how much space and how many inodes are free on the file system of the
+// local queue_directory, read with statfs at every listing. The figures
+// turn into a warning below disk_warn percent; when the directory cannot
+// be read (-hosts, another mail server, no access) they are left out.
+
+var diskWarnStyle = lipgloss.NewStyle().Bold(true).
+	Background(colorBadge).Foreground(colorBadgeText)
+
+// diskUsage is what is free on the file system of the queue.
+type diskUsage struct {
+	known      bool
+	freePct    int // of the blocks, as available to unprivileged users
+	inodesPct  int // of the inodes, -1 when the file system has no fixed number
+	freeBlocks uint64
+}
+
+// statQueueDisk reads the free space of the local queue directory.
+func statQueueDisk() diskUsage {
+	dir := detectedPostfix.queueDir
+	if dir == "" {
+		return diskUsage{}
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil || st.Blocks == 0 {
+		if err != nil {
+			logger.Debug("queue directory not stat'ed", "dir", dir, "err", err)
+		}
+		return diskUsage{}
+	}
+	u := diskUsage{
+		known:      true,
+		freePct:    int(uint64(st.Bavail) * 100 / uint64(st.Blocks)),
+		inodesPct:  -1,
+		freeBlocks: uint64(st.Bavail) * uint64(st.Bsize),
+	}
+	if st.Files > 0 {
+		u.inodesPct = int(uint64(st.Ffree) * 100 / uint64(st.Files))
+	}
+	return u
+}
+
+// low reports whether space or inodes are below warn percent.
+func (u diskUsage) low(warn int) bool {
+	return u.known && (u.freePct < warn || u.inodesPct >= 0 && u.inodesPct < warn)
+}
+
+// String renders the usage for the title bar, e.g. "spool 42% free
+// (12.3 GiB), inodes 87%".
+func (u diskUsage) String() string {
+	s := fmt.Sprintf("spool %d%% free (%s)", u.freePct, formatSize(int64(u.freeBlocks)))
+	if u.inodesPct >= 0 {
+		s += fmt.Sprintf(", inodes %d%%", u.inodesPct)
+	}
+	return s
+}
+
+// diskBadge renders the usage for the title bar, "" when it is unknown.
+func (m model) diskBadge() string {
+	if !m.disk.known {
+		return ""
+	}
+	if m.disk.low(m.cfg.DiskWarn) {
+		return diskWarnStyle.Render(" " + m.disk.String() + " ")
+	}
+	return titleBarStyle.Render(" " + m.disk.String() + " ")
+}
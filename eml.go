@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// 'E' saves the selected message as an .eml file that mail clients and
+// analysis tools open: the headers and the body as the message was
+// received, with CRLF line endings. postcat's envelope records are left
+// out; the queue ID, the envelope sender and the recipients go into
+// X-Postdel-* headers in front instead.
+
+// emlMsg reports the file written for a message, or the error.
+type emlMsg struct {
+	id, path string
+	err      error
+}
+
+// openExportPrompt asks where to save the selected message.
+func (m *model) openExportPrompt() {
+	if m.selected >= len(m.entries) {
+		return
+	}
+	id := m.entries[m.selected].ID
+	m.exportInput = textinput.New()
+	m.exportInput.Prompt = "save " + id + " as: "
+	m.exportInput.SetValue(id + ".eml")
+	m.exportInput.Focus()
+	m.exportID = id
+}
+
+// updateExportPrompt handles keys while the prompt is open.
+func (m model) updateExportPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.exportID = ""
+		return m, nil
+	case "enter":
+		path := emlPath(strings.TrimSpace(m.exportInput.Value()), m.exportID)
+		cmd := m.exportEML(m.exportID, path)
+		m.exportID = ""
+		return m, cmd
+	}
+	var cmd tea.Cmd
+	m.exportInput, cmd = m.exportInput.Update(msg)
+	return m, cmd
+}
+
+// emlPath makes the path typed for message id one of an .eml file: a
+// directory gets the ID as file name, other names the extension.
+func emlPath(path, id string) string {
+	if path == "" {
+		return id + ".eml"
+	}
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return filepath.Join(path, id+".eml")
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".eml") {
+		path += ".eml"
+	}
+	return path
+}
+
+// exportEML reads the whole message id and writes it to path.
+func (m *model) exportEML(id, path string) tea.Cmd {
+	var entry queueEntry
+	for _, e := range m.entries {
+		if e.ID == id {
+			entry = e
+		}
+	}
+	m.status = "saving " + id + "…"
+	return m.pool.submit("eml "+id, prioSelected, func(ctx context.Context) tea.Msg {
+		ctx, cancel := commandContext(ctx, "postcat")
+		defer cancel()
+		out, err := runOutput(backend.show(ctx, entry.Host, id, false))
+		if err != nil {
+			return emlMsg{id: id, err: err}
+		}
+		eml, err := emlOf(entry, string(out))
+		if err != nil {
+			return emlMsg{id: id, err: err}
+		}
+		if err := os.WriteFile(path, []byte(eml), 0o600); err != nil {
+			return emlMsg{id: id, err: err}
+		}
+		return emlMsg{id: id, path: path}
+	})
+}
+
+// messageOf takes the headers and the body of the message out of the
+// output of the backend's show command.
+func messageOf(out string) (headers, body string, err error) {
+	headers, body, ok := splitMessage(out)
+	if !ok {
+		if backend.name == "postfix" {
+			return "", "", fmt.Errorf("no message contents in the postcat output")
+		}
+		// the other servers print the message as it is
+		headers, body, _ = strings.Cut(out, "\n\n")
+		headers += "\n"
+	}
+	return headers, body, nil
+}
+
+// emlOf turns the output of the backend's show command for e into the
+// message as an .eml file.
+func emlOf(e queueEntry, out string) (string, error) {
+	headers, body, err := messageOf(out)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString("X-Postdel-Queue-ID: " + e.ID + "\n")
+	sb.WriteString("X-Postdel-Envelope-From: <" + e.Sender + ">\n")
+	for _, r := range e.Recipients {
+		sb.WriteString("X-Postdel-Envelope-To: <" + r + ">\n")
+	}
+	sb.WriteString(headers + "\n" + body)
+	text := normalizeLineEndings(sb.String(), lineEndingsLF)
+	return strings.ReplaceAll(text, "\n", "\r\n"), nil
+}
+
+// reportEML shows where msg's message went.
+func (m *model) reportEML(msg emlMsg) {
+	if msg.err != nil {
+		m.status = "cannot save " + msg.id + ": " + msg.err.Error()
+		return
+	}
+	if abs, err := filepath.Abs(msg.path); err == nil {
+		msg.path = abs
+	}
+	m.status = "saved " + msg.id + " to " + msg.path
+}
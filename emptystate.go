@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// When the list shows nothing, the panes say why instead of staying blank:
+// the list the reason and how to get messages back, the details the counts
+// of the last listing. They follow every new listing like the list does.
+
+// emptyStateStyle is the text of the empty panes.
+var emptyStateStyle = lipgloss.NewStyle().Foreground(colorMuted).Align(lipgloss.Center)
+
+// emptyReason tells why the list shows no message.
+func (m model) emptyReason() string {
+	switch {
+	case len(m.allEntries) == 0:
+		return "Mail queue is empty"
+	case m.filter.active():
+		return fmt.Sprintf("no entries match filter '%s' — press esc to clear", truncate(m.filter.expr, filterShown))
+	case m.actionableOnly:
+		return "no message you can act on — A shows all"
+	}
+	return "no message in the shown queues — 1-6 show the others"
+}
+
+// emptyList renders the list pane of the empty state.
+func (m model) emptyList() string {
+	text := emptyStateStyle.Width(m.left.Width).Render(m.emptyReason())
+	return lipgloss.Place(m.left.Width, m.left.Height, lipgloss.Center, lipgloss.Center, text)
+}
+
+// emptyDetails renders the details pane of the empty state: the counts per
+// queue of the last listing and when it was taken.
+func (m model) emptyDetails() string {
+	counts := map[string]int{}
+	for _, e := range m.allEntries {
+		counts[e.Queue]++
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d messages in the queue\n\n", len(m.allEntries))
+	for _, q := range queueNames {
+		fmt.Fprintf(&sb, "%-9s %6d\n", q, counts[q])
+	}
+	if !m.listedAt.IsZero() {
+		fmt.Fprintf(&sb, "\nlisted at %s (%s ago)", m.listedAt.Format("15:04:05"), formatAge(time.Since(m.listedAt)))
+	}
+	width := m.right.Width + minimapWidth
+	text := emptyStateStyle.Width(width).Render(sb.String())
+	return lipgloss.Place(width, m.right.Height, lipgloss.Center, lipgloss.Center, text)
+}
+
+// clearEmptyFilter drops the filter that left the list empty and loads the
+// message that is selected then.
+func (m *model) clearEmptyFilter() tea.Cmd {
+	m.setFilter(listFilter{})
+	if len(m.entries) == 0 {
+		return nil
+	}
+	return m.runPostcatCmd(m.entries[m.selected].ID)
+}
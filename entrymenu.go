@@ -0,0 +1,103 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// enter in the list opens a menu of what can be done with the selected
+// message, for those who do not remember the keys. Its entries are the
+// commands of the registry marked menu, so it lists what the keys do and
+// runs them the same way, on the message the menu was opened on even if a
+// listing moved it; what cannot run right now is dimmed with the reason,
+// like in the palette.
+
+// entryMenu is the open menu.
+type entryMenu struct {
+	id       string // the message it was opened on
+	selected int
+}
+
+// menuCommands returns the commands the entry menu offers.
+func menuCommands() []command {
+	var menu []command
+	for _, c := range commands {
+		if c.menu {
+			menu = append(menu, c)
+		}
+	}
+	return menu
+}
+
+// openEntryMenu opens the menu on the selected message.
+func (m *model) openEntryMenu() {
+	if m.selected >= len(m.entries) {
+		return
+	}
+	m.entryMenu = &entryMenu{id: m.entries[m.selected].ID}
+}
+
+// updateEntryMenu handles keys while the menu is open: up/down choose,
+// enter or the command's own key run it, esc closes.
+func (m *model) updateEntryMenu(key string) tea.Cmd {
+	menu := menuCommands()
+	switch key {
+	case "esc", "ctrl+c", "q":
+		m.entryMenu = nil
+		return nil
+	case "up", "k":
+		m.entryMenu.selected = (m.entryMenu.selected + len(menu) - 1) % len(menu)
+		return nil
+	case "down", "j":
+		m.entryMenu.selected = (m.entryMenu.selected + 1) % len(menu)
+		return nil
+	}
+	var chosen command
+	if key == "enter" {
+		chosen = menu[m.entryMenu.selected]
+	} else if c, ok := commandForKey(key); ok && c.menu {
+		chosen = c
+	} else {
+		return nil
+	}
+	if reason := m.disabledReason(chosen); reason != "" {
+		m.status = reason
+		return nil
+	}
+	id := m.entryMenu.id
+	m.entryMenu = nil
+	// a listing may have come in while the menu was open
+	i := slices.IndexFunc(m.entries, func(e queueEntry) bool { return e.ID == id })
+	if i < 0 {
+		m.status = id + " is no longer listed, nothing was done"
+		return nil
+	}
+	if i != m.selected {
+		m.selected = i
+		m.syncLeft()
+	}
+	return m.runCommand(chosen)
+}
+
+// entryMenuView renders the menu centered on the screen.
+func (m model) entryMenuView() string {
+	var sb strings.Builder
+	sb.WriteString(m.entryMenu.id + "\n\n")
+	for i, c := range menuCommands() {
+		line := padRight(c.title, 42) + " " + c.keys[0]
+		if reason := m.disabledReason(c); reason != "" {
+			line = disabledStyle.Render(line + "  (" + reason + ")")
+		}
+		if i == m.entryMenu.selected {
+			line = selectedStyle.Render("> ") + line
+		} else {
+			line = "  " + line
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\nenter runs, esc closes")
+	return m.dialogBox(min(90, m.termWidth-4), lipgloss.Center, sb.String())
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Every error of the session is kept in a log of the last errorLogSize,
+// with the time, the command and its whole output, because the status line
+// shows only the newest. ctrl+e lists them in a popup, newest first;
+// enter shows the output of one.
+
+// errorLogSize is how many errors the log keeps.
+const errorLogSize = 100
+
+// loggedError is an entry of the error log.
+type loggedError struct {
+	at      time.Time
+	command string // the command line, "" when the error came from elsewhere
+	text    string // the error without the output
+	output  string
+}
+
+// errorLogView is the open popup.
+type errorLogView struct {
+	selected int // index into the log from its end, 0 is the newest
+	expanded bool
+	view     viewport.Model
+}
+
+// logError appends err to the error log, dropping the oldest entry when
+// the log is full.
+func (m *model) logError(err error) {
+	e := loggedError{at: time.Now(), text: err.Error()}
+	var ce *cmdError
+	if errors.As(err, &ce) {
+		e.command = strings.Join(ce.args, " ")
+		e.output = ce.output
+		short := *ce
+		short.output = ""
+		e.text = short.Error()
+	}
+	if len(m.errLog) == errorLogSize {
+		m.errLog = m.errLog[1:]
+	}
+	m.errLog = append(m.errLog, e)
+	if m.errLogView != nil {
+		m.errLogView.selected++
+		m.renderErrorLog()
+	}
+}
+
+// errorLogHint is appended to the status of an error when there are
+// earlier ones to look at.
+func (m model) errorLogHint() string {
+	if len(m.errLog) < 2 {
+		return ""
+	}
+	return fmt.Sprintf(" (ctrl+e: all %d errors)", len(m.errLog))
+}
+
+// openErrorLog opens the popup on the newest error.
+func (m *model) openErrorLog() {
+	if len(m.errLog) == 0 {
+		m.status = "no errors in this session"
+		return
+	}
+	width := max(min(m.termWidth-8, 120), 20)
+	height := max(m.termHeight-10, 3)
+	m.errLogView = &errorLogView{view: m.popupViewport(width, height)}
+	m.renderErrorLog()
+}
+
+// renderErrorLog fills the popup: one line per error, the selected one
+// with its command and, expanded, its output.
+func (m *model) renderErrorLog() {
+	v := m.errLogView
+	v.selected = min(v.selected, len(m.errLog)-1)
+	var lines []string
+	top := 0
+	for i := len(m.errLog) - 1; i >= 0; i-- {
+		e := m.errLog[i]
+		line := truncate(e.at.Format("15:04:05")+"  "+e.text, v.view.Width-2)
+		if len(m.errLog)-1-i != v.selected {
+			lines = append(lines, "  "+line)
+			continue
+		}
+		top = len(lines)
+		lines = append(lines, selectedStyle.Render("> "+line))
+		if e.command != "" {
+			lines = append(lines, "    $ "+truncate(e.command, v.view.Width-6))
+		}
+		switch {
+		case !v.expanded && e.output != "":
+			lines = append(lines, "    (enter shows the output)")
+		case v.expanded && e.output == "":
+			lines = append(lines, "    (no output)")
+		case v.expanded:
+			out := lipgloss.NewStyle().Width(v.view.Width - 4).Render(e.output)
+			for _, l := range strings.Split(out, "\n") {
+				lines = append(lines, "    "+l)
+			}
+		}
+	}
+	v.view.SetContent(strings.Join(lines, "\n"))
+	if top < v.view.YOffset || top >= v.view.YOffset+v.view.Height {
+		v.view.SetYOffset(top)
+	}
+}
+
+// updateErrorLog handles a key while the popup is open.
+func (m *model) updateErrorLog(key string) {
+	v := m.errLogView
+	switch key {
+	case "up", "k":
+		if v.selected > 0 {
+			v.selected, v.expanded = v.selected-1, false
+		}
+	case "down", "j":
+		if v.selected < len(m.errLog)-1 {
+			v.selected, v.expanded = v.selected+1, false
+		}
+	case "pgup":
+		v.view.HalfViewUp()
+		return
+	case "pgdown":
+		v.view.HalfViewDown()
+		return
+	case "enter":
+		v.expanded = !v.expanded
+	case "esc", "q", "ctrl+e":
+		m.errLogView = nil
+		return
+	}
+	m.renderErrorLog()
+}
+
+// errorLogPopup renders the popup centered over the panes.
+func (m model) errorLogPopup() string {
+	title := fmt.Sprintf("%d errors, newest first — up/down choose, enter output, esc closes", len(m.errLog))
+	return m.dialogBox(m.errLogView.view.Width+4, lipgloss.Center, title+"\n\n"+m.errLogView.view.View())
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Kinds of command failures. The UI reacts to them differently, see
+// handleError.
+var (
+	ErrBinaryMissing = errors.New("program not found")
+	ErrPermission    = errors.New("permission denied")
+	ErrNotFound      = errors.New("queue file not found")
+	ErrTimeout       = errors.New("timed out")
+	ErrParse         = errors.New("unexpected output")
+	ErrUnavailable   = errors.New("mail system not running")
+)
+
+// Exit statuses of sendmail-compatible programs (sysexits.h) that postqueue
+// and mailq use when the mail system cannot be reached, not because the
+// command was wrong.
+const (
+	exUnavailable = 69 // EX_UNAVAILABLE
+	exTempFail    = 75 // EX_TEMPFAIL
+)
+
+// cmdError is a failed external command together with its classification.
+// errors.Is(err, ErrPermission) and friends match on the kind.
+type cmdError struct {
+	args   []string
+	kind   error // one of the Err* kinds, nil if unclassified
+	err    error
+	output string
+}
+
+func (e *cmdError) Error() string {
+	msg := fmt.Sprintf("error running %s: %v", strings.Join(e.args, " "), e.err)
+	if e.kind != nil {
+		msg = fmt.Sprintf("error running %s: %v (%v)", strings.Join(e.args, " "), e.kind, e.err)
+	}
+	if e.output != "" {
+		msg += "\nOutput:\n" + e.output
+	}
+	return msg
+}
+
+func (e *cmdError) Unwrap() error { return e.err }
+
+// program is the Postfix program that failed, also when it ran on another
+// host through ssh (see hostCommand).
+func (e *cmdError) program() string {
+	if len(e.args) == 0 {
+		return ""
+	}
+	if e.args[0] == "ssh" {
+		for i, a := range e.args {
+			if a == "--" && i+1 < len(e.args) {
+				return filepath.Base(strings.Trim(e.args[i+1], "'"))
+			}
+		}
+	}
+	return e.args[0]
+}
+
+func (e *cmdError) Is(target error) bool { return e.kind != nil && target == e.kind }
+
+// exitCode is the exit status of the command, -1 if it did not exit.
+func (e *cmdError) exitCode() int {
+	var exitErr *exec.ExitError
+	if errors.As(e.err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// commandError wraps the error of cmd and classifies it by the error itself
+// and by what the command wrote (stderr, or the combined output). A command
+// killed because ctx ran out of time is an ErrTimeout.
+func commandError(ctx context.Context, cmd *exec.Cmd, err error, output []byte) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &cmdError{args: cmd.Args, kind: ErrTimeout, err: errors.New("killed, took longer than the configured timeout")}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(output) == 0 {
+		output = exitErr.Stderr
+	}
+	text := strings.TrimSpace(string(output))
+	return &cmdError{args: cmd.Args, kind: classify(err, text), err: err, output: text}
+}
+
+// classify maps a command error and its diagnostics to a failure kind.
+func classify(err error, output string) error {
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		// exec could not start the program at all
+		return ErrBinaryMissing
+	case errors.Is(err, fs.ErrPermission):
+		return ErrPermission
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case exUnavailable, exTempFail:
+			return ErrUnavailable
+		}
+	}
+	lower := strings.ToLower(output)
+	switch {
+	case strings.Contains(lower, "permission denied"),
+		strings.Contains(lower, "operation not permitted"),
+		strings.Contains(lower, "must be run by the super-user"),
+		strings.Contains(lower, "not owned by"):
+		return ErrPermission
+	case strings.Contains(lower, "no such file or directory"),
+		strings.Contains(lower, "no message file found"),
+		strings.Contains(lower, "not found in queue"):
+		return ErrNotFound
+	case strings.Contains(lower, "timed out"), strings.Contains(lower, "timeout"):
+		return ErrTimeout
+	case strings.Contains(lower, "mail system is down"):
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// actedOnNothing reports a single action that found nothing to act on: the
+// message left the queue since it was listed (delivered, expired or
+// removed by someone else), or for hold and release already was in the
+// queue it was to go to. The list is refreshed.
+func (m *model) actedOnNothing(a action, id string) tea.Cmd {
+	logger.Info("action found nothing to do", "action", a, "id", id)
+	switch a {
+	case actionHold:
+		m.status = id + " was not put on hold: already held, or gone from the queue"
+	case actionRelease:
+		m.status = id + " was not released: not on hold, or gone from the queue"
+	default:
+		m.status = id + ": message already gone from the queue, nothing to " + a.String()
+	}
+	return runMailqCmd
+}
+
+// countSum adds up the counts of an MTA's count function.
+func countSum(counts map[string]int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
+
+// mailSystemDown explains an ErrUnavailable to the user.
+func mailSystemDown(err error) string {
+	var ce *cmdError
+	if errors.As(err, &ce) && ce.exitCode() == exTempFail {
+		return backend.title + " is temporarily unavailable — ctrl+l lists the queue again"
+	}
+	return backend.title + " does not appear to be running — start it, then ctrl+l lists the queue again"
+}
+
+// handleError decides how the session continues after err: missing
+// programs and permission problems end on the error screen, vanished queue
+// files just refresh the list, timeouts retry the listing and everything
+// else is reported in the status line. A failed listing is always fatal,
+// whichever listing command failed and however, since listing again would
+// only repeat it; unless the mail system is merely down, then ctrl+l lists
+// again once it is started. on_error runs for every one of them.
+func (m *model) handleError(err error) tea.Cmd {
+	logger.Error("command failed", "err", err)
+	m.logError(err)
+	return tea.Batch(m.continueAfter(err), m.errorHook(err))
+}
+
+// continueAfter is the part of handleError that depends on err.
+func (m *model) continueAfter(err error) tea.Cmd {
+	var ce *cmdError
+	switch {
+	case errors.Is(err, ErrTimeout) && errors.As(err, &ce) && ce.program() == "postcat":
+		// retrying would most likely time out again; leave it to the user
+		m.rightRaw = fmt.Sprintf("Loading timed out after %s, postcat was stopped.\n\nPress 'l' to try again.", commandTimeouts.Postcat)
+		m.rightMarks = nil
+		m.right.SetContent(m.rightRaw)
+		m.status = "loading timed out"
+		return nil
+	case errors.Is(err, ErrUnavailable):
+		m.status = mailSystemDown(err)
+		return nil
+	case errors.As(err, new(listError)), errors.As(err, new(listFailures)):
+		m.err = err
+		return nil
+	case m.readOnlyReason == readOnlyNoPrivileges && errors.Is(err, ErrPermission) && errors.As(err, &ce) && ce.program() == "postcat":
+		return m.explainPostcatDenied(err)
+	case errors.Is(err, ErrBinaryMissing), errors.Is(err, ErrPermission):
+		m.err = err
+		return nil
+	case errors.Is(err, ErrNotFound):
+		m.status = "message already gone from the queue, list refreshed"
+		return runMailqCmd
+	case errors.Is(err, ErrTimeout):
+		m.status = "command timed out, retrying…"
+		return runMailqCmd
+	}
+	m.status = "error: " + firstLine(err.Error()) + m.errorLogHint()
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exitStatus runs a shell exiting with status and returns its error.
+func exitStatus(t *testing.T, status int) error {
+	t.Helper()
+	err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", status)).Run()
+	if err == nil {
+		t.Fatalf("exit %d: no error", status)
+	}
+	return err
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		output string // stderr as the Postfix programs and ssh print it
+		want   error
+	}{
+		{"postcat denied", 1, "postcat: fatal: open queue file 4ABC123DEF: Permission denied", ErrPermission},
+		{"postsuper not root", 1, "postsuper: fatal: postsuper must be run by the super-user", ErrPermission},
+		{"postsuper not permitted", 1, "postsuper: fatal: chdir /var/spool/postfix: Operation not permitted", ErrPermission},
+		{"queue not owned", 1, "postsuper: fatal: queue directory /var/spool/postfix is not owned by postfix", ErrPermission},
+		{"file vanished", 1, "postcat: fatal: open queue file 4ABC123DEF: No such file or directory", ErrNotFound},
+		{"no message file", 1, "postcat: fatal: no message file found for 4ABC123DEF", ErrNotFound},
+		{"not in queue", 1, "postsuper: warning: 4ABC123DEF: not found in queue", ErrNotFound},
+		{"ssh timed out", 255, "ssh: connect to host mx1 port 22: Connection timed out", ErrTimeout},
+		{"other", 1, "postcat: fatal: 4ABC123DEF: unexpected record type: 0", nil},
+		{"no output", 1, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(exitStatus(t, tt.status), tt.output); got != tt.want {
+				t.Errorf("classify(exit %d, %q) = %v, want %v", tt.status, tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyStartFailure(t *testing.T) {
+	err := exec.Command("postdel-test-no-such-program").Run()
+	if got := classify(err, ""); got != ErrBinaryMissing {
+		t.Errorf("missing program: classify = %v, want ErrBinaryMissing", got)
+	}
+	if got := classify(context.DeadlineExceeded, ""); got != ErrTimeout {
+		t.Errorf("deadline: classify = %v, want ErrTimeout", got)
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	if err := commandError(context.Background(), exec.Command("postcat"), nil, nil); err != nil {
+		t.Errorf("no error: got %v", err)
+	}
+
+	cmd := exec.Command("postcat", "-q", "4ABC123DEF")
+	err := commandError(context.Background(), cmd, exitStatus(t, 1), []byte("postcat: fatal: open queue file 4ABC123DEF: Permission denied\n"))
+	var ce *cmdError
+	switch {
+	case !errors.As(err, &ce):
+		t.Fatalf("got %T, want *cmdError", err)
+	case !errors.Is(err, ErrPermission) || errors.Is(err, ErrNotFound):
+		t.Errorf("%v: not classified as ErrPermission only", err)
+	case ce.exitCode() != 1:
+		t.Errorf("exit code %d, want 1", ce.exitCode())
+	case ce.program() != "postcat":
+		t.Errorf("program %q, want postcat", ce.program())
+	case ce.output != "postcat: fatal: open queue file 4ABC123DEF: Permission denied":
+		t.Errorf("output %q", ce.output)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	err = commandError(ctx, exec.Command("postcat"), exitStatus(t, 1), nil)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("deadline passed: got %v, want ErrTimeout", err)
+	}
+}
+
+func TestCmdErrorProgram(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"postsuper", "-d", "-"}, "postsuper"},
+		{[]string{"ssh", "-o", "BatchMode=yes", "mx1", "--", "postcat", "-q", "4ABC"}, "postcat"},
+		{[]string{"ssh", "-o", "BatchMode=yes", "mx1", "--", "'/opt/postfix sbin/postqueue'", "-j"}, "postqueue"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		e := &cmdError{args: tt.args}
+		if got := e.program(); got != tt.want {
+			t.Errorf("program of %q = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyUnavailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		output string
+	}{
+		{"EX_UNAVAILABLE", exUnavailable, "postqueue: fatal: Queue report unavailable - mail system is down"},
+		{"EX_TEMPFAIL", exTempFail, "postqueue: fatal: Connect to the Postfix showq service: Resource temporarily unavailable"},
+		{"exit 69 without output", exUnavailable, ""},
+		{"exit 75 without output", exTempFail, ""},
+		// older postqueue says so and exits 1
+		{"mail system is down", 1, "postqueue: warning: Mail system is down -- accessing queue directly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(exitStatus(t, tt.status), tt.output); got != ErrUnavailable {
+				t.Errorf("classify(exit %d, %q) = %v, want ErrUnavailable", tt.status, tt.output, got)
+			}
+		})
+	}
+}
+
+func TestMailSystemDown(t *testing.T) {
+	down := commandError(context.Background(), exec.Command("postqueue", "-p"), exitStatus(t, exUnavailable), nil)
+	temp := commandError(context.Background(), exec.Command("postqueue", "-p"), exitStatus(t, exTempFail), nil)
+	if !errors.Is(down, ErrUnavailable) || !errors.Is(temp, ErrUnavailable) {
+		t.Fatalf("not ErrUnavailable: %v; %v", down, temp)
+	}
+	if got := mailSystemDown(down); !strings.Contains(got, "does not appear to be running") {
+		t.Errorf("exit 69: %q", got)
+	}
+	if got := mailSystemDown(temp); !strings.Contains(got, "temporarily unavailable") {
+		t.Errorf("exit 75: %q", got)
+	}
+}
+
+func TestFailedListingIsFatal(t *testing.T) {
+	for _, kind := range []error{ErrNotFound, ErrTimeout, ErrPermission, nil} {
+		err := &cmdError{args: []string{"postqueue", "-p"}, kind: kind, err: errors.New("exit status 1")}
+		m := &model{}
+		if cmd := m.continueAfter(listError{err}); cmd != nil || m.err == nil {
+			t.Errorf("listing failed with %v: cmd %v, err %v; want the error screen", kind, cmd != nil, m.err)
+		}
+	}
+	// the same error of an action lists the queue again
+	m := &model{}
+	err := &cmdError{args: []string{"postsuper", "-d", "4ABC2"}, kind: ErrNotFound, err: errors.New("exit status 1")}
+	if cmd := m.continueAfter(err); cmd == nil || m.err != nil {
+		t.Errorf("postsuper failed with ErrNotFound: cmd %v, err %v; want a new listing", cmd != nil, m.err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/muesli/termenv"
+)
+
+// tsvColumns is the header row of the TSV export.
+var tsvColumns = []string{"id", "queue", "size", "arrival", "sender", "recipients", "reason"}
+
+// entriesTSV renders entries as tab separated values with a header row,
+// ready to be pasted into a spreadsheet.
+func entriesTSV(entries []queueEntry) string {
+	var sb strings.Builder
+	columns := tsvColumns
+	if len(queueHosts) > 0 {
+		columns = append(columns[:len(columns):len(columns)], "host")
+	}
+	sb.WriteString(strings.Join(columns, "\t") + "\n")
+	for _, e := range entries {
+		arrival := ""
+		if !e.Arrival.IsZero() {
+			arrival = e.Arrival.Format(time.RFC3339)
+		}
+		sender := e.Sender
+		if sender == "" {
+			sender = "<>" // the null sender, not a missing value
+		}
+		row := []string{
+			e.ID,
+			e.Queue,
+			strconv.FormatInt(e.Size, 10),
+			arrival,
+			sender,
+			strings.Join(e.Recipients, ","),
+			e.Reason,
+		}
+		if len(queueHosts) > 0 {
+			row = append(row, e.Host)
+		}
+		for i, v := range row {
+			row[i] = tsvField(v)
+		}
+		sb.WriteString(strings.Join(row, "\t") + "\n")
+	}
+	return sb.String()
+}
+
+// tsvField keeps a value on one cell: tabs and newlines become spaces.
+func tsvField(s string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(s)
+}
+
+// entryIDs lists the queue IDs of entries one per line, as postdel delete
+// reads them from stdin.
+func entryIDs(entries []queueEntry) string {
+	var sb strings.Builder
+	for _, e := range entries {
+		sb.WriteString(e.ID + "\n")
+	}
+	return sb.String()
+}
+
+// copyToClipboard puts s on the terminal's clipboard using OSC 52, which
+// also works over SSH where no local clipboard tool exists.
+func copyToClipboard(s string) {
+	termenv.Copy(s)
+}
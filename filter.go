@@ -0,0 +1,732 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// The '/' prompt takes a filter expression next to the text to find:
+//
+//	from:@example.com age:>3d not to:@internal invoice
+//	(tag:spam or size:>10M) and queue:deferred
+//
+// Terms are tag:, size:, age:, retry:, queue:, from:, to:, attach: and score:, combined with and
+// (also implied between two terms), or, not and parentheses. The expression
+// narrows the list; the plain words next to it are searched for in the
+// messages that remain.
+
+// listFilter narrows the shown entries. The zero value shows everything.
+type listFilter struct {
+	expr string          // the expression as typed, without the search text
+	root filterNode      // nil for no filter
+	keys map[string]bool // the term keys used, e.g. "size"
+}
+
+// active reports whether f hides anything.
+func (f listFilter) active() bool {
+	return f.root != nil
+}
+
+// String renders f as it was typed.
+func (f listFilter) String() string {
+	return f.expr
+}
+
+// uses reports whether a term with key occurs in f.
+func (f listFilter) uses(key string) bool {
+	return f.keys[key]
+}
+
+// filterEnv is what the terms are evaluated against besides the entry.
+type filterEnv struct {
+	tags        map[string]string
+	attachments map[string]string    // see attachments.go
+	retries     map[string]time.Time // next attempt of deferred messages, see timing.go
+	now         time.Time
+}
+
+// filterResult is the outcome of a filter for an entry. Size, age and
+// attach terms cannot tell for entries of unknown size, arrival or
+// attachments (not fetched yet); not keeps that
+// unknown, and and or only when the other side does not decide.
+type filterResult int
+
+const (
+	filterNo filterResult = iota
+	filterYes
+	filterUnknown
+)
+
+// eval evaluates f for e; without a filter every entry passes.
+func (f listFilter) eval(env filterEnv, e queueEntry) filterResult {
+	if f.root == nil {
+		return filterYes
+	}
+	return f.root.eval(env, e)
+}
+
+// filterNode is a node of a parsed filter expression.
+type filterNode interface {
+	eval(env filterEnv, e queueEntry) filterResult
+}
+
+type andNode []filterNode
+
+func (n andNode) eval(env filterEnv, e queueEntry) filterResult {
+	res := filterYes
+	for _, c := range n {
+		switch c.eval(env, e) {
+		case filterNo:
+			return filterNo
+		case filterUnknown:
+			res = filterUnknown
+		}
+	}
+	return res
+}
+
+type orNode []filterNode
+
+func (n orNode) eval(env filterEnv, e queueEntry) filterResult {
+	res := filterNo
+	for _, c := range n {
+		switch c.eval(env, e) {
+		case filterYes:
+			return filterYes
+		case filterUnknown:
+			res = filterUnknown
+		}
+	}
+	return res
+}
+
+type notNode struct {
+	filterNode
+}
+
+func (n notNode) eval(env filterEnv, e queueEntry) filterResult {
+	switch r := n.filterNode.eval(env, e); r {
+	case filterYes:
+		return filterNo
+	case filterNo:
+		return filterYes
+	default:
+		return r
+	}
+}
+
+// termNode is a single term such as size:>10M, or a plain word (key "")
+// to search for.
+type termNode struct {
+	key  string
+	tok  filterToken
+	test func(env filterEnv, e queueEntry) filterResult
+}
+
+func (n termNode) eval(env filterEnv, e queueEntry) filterResult {
+	if n.test == nil {
+		return filterYes
+	}
+	return n.test(env, e)
+}
+
+// filterSyntaxError is a filter expression that cannot be parsed. col is
+// the 1-based position of the offending character, in runes.
+type filterSyntaxError struct {
+	col int
+	msg string
+}
+
+func (e *filterSyntaxError) Error() string {
+	return fmt.Sprintf("%s (column %d)", e.msg, e.col)
+}
+
+// syntaxError reports msg at byte offset pos of input.
+func syntaxError(input string, pos int, format string, args ...any) *filterSyntaxError {
+	return &filterSyntaxError{col: utf8.RuneCountInString(input[:pos]) + 1, msg: fmt.Sprintf(format, args...)}
+}
+
+// filterToken is a word or parenthesis of the input; pos and end are byte
+// offsets into it.
+type filterToken struct {
+	text     string // without quotes
+	pos, end int
+	paren    bool // "(" or ")"
+	quoted   bool // starts with a quote, so never an operator or a term
+}
+
+// tokenizeFilter splits input at blanks and parentheses. Double quotes keep
+// blanks and parentheses in a word and are removed.
+func tokenizeFilter(input string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(input); {
+		switch c := input[i]; c {
+		case ' ', '\t':
+			i++
+		case '(', ')':
+			toks = append(toks, filterToken{text: string(c), pos: i, end: i + 1, paren: true})
+			i++
+		default:
+			start := i
+			var sb strings.Builder
+			for i < len(input) && !strings.ContainsRune(" \t()", rune(input[i])) {
+				if input[i] != '"' {
+					sb.WriteByte(input[i])
+					i++
+					continue
+				}
+				j := strings.IndexByte(input[i+1:], '"')
+				if j < 0 {
+					return nil, syntaxError(input, i, "unterminated quote")
+				}
+				sb.WriteString(input[i+1 : i+1+j])
+				i += j + 2
+			}
+			toks = append(toks, filterToken{text: sb.String(), pos: start, end: i, quoted: input[start] == '"'})
+		}
+	}
+	return toks, nil
+}
+
+// filterParser is a recursive descent parser for
+//
+//	or    = and { "or" and }
+//	and   = unary { ["and"] unary }
+//	unary = "not" unary | "(" or ")" | term
+type filterParser struct {
+	input string
+	toks  []filterToken
+	i     int
+	keys  map[string]bool
+}
+
+// operator reports whether the next token is the operator op.
+func (p *filterParser) operator(op string) bool {
+	if p.i >= len(p.toks) {
+		return false
+	}
+	t := p.toks[p.i]
+	if t.paren {
+		return t.text == op
+	}
+	return !t.quoted && strings.EqualFold(t.text, op)
+}
+
+// where is the byte offset of the next token, or the end of the input.
+func (p *filterParser) where() int {
+	if p.i < len(p.toks) {
+		return p.toks[p.i].pos
+	}
+	return len(p.input)
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	var n orNode
+	for {
+		c, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		n = append(n, c)
+		if !p.operator("or") {
+			break
+		}
+		p.i++
+	}
+	if len(n) == 1 {
+		return n[0], nil
+	}
+	return n, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	var n andNode
+	for {
+		c, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		n = append(n, c)
+		if p.operator("and") {
+			p.i++
+			continue
+		}
+		if p.i >= len(p.toks) || p.operator("or") || p.operator(")") {
+			break
+		}
+	}
+	if len(n) == 1 {
+		return n[0], nil
+	}
+	return n, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	switch {
+	case p.i >= len(p.toks):
+		return nil, syntaxError(p.input, p.where(), "expected a term")
+	case p.operator("not"):
+		p.i++
+		c, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{c}, nil
+	case p.operator("("):
+		open := p.toks[p.i]
+		p.i++
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.operator(")") {
+			return nil, syntaxError(p.input, open.pos, "( is not closed")
+		}
+		p.i++
+		return c, nil
+	case p.operator(")"), p.operator("and"), p.operator("or"):
+		return nil, syntaxError(p.input, p.where(), "expected a term, not %q", p.toks[p.i].text)
+	}
+	tok := p.toks[p.i]
+	p.i++
+	n, err := parseTerm(tok)
+	if err != nil {
+		return nil, syntaxError(p.input, tok.pos, "%v", err)
+	}
+	if n.key != "" {
+		p.keys[n.key] = true
+	}
+	return n, nil
+}
+
+// parseTerm turns tok into a term. Words without a known key are plain
+// text to search for.
+func parseTerm(tok filterToken) (termNode, error) {
+	n := termNode{tok: tok}
+	key, value, ok := strings.Cut(tok.text, ":")
+	if !ok || tok.quoted {
+		return n, nil
+	}
+	switch key {
+	case "tag", "size", "age", "retry", "queue", "from", "to", "attach", "dest", "score":
+	default:
+		return n, nil
+	}
+	if value == "" {
+		return n, fmt.Errorf("%s: needs a value", key)
+	}
+	n.key = key
+	switch key {
+	case "tag":
+		n.test = func(env filterEnv, e queueEntry) filterResult {
+			return filterIf(env.tags[e.ID] == value)
+		}
+	case "size":
+		lo, hi, err := parseRange(value, parseSize)
+		if err != nil {
+			return n, fmt.Errorf("size:%s: %w", value, err)
+		}
+		n.test = func(_ filterEnv, e queueEntry) filterResult {
+			if e.Size <= 0 {
+				return filterUnknown
+			}
+			return filterIf(e.Size >= lo && (hi == 0 || e.Size <= hi))
+		}
+	case "age":
+		lo, hi, err := parseRange(value, func(s string) (int64, error) {
+			d, err := parseAge(s)
+			return int64(d), err
+		})
+		if err != nil {
+			return n, fmt.Errorf("age:%s: %w", value, err)
+		}
+		n.test = func(env filterEnv, e queueEntry) filterResult {
+			if e.Arrival.IsZero() {
+				return filterUnknown
+			}
+			age := int64(env.now.Sub(e.Arrival))
+			return filterIf(age >= lo && (hi == 0 || age <= hi))
+		}
+	case "retry":
+		lo, hi, err := parseRange(value, func(s string) (int64, error) {
+			d, err := parseAge(s)
+			return int64(d), err
+		})
+		if err != nil {
+			return n, fmt.Errorf("retry:%s: %w", value, err)
+		}
+		n.test = func(env filterEnv, e queueEntry) filterResult {
+			if e.Queue != "deferred" {
+				// never retried by itself: as far off as it gets
+				return filterIf(hi == 0)
+			}
+			next, ok := env.retries[e.ID]
+			if !ok {
+				return filterUnknown
+			}
+			in := max(int64(next.Sub(env.now)), 0)
+			return filterIf(in >= lo && (hi == 0 || in <= hi))
+		}
+	case "attach":
+		if value != attachYes && value != attachNo {
+			return n, fmt.Errorf("attach:%s: expected yes or no", value)
+		}
+		n.test = func(env filterEnv, e queueEntry) filterResult {
+			switch has := env.attachments[e.ID]; has {
+			case attachYes, attachNo:
+				return filterIf(has == value)
+			}
+			return filterUnknown
+		}
+	case "score":
+		in, err := parseScoreRange(value)
+		if err != nil {
+			return n, fmt.Errorf("score:%s: %w", value, err)
+		}
+		n.test = func(_ filterEnv, e queueEntry) filterResult {
+			if !e.scoreKnown {
+				return filterUnknown
+			}
+			return filterIf(in(e.Score))
+		}
+	case "queue":
+		if !slices.Contains(queueNames, value) {
+			return n, fmt.Errorf("queue:%s: expected one of %s", value, strings.Join(queueNames, ", "))
+		}
+		n.test = func(_ filterEnv, e queueEntry) filterResult {
+			return filterIf(e.Queue == value)
+		}
+	case "dest":
+		match, err := addressMatcher(value)
+		if err != nil {
+			return n, fmt.Errorf("dest:%s: %w", value, err)
+		}
+		if value == destUnknown {
+			match = func(s string) bool { return s == destUnknown }
+		}
+		n.test = func(_ filterEnv, e queueEntry) filterResult {
+			// messages without a reason are not on their way anywhere yet
+			return filterIf(e.Reason != "" && match(reasonDestination(e.Reason)))
+		}
+	case "from", "to":
+		if key == "from" && value == "<>" {
+			n.test = func(_ filterEnv, e queueEntry) filterResult {
+				return filterIf(e.Sender == "")
+			}
+			break
+		}
+		match, err := addressMatcher(value)
+		if err != nil {
+			return n, fmt.Errorf("%s:%s: %w", key, value, err)
+		}
+		n.test = func(_ filterEnv, e queueEntry) filterResult {
+			if key == "from" {
+				return filterIf(match(e.Sender))
+			}
+			for _, r := range e.Recipients {
+				if match(r) {
+					return filterYes
+				}
+			}
+			return filterNo
+		}
+	}
+	return n, nil
+}
+
+// addressMatcher matches addresses containing value, ignoring case, or
+// matching the regular expression between the slashes of /value/.
+func addressMatcher(value string) (func(string) bool, error) {
+	if len(value) > 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		re, err := regexp.Compile("(?i)" + value[1:len(value)-1])
+		var syntaxErr *syntax.Error
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("invalid regular expression: %s", syntaxErr.Code)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression")
+		}
+		return re.MatchString, nil
+	}
+	value = strings.ToLower(value)
+	return func(s string) bool { return strings.Contains(strings.ToLower(s), value) }, nil
+}
+
+// filterIf converts a bool into a filterResult.
+func filterIf(b bool) filterResult {
+	if b {
+		return filterYes
+	}
+	return filterNo
+}
+
+// parseFilterInput splits the prompt input into the filter expression and
+// the plain words to search for. Those must stand next to the expression:
+// inside parentheses or after or and not they are a syntax error.
+func parseFilterInput(input string) (f listFilter, text string, err error) {
+	toks, err := tokenizeFilter(input)
+	if err != nil || len(toks) == 0 {
+		return listFilter{}, "", err
+	}
+	p := &filterParser{input: input, toks: toks, keys: map[string]bool{}}
+	root, err := p.parseOr()
+	if err == nil && p.i < len(toks) {
+		err = syntaxError(input, p.where(), "unexpected %q", toks[p.i].text)
+	}
+	if err != nil {
+		return listFilter{}, "", err
+	}
+
+	// take the plain words of the top level out as the search text
+	top, ok := root.(andNode)
+	if !ok {
+		top = andNode{root}
+	}
+	var rest andNode
+	var words []string
+	expr := input
+	for i := len(top) - 1; i >= 0; i-- {
+		if t, ok := top[i].(termNode); ok && t.key == "" {
+			words = append([]string{t.tok.text}, words...)
+			expr = expr[:t.tok.pos] + expr[t.tok.end:]
+			continue
+		}
+		rest = append(andNode{top[i]}, rest...)
+	}
+	if err := plainWordError(input, rest); err != nil {
+		return listFilter{}, "", err
+	}
+	f = listFilter{expr: strings.Join(strings.Fields(expr), " "), keys: p.keys}
+	switch len(rest) {
+	case 0:
+		f = listFilter{}
+	case 1:
+		f.root = rest[0]
+	default:
+		f.root = rest
+	}
+	return f, strings.Join(words, " "), nil
+}
+
+// plainWordError reports the first plain word left in n.
+func plainWordError(input string, n filterNode) error {
+	switch n := n.(type) {
+	case andNode:
+		for _, c := range n {
+			if err := plainWordError(input, c); err != nil {
+				return err
+			}
+		}
+	case orNode:
+		for _, c := range n {
+			if err := plainWordError(input, c); err != nil {
+				return err
+			}
+		}
+	case notNode:
+		return plainWordError(input, n.filterNode)
+	case termNode:
+		if n.key == "" {
+			return syntaxError(input, n.tok.pos, "%q is no filter term, and text to find cannot be combined with or, not and ( )", n.tok.text)
+		}
+	}
+	return nil
+}
+
+// parseRange parses ">10M", "<1K", ">=5M", "<=5M" and "1M..50M" into
+// bounds, 0 meaning unbounded; parse reads the values (sizes or ages).
+func parseRange(s string, parse func(string) (int64, error)) (min, max int64, err error) {
+	if lo, hi, ok := strings.Cut(s, ".."); ok {
+		if lo != "" {
+			if min, err = parse(lo); err != nil {
+				return 0, 0, err
+			}
+		}
+		if hi != "" {
+			if max, err = parse(hi); err != nil {
+				return 0, 0, err
+			}
+		}
+		if max > 0 && min > max {
+			return 0, 0, fmt.Errorf("empty range")
+		}
+		return min, max, nil
+	}
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if v, ok := strings.CutPrefix(s, op); ok {
+			n, err := parse(v)
+			if err != nil {
+				return 0, 0, err
+			}
+			switch op {
+			case ">=":
+				return n, 0, nil
+			case ">":
+				return n + 1, 0, nil
+			case "<=":
+				return 0, n, nil
+			}
+			if n <= 1 {
+				return 0, 0, fmt.Errorf("empty range")
+			}
+			return 0, n - 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("expected >N, <N or N..M")
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix (powers
+// of 1024, case does not matter).
+func parseSize(s string) (int64, error) {
+	unit := int64(1)
+	switch strings.ToUpper(s[len(s)-min(len(s), 1):]) {
+	case "K":
+		unit = 1 << 10
+	case "M":
+		unit = 1 << 20
+	case "G":
+		unit = 1 << 30
+	}
+	if unit > 1 {
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(v * float64(unit)), nil
+}
+
+// parseAge parses a duration like "90s", "30m", "12h" or "2d".
+func parseAge(s string) (time.Duration, error) {
+	unit := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}[s[len(s)-min(len(s), 1):]]
+	if unit == 0 {
+		return 0, fmt.Errorf("invalid age %q, expected a number with s, m, h or d", s)
+	}
+	v, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return time.Duration(v * float64(unit)), nil
+}
+
+// matches reports whether e passes f, with ages taken at now. known is
+// false when e was left out only because its size, arrival or attachments
+// are unknown.
+func (m model) matches(f listFilter, e queueEntry, now time.Time) (ok, known bool) {
+	r := f.eval(filterEnv{tags: m.tags, attachments: m.attachments, retries: m.retryTimes, now: now}, e)
+	return r == filterYes, r != filterUnknown
+}
+
+// unknownNoun names what the terms of f could not be checked against.
+func (f listFilter) unknownNoun() string {
+	var nouns []string
+	for _, t := range []struct{ key, noun string }{{"size", "size"}, {"age", "age"}, {"retry", "next attempt"}, {"attach", "attachments"}, {"score", "spam score"}} {
+		if f.uses(t.key) {
+			nouns = append(nouns, t.noun)
+		}
+	}
+	if len(nouns) == 0 {
+		return "size"
+	}
+	return strings.Join(nouns, " or ")
+}
+
+// filterShown is how much of the expression the status line and the chip
+// below the list show.
+const filterShown = 40
+
+// truncate shortens s to n cells, ending in "…" if anything was cut. Wide
+// characters and escape sequences are never split.
+func truncate(s string, n int) string {
+	if ansi.StringWidth(s) <= n {
+		return s
+	}
+	return ansi.Truncate(s, n, "…")
+}
+
+// ellipsizeAddress shortens an address (or a text ending in one, such as
+// "a@b +2") to n cells like truncate, but cuts the local part to keep the
+// domain, which tells more; a domain too long itself keeps its end.
+func ellipsizeAddress(s string, n int) string {
+	w := ansi.StringWidth(s)
+	if w <= n {
+		return s
+	}
+	at := strings.LastIndex(s, "@")
+	if at < 0 {
+		return truncate(s, n)
+	}
+	if domain := ansi.StringWidth(s[at:]); domain+2 <= n {
+		return ansi.Truncate(s[:at], n-domain, "…") + s[at:]
+	}
+	return ansi.TruncateLeft(s, w-n+1, "…")
+}
+
+// setFilter applies f to the list and reports the result.
+func (m *model) setFilter(f listFilter) {
+	m.filter = f
+	m.applyFilter()
+	if !f.active() {
+		m.status = "filter cleared"
+		return
+	}
+	m.status = fmt.Sprintf("%d of %d messages match %s", len(m.entries), len(m.allEntries), truncate(f.expr, filterShown))
+	if m.unknown > 0 {
+		m.status += fmt.Sprintf(" (%d of unknown %s left out)", m.unknown, f.unknownNoun())
+	}
+	m.status += " — ctrl+a selects them all"
+}
+
+// flagBound is a flag such as -min-size that stands for the filter term
+// term+value.
+type flagBound struct{ name, value, term string }
+
+// sizeBounds are the terms of -min-size and -max-size.
+func sizeBounds(min, max string) []flagBound {
+	return []flagBound{{"min-size", min, "size:>="}, {"max-size", max, "size:<="}}
+}
+
+// boundTerms returns the terms of the bounds that were given. The values
+// are checked one by one for an error naming the flag.
+func boundTerms(bounds []flagBound) ([]string, error) {
+	var terms []string
+	for _, b := range bounds {
+		if b.value == "" {
+			continue
+		}
+		if _, text, err := parseFilterInput(b.term + b.value); err != nil || text != "" {
+			return nil, fmt.Errorf("-%s: invalid value %q", b.name, b.value)
+		}
+		terms = append(terms, b.term+b.value)
+	}
+	return terms, nil
+}
+
+// openSizeFilterPrompt opens the '/' prompt with a size term to complete
+// added, starting at the size of the selected message: 'z' on a big one
+// and enter shows all that are at least as big.
+func (m *model) openSizeFilterPrompt() {
+	m.openSearchPrompt()
+	term := "size:>="
+	if m.selected >= 0 && m.selected < len(m.entries) && m.entries[m.selected].Size > 0 {
+		e := m.entries[m.selected]
+		if e.Size >= 1024 {
+			term += fmt.Sprintf("%dK", e.Size/1024)
+		} else {
+			term += fmt.Sprint(e.Size)
+		}
+	}
+	m.searchInput.SetValue(strings.TrimSpace(m.searchInput.Value() + " " + term))
+	m.searchInput.CursorEnd()
+	m.previewFilter()
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cliFlags holds the command line flags that override the config file. They
+// are kept for the whole session so a config reload applies them again.
+type cliFlags struct {
+	configPath string
+	confirm    string
+	batchSize  int
+	batchPause time.Duration
+	rate       string // -requeue-rate, see parseRate
+	workers    int
+	postfixDir string
+	mta        string
+	listCmd    string
+	hosts      string
+	readOnly   bool
+	noAutoload bool
+	autoload   string
+	safeDelete bool
+	trash      bool
+	showCmds   bool
+	latency    bool // see latency.go
+	afterDel   string
+	confQuit   string
+	lineEnds   string
+	label      string
+	rawFiles   bool   // 'w' reads queue files, see rawfile.go
+	snapshot   string // compared with by alt+c, see snapshot.go
+
+	noAltScreen bool   // draw in the normal screen, see summary.go
+	plain       bool   // no colors, borders or overlays, see plain.go
+	mouse       bool   // drag the pane border, see mouse.go
+	preset      string // filter preset to start with
+	sort        string // initial sort, see sort.go
+	split       string // list pane width in percent, see columns.go
+	minSize     string // initial size bounds, added to the filter
+	queue       string // queues shown at the start, see parseQueueView
+	maxSize     string
+
+	printConfig bool
+
+	serve         string // listen address for the queue as JSON, see serve.go
+	serveInterval time.Duration
+	serveActions  bool
+
+	check     bool // run as a monitoring plugin, see check.go
+	warnCount int
+	critCount int
+	warnAge   time.Duration
+
+	postcatTimeout time.Duration
+	commandTimeout time.Duration
+
+	debug     bool   // log at debug level, see log.go
+	debugPath string // -debug=PATH, where to log
+	logFile   string // log to this file
+	review    string // review list on quit, see review.go
+}
+
+// parseFlags registers and parses the global flags.
+func parseFlags() cliFlags {
+	var f cliFlags
+	flag.StringVar(&f.configPath, "config", defaultConfigPath(), "path to the config file")
+	flag.StringVar(&f.confirm, "confirm", "", "comma separated actions that ask before running (delete,hold,release,requeue,flush, all or none)")
+	flag.IntVar(&f.batchSize, "batch-size", 0, "queue IDs per postsuper run in bulk operations (default from config, 500)")
+	flag.StringVar(&f.rate, "requeue-rate", "", "requeue at most `n/sec` messages a second in bulk requeues, e.g. 50/sec, to spare a mail server that just came back (0: no limit)")
+	flag.IntVar(&f.workers, "workers", 0, "maximum number of concurrent background commands (default from config, 4)")
+	flag.DurationVar(&f.batchPause, "batch-pause", -1, "pause between two batches of a bulk operation, e.g. 200ms")
+	flag.StringVar(&f.postfixDir, "postfix-dir", "", "directory containing mailq, postcat, postsuper and postqueue")
+	flag.StringVar(&f.mta, "mta", "", "mail server whose queue is managed: "+strings.Join(mtaNames(), ", ")+" (default: the one installed)")
+	flag.StringVar(&f.listCmd, "list-command", "", "list the Postfix queue with `cmd`: "+strings.Join(listCommands, ", ")+" (default: the first that works)")
+	flag.StringVar(&f.hosts, "hosts", "", "comma separated hosts whose queues are listed and handled over ssh instead of the local one")
+	flag.BoolVar(&f.readOnly, "read-only", false, "disable every action that changes the queue")
+	flag.BoolVar(&f.noAutoload, "no-autoload", false, "do not load the details of the selected message after (re)loading the list (-autoload off)")
+	flag.StringVar(&f.autoload, "autoload", "", "after (re)loading the list, load `what` of the selected message: "+strings.Join(autoloadModes, ", ")+" (default full)")
+	flag.BoolVar(&f.safeDelete, "safe-delete", false, "hold messages and verify they are held before deleting them")
+	flag.BoolVar(&f.trash, "trash", false, "let 'd' put messages on hold into a trash that 'D' deletes, instead of deleting them")
+	flag.BoolVar(&f.showCmds, "show-commands", false, "show every command postdel runs (program, arguments, exit code, duration) below the status line")
+	flag.BoolVar(&f.latency, "latency", false, "show how long the last mailq, postcat, postsuper, … took in the status line, and log their mean and maximum on exit")
+	flag.StringVar(&f.afterDel, "after-delete", "", "what to do after deleting a message, `mode`: refresh (list the queue again, the default) or remove (take it out of the list, list the queue later)")
+	flag.StringVar(&f.confQuit, "confirm-quit", "", "when 'q' asks before quitting, `when`: never (the default), always, or changes (after changing the queue, or with messages selected)")
+	flag.StringVar(&f.lineEnds, "line-endings", "", "how the details show CRLF and bare CR line endings, `mode`: lf (the default), visible (a ␍ at each CR) or raw")
+	flag.StringVar(&f.label, "label", "", "show `text` on a badge in the header line, e.g. PROD-MX1, to tell sessions apart")
+	flag.BoolVar(&f.rawFiles, "raw-files", false, "let 'w' read the queue file of a message directly, bypassing postcat (needs root)")
+	flag.StringVar(&f.snapshot, "snapshot", "", "compare the queue with the snapshot saved in `file` (alt+c)")
+	flag.BoolVar(&f.noAltScreen, "no-altscreen", false, "draw in the normal terminal screen instead of the alternate one, so the session stays in the scrollback")
+	flag.BoolVar(&f.mouse, "mouse", false, "let the mouse drag the border between the list and the details, the wheel scroll and a click on a column header sort the list (the terminal then selects text only with shift)")
+	flag.BoolVar(&f.plain, "plain", false, "draw plain text only: no colors, no borders, no popups over the panes, the selection in inverse video (for dumb terminals and slow links)")
+	flag.StringVar(&f.preset, "preset", "", "start with the filter preset `name` from the config file applied")
+	flag.StringVar(&f.split, "split", "", "make the list pane `percent` of the terminal width, e.g. 30%")
+	flag.StringVar(&f.minSize, "min-size", "", "start showing only messages of at least `size` (K, M and G suffixes)")
+	flag.StringVar(&f.maxSize, "max-size", "", "start showing only messages of at most `size`")
+	flag.StringVar(&f.queue, "queue", "", "start showing only the queues in `list`, e.g. hold or deferred,hold (all: every queue)")
+	flag.StringVar(&f.sort, "sort", "", "sort the list by `key`: "+strings.Join(sortKeys, ", ")+"; -key for descending")
+	flag.BoolVar(&f.printConfig, "print-config", false, "print the effective configuration (defaults, config file, flags) and exit")
+	flag.StringVar(&f.serve, "serve", "", "serve the queue as JSON over HTTP on `addr`, e.g. :8080 (loopback only) or 0.0.0.0:8080 (GET /queue)")
+	flag.DurationVar(&f.serveInterval, "serve-interval", 30*time.Second, "with -serve, list the queue this often")
+	flag.BoolVar(&f.serveActions, "serve-actions", false, "with -serve, allow POST /messages/ID/ACTION (delete, hold, release, requeue, expire) with the token of serve_token or $POSTDEL_SERVE_TOKEN")
+	flag.BoolVar(&f.check, "check", false, "check the queue against -warn-count, -crit-count and -warn-age, print one status line for a monitoring system and exit 0 (ok), 1 (warning), 2 (critical) or 3 (unknown)")
+	flag.IntVar(&f.warnCount, "warn-count", 0, "with -check, warn from `n` messages in the queue (0: never)")
+	flag.IntVar(&f.critCount, "crit-count", 0, "with -check, critical from `n` messages in the queue (0: never)")
+	flag.DurationVar(&f.warnAge, "warn-age", 0, "with -check, warn when the oldest message is `age` old, e.g. 24h (0: never)")
+	flag.DurationVar(&f.postcatTimeout, "postcat-timeout", -1, "kill postcat after this long, 0 for no limit (default from config, 30s)")
+	flag.DurationVar(&f.commandTimeout, "command-timeout", -1, "kill mailq, postqueue and postsuper after this long, 0 for no limit (default from config, 2m)")
+	flag.Var(debugFlag{&f.debug, &f.debugPath}, "debug", "log every command with its exit status and duration, the messages of the interface, parser statistics and cache hits (-debug=PATH to log to PATH; else to -log-file, default "+defaultLogPath()+")")
+	flag.StringVar(&f.logFile, "log-file", "", "log warnings and errors to this file (with -debug: everything)")
+	flag.StringVar(&f.review, "review-file", "", "write the messages marked for review with 'M' to this file as TSV on quit")
+	// with -check a mistake is the UNKNOWN status line, without the usage
+	check := checkRequested(os.Args[1:])
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if check {
+		flag.CommandLine.SetOutput(io.Discard)
+	}
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitOK)
+		}
+		if check {
+			fatal(true, exitUsage, "invalid flags:", err)
+		}
+		os.Exit(exitUsage)
+	}
+	flag.CommandLine.SetOutput(nil)
+	return f
+}
+
+// apply overrides c with the flags that were given.
+func (f cliFlags) apply(c *config) error {
+	if f.confirm != "" {
+		if err := c.setConfirmList(f.confirm); err != nil {
+			return fmt.Errorf("--confirm: %w", err)
+		}
+	}
+	if f.batchSize > 0 {
+		c.Bulk.BatchSize = f.batchSize
+	}
+	if f.batchPause >= 0 {
+		c.Bulk.Pause = f.batchPause
+	}
+	if f.rate != "" {
+		rate, err := parseRate(f.rate)
+		if err != nil {
+			return fmt.Errorf("--requeue-rate: %w", err)
+		}
+		c.Bulk.RequeueRate = rate
+	}
+	if f.workers > 0 {
+		c.Workers = f.workers
+	}
+	if f.hosts != "" {
+		c.Hosts = nil
+		for _, h := range strings.Split(f.hosts, ",") {
+			h = strings.TrimSpace(h)
+			switch {
+			case h == "":
+			case strings.HasPrefix(h, "-"):
+				// ssh would take it for an option
+				return fmt.Errorf("--hosts: invalid host %q", h)
+			default:
+				c.Hosts = append(c.Hosts, h)
+			}
+		}
+	}
+	if f.postfixDir != "" {
+		c.PostfixDir = f.postfixDir
+	}
+	if f.listCmd != "" {
+		if !slices.Contains(listCommands, f.listCmd) {
+			return fmt.Errorf("--list-command: must be one of %s", strings.Join(listCommands, ", "))
+		}
+		c.ListCommand = f.listCmd
+	}
+	if f.mta != "" {
+		if _, err := selectMTA(f.mta, false); err != nil {
+			return fmt.Errorf("--mta: %w", err)
+		}
+		c.MTA = f.mta
+	}
+	if f.postcatTimeout >= 0 {
+		c.Timeouts.Postcat = f.postcatTimeout
+	}
+	if f.commandTimeout >= 0 {
+		c.Timeouts.Commands = f.commandTimeout
+	}
+	if f.autoload != "" {
+		if !slices.Contains(autoloadModes, f.autoload) {
+			return fmt.Errorf("--autoload: must be one of %s", strings.Join(autoloadModes, ", "))
+		}
+		c.Autoload = autoloadMode(f.autoload)
+	}
+	if f.noAutoload {
+		c.Autoload = autoloadOff
+	}
+	if f.safeDelete {
+		c.SafeDelete = true
+	}
+	if f.trash {
+		c.Trash = true
+	}
+	if f.showCmds {
+		c.ShowCommands = true
+	}
+	if f.latency {
+		c.ShowLatency = true
+	}
+	if f.plain {
+		c.Plain = true
+	}
+	if f.mouse {
+		c.Mouse = true
+	}
+	if f.label != "" {
+		c.Label = f.label
+	}
+	if f.review != "" {
+		c.ReviewFile = f.review
+	}
+	if f.afterDel != "" {
+		if !slices.Contains(afterDeleteModes, f.afterDel) {
+			return fmt.Errorf("--after-delete: must be one of %s", strings.Join(afterDeleteModes, ", "))
+		}
+		c.AfterDelete = f.afterDel
+	}
+	if f.confQuit != "" {
+		if !slices.Contains(confirmQuitModes, f.confQuit) {
+			return fmt.Errorf("--confirm-quit: must be one of %s", strings.Join(confirmQuitModes, ", "))
+		}
+		c.ConfirmQuit = f.confQuit
+	}
+	if f.lineEnds != "" {
+		if !slices.Contains(lineEndingsModes, f.lineEnds) {
+			return fmt.Errorf("--line-endings: must be one of %s", strings.Join(lineEndingsModes, ", "))
+		}
+		c.LineEndings = f.lineEnds
+	}
+	if f.split != "" {
+		split, err := parseSplit(f.split)
+		if err != nil {
+			return fmt.Errorf("--split: %w", err)
+		}
+		c.Split = split
+	}
+	if f.readOnly {
+		c.ReadOnly = true
+	}
+	return nil
+}
+
+// load reads the config file and applies the flags on top of it.
+func (f cliFlags) load() (config, error) {
+	c, err := loadConfig(f.configPath)
+	if err != nil {
+		return c, err
+	}
+	if err := c.addSavedPresets(savedPresetsPath(f.configPath)); err != nil {
+		return c, err
+	}
+	if err := c.addSavedLayout(savedLayoutPath(f.configPath)); err != nil {
+		return c, err
+	}
+	if err := c.addSavedSettings(savedSettingsPath(f.configPath)); err != nil {
+		return c, err
+	}
+	return c, f.apply(&c)
+}
+
+// debugFlag is -debug, which takes an optional path: -debug or
+// -debug=PATH.
+type debugFlag struct {
+	on   *bool
+	path *string
+}
+
+func (d debugFlag) String() string {
+	if d.path != nil && *d.path != "" {
+		return *d.path
+	}
+	return ""
+}
+
+func (d debugFlag) Set(s string) error {
+	switch s {
+	case "true":
+		*d.on = true
+	case "false":
+		*d.on, *d.path = false, ""
+	default:
+		*d.on, *d.path = true, s
+	}
+	return nil
+}
+
+// IsBoolFlag lets -debug stand without a value.
+func (debugFlag) IsBoolFlag() bool { return true }
+
+// parseRate parses a rate of messages a second: "50", "50/s" or "50/sec".
+func parseRate(s string) (int, error) {
+	n, unit, _ := strings.Cut(s, "/")
+	if unit != "" && unit != "s" && unit != "sec" {
+		return 0, fmt.Errorf("invalid rate %q, expected messages a second such as 50/sec", s)
+	}
+	rate, err := strconv.Atoi(n)
+	if err != nil || rate < 0 {
+		return 0, fmt.Errorf("invalid rate %q, expected messages a second such as 50/sec", s)
+	}
+	return rate, nil
+}
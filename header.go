@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The header line above the panes is a title bar naming the machine whose
+// queue is shown: the host name, the Postfix configuration (or instance)
+// and with -hosts the ssh targets, plus the label of the config file. On
+// the right it tells which part of the queue is listed: the queues shown,
+// the filter and the number of messages, after the free space of the spool
+// (see diskspace.go). Several postdel sessions side by
+// side are then told apart at a glance.
+
+var (
+	hostBadgeStyle  = lipgloss.NewStyle().Bold(true).Reverse(true).Padding(0, 1)
+	labelBadgeStyle = lipgloss.NewStyle().Bold(true).Padding(0, 1).
+			Foreground(colorBadgeText)
+	titleBarStyle = lipgloss.NewStyle().Background(colorTitleBar).
+			Foreground(colorTitleText)
+	titleNameStyle = titleBarStyle.Copy().Bold(true).Padding(0, 1)
+)
+
+// defaultLabelColor is the background of the label without label_color,
+// colorBadge at 256 colors.
+const defaultLabelColor = "160"
+
+// hostname is the short name of this machine, set in main.
+var hostname string
+
+// shortHostname returns the host name up to the first dot, "?" when it is
+// unknown.
+func shortHostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "?"
+	}
+	name, _, _ = strings.Cut(name, ".")
+	return name
+}
+
+// headerLine renders the title bar: the tool, the badges and where the
+// queue comes from on the left, what is listed of it on the right.
+func (m model) headerLine() string {
+	parts := []string{titleNameStyle.Render("postdel")}
+	if m.cfg.Label != "" {
+		var color lipgloss.TerminalColor = colorBadge
+		if m.cfg.LabelColor != "" {
+			color = lipgloss.Color(m.cfg.LabelColor)
+		}
+		parts = append(parts, labelBadgeStyle.Background(color).Render(m.cfg.Label))
+	}
+	parts = append(parts, hostBadgeStyle.Render(hostname))
+	switch {
+	case len(queueHosts) > 0:
+		parts = append(parts, "ssh: "+strings.Join(queueHosts, ", "))
+	case backend.name != "postfix":
+		parts = append(parts, backend.title)
+	default:
+		parts = append(parts, detectedPostfix.where())
+	}
+	left := strings.Join(parts, titleBarStyle.Render(" "))
+	right := titleBarStyle.Render(" " + truncate(m.listedScope(), max(m.termWidth/2-2, 0)) + " ")
+	if disk := m.diskBadge(); disk != "" {
+		right = disk + titleBarStyle.Render(" ") + right
+	}
+	if lipgloss.Width(left)+lipgloss.Width(right) > m.termWidth {
+		left = truncate(left, max(m.termWidth-lipgloss.Width(right), 0))
+	}
+	gap := max(m.termWidth-lipgloss.Width(left)-lipgloss.Width(right), 0)
+	return truncate(left+titleBarStyle.Render(strings.Repeat(" ", gap))+right, m.termWidth)
+}
+
+// listedScope describes what of the queue is listed: the queues shown,
+// the filter and the count of messages.
+func (m model) listedScope() string {
+	var parts, hidden, shown []string
+	for _, name := range queueNames {
+		if m.hiddenQueues[name] {
+			hidden = append(hidden, name)
+		} else {
+			shown = append(shown, name)
+		}
+	}
+	switch {
+	case len(shown) == 0:
+		parts = append(parts, "no queues")
+	case len(shown) == 1:
+		parts = append(parts, shown[0]+" queue")
+	case len(shown) < len(hidden):
+		parts = append(parts, "queues "+strings.Join(shown, ", "))
+	case len(hidden) > 0:
+		parts = append(parts, "without "+strings.Join(hidden, ", "))
+	default:
+		parts = append(parts, "all queues")
+	}
+	if m.actionableOnly {
+		parts = append(parts, "actionable only")
+	}
+	if m.filter.active() {
+		parts = append(parts, "filter "+truncate(m.filter.String(), filterShown/2))
+	}
+	if len(m.entries) == len(m.allEntries) {
+		parts = append(parts, fmt.Sprintf("%d messages", len(m.allEntries)))
+	} else {
+		parts = append(parts, fmt.Sprintf("%d of %d messages", len(m.entries), len(m.allEntries)))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// where names the Postfix configuration in use for the header line.
+func (p postfixInfo) where() string {
+	switch {
+	case p.instance != "":
+		return "Postfix instance " + p.instance + " (" + p.configDir + ")"
+	case p.configDir != "":
+		return "Postfix config " + p.configDir
+	}
+	return "Postfix"
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// headerCompareMsg reports the headers of one compared message.
+type headerCompareMsg struct {
+	cmp    *headerCompare
+	id     string
+	header mail.Header
+}
+
+// headerCompareDoneMsg is sent when all headers have been fetched.
+type headerCompareDoneMsg struct {
+	cmp *headerCompare
+}
+
+// headerCompare compares the headers of the selected messages: which are
+// the same everywhere and which vary, and how.
+type headerCompare struct {
+	ids     []string
+	headers map[string]mail.Header
+	updates chan tea.Msg
+	cancel  context.CancelFunc
+
+	rows   []headerRow // the table once everything is fetched
+	cursor int         // index into rows, only value rows are selectable
+}
+
+// headerRow is a line of the comparison table: a header name or one of its
+// values with the messages carrying it.
+type headerRow struct {
+	text string
+	ids  []string // nil for name lines
+}
+
+// startHeaderCompare fetches the headers of all marked entries in the
+// background, bounded like the queue-wide search.
+func (m *model) startHeaderCompare() tea.Cmd {
+	ids := m.markedIDs()
+	if len(ids) < 2 {
+		m.status = "select at least two messages (space) to compare their headers"
+		return nil
+	}
+	if m.hdrCompare != nil {
+		m.hdrCompare.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &headerCompare{
+		ids:     ids,
+		headers: map[string]mail.Header{},
+		updates: make(chan tea.Msg, searchWorkers),
+		cancel:  cancel,
+	}
+	m.hdrCompare = c
+
+	queue := make(chan string)
+	go func() {
+		defer close(queue)
+		for _, id := range ids {
+			select {
+			case queue <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	p, hosts := m.pool, m.entryHosts()
+	var wg sync.WaitGroup
+	for i := 0; i < searchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range queue {
+				res := p.submit(id, prioBackground, func(jctx context.Context) tea.Msg {
+					jctx, cancel := commandContext(jctx, "postcat")
+					defer cancel()
+					out, err := runOutput(backend.show(jctx, hosts[id], id, true))
+					if err != nil {
+						return mail.Header{}
+					}
+					return postcatHeaders(string(out))
+				})()
+				h, _ := res.(mail.Header)
+				select {
+				case c.updates <- headerCompareMsg{cmp: c, id: id, header: h}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		if ctx.Err() == nil {
+			c.updates <- headerCompareDoneMsg{cmp: c}
+		}
+		close(c.updates)
+	}()
+
+	m.status = fmt.Sprintf("comparing headers: 0/%d messages", len(ids))
+	return waitForSearch(c.updates)
+}
+
+// postcatHeaders parses the output of postcat -h, which may start with
+// "*** ..." record lines.
+func postcatHeaders(out string) mail.Header {
+	if h := messageHeaders(out); h != nil {
+		return h
+	}
+	lines := strings.Split(out, "\n")
+	for len(lines) > 0 && strings.HasPrefix(lines[0], "*** ") {
+		lines = lines[1:]
+	}
+	return messageHeaders(strings.Join(lines, "\n"))
+}
+
+// buildRows computes the table: headers with one value everywhere are
+// collapsed to one line, as are headers that differ in every message
+// (Message-ID, Received); the others list each value with its count.
+func (c *headerCompare) buildRows() {
+	values := map[string]map[string][]string{} // name -> value -> ids
+	for _, id := range c.ids {
+		for name, vs := range c.headers[id] {
+			v := decodeHeader(strings.Join(vs, " | "))
+			if values[name] == nil {
+				values[name] = map[string][]string{}
+			}
+			values[name][v] = append(values[name][v], id)
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	n := len(c.ids)
+	c.rows = nil
+	for _, name := range names {
+		vals := values[name]
+		switch {
+		case len(vals) == 1:
+			for v, ids := range vals {
+				c.rows = append(c.rows, headerRow{text: fmt.Sprintf("%s: %s  (same in %d of %d)", name, v, len(ids), n)})
+			}
+			continue
+		case len(vals) == n:
+			c.rows = append(c.rows, headerRow{text: fmt.Sprintf("%s: different in every message", name)})
+			continue
+		}
+		c.rows = append(c.rows, headerRow{text: fmt.Sprintf("%s: %d distinct values", name, len(vals))})
+		keys := make([]string, 0, len(vals))
+		for v := range vals {
+			keys = append(keys, v)
+		}
+		sort.Slice(keys, func(i, j int) bool { return len(vals[keys[i]]) > len(vals[keys[j]]) })
+		for _, v := range keys {
+			c.rows = append(c.rows, headerRow{text: fmt.Sprintf("  %4d× %s", len(vals[v]), v), ids: vals[v]})
+		}
+	}
+	c.cursor = -1
+	c.move(1)
+}
+
+// move puts the cursor on the next value row in direction dir.
+func (c *headerCompare) move(dir int) {
+	for i := c.cursor + dir; i >= 0 && i < len(c.rows); i += dir {
+		if c.rows[i].ids != nil {
+			c.cursor = i
+			return
+		}
+	}
+}
+
+// showHeaderCompare renders the table in the details pane.
+func (m *model) showHeaderCompare() {
+	c := m.hdrCompare
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Headers of %d selected messages — up/down choose a value, enter selects the messages carrying it\n\n", len(c.ids))
+	for i, r := range c.rows {
+		line := r.text
+		if i == c.cursor {
+			line = selectedStyle.Render(">" + line[1:])
+		}
+		sb.WriteString(line + "\n")
+	}
+	m.rightRaw = sb.String()
+	m.rightMarks = nil
+	m.right.SetContent(m.rightRaw)
+	if line := c.cursor + 2; line >= m.right.YOffset+m.right.Height || line < m.right.YOffset {
+		m.right.SetYOffset(line - m.right.Height/2)
+	}
+}
+
+// updateHeaderCompare handles the details-pane keys while the table is shown.
+func (m *model) updateHeaderCompare(key string) (bool, tea.Cmd) {
+	c := m.hdrCompare
+	switch key {
+	case "up":
+		c.move(-1)
+	case "down":
+		c.move(1)
+	case "enter":
+		if c.cursor < 0 {
+			return true, nil
+		}
+		row := c.rows[c.cursor]
+		m.hdrCompare = nil
+		return true, m.selectIDs(row.ids, fmt.Sprintf("selected the %d messages with %s", len(row.ids), strings.TrimSpace(row.text)))
+	default:
+		return false, nil
+	}
+	m.showHeaderCompare()
+	return true, nil
+}
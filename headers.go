@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// wordDecoder decodes RFC 2047 encoded words. Charsets beyond UTF-8 and
+// ISO-8859-1 are looked up in x/text, which knows the usual mail charsets.
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(charset)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	},
+}
+
+// decodeHeader decodes RFC 2047 encoded words in a header value. Malformed
+// words or unknown charsets leave the raw text in place.
+func decodeHeader(v string) string {
+	d, err := wordDecoder.DecodeHeader(v)
+	if err != nil {
+		return v
+	}
+	return d
+}
+
+// messageHeaders parses the header section of the message contained in
+// postcat output. It returns nil if no headers could be parsed.
+func messageHeaders(postcat string) mail.Header {
+	text := postcat
+	if i := strings.Index(text, "*** MESSAGE CONTENTS"); i >= 0 {
+		text = text[i:]
+		if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+			text = text[nl+1:]
+		}
+	}
+	// cut after the header section, the body is of no interest here
+	if i := strings.Index(text, "\n\n"); i >= 0 {
+		text = text[:i+1]
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(text + "\n"))
+	if err != nil {
+		return nil
+	}
+	return msg.Header
+}
+
+// summaryHeaders are shown decoded on top of the details panel.
+var summaryHeaders = []string{"From", "To", "Subject", "Date"}
+
+// envelopeSummary renders the envelope of e in full, which the list may
+// have cut.
+func envelopeSummary(e queueEntry) string {
+	s := fmt.Sprintf("%-8s %s\n", "Sender:", e.senderLabel())
+	if len(e.Recipients) > 0 {
+		s += fmt.Sprintf("%-8s %s\n", "Rcpts:", strings.Join(e.Recipients, ", "))
+	}
+	return s
+}
+
+// messageSummary renders the decoded key headers of a postcat output, or ""
+// if the output contains no message headers.
+func messageSummary(postcat string) string {
+	h := messageHeaders(postcat)
+	if h == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, name := range summaryHeaders {
+		if v := h.Get(name); v != "" {
+			fmt.Fprintf(&sb, "%-8s %s\n", name+":", decodeHeader(v))
+		}
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	sb.WriteString(strings.Repeat("─", 40) + "\n")
+	return sb.String()
+}
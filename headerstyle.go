@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The details set the message headers apart from the body: header names
+// bold, and the blank line that ends the headers drawn as a rule. Each
+// line is styled on its own and keeps its place, so the search marks and
+// the minimap still point at the right lines.
+
+var (
+	headerNameStyle = lipgloss.NewStyle().Bold(true)
+	headerEndStyle  = lipgloss.NewStyle().Foreground(colorSubtle)
+)
+
+// headerEndRule replaces the blank line between the headers and the body.
+const headerEndRule = "──── end of headers ────"
+
+// styleMessage styles the headers of the message in postcat output. Text
+// without postcat's "*** MESSAGE CONTENTS" line is returned as it is. The
+// headers end at the first blank line, which with line_endings visible or
+// raw still carries the ␍ or the CR of a CRLF.
+func styleMessage(text string) string {
+	start := strings.Index(text, "*** MESSAGE CONTENTS")
+	if start < 0 {
+		return text
+	}
+	nl := strings.IndexByte(text[start:], '\n')
+	if nl < 0 {
+		return text
+	}
+	start += nl + 1
+	lines := strings.Split(text[start:], "\n")
+	mark := disabledStyle.Render("␍")
+	end := slices.IndexFunc(lines, func(line string) bool {
+		return strings.TrimSuffix(strings.TrimSuffix(line, "\r"), mark) == ""
+	})
+	if end < 0 {
+		return text[:start] + styleHeaders(text[start:])
+	}
+	styled := text[:start] + styleHeaders(strings.Join(lines[:end], "\n"))
+	return styled + "\n" + headerEndStyle.Render(headerEndRule) + "\n" + strings.Join(lines[end+1:], "\n")
+}
+
+// styleHeaders makes the names of the header lines in text bold;
+// continuation lines stay as they are.
+func styleHeaders(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && !strings.ContainsAny(name, " \t") {
+			lines[i] = headerNameStyle.Render(name+":") + value
+		}
+	}
+	return strings.Join(lines, "\n")
+}
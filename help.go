@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// '?' shows what postdel found out about the mail server and the keys,
+// from the command registry, in the details pane.
+
+// showHelp puts the help into the details pane.
+func (m *model) showHelp() {
+	m.rightRaw = m.helpText()
+	m.rightMarks = nil
+	m.right.SetContent(m.rightRaw)
+	m.right.GotoTop()
+	m.status = "help, select a message to leave"
+}
+
+// helpText renders the mail server, the listing command and the keys.
+func (m model) helpText() string {
+	var sb strings.Builder
+	sb.WriteString("postdel — manage the mail queue\n\n")
+	switch {
+	case backend.name != "postfix":
+		fmt.Fprintf(&sb, "%-14s %s\n", "Mail server:", backend.title)
+	case len(queueHosts) > 0:
+		fmt.Fprintf(&sb, "%-14s Postfix on %s, versions not checked\n", "Mail server:", strings.Join(queueHosts, ", "))
+	default:
+		fmt.Fprintf(&sb, "%-14s %s\n", "Mail server:", detectedPostfix)
+	}
+	if backend.name == "postfix" {
+		listing := "first that works of "
+		for i, name := range listCommands {
+			if i > 0 {
+				listing += ", "
+			}
+			listing += listCommandLines[name]
+		}
+		if listCommand != "" {
+			listing = listCommandLines[listCommand] + " (list_command)"
+		}
+		fmt.Fprintf(&sb, "%-14s %s\n", "Listing:", listing)
+	}
+	var off []string
+	for _, a := range allActions {
+		if !backend.supports(a) {
+			off = append(off, a.String())
+		}
+	}
+	if len(off) > 0 {
+		fmt.Fprintf(&sb, "%-14s %s\n", "Not available:", strings.Join(off, ", "))
+	}
+	if reason := m.deliverFallback(); reason != "" {
+		fmt.Fprintf(&sb, "%-14s %s\n", "Deliver now:", "requeues, "+reason)
+	}
+	fmt.Fprintf(&sb, "%-14s %s\n\nKeys:\n", "Config:", m.flags.configPath)
+	for _, c := range commands {
+		line := fmt.Sprintf("  %-14s %s", strings.Join(c.keys, " "), c.title)
+		if hint := m.confirmHint(c); hint != "" && !(c.destructive && m.readOnly) {
+			line += ", " + hint
+		}
+		if reason := m.disabledReason(c); reason != "" {
+			line += "  (" + reason + ")"
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+// historySize is how many entries a prompt history keeps.
+const historySize = 50
+
+// promptHistory is the per-session history of a prompt, recalled with
+// up/down while the prompt is open. Recalled entries are copied into the
+// input, so editing one leaves the history alone until enter adds the
+// result as a new entry.
+type promptHistory struct {
+	items []historyItem // oldest first
+	pos   int           // index of the recalled item, len(items) for the draft
+	draft historyItem   // what was typed before recalling
+}
+
+// historyItem is a prompt input with the options it was entered with.
+type historyItem struct {
+	text string
+	opts searchOptions
+}
+
+// open starts browsing from the newest entry.
+func (h *promptHistory) open() {
+	h.pos = len(h.items)
+	h.draft = historyItem{}
+}
+
+// prev returns the entry before the shown one, saving cur as the draft when
+// leaving it. ok is false at the oldest entry.
+func (h *promptHistory) prev(cur historyItem) (item historyItem, ok bool) {
+	if h.pos == 0 {
+		return historyItem{}, false
+	}
+	if h.pos == len(h.items) {
+		h.draft = cur
+	}
+	h.pos--
+	return h.items[h.pos], true
+}
+
+// next returns the entry after the shown one, or the draft past the newest.
+func (h *promptHistory) next() (item historyItem, ok bool) {
+	if h.pos >= len(h.items) {
+		return historyItem{}, false
+	}
+	h.pos++
+	if h.pos == len(h.items) {
+		return h.draft, true
+	}
+	return h.items[h.pos], true
+}
+
+// add records item as the newest entry, moving it there if it was already
+// in the history.
+func (h *promptHistory) add(item historyItem) {
+	if item.text == "" {
+		return
+	}
+	for i, old := range h.items {
+		if old == item {
+			h.items = append(h.items[:i], h.items[i+1:]...)
+			break
+		}
+	}
+	h.items = append(h.items, item)
+	if len(h.items) > historySize {
+		h.items = h.items[len(h.items)-historySize:]
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hookConfig is an external command run after actions on single messages,
+// e.g. to post held messages to a chat channel, and the commands run on
+// events of the session, see runEvent.
+type hookConfig struct {
+	// Command is the program and its arguments; empty disables the hook.
+	// The action and the queue ID are appended, the details arrive as
+	// JSON on stdin.
+	Command []string      `toml:"command"`
+	Actions []string      `toml:"actions"` // actions that fire the hook
+	Timeout time.Duration `toml:"timeout"`
+
+	OnDelete    []string `toml:"on_delete"`    // after a bulk delete
+	OnThreshold []string `toml:"on_threshold"` // when the queue crosses Threshold
+	OnError     []string `toml:"on_error"`     // when a command fails
+	Threshold   int      `toml:"threshold"`    // messages, 0 for none
+}
+
+// hookIDsMax is how many queue IDs POSTDEL_IDS holds at most.
+const hookIDsMax = 100
+
+// hookFailedMsg reports a hook that could not run or exited non-zero.
+type hookFailedMsg struct {
+	err error
+}
+
+// hookEvent is the JSON document a hook gets on stdin.
+type hookEvent struct {
+	Action     string    `json:"action"`
+	QueueID    string    `json:"queue_id"`
+	Queue      string    `json:"queue"`
+	Sender     string    `json:"sender"`
+	Recipients []string  `json:"recipients"`
+	Time       time.Time `json:"time"`
+	Host       string    `json:"host,omitempty"` // with -hosts
+}
+
+// fires reports whether the hook is configured for a.
+func (h hookConfig) fires(a action) bool {
+	if len(h.Command) == 0 {
+		return false
+	}
+	for _, name := range h.Actions {
+		if name == a.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// run returns a command running the hook for a on e in the background,
+// or nil if no hook is configured for a. Only failures produce a message.
+func (h hookConfig) run(a action, e queueEntry) tea.Cmd {
+	if !h.fires(a) {
+		return nil
+	}
+	event, err := json.Marshal(hookEvent{
+		Action:     a.String(),
+		QueueID:    e.ID,
+		Queue:      e.Queue,
+		Sender:     e.Sender,
+		Recipients: e.Recipients,
+		Time:       time.Now(),
+		Host:       e.Host,
+	})
+	if err != nil {
+		return func() tea.Msg { return hookFailedMsg{err} }
+	}
+	argv := append(append([]string{}, h.Command[1:]...), a.String(), e.ID)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, h.Command[0], argv...)
+		cmd.Stdin = bytes.NewReader(event)
+		out, err := runCombinedOutput(cmd)
+		if ctx.Err() != nil {
+			return hookFailedMsg{fmt.Errorf("%s: no answer after %s", h.Command[0], h.Timeout)}
+		}
+		if err != nil {
+			return hookFailedMsg{fmt.Errorf("%s: %v: %s", h.Command[0], err, firstLine(string(out)))}
+		}
+		return nil
+	}
+}
+
+// runEvent returns a command running the event hook command in the
+// background, with the event in the environment: POSTDEL_EVENT,
+// POSTDEL_COUNT, POSTDEL_IDS (the first hookIDsMax, separated by blanks),
+// POSTDEL_HOST and the variables of extra ("NAME=value"). Only failures
+// produce a message, for the error log.
+func (h hookConfig) runEvent(command []string, event string, count int, ids []string, extra ...string) tea.Cmd {
+	if len(command) == 0 {
+		return nil
+	}
+	host := hostname
+	if len(queueHosts) > 0 {
+		host = strings.Join(queueHosts, ",")
+	}
+	env := append(os.Environ(),
+		"POSTDEL_EVENT="+event,
+		"POSTDEL_COUNT="+strconv.Itoa(count),
+		"POSTDEL_IDS="+strings.Join(ids[:min(len(ids), hookIDsMax)], " "),
+		"POSTDEL_HOST="+host)
+	env = append(env, extra...)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Env = env
+		out, err := runCombinedOutput(cmd)
+		if ctx.Err() != nil {
+			return hookFailedMsg{fmt.Errorf("on_%s hook %s: no answer after %s", event, command[0], h.Timeout)}
+		}
+		if err != nil {
+			return hookFailedMsg{fmt.Errorf("on_%s hook %s: %v: %s", event, command[0], err, firstLine(string(out)))}
+		}
+		return nil
+	}
+}
+
+// eventHook runs the event hook command, but never in a read-only session.
+func (m model) eventHook(command []string, event string, count int, ids []string, extra ...string) tea.Cmd {
+	if m.readOnly {
+		return nil
+	}
+	return m.cfg.Hook.runEvent(command, event, count, ids, extra...)
+}
+
+// deleteHook runs on_delete after a bulk delete of ids.
+func (m model) deleteHook(r bulkResult, ids []string) tea.Cmd {
+	if r.action != actionDelete {
+		return nil
+	}
+	return m.eventHook(m.cfg.Hook.OnDelete, "delete", r.affected, ids)
+}
+
+// thresholdHook runs on_threshold when a listing of n messages is on the
+// other side of the threshold than the one before; the first listing
+// counts as coming from below. POSTDEL_STATE tells "above" or "below".
+func (m *model) thresholdHook(n int) tea.Cmd {
+	t := m.cfg.Hook.Threshold
+	if t <= 0 || (n >= t) == m.aboveThreshold {
+		return nil
+	}
+	m.aboveThreshold = n >= t
+	state := "below"
+	if m.aboveThreshold {
+		state = "above"
+	}
+	return m.eventHook(m.cfg.Hook.OnThreshold, "threshold", n, nil,
+		"POSTDEL_STATE="+state, "POSTDEL_THRESHOLD="+strconv.Itoa(t))
+}
+
+// errorHook runs on_error for a failed command; POSTDEL_ERROR holds the
+// first line of the error, POSTDEL_IDS the listed messages it named.
+func (m model) errorHook(err error) tea.Cmd {
+	var ids []string
+	var ce *cmdError
+	if errors.As(err, &ce) && len(ce.args) > 0 {
+		for _, arg := range ce.args[1:] {
+			if slices.ContainsFunc(m.allEntries, func(e queueEntry) bool { return e.ID == arg }) {
+				ids = append(ids, arg)
+			}
+		}
+	}
+	return m.eventHook(m.cfg.Hook.OnError, "error", len(ids), ids, "POSTDEL_ERROR="+firstLine(err.Error()))
+}
+
+// bulkErrorHook runs on_error for a bulk operation that failed in part or
+// was cancelled; POSTDEL_ERROR holds the first failure, POSTDEL_IDS the
+// messages it was given.
+func (m model) bulkErrorHook(r bulkResult, ids []string) tea.Cmd {
+	if !r.failed() {
+		return nil
+	}
+	err := r.err
+	if len(r.failures) > 0 {
+		err = r.failures[0]
+	}
+	return m.eventHook(m.cfg.Hook.OnError, "error", len(ids), ids, "POSTDEL_ERROR="+firstLine(err.Error()))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Like vim's jump list, the messages left by a jump (g/G, n/N, ]/[, a
+// selection by ID) are remembered, and alt+left and alt+right go back and
+// forth through them, loading the details again. ctrl+o and ctrl+i, vim's
+// keys, are taken: ctrl+o echoes the commands and terminals send ctrl+i
+// as tab. Messages that left the queue are dropped from the list.
+
+// jumpListSize is how many messages the jump list remembers.
+const jumpListSize = 100
+
+// jumpList holds queue IDs, oldest first; pos is where alt+left and
+// alt+right are, len(ids) while not going through it.
+type jumpList struct {
+	ids []string
+	pos int
+}
+
+// recordJump remembers the selected message before a jump away from it.
+// Going back and then jumping elsewhere drops the newer messages, as in
+// vim.
+func (m *model) recordJump() {
+	if m.selected >= len(m.entries) {
+		return
+	}
+	j := &m.jumps
+	j.ids = j.ids[:min(j.pos, len(j.ids))]
+	if id := m.entries[m.selected].ID; len(j.ids) == 0 || j.ids[len(j.ids)-1] != id {
+		j.ids = append(j.ids, id)
+	}
+	if len(j.ids) > jumpListSize {
+		j.ids = j.ids[len(j.ids)-jumpListSize:]
+	}
+	j.pos = len(j.ids)
+}
+
+// jumpBack selects the message before the last jump (dir -1) or goes
+// forward again (dir 1).
+func (m *model) jumpBack(dir int) tea.Cmd {
+	j := &m.jumps
+	if dir < 0 && j.pos == len(j.ids) && m.selected < len(m.entries) {
+		// keep the message we come from, to return to it
+		m.recordJump()
+		j.pos = len(j.ids) - 1
+	}
+	for next := j.pos + dir; next >= 0 && next < len(j.ids); next += dir {
+		i := m.entryIndex(j.ids[next])
+		if i < 0 {
+			continue // hidden by the filter or the queue keys
+		}
+		j.pos = next
+		m.selected = i
+		m.syncLeft()
+		m.status = fmt.Sprintf("jump %d of %d: %s", next+1, len(j.ids), j.ids[next])
+		return m.runPostcatCmd(j.ids[next])
+	}
+	if dir < 0 {
+		m.status = "no earlier message to go back to"
+	} else {
+		m.status = "no later message to go forward to"
+	}
+	return nil
+}
+
+// entryIndex returns the position of id in the shown entries, -1 if it is
+// not shown.
+func (m model) entryIndex(id string) int {
+	for i, e := range m.entries {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// pruneJumps drops the messages that are no longer in the queue.
+func (m *model) pruneJumps() {
+	present := make(map[string]bool, len(m.allEntries))
+	for _, e := range m.allEntries {
+		present[e.ID] = true
+	}
+	j := &m.jumps
+	kept := j.ids[:0]
+	pos := j.pos
+	for i, id := range j.ids {
+		if present[id] {
+			kept = append(kept, id)
+		} else if i < j.pos {
+			pos--
+		}
+	}
+	j.ids, j.pos = kept, max(pos, 0)
+}
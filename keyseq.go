@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keySequences are the vim-style two-key sequences. Their first key is an
+// operator: it waits for the second key and falls back to its single-key
+// command when none follows within keys.sequence_timeout.
+var keySequences = map[string]func(m *model) tea.Cmd{
+	"dd": func(m *model) tea.Cmd { return m.requestAction(actionDelete) },
+	"dG": func(m *model) tea.Cmd { return m.confirmRange(actionDelete, m.selected, len(m.entries)) },
+}
+
+// keysConfig holds the keyboard settings.
+type keysConfig struct {
+	// SequenceTimeout is how long an operator key waits for the second key
+	// of a sequence; 0 disables sequences.
+	SequenceTimeout time.Duration `toml:"sequence_timeout"`
+}
+
+// keySeqTimeoutMsg ends the wait for the second key of sequence seq.
+type keySeqTimeoutMsg struct {
+	seq int
+}
+
+// operatorFollowers returns the second keys of the sequences starting with
+// key, or nil if key is no operator.
+func operatorFollowers(key string) []string {
+	var next []string
+	for s := range keySequences {
+		if strings.HasPrefix(s, key) && len(s) > len(key) {
+			next = append(next, s[len(key):])
+		}
+	}
+	sort.Strings(next)
+	return next
+}
+
+// handleKeySequence feeds key into the sequence state machine. It reports
+// whether the key was consumed; if not, it is handled as a single key.
+func (m *model) handleKeySequence(key string) (bool, tea.Cmd) {
+	if m.pendingOp != "" {
+		op := m.pendingOp
+		m.pendingOp = ""
+		if key == "esc" {
+			m.status = op + ": cancelled"
+			return true, nil
+		}
+		if run, ok := keySequences[op+key]; ok {
+			m.status = ""
+			return true, run(m)
+		}
+		m.status = fmt.Sprintf("no key sequence %s%s", op, key)
+		return false, nil
+	}
+
+	next := operatorFollowers(key)
+	if m.cfg.Keys.SequenceTimeout <= 0 || next == nil || m.visual {
+		// in visual mode the operator applies to the range right away
+		return false, nil
+	}
+	if c, ok := commandForKey(key); ok && m.disabledReason(c) != "" {
+		// let the single-key command refuse it with the reason
+		return false, nil
+	}
+	m.pendingOp = key
+	m.pendingSeq++
+	m.status = fmt.Sprintf("%s… (then %s, esc cancels)", key, strings.Join(next, " or "))
+	seq := m.pendingSeq
+	return true, tea.Tick(m.cfg.Keys.SequenceTimeout, func(time.Time) tea.Msg { return keySeqTimeoutMsg{seq} })
+}
+
+// keySequenceTimeout runs the single-key command of an operator whose
+// second key did not come in time.
+func (m *model) keySequenceTimeout(msg keySeqTimeoutMsg) tea.Cmd {
+	if m.pendingOp == "" || msg.seq != m.pendingSeq {
+		return nil
+	}
+	op := m.pendingOp
+	m.pendingOp = ""
+	m.status = ""
+	if c, ok := commandForKey(op); ok {
+		return m.runCommand(c)
+	}
+	return nil
+}
+
+// confirmRange runs a on the entries from..to-1 of the list as shown,
+// after asking unless the policy of a is never.
+func (m *model) confirmRange(a action, from, to int) tea.Cmd {
+	if m.bulk != nil {
+		m.status = "a bulk operation is already running"
+		return nil
+	}
+	if from >= to {
+		return nil
+	}
+	if a == actionDelete && m.cfg.Trash {
+		a, m.confirmTrash = actionHold, true
+	}
+	var ids []string
+	for _, e := range m.entries[from:to] {
+		ids = append(ids, e.ID)
+	}
+	return m.askOrRun(a, ids, "")
+}
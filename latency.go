@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// With show_latency (-latency) postdel times the programs it runs, per
+// program: the status line shows how long the last mailq, postcat,
+// postsuper, … took, "[mailq 4.2s · postcat 180ms]", and on exit the count,
+// mean and maximum of each go to the log. On a large queue this tells when
+// listing has become slow enough for -after-delete remove (see "Large
+// queues" in the README). Over ssh the program on the host is counted, the
+// time includes the connection.
+
+// measureLatency is whether commands are timed. Like echoCommands it is
+// read by the workers.
+var measureLatency atomic.Bool
+
+// latency is the timing of one program.
+type latency struct {
+	count      int
+	last, max  time.Duration
+	total      time.Duration
+	lastFailed bool
+}
+
+// latencies are the timings by program name.
+var latencies = struct {
+	sync.Mutex
+	by map[string]*latency
+}{by: map[string]*latency{}}
+
+// recordLatency adds a run of the program of args that took took.
+func recordLatency(args []string, took time.Duration, failed bool) {
+	if !measureLatency.Load() || len(args) == 0 {
+		return
+	}
+	name := programName(args)
+	latencies.Lock()
+	defer latencies.Unlock()
+	l := latencies.by[name]
+	if l == nil {
+		l = &latency{}
+		latencies.by[name] = l
+	}
+	l.count++
+	l.last, l.total, l.lastFailed = took, l.total+took, failed
+	l.max = max(l.max, took)
+}
+
+// programName is the program args run, on the host for ssh.
+func programName(args []string) string {
+	prog := args[0]
+	if filepath.Base(prog) == "ssh" {
+		if i := slices.Index(args, "--"); i >= 0 && i+1 < len(args) {
+			prog = strings.Trim(args[i+1], "'")
+		}
+	}
+	return filepath.Base(prog)
+}
+
+// latencyNames returns the programs timed so far, sorted.
+func latencyNames() []string {
+	names := make([]string, 0, len(latencies.by))
+	for name := range latencies.by {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// latencyStatus is the status line prefix, "" when not measuring or
+// nothing ran yet.
+func latencyStatus() string {
+	if !measureLatency.Load() {
+		return ""
+	}
+	latencies.Lock()
+	defer latencies.Unlock()
+	var parts []string
+	for _, name := range latencyNames() {
+		l := latencies.by[name]
+		part := name + " " + formatLatency(l.last)
+		if l.lastFailed {
+			part += "!"
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(parts, " · ") + "] "
+}
+
+// formatLatency renders d in milliseconds below a second, "180ms", and in
+// tenths of seconds above, "4.2s".
+func formatLatency(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+// logLatencies writes the timings of the session to the log.
+func logLatencies() {
+	if !measureLatency.Load() {
+		return
+	}
+	latencies.Lock()
+	defer latencies.Unlock()
+	for _, name := range latencyNames() {
+		l := latencies.by[name]
+		logger.Info("latency", "program", name, "runs", l.count,
+			"mean", (l.total / time.Duration(l.count)).Round(time.Millisecond),
+			"max", l.max.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// With three panes ('|' or three_panes = true) the details are split: the
+// envelope and the decoded headers in a middle pane, the body on the
+// right. Terminals narrower than threePaneMinWidth keep two panes.
+
+// threePaneMinWidth is the narrowest terminal that gets three panes.
+const threePaneMinWidth = 150
+
+// focusHeaders is m.focus on the middle pane; 0 is the list, 1 the
+// details or the body.
+const focusHeaders = 2
+
+// showsThreePanes reports whether the middle pane is shown.
+func (m model) showsThreePanes() bool {
+	return m.threePanes && m.termWidth >= threePaneMinWidth && !m.cfg.Plain
+}
+
+// cycleFocus moves the focus to the next pane from left to right; zoomed
+// the list is left out.
+func (m *model) cycleFocus() {
+	switch {
+	case m.zoomed && m.showsThreePanes():
+		m.focus = focusHeaders + 1 - m.focus // 1 and 2 take turns
+	case m.zoomed:
+	case !m.showsThreePanes():
+		m.focus = 1 - min(m.focus, 1)
+	case m.focus == 0:
+		m.focus = focusHeaders
+	case m.focus == focusHeaders:
+		m.focus = 1
+	default:
+		m.focus = 0
+	}
+}
+
+// toggleThreePanes switches between two and three panes and loads the
+// details again to split or join them.
+func (m *model) toggleThreePanes() tea.Cmd {
+	m.threePanes = !m.threePanes
+	m.resizePanes()
+	m.syncLeft()
+	switch {
+	case !m.threePanes:
+		m.status = "two panes"
+	case !m.showsThreePanes():
+		m.status = "three panes from a terminal width of 150 columns, two until then"
+	default:
+		m.status = "three panes: list, headers, body"
+	}
+	if m.focus == focusHeaders && !m.showsThreePanes() {
+		m.focus = 1
+	}
+	if m.selected >= len(m.entries) || m.subjectView != nil || m.destView != nil || m.hdrCompare != nil {
+		return nil
+	}
+	return m.runPostcatCmd(m.entries[m.selected].ID)
+}
+
+// splitMessage cuts postcat output into the header section of the message
+// and its body. ok is false when there is no message content to cut.
+func splitMessage(postcat string) (headers, body string, ok bool) {
+	i := strings.Index(postcat, "*** MESSAGE CONTENTS")
+	if i < 0 {
+		return "", postcat, false
+	}
+	text := postcat[i:]
+	nl := strings.IndexByte(text, '\n')
+	if nl < 0 {
+		return "", postcat, false
+	}
+	text = text[nl+1:]
+	headers, body, _ = strings.Cut(text, "\n\n")
+	for _, end := range []string{"*** HEADER EXTRACTED", "*** MESSAGE FILE END"} {
+		if j := strings.Index(body, end); j >= 0 {
+			body = body[:j]
+		}
+	}
+	return headers + "\n", strings.TrimRight(body, "\n") + "\n", true
+}
@@ -0,0 +1,29 @@
+package main
+
+import "strings"
+
+// Messages from Windows mailers end their lines with CRLF, spam often with
+// bare CRs; printed as they are, the CRs garble the details. The text is
+// normalized before it is shown, as config.LineEndings says.
+
+// Values of config.LineEndings.
+const (
+	lineEndingsRaw     = "raw"     // as postcat printed it
+	lineEndingsLF      = "lf"      // CRLF and bare CR become LF
+	lineEndingsVisible = "visible" // like lf, with a ␍ where each CR was
+)
+
+var lineEndingsModes = []string{lineEndingsRaw, lineEndingsLF, lineEndingsVisible}
+
+// normalizeLineEndings returns text with its line endings as mode says.
+func normalizeLineEndings(text, mode string) string {
+	if mode == lineEndingsRaw || !strings.Contains(text, "\r") {
+		return text
+	}
+	mark := ""
+	if mode == lineEndingsVisible {
+		mark = disabledStyle.Render("␍")
+	}
+	text = strings.ReplaceAll(text, "\r\n", mark+"\n")
+	return strings.ReplaceAll(text, "\r", mark+"\n")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Parsing the listing of a queue with a million messages takes a while.
+// The parsers report how many bytes of the output they have gone through,
+// and while they run the loading screen and the status line show the
+// progress with the rate and the time left, so a slow parse does not look
+// like a hang.
+
+// parseProgress sums up the parsers running at the moment, one per host.
+type parseProgress struct {
+	mu      sync.Mutex
+	running int
+	started time.Time
+
+	total, done atomic.Int64 // bytes of output, and of it parsed
+
+	notify func(tea.Msg) // set in main to start the ticks, see parseTickMsg
+}
+
+// listParse is the progress of the listing being parsed.
+var listParse parseProgress
+
+// parseTickMsg updates the progress shown while a listing is parsed; start
+// is set on the one sent when parsing begins.
+type parseTickMsg struct{ start bool }
+
+// parseProgressShown is how long a parse runs before its progress is
+// shown; quick ones would only flicker.
+const parseProgressShown = 300 * time.Millisecond
+
+// begin registers a parser of total bytes of output.
+func (p *parseProgress) begin(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running == 0 {
+		p.started = time.Now()
+		p.total.Store(0)
+		p.done.Store(0)
+		if p.notify != nil {
+			go p.notify(parseTickMsg{start: true})
+		}
+	}
+	p.running++
+	p.total.Add(int64(total))
+}
+
+// advance counts n more bytes as parsed.
+func (p *parseProgress) advance(n int) {
+	p.done.Add(int64(n))
+}
+
+// end unregisters a parser.
+func (p *parseProgress) end() {
+	p.mu.Lock()
+	p.running--
+	p.mu.Unlock()
+}
+
+// String renders the progress for the status line, "" when nothing is
+// parsed or not for long.
+func (p *parseProgress) String() string {
+	p.mu.Lock()
+	running, started := p.running, p.started
+	p.mu.Unlock()
+	elapsed := time.Since(started)
+	if running == 0 || elapsed < parseProgressShown {
+		return ""
+	}
+	done, total := p.done.Load(), max(p.total.Load(), 1)
+	s := fmt.Sprintf("parsing the queue listing: %d%% (%s of %s", 100*done/total, formatSize(done), formatSize(total))
+	if rate := float64(done) / elapsed.Seconds(); rate > 0 {
+		left := time.Duration(float64(total-done) / rate * float64(time.Second))
+		s += fmt.Sprintf(", %s/s, about %s left", formatSize(int64(rate)), left.Round(time.Second))
+	}
+	return s + ")"
+}
+
+// updateParseProgress shows the progress and ticks again while parsing
+// goes on.
+func (m *model) updateParseProgress(msg parseTickMsg) tea.Cmd {
+	if msg.start && m.parseTicking {
+		return nil
+	}
+	m.parseStatus = listParse.String()
+	listParse.mu.Lock()
+	running := listParse.running
+	listParse.mu.Unlock()
+	if running == 0 {
+		m.parseTicking = false
+		return nil
+	}
+	m.parseTicking = true
+	return tea.Tick(250*time.Millisecond, func(time.Time) tea.Msg { return parseTickMsg{} })
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// logger records what postdel does for diagnosing problems. The terminal
+// belongs to the TUI, so it writes to a file, and only with -log-file or
+// -debug; otherwise every call is dropped before formatting.
+var logger = slog.New(slog.DiscardHandler)
+
+// debugging is set while logging at debug level. The busy paths (every
+// message of the TUI, every listing) check it before building their log
+// attributes, so without -debug they cost nothing.
+var debugging bool
+
+// defaultLogPath is where -debug writes without -log-file.
+func defaultLogPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "postdel-debug.log"
+	}
+	return filepath.Join(dir, "postdel", "debug.log")
+}
+
+// openLog starts logging to path at the given level and returns the file to
+// close on exit.
+func openLog(path string, level slog.Level) (io.Closer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	logger = slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: level}))
+	debugging = level <= slog.LevelDebug
+	logger.Info("postdel started", "pid", os.Getpid(), "args", os.Args[1:])
+	return f, nil
+}
+
+// runOutput runs cmd like cmd.Output and logs the invocation.
+func runOutput(cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	out, err := cmd.Output()
+	var stderr []byte
+	if ee, ok := err.(*exec.ExitError); ok {
+		stderr = ee.Stderr
+	}
+	logCommand(cmd, start, out, stderr, err)
+	return out, err
+}
+
+// runCombinedOutput runs cmd like cmd.CombinedOutput and logs the
+// invocation.
+func runCombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	var diag []byte
+	if err != nil {
+		diag = out
+	}
+	logCommand(cmd, start, out, diag, err)
+	return out, err
+}
+
+// logCommand logs a finished command: at debug level always, as a warning
+// when it failed. It is echoed with show_commands too.
+func logCommand(cmd *exec.Cmd, start time.Time, out, diag []byte, err error) {
+	exit := -1
+	if cmd.ProcessState != nil {
+		exit = cmd.ProcessState.ExitCode()
+	}
+	took := time.Since(start)
+	recordLatency(cmd.Args, took, err != nil)
+	echoCommand(commandRunMsg{at: start, args: cmd.Args, exit: exit, took: took, failed: err != nil})
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+	attrs := []any{
+		"cmd", strings.Join(cmd.Args, " "),
+		"exit", exit,
+		"duration", took.Round(time.Millisecond),
+		"output_bytes", len(out),
+	}
+	if err != nil {
+		attrs = append(attrs, "err", err)
+	}
+	if d := bytes.TrimSpace(diag); len(d) > 0 {
+		attrs = append(attrs, "stderr", firstLine(string(d)))
+	}
+	logger.Log(context.Background(), level, "command", attrs...)
+}
+
+// logMsg logs the type of a message the TUI handles, never what it
+// carries: keys typed into a prompt or message content stay out of the
+// log.
+func logMsg(msg tea.Msg) {
+	logger.Debug("update", "msg", fmt.Sprintf("%T", msg))
+}
+
+// parseStats counts what a queue parser made of its output.
+type parseStats struct {
+	lines, entries, recipients, skipped int
+}
+
+// log logs the statistics of one listing with parser.
+func (s parseStats) log(parser string) {
+	if debugging {
+		logger.Debug("listing parsed", "parser", parser, "lines", s.lines, "entries", s.entries,
+			"recipients", s.recipients, "skipped", s.skipped)
+	}
+}
+
+// logCache logs how many of the listed messages a cache by queue ID knew
+// (hits) and how many it fetches (misses).
+func logCache(name string, hits, misses int) {
+	if debugging {
+		logger.Debug("cache", "name", name, "hits", hits, "misses", misses)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Mail submitted with sendmail on this machine waits in the maildrop queue
+// until the pickup daemon moves it into incoming, under a new queue ID.
+// postqueue -j and showq name the queue; with mailq, which shows maildrop
+// files like deferred ones, they are recognized by their file in the
+// maildrop directory (readable by root). Such a message cannot be held,
+// released, requeued or expired, only deleted with "postsuper -d ID
+// maildrop", which bulk deletes run for each of them; if it stays, pickup
+// is not running.
+
+// maildropNotice heads the details of a message in maildrop.
+const maildropNotice = "In maildrop: submitted on this machine and waiting for the pickup daemon, which moves it to incoming under a new queue ID. If it stays here, pickup is not running (postfix status, master.cf)."
+
+// markMaildrop sets the queue of the entries whose file lies in the
+// maildrop directory, where the listing could not tell.
+func markMaildrop(entries []queueEntry) {
+	files, err := os.ReadDir(filepath.Join(queueDirectory(), "maildrop"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+	inMaildrop := map[string]bool{}
+	for _, f := range files {
+		inMaildrop[f.Name()] = true
+	}
+	for i := range entries {
+		if inMaildrop[entries[i].ID] {
+			entries[i].Queue = "maildrop"
+		}
+	}
+}
+
+// maildropRefusal explains why a cannot run on a message in maildrop, ""
+// if it can.
+func maildropRefusal(a action) string {
+	if a == actionDelete || !a.perEntry() {
+		return ""
+	}
+	return "the message is in maildrop waiting for pickup: " + a.String() + " is not possible there, only delete; it is in incoming under a new ID once picked up"
+}
+
+// deleteMaildrop deletes the maildrop message id, which postsuper only
+// looks for when the queue is named, and returns how many messages
+// postsuper deleted: 0 when pickup took it meanwhile.
+func deleteMaildrop(host, id string) (int, error) {
+	if backend.deleteMaildrop == nil {
+		return 0, fmt.Errorf("%s has no maildrop queue", backend.title)
+	}
+	ctx, cancel := commandContext(context.Background(), "postsuper")
+	defer cancel()
+	cmd := backend.deleteMaildrop(ctx, host, id)
+	out, err := runCombinedOutput(cmd)
+	if err = commandError(ctx, cmd, err, out); err != nil {
+		return 0, err
+	}
+	return countSum(backend.count(out, actionDelete, []string{id})), nil
+}
+
+// deleteMaildrops deletes the maildrop messages of a bulk delete one at a
+// time, as "postsuper -d -" passes them over, and adds them to res.
+func deleteMaildrops(ctx context.Context, res *bulkResult, entries []queueEntry) {
+	deleted := 0
+	for i, e := range entries {
+		if ctx.Err() != nil {
+			res.total += len(entries) - i
+			res.failedIDs += len(entries) - i
+			res.err = fmt.Errorf("cancelled before %d messages in maildrop", len(entries)-i)
+			break
+		}
+		res.total++
+		n, err := deleteMaildrop(e.Host, e.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			res.failedIDs++
+			res.failures = append(res.failures, fmt.Errorf("%s in maildrop: %w", e.ID, err))
+		}
+		deleted += n
+	}
+	res.affected += deleted
+	if deleted > 0 {
+		res.summary = append(res.summary, fmt.Sprintf("Deleted from maildrop: %d messages", deleted))
+	}
+}
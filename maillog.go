@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// alt+l shows the lines of the mail log that name the selected message:
+// where it came from, each delivery attempt and why it failed, in a popup
+// over the list. The log is searched with grep for the queue ID, on the
+// message's host with -hosts; mail_log names the file, by default the
+// usual ones of the mail server (/var/log/mail.log or /var/log/maillog for
+// Postfix). Only the current file is read, not the rotated ones. esc or
+// alt+l closes it; any other key closes it too and does its usual work,
+// like 'p'.
+
+// mailLogView is the open popup.
+type mailLogView struct {
+	id   string
+	view viewport.Model
+}
+
+// mailLogMsg delivers the log lines of a message.
+type mailLogMsg struct {
+	id, text string
+	files    []string
+	err      error
+}
+
+// mailLogFiles are the log files alt+l searches.
+func (m model) mailLogFiles() []string {
+	if m.cfg.MailLog != "" {
+		return []string{m.cfg.MailLog}
+	}
+	return backend.logs
+}
+
+// openMailLog opens the popup for the selected message and searches the
+// log for it.
+func (m *model) openMailLog() tea.Cmd {
+	if m.selected >= len(m.entries) {
+		return nil
+	}
+	e := m.entries[m.selected]
+	width := max(m.termWidth-8, 20)
+	height := max(min(m.termHeight-10, 30), 3)
+	m.mailLog = &mailLogView{id: e.ID, view: m.popupViewport(width, height)}
+	m.mailLog.view.SetContent("Searching the mail log…")
+	id, host, files := e.ID, e.Host, m.mailLogFiles()
+	return m.pool.submit("maillog "+id, prioSelected, func(ctx context.Context) tea.Msg {
+		ctx, cancel := commandContext(ctx, "grep")
+		defer cancel()
+		// -s: a missing one of the usual files is no error
+		args := append([]string{"-h", "-s", "-F", "-w", "--", id}, files...)
+		var cmd *exec.Cmd
+		if host == "" {
+			cmd = exec.CommandContext(ctx, "grep", args...)
+		} else {
+			cmd = sshCommand(ctx, host, "grep", args...)
+		}
+		out, err := runOutput(cmd)
+		var ee *exec.ExitError
+		if len(out) > 0 || errors.As(err, &ee) && ee.ExitCode() == 1 {
+			// lines found, maybe with a file missing, or none at all
+			err = nil
+		}
+		return mailLogMsg{id: id, text: string(out), files: files, err: commandError(ctx, cmd, err, nil)}
+	})
+}
+
+// showMailLog fills the popup with the lines found.
+func (m *model) showMailLog(msg mailLogMsg) {
+	if m.mailLog == nil || m.mailLog.id != msg.id {
+		return
+	}
+	files := strings.Join(msg.files, ", ")
+	switch {
+	case msg.err != nil:
+		m.mailLog.view.SetContent(warningStyle.Render(fmt.Sprintf("cannot read %s: %s (mail_log names the file)", files, firstLine(msg.err.Error()))))
+	case strings.TrimSpace(msg.text) == "":
+		m.mailLog.view.SetContent(fmt.Sprintf("no line of %s names %s; rotated files are not read", files, msg.id))
+	default:
+		m.mailLog.view.SetContent(lipgloss.NewStyle().Width(m.mailLog.view.Width).Render(strings.TrimRight(msg.text, "\n")))
+		m.mailLog.view.GotoBottom()
+	}
+}
+
+// updateMailLog handles a key while the popup is open. Keys other than
+// scrolling close it and are not handled.
+func (m *model) updateMailLog(key string) bool {
+	switch key {
+	case "up":
+		m.mailLog.view.LineUp(1)
+	case "down":
+		m.mailLog.view.LineDown(1)
+	case "pgup":
+		m.mailLog.view.HalfViewUp()
+	case "pgdown":
+		m.mailLog.view.HalfViewDown()
+	case "esc", "alt+l":
+		m.mailLog = nil
+	default:
+		m.mailLog = nil
+		return false
+	}
+	return true
+}
+
+// mailLogPopup renders the popup centered over the list.
+func (m model) mailLogPopup() string {
+	title := fmt.Sprintf("%s in the mail log — up/down scroll, esc closes", m.mailLog.id)
+	return m.dialogBox(m.mailLog.view.Width+4, lipgloss.Center, title+"\n\n"+m.mailLog.view.View())
+}
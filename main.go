@@ -3,22 +3,86 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"os/user"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/charmbracelet/lipgloss"
 )
 
-// mailqIDsMsg holds the list of queue IDs parsed from mailq.
-type mailqIDsMsg []string
+// queueEntry is one message as listed by postqueue -j or mailq.
+type queueEntry struct {
+	ID         string
+	Queue      string // one of queueNames; mailq only tells active (*), hold (!) and deferred
+	Size       int64
+	Arrival    time.Time
+	Sender     string
+	Recipients []string
+	Reason     string // deferral reason, without the parentheses
+	Host       string // host it is queued on with -hosts, "" for this machine
+
+	Attempts      int  // delivery attempts so far, estimated; see timing.go
+	attemptsKnown bool // whether Attempts was estimated
+
+	Score      float64 // of the classifier, see classify.go
+	scoreKnown bool    // whether the message was classified
+}
+
+// nullSenderLabel shows the null sender <> of bounces, which would
+// otherwise be an empty cell.
+const nullSenderLabel = "<> (null sender / bounce)"
+
+// senderLabel renders the sender of e for display.
+func (e queueEntry) senderLabel() string {
+	if e.Sender == "" {
+		return nullSenderLabel
+	}
+	return e.Sender
+}
+
+// noRecipients reports an entry without any recipient left: either every
+// recipient was delivered and the queue file is leftover, or it is corrupt.
+// Such entries are usually safe to clean up.
+func (e queueEntry) noRecipients() bool {
+	return len(e.Recipients) == 0
+}
+
+// mailqIDsMsg holds the list of queue entries (from postqueue -j or mailq).
+type mailqIDsMsg []queueEntry
 
 // postcatMsg is the output of "postcat -q <ID>".
-type postcatMsg string
+type postcatMsg struct {
+	id   string
+	text string
+
+	// partial is set for active messages whose queue file was incomplete
+	// or locked because Postfix is delivering them right now.
+	partial bool
+	err     error
+
+	timing string    // arrival and delivery attempts, see timingSummary
+	retry  time.Time // next delivery attempt, zero if unknown; see countdown.go
+
+	headersOnly bool // postcat -h, see autoloadHeaders
+}
+
+// detailsCancelledMsg ends the loading of the details of id when its
+// postcat was cancelled before it answered.
+type detailsCancelledMsg struct {
+	id string
+}
 
 // errorMsg represents any error running external commands.
 type errorMsg error
@@ -29,9 +93,12 @@ type model struct {
 	warningReady bool
 	warningView  viewport.Model
 
-	entries  []string // all Queue-IDs from mailq
-	selected int
-	ready    bool
+	entries   []queueEntry // entries shown in the list (allEntries minus hidden queues)
+	selected  int
+	ready     bool
+	loadingID string // the message whose details are being fetched, "…" in the list until they arrive
+
+	disk diskUsage // free space of the spool at the last listing, see diskspace.go
 
 	left      viewport.Model
 	right     viewport.Model
@@ -40,9 +107,196 @@ type model struct {
 	err       error
 	focus     int // 0=left, 1=right
 
-	showDeleteDialog bool
-	termWidth        int
-	termHeight       int
+	flags cliFlags // kept to re-apply them when the config is reloaded
+	cfg   config
+
+	allEntries   []queueEntry    // every entry of the last listing
+	hiddenQueues map[string]bool // queues toggled off with the keys 1-6
+
+	actionableOnly bool // 'A': hide entries the user cannot act on
+
+	tags map[string]string // session tags by queue ID, see tags.go
+
+	filter  listFilter // terms like tag:spam from the '/' prompt, see filter.go
+	sort    sortOrder  // 'o'/'O' or -sort, see sort.go
+	unknown int        // entries the filter left out for lack of a size or arrival
+
+	dialog            *confirmDialog // the open confirmation, nil if none; see dialog.go
+	confirmAction     action
+	confirmIDs        []string // range to confirm for a bulk action, nil for the selected entry
+	confirmSummary    string   // selection summary shown when confirming marked entries
+	confirmTrash      bool     // the hold confirmed is a delete into the trash
+	confirmEntry      queueEntry // the entry a single action asks about, taken when the dialog opened
+	confirmShown      string     // scope of the shown messages confirmed for alt+d, see shown.go
+
+	displayed displayedMessage // the message the details show, see target.go
+
+	trash     map[string]bool // held for deletion in trash mode, by queue ID, see trash.go
+	showTrash bool            // 'b' shows the trash pane
+
+	marked map[string]bool // multi-select, by queue ID, see marks.go
+
+	bookmarks map[string]bool       // 'm', by queue ID, see bookmarks.go
+	review    map[string]queueEntry // 'M', by queue ID, see review.go
+	jumps     jumpList              // alt+left/alt+right, see jumps.go
+
+	columns      []string          // of the list, see columns.go
+	chooser      *columnChooser    // column chooser ('L'), nil while closed
+	split        int               // list pane width in percent, 0 to fit the columns
+	dragging     bool              // the border of the list is being dragged, see mouse.go
+	contentTypes map[string]string // column labels by queue ID, "" while fetching; see contenttype.go
+	previews     map[string]string // first body lines by queue ID, "" while fetching; see bodypreview.go
+	scores       map[string]classification // verdicts of the classifier by queue ID, see classify.go
+	scoreBacklog []queueEntry              // background classifications not yet in the pool
+	scoreJobs    int                       // background classifications in the pool
+	scoreArrived int                       // verdicts since the list was last filtered
+
+	attachments   map[string]string // attachYes, attachNo, … by queue ID, "" while fetching; see attachments.go
+	anyAttachment bool              // a message was found with attachments, the list shows the paperclips
+	attachPending int               // attachments being fetched for attach:
+	attachArrived int               // answers since the list was last filtered
+
+	pendingDeletes   map[string]queueEntry   // bulk deletes not yet seen gone, by entryKey; see sendertally.go
+	deletesConfirmed int                     // messages postsuper reported deleted of pendingDeletes
+	deletedBySender  map[string]*senderCount // what the session deleted per envelope sender
+
+	retryTimes        map[string]time.Time // next attempts for the retry: term, nil until read; see timing.go
+	attemptsEstimated bool                 // allEntries have their Attempts, see estimateAttempts
+	retryWanted       bool                 // the listing needs retryTimes, see fetchRetryTimes
+	retryLoading      bool                 // retryTimes are being read
+	writable          map[string]bool      // entries whose queue file can be changed, nil until read; see actionable.go
+
+	countdown countdown // to the next attempt of the selected message, see countdown.go
+
+	diffFirst *queueEntry // entry marked with '=' to be compared with the next one
+
+	hdrCompare *headerCompare // header comparison of the selection ('H')
+
+	peek *peekView // the 'p' popup, nil while closed
+
+	mailLog *mailLogView // the alt+l popup, nil while closed
+
+	serverInfo *serverInfoView // the 'P' popup, nil while closed
+
+	entryMenu *entryMenu // enter in the list, nil while closed; see entrymenu.go
+
+	showCommands bool            // echo the commands run, see transparency.go
+	ranCommands  []commandRunMsg // the last commandHistorySize, oldest first
+
+	triage *triageState // 'ctrl+t', nil when not triaging
+
+	preview *bulkPreview // 'v' in the confirmation of a bulk action, nil while closed
+
+	cleanup *cleanupWizard // 'W', nil while closed; see cleanup.go
+
+	parseStatus  string // progress of parsing a large listing, see listprogress.go
+	parseTicking bool
+
+	errLog     []loggedError // the errors of the session, see errlog.go
+	errLogView *errorLogView // the ctrl+e popup, nil while closed
+
+	// three panes ('|'), see layout.go: mid shows midRaw, the headers of
+	// the message midID
+	threePanes bool
+	mid        viewport.Model
+	midRaw     string
+	midID      string
+
+	zoomed    bool // the details take the whole width ('Z'), see zoom.go
+	zoomFocus int  // the focus before zooming
+
+	// messages a bulk delete failed on, see retry.go; retryPending until
+	// the listing after it
+	retryIDs     []string
+	retryPending bool
+
+	subjects    map[string]string // decoded subjects by queue ID, see subjects.go
+	subjectView *subjectClusters  // subject clusters ('J'), nil while not shown
+	destView    *destinationTable // destinations (alt+r), nil while not shown
+
+	subjectBacklog []queueEntry // subjects pending but not yet in the pool, see submitSubjects
+	subjectJobs    int          // subjects in the pool
+
+	unlisted int // deletes only removed from the list since the last listing, see afterdelete.go
+
+	settingsPanel  *settingsPanel // ',', nil while closed; see settings.go
+	autoRefreshSeq int            // of the auto_refresh ticks, see autorefresh.go
+
+	// visual mode ('V'): the range between visualAnchor and selected
+	visual       bool
+	visualAnchor int
+
+	// vim-style key sequences: the operator waiting for its second key
+	pendingOp  string
+	pendingSeq int
+
+	// readOnly disables every action that changes the queue; set when the
+	// user may not run postsuper or by --read-only. Nothing in the session
+	// can clear it.
+	readOnly       bool
+	readOnlyReason string // shown when a destructive key is refused
+
+	// most recent per-entry action (d/h/u/r), repeated with '.'
+	lastAction    action
+	hasLastAction bool
+
+	noDeliver string // why postqueue -i failed, then 'i' requeues; see deliver.go
+
+	// advanceFrom is the entry the last per-entry action ran on; the
+	// refresh after it selects the entry following it, ready for '.'
+	advanceFrom string
+	advancePos  int
+
+	// bulk requeue: the typed "yes" of its dialog and the running operation
+	requeueInput textinput.Model
+	requeueScope int // requeueDeferred, requeueAll or requeueShown
+	bulk         *bulkOp
+	status       string // one-line feedback below the panes
+
+	pool      *pool // bounded runner for postcat and other background commands
+	showDebug bool  // show pool statistics in the status line
+
+	// queue-wide "find in all messages"
+	search           *search
+	showSearchPrompt bool
+	searchInput      textinput.Model
+	searchHistory    promptHistory // searches and filters of this session
+	searchOpts       searchOptions // toggled in the prompt, kept for the session
+	searchErr        string        // syntax error of the filter, shown next to the prompt
+	searchPreview    string        // what the filter being typed matches, see previewFilter
+	rightMarks       []int         // lines of rightRaw matching the search, for the minimap
+
+	shutdownSignal os.Signal // set once SIGINT/SIGTERM arrived
+	exitReport     string    // printed to stderr after the TUI has closed
+
+	startedAt    time.Time      // for the summary on exit, see summary.go
+	listedAt     time.Time      // when allEntries was listed
+	firstListing *queueSnapshot // compared with by 'S', see sessiondiff.go
+	snapshot     *queueSnapshot // compared with by alt+c, see snapshot.go
+
+	aboveThreshold bool // the last listing reached hook.threshold, see thresholdHook
+	changes      map[action]int // messages changed per action this session
+
+	// ctrl+p command palette
+	showPalette     bool
+	paletteInput    textinput.Model
+	paletteSelected int
+	palettePresets  bool // opened as the preset picker ('F')
+
+	showPresetPrompt bool // naming the filter to save, see presets.go
+	presetInput      textinput.Model
+
+	showPastePrompt bool // 'I': queue IDs to select, see pasteids.go
+	pasteInput      textinput.Model
+
+	exportID    string // 'E': the message to save as .eml, "" when not asking; see eml.go
+	exportInput textinput.Model
+
+	quitAfterBulk bool // quit as soon as the running bulk operation is done
+	termWidth         int
+	termHeight        int
+
+	title string // last set terminal title, see termtitle.go
 
 	// Flag, ob wir gerade frisch gelöscht haben
 	justDeleted bool
@@ -55,14 +309,17 @@ var (
 			Padding(0, 1)
 
 	selectedStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("229"))
+			Foreground(colorHighlight)
 
 	warningBorder = lipgloss.NewStyle().
 			Border(lipgloss.DoubleBorder()).
 			Padding(1, 2).
-			Foreground(lipgloss.Color("196"))
+			Foreground(colorAlert)
+
+	warningStyle = lipgloss.NewStyle().
+			Foreground(colorWarning)
 
-	focusBorderColor = lipgloss.Color("229")
+	focusBorderColor = colorHighlight
 
 	dialogBoxStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
@@ -72,44 +329,271 @@ var (
 
 // Run mailq, parse IDs.
 func runMailqCmd() tea.Msg {
-	out, err := exec.Command("mailq").Output()
+	entries, err := listQueue()
+	if partialListing(err) {
+		return waitForHosts(entries, err.(listFailures))
+	}
 	if err != nil {
 		return errorMsg(err)
 	}
-	ids := parseMailqForIDs(out)
-	return mailqIDsMsg(ids)
+	return mailqIDsMsg(entries)
+}
+
+// listHostQueue lists the queue of host ("" for this machine) with
+// "postqueue -j", or where that does not work with mailq or "postqueue -p",
+// see listPostfixQueue. The corrupt queue is only read on this machine.
+func listHostQueue(host string) ([]queueEntry, error) {
+	entries, err := listPostfixQueue(host)
+	if err != nil {
+		return nil, err
+	}
+	if host != "" {
+		for i := range entries {
+			entries[i].Host = host
+		}
+		logger.Debug("queue listed", "host", host, "entries", len(entries))
+		return entries, nil
+	}
+	markMaildrop(entries)
+	corrupt := listCorrupt()
+	logger.Debug("queue listed", "entries", len(entries), "corrupt", len(corrupt))
+	return append(entries, corrupt...), nil
+}
+
+// listMailq runs mailq, or "postqueue -p" which prints the same, and
+// returns the parsed entries. mailq cannot tell the incoming and corrupt
+// queues apart from the deferred one.
+func listMailq(host, name string, args ...string) ([]queueEntry, error) {
+	ctx, cancel := commandContext(context.Background(), name)
+	defer cancel()
+	cmd := hostCommand(ctx, host, name, args...)
+	out, err := runOutput(cmd)
+	if err != nil {
+		return nil, commandError(ctx, cmd, err, nil)
+	}
+	entries := parseMailq(out)
+	if len(entries) == 0 && len(bytes.TrimSpace(out)) > 0 && !bytes.Contains(out, []byte("is empty")) {
+		// neither entries nor "Mail queue is empty": not mailq as we know it
+		return nil, &cmdError{args: cmd.Args, kind: ErrParse, err: errors.New("no queue entries found"), output: firstLine(string(out))}
+	}
+	return entries, nil
 }
 
-// Run postcat -q <ID>.
-func runPostcatCmd(queueID string) tea.Cmd {
+// Run postcat -q <ID> through the worker pool, ahead of any prefetching.
+func (m *model) runPostcatCmd(queueID string) tea.Cmd {
+	return m.loadDetails(queueID, false)
+}
+
+// loadDetails runs postcat for the details of queueID, of only its
+// headers with headersOnly.
+func (m *model) loadDetails(queueID string, headersOnly bool) tea.Cmd {
+	m.pruneFetches(queueID)
+	m.loadingID = queueID
+	cmd := m.submitDetails(queueID, headersOnly)
 	return func() tea.Msg {
-		out, err := exec.Command("/usr/sbin/postcat", "-q", queueID).Output()
+		if msg := cmd(); msg != nil {
+			return msg
+		}
+		// dropped from the pool before it ran
+		return detailsCancelledMsg{queueID}
+	}
+}
+
+// submitDetails submits the postcat of loadDetails to the pool.
+func (m *model) submitDetails(queueID string, headersOnly bool) tea.Cmd {
+	active := m.entryQueue(queueID) == "active"
+	if m.entryQueue(queueID) == "corrupt" {
+		return m.pool.submit(queueID, prioSelected, func(ctx context.Context) tea.Msg {
+			return inspectCorrupt(ctx, queueID)
+		})
+	}
+	host := m.entryHost(queueID)
+	var entry queueEntry
+	for _, e := range m.entries {
+		if e.ID == queueID {
+			entry = e
+		}
+	}
+	return m.pool.submit(queueID, prioSelected, func(ctx context.Context) tea.Msg {
+		ctx, cancel := commandContext(ctx, "postcat")
+		defer cancel()
+		cmd := backend.show(ctx, host, queueID, headersOnly)
+		out, err := runOutput(cmd)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return detailsCancelledMsg{queueID}
+		}
+		err = commandError(ctx, cmd, err, nil)
+		if active && !headersOnly && !errors.Is(err, ErrTimeout) && (err != nil || !strings.Contains(string(out), "*** MESSAGE FILE END")) {
+			return postcatMsg{id: queueID, text: string(out), partial: true, err: err}
+		}
 		if err != nil {
 			return errorMsg(err)
 		}
-		return postcatMsg(out)
+		retry, _ := localRetry(entry)
+		return postcatMsg{id: queueID, text: string(out), timing: timingSummary(entry, time.Now()), retry: retry, headersOnly: headersOnly}
+	})
+}
+
+// showDetailsPlaceholder empties the details pane until a message is
+// chosen: with autoload = "off", and after a delete without a listing.
+func (m *model) showDetailsPlaceholder() {
+	m.doneLoading(m.loadingID)
+	m.rightRaw = ""
+	if len(m.entries) > 0 {
+		m.rightRaw = "Select a message to preview it, or press 'l' to load this one."
 	}
+	m.rightMarks = nil
+	m.right.SetContent(m.rightRaw)
 }
 
-// parseMailqForIDs scans mailq output for something that looks like a queue ID.
-func parseMailqForIDs(output []byte) []string {
+// entryQueue returns the queue name of the entry with the given ID.
+func (m model) entryQueue(id string) string {
+	for _, e := range m.entries {
+		if e.ID == id {
+			return e.Queue
+		}
+	}
+	return ""
+}
+
+// pruneFetches cancels fetches that became pointless once selected is
+// shown: earlier "selected" fetches and prefetches far away from it.
+func (m *model) pruneFetches(selected string) {
+	pos := map[string]int{}
+	for i, e := range m.entries {
+		pos[e.ID] = i
+	}
+	m.pool.cancelWhere(func(key string, prio int) bool {
+		if key == selected {
+			return false
+		}
+		if prio == prioBackground {
+			return false
+		}
+		i, ok := pos[key]
+		if !ok || prio == prioSelected {
+			return true
+		}
+		d := i - m.selected
+		return d > prefetchWindow || d < -prefetchWindow
+	})
+}
+
+// parseMailq parses mailq output. Every entry starts with a line
+// "ID[*!] size arrival sender", followed by an optional "(reason)" line and
+// the recipients. A trailing "*" marks an active message, a trailing "!" a
+// held one.
+func parseMailq(output []byte) []queueEntry {
+	return parseMailqAt(output, time.Now())
+}
+
+// parseMailqAt is parseMailq with a fixed "now", used to complete the
+// year-less arrival times.
+func parseMailqAt(output []byte, now time.Time) []queueEntry {
 	scanner := bufio.NewScanner(bytes.NewReader(output))
-	var ids []string
+	var entries []queueEntry
+	var cur *queueEntry
+	var stats parseStats
+	listParse.begin(len(output))
+	defer listParse.end()
+	defer func() {
+		if !debugging {
+			// the count walks every entry again, for a log line not written
+			return
+		}
+		stats.entries = len(entries)
+		for _, e := range entries {
+			stats.recipients += len(e.Recipients)
+		}
+		stats.log("mailq")
+	}()
 	for scanner.Scan() {
+		stats.lines++
+		listParse.advance(len(scanner.Bytes()) + 1)
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
+			cur = nil
 			continue
 		}
 		fields := strings.Fields(line)
-		if len(fields) > 0 && looksLikeQueueID(fields[0]) {
-			ids = append(ids, fields[0])
+		if e, ok := parseEntryLine(fields, now); ok {
+			entries = append(entries, e)
+			cur = &entries[len(entries)-1]
+			continue
+		}
+		if cur == nil || strings.HasPrefix(line, "-") {
+			// header and summary lines
+			logger.Debug("mailq: line skipped", "line", line)
+			stats.skipped++
+			continue
+		}
+		if strings.HasPrefix(line, "(") {
+			cur.Reason = strings.TrimSuffix(strings.TrimPrefix(line, "("), ")")
+			continue
+		}
+		cur.Recipients = append(cur.Recipients, fields...)
+	}
+	return entries
+}
+
+// parseEntryLine parses the first line of a mailq entry.
+func parseEntryLine(fields []string, now time.Time) (queueEntry, bool) {
+	if len(fields) < 2 || !isDigits(fields[1]) {
+		return queueEntry{}, false
+	}
+	id, queue := fields[0], "deferred"
+	switch {
+	case strings.HasSuffix(id, "*"):
+		id, queue = strings.TrimSuffix(id, "*"), "active"
+	case strings.HasSuffix(id, "!"):
+		id, queue = strings.TrimSuffix(id, "!"), "hold"
+	}
+	if !postfixQueueID(id) {
+		return queueEntry{}, false
+	}
+	e := queueEntry{ID: id, Queue: queue}
+	e.Size, _ = strconv.ParseInt(fields[1], 10, 64)
+	if len(fields) >= 6 {
+		e.Arrival = parseArrival(strings.Join(fields[2:6], " "), now)
+	}
+	if len(fields) >= 7 && fields[6] != "MAILER-DAEMON" {
+		// mailq shows the null sender as MAILER-DAEMON, postqueue -j as ""
+		e.Sender = fields[6]
+	}
+	return e, true
+}
+
+// parseArrival parses mailq's "Tue Mar 11 10:00:00". mailq omits the year,
+// so it is taken from now, or the year before if that lies in the future.
+func parseArrival(s string, now time.Time) time.Time {
+	t, err := time.ParseInLocation("Mon Jan _2 15:04:05", s, now.Location())
+	if err != nil {
+		return time.Time{}
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
 		}
 	}
-	return ids
+	return s != ""
 }
 
-// Simplistic check for a Postfix-like queue ID.
+// looksLikeQueueID reports whether s has the form of a queue ID of the
+// mail server of the session.
 func looksLikeQueueID(s string) bool {
+	return backend.queueID(s)
+}
+
+// Simplistic check for a Postfix queue ID, short or long.
+func postfixQueueID(s string) bool {
 	if len(s) < 3 || len(s) > 20 {
 		return false
 	}
@@ -123,34 +607,228 @@ func looksLikeQueueID(s string) bool {
 	return true
 }
 
-// Der eigentliche Löschbefehl. Anschließend refresh per mailq.
-func (m *model) deleteQueueID() tea.Cmd {
-	if m.selected < 0 || m.selected >= len(m.entries) {
-		return nil
+// runAction runs a on the selected entry. Apart from corrupt and maildrop
+// messages the command runs in the returned command, see actionDone.
+func (m *model) runAction(a action) tea.Cmd {
+	var entry queueEntry
+	if a.perEntry() {
+		if m.selected < 0 || m.selected >= len(m.entries) {
+			return nil
+		}
+		entry = m.entries[m.selected]
 	}
-	id := m.entries[m.selected]
+	id := entry.ID
 
-	out, err := exec.Command("postsuper", "-d", id).CombinedOutput()
-	if err != nil {
-		m.err = fmt.Errorf("error running postsuper -d %s: %w\nOutput:\n%s", id, err, string(out))
+	if entry.Queue == "corrupt" {
+		if a != actionDelete {
+			m.status = "corrupt messages can only be inspected and deleted"
+			return nil
+		}
+		if err := deleteCorrupt(id); err != nil {
+			m.status = "error: " + err.Error()
+			return nil
+		}
+		m.recordChange(actionDelete, 1)
+		m.addDeleted(entry)
+		m.justDeleted = true
+		return runMailqCmd
+	}
+
+	if entry.Queue == "maildrop" && a == actionDelete {
+		n, err := deleteMaildrop(entry.Host, id)
+		if errors.Is(err, ErrNotFound) || (err == nil && n == 0) {
+			return m.actedOnNothing(a, id)
+		}
+		if err != nil {
+			return m.handleError(err)
+		}
+		m.recordChange(actionDelete, 1)
+		m.addDeleted(entry)
+		m.justDeleted = true
+		m.pool.cancel(id)
+		return tea.Batch(runMailqCmd, m.cfg.Hook.run(a, entry))
+	}
+
+	if a == actionClearCorrupt && len(queueHosts) > 0 {
+		m.status = "the corrupt queue is only read on this machine, not with -hosts"
 		return nil
 	}
+	hosts := []string{entry.Host}
+	if a == actionFlush {
+		hosts = listedHosts()
+	}
+	done := actionDoneMsg{action: a, entry: entry, pos: m.selected}
+	if a == actionDelete && safeDelete {
+		pacing := m.cfg.Bulk
+		return func() tea.Msg {
+			res := runSafeDelete(context.Background(), entry.Host, a, []string{id}, pacing, nil)
+			done.safe = &res
+			return done
+		}
+	}
+	return func() tea.Msg {
+		done.nothing, done.err = actOn(a, hosts, id)
+		return done
+	}
+}
+
+// actionDoneMsg is an action of runAction that has finished.
+type actionDoneMsg struct {
+	action  action
+	entry   queueEntry // the selected entry, zero for flush and clear-corrupt
+	pos     int        // the selected row when it was started
+	nothing bool       // it found no such message
+	err     error
+	safe    *bulkResult // the result of a safe delete, see safedelete.go
+}
 
-	// Markieren, dass wir gerade gelöscht haben
-	m.justDeleted = true
+// actOn runs a on id on each of hosts; nothing reports that it found no
+// message id to act on.
+func actOn(a action, hosts []string, id string) (nothing bool, err error) {
+	for _, host := range hosts {
+		ctx, cancel := commandContext(context.Background(), "postsuper")
+		cmd := a.command(ctx, host, id)
+		out, err := runCombinedOutput(cmd)
+		err = commandError(ctx, cmd, err, out)
+		cancel()
+		if err != nil && errors.Is(err, ErrNotFound) && a.perEntry() {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if a.perEntry() && backend.name == "postfix" && countSum(backend.count(out, a, []string{id})) == 0 {
+			// postsuper exits 0 for IDs it does not find
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// actionDone records the action of msg and lists the queue again after it.
+func (m *model) actionDone(msg actionDoneMsg) tea.Cmd {
+	a, entry, id := msg.action, msg.entry, msg.entry.ID
+	if msg.safe != nil {
+		m.status = msg.safe.String()
+		if msg.safe.affected == 0 {
+			return runMailqCmd
+		}
+	}
+	if msg.nothing {
+		return m.actedOnNothing(a, id)
+	}
+	if msg.err != nil {
+		return m.handleError(msg.err)
+	}
+	m.recordChange(a, 1)
+	if a == actionDelete {
+		m.addDeleted(entry)
+	}
+	if a.perEntry() {
+		m.advanceFrom, m.advancePos = id, msg.pos
+		m.noteTriaged(a, id)
+	}
+
+	if a == actionDelete && m.cfg.AfterDelete == afterDeleteRemove {
+		m.pool.cancel(id)
+		cmd := tea.Batch(m.removeDeleted(entry), m.cfg.Hook.run(a, entry))
+		if m.triage != nil {
+			// no listing follows that would advance
+			return tea.Batch(cmd, m.triageAdvance())
+		}
+		return cmd
+	}
+
+	if a == actionDelete {
+		m.justDeleted = true
+		m.pool.cancel(id)
+	}
+	if a.perEntry() {
+		return tea.Batch(runMailqCmd, m.cfg.Hook.run(a, entry))
+	}
 	return runMailqCmd
 }
 
+// requestAction opens the confirmation dialog for a, or runs it right away
+// when the configuration says it needs no confirmation.
+func (m *model) requestAction(a action) tea.Cmd {
+	if m.refuseReadOnly() {
+		return nil
+	}
+	if !backend.supports(a) {
+		m.status = unsupported(a)
+		return nil
+	}
+	if a.perEntry() && len(m.entries) == 0 {
+		return nil
+	}
+	if a.perEntry() && !m.visual && len(m.marked) == 0 {
+		if err := m.targetDisplayed(); err != nil {
+			m.status = err.Error()
+			return nil
+		}
+	}
+	if a.perEntry() && !m.visual && len(m.marked) == 0 && m.entries[m.selected].Queue == "maildrop" {
+		if reason := maildropRefusal(a); reason != "" {
+			m.status = reason
+			return nil
+		}
+	}
+	if a.perEntry() {
+		m.lastAction, m.hasLastAction = a, true
+	}
+	m.confirmTrash = false
+	if a == actionDelete && m.cfg.Trash {
+		if q := m.entries[m.selected].Queue; q != "corrupt" && q != "maildrop" {
+			// put on hold instead, 'D' deletes for real
+			a, m.confirmTrash = actionHold, true
+		}
+	}
+	if m.visual && a.perEntry() {
+		return m.requestRangeAction(a)
+	}
+	if len(m.marked) > 0 && a.perEntry() {
+		return m.requestMarkedAction(a)
+	}
+	if m.cfg.needsConfirm(a, 1) || (a == actionDelete && !m.confirmTrash && m.entryProtection() != "") {
+		m.confirmAction = a
+		m.confirmIDs, m.confirmSummary = nil, ""
+		m.confirmEntry = queueEntry{}
+		if a.perEntry() {
+			// by ID: a listing may arrive before the answer
+			m.confirmEntry = m.entries[m.selected]
+		}
+		return m.openActionDialog()
+	}
+	if m.confirmTrash {
+		id := m.entries[m.selected].ID
+		m.addToTrash(id)
+		m.status = "moved " + id + " to the trash (on hold), 'b' shows it, 'D' empties it"
+	}
+	notice := m.activeNotice(a)
+	cmd := m.runAction(a)
+	m.status = strings.TrimSpace(m.status + notice)
+	return cmd
+}
+
+// focusRefreshAfter is how old a listing must be for the terminal getting
+// the focus back to list the queue again, see refresh_on_focus.
+const focusRefreshAfter = 5 * time.Second
+
 // Init: Show warning or run mailq
 func (m model) Init() tea.Cmd {
 	if m.showWarning {
-		return nil
+		return m.autoRefreshTick()
 	}
-	return runMailqCmd
+	return tea.Batch(runMailqCmd, m.autoRefreshTick())
 }
 
 // Update handles all events.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	defer m.recoverCrash()
+	if debugging {
+		logMsg(msg)
+	}
 	switch msg := msg.(type) {
 
 	case tea.WindowSizeMsg:
@@ -170,77 +848,432 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		m.ready = true
-		leftWidth := 14
-		rightWidth := m.termWidth - leftWidth - 8
-
-		m.left.Width = leftWidth
-		m.left.Height = m.termHeight - 5
-		m.right.Width = rightWidth
-		m.right.Height = m.termHeight - 5
-
+		m.resizePanes()
 		m.syncLeft()
+		if m.focus == focusHeaders && !m.showsThreePanes() {
+			m.focus = 1
+		}
 		return m, nil
 
 	case mailqIDsMsg:
+		if m.cfg.StableOrder {
+			msg = stableOrder(m.allEntries, msg)
+		}
+		m.listedAt = time.Now()
+		m.disk = statQueueDisk()
+		m.unlisted = 0
+		m.retryTimes, m.retryWanted = nil, false // read again for the retry: term
+		m.writable = nil
+		m.attemptsEstimated = false
+		m.countDeletes(msg)
+		m.selectFailedDeletes(msg)
+		if m.firstListing == nil {
+			m.firstListing = newSnapshot(msg, m.listedAt)
+		}
+		hook := tea.Batch(m.thresholdHook(len(msg)), m.updateTitle(msg))
+		if m.ready && m.advanceFrom == "" && !m.justDeleted && !m.filter.uses("retry") && sameListing(m.allEntries, msg) {
+			// nothing changed: keep selection, scroll position and details
+			m.allEntries = msg
+			if m.usesAttempts() {
+				m.wantRetryTimes()
+			}
+			return m, tea.Batch(hook, m.fetchRetryTimes())
+		}
+
 		// Neue Liste von IDs
-		m.entries = msg
+		m.allEntries = msg
+		m.pruneMarks()
+		m.pruneBookmarks()
+		m.pruneJumps()
+		m.pruneTrash()
+		m.pruneContentTypes()
+		m.prunePreviews()
+		m.pruneScores()
 
-		// Wieder an den Anfang
-		m.selected = 0
-		m.syncLeft()
+		// back to the top, with stable_order to the same message
+		kept := m.keepSelection()
+		m.entries, m.selected, m.visual = nil, 0, false
+		m.applyFilter()
+		if m.cleanup != nil && m.cleanup.step == cleanupCriteria {
+			m.matchCleanup()
+		}
+		advanced := m.advanceFrom != ""
+		if advanced {
+			m.selectAfterAction()
+		} else if m.cfg.StableOrder {
+			m.restoreSelection(kept)
+		}
+		fetchTypes := tea.Batch(m.fetchContentTypes(), m.fetchAttachments(), m.fetchPreviews(), m.fetchScores(), m.fetchRetryTimes())
+		if advanced && m.triage != nil {
+			return m, tea.Batch(m.triageAdvance(), fetchTypes, hook)
+		}
 
 		// Wenn wir NICHT gerade frisch gelöscht haben,
 		// laden wir automatisch die erste ID
 		if !m.justDeleted {
-			if len(m.entries) > 0 {
+			if m.cfg.Autoload == autoloadOff {
+				// runs postcat only once the user moves or presses 'l'
+				m.showDetailsPlaceholder()
+			} else if len(m.entries) > 0 {
 				m.rightRaw = "Loading details…"
 				m.right.SetContent(m.rightRaw)
-				return m, runPostcatCmd(m.entries[m.selected])
+				return m, tea.Batch(m.loadDetails(m.entries[m.selected].ID, m.cfg.Autoload == autoloadHeaders), fetchTypes, hook)
 			}
 		} else {
 			// War ein frischer Löschvorgang
 			// => Kein automatisches "postcat" mehr
 			m.justDeleted = false
+			if m.cfg.Autoload == autoloadOff {
+				// nor the details of the deleted message
+				m.showDetailsPlaceholder()
+			}
 		}
+		return m, tea.Batch(fetchTypes, hook)
+
+	case rawFileMsg:
+		m.updateRawFile(msg)
+		return m, nil
+
+	case subjectMsg:
+		return m, m.updateSubject(msg)
+
+	case previewMsg:
+		m.notePreview(msg)
+		return m, nil
+
+	case classifyMsg:
+		return m, m.noteScore(msg)
+
+	case serverInfoMsg:
+		m.noteServerInfo(msg)
+		return m, nil
+
+	case peekMsg:
+		m.showPeek(msg)
+		return m, nil
+
+	case mailLogMsg:
+		m.showMailLog(msg)
+		return m, nil
+
+	case attachmentMsg:
+		m.noteAttachments(msg)
+		return m, nil
+
+	case contentTypeMsg:
+		if _, ok := m.contentTypes[msg.id]; ok {
+			m.contentTypes[msg.id] = msg.label
+			m.syncLeft()
+		}
+		return m, nil
+
+	case commandRunMsg:
+		m.noteCommand(msg)
+		return m, nil
+
+	case emlMsg:
+		m.reportEML(msg)
+		return m, nil
+
+	case retryTimesMsg:
+		return m, m.noteRetryTimes(msg)
+
+	case detailsCancelledMsg:
+		m.doneLoading(msg.id)
 		return m, nil
 
 	case postcatMsg:
-		m.rightRaw = string(msg)
+		m.doneLoading(msg.id)
+		if m.selected >= len(m.entries) || m.entries[m.selected].ID != msg.id {
+			// the user has moved on in the meantime
+			return m, nil
+		}
+		if m.hdrCompare != nil && m.hdrCompare.rows != nil {
+			// another message was selected, the table is gone
+			m.hdrCompare = nil
+		}
+		m.subjectView, m.destView = nil, nil
+		if _, ok := m.subjects[msg.id]; !ok && m.subjects != nil && !msg.partial {
+			// saves fetching the headers for 'J' later
+			m.subjects[msg.id] = decodeHeader(messageHeaders(msg.text).Get("Subject"))
+		}
+		if m.showsColumn("type") && !msg.partial && m.entries[m.selected].Queue != "corrupt" {
+			// the full message has the headers too, no need for postcat -h
+			m.contentTypes[msg.id] = contentTypeLabel(messageHeaders(msg.text))
+			m.syncLeft()
+		}
+		if m.showsColumn("preview") && !msg.partial && !msg.headersOnly && m.entries[m.selected].Queue != "corrupt" {
+			if m.previews == nil {
+				m.previews = map[string]string{}
+			}
+			m.previews[msg.id] = messagePreview(msg.text)
+			m.syncLeft()
+		}
+		if !msg.partial && !msg.headersOnly && m.attachments[msg.id] == "" {
+			m.noteAttachments(attachmentMsg{id: msg.id, has: attachmentsOf(msg.text)})
+		}
+		content := normalizeLineEndings(msg.text, m.cfg.LineEndings)
+		styled := styleMessage(content)
+		m.rightRaw = envelopeSummary(m.entries[m.selected]) + messageSummary(msg.text) + msg.timing + originSummary(msg.text) + styled
+		if host := m.entries[m.selected].Host; host != "" {
+			m.rightRaw = fmt.Sprintf("%-8s %s\n", "Host:", host) + m.rightRaw
+		}
+		if m.entries[m.selected].Queue == "maildrop" {
+			m.rightRaw = warningStyle.Render(maildropNotice) + "\n\n" + m.rightRaw
+		}
+		if m.entries[m.selected].noRecipients() {
+			notice := "(no recipients) — possibly delivered completely or a corrupt queue file; a candidate for cleanup."
+			m.rightRaw = warningStyle.Render(notice) + "\n\n" + m.rightRaw
+		}
+		if msg.headersOnly {
+			m.rightRaw = disabledStyle.Render("Headers only — press 'l' to load the whole message.") + "\n\n" + m.rightRaw
+		}
+		if msg.partial {
+			notice := "Message is being delivered — content may be incomplete."
+			if msg.err != nil {
+				notice += fmt.Sprintf("\n(postcat: %v)", msg.err)
+			}
+			m.rightRaw = warningStyle.Render(notice) + "\n\n" + m.rightRaw
+			m.status = "active message: press 'l' to load it again"
+		}
+		if m.showsThreePanes() {
+			// everything up to the postcat output goes to the middle pane
+			headers, body, _ := splitMessage(content)
+			m.midRaw, m.midID = m.rightRaw[:len(m.rightRaw)-len(styled)]+"\n"+styleHeaders(headers), msg.id
+			m.mid.SetContent(m.midRaw)
+			m.mid.GotoTop()
+			m.rightRaw = body
+		}
+		// highlight last so the marks count the notice lines as well
+		m.rightRaw, m.rightMarks = m.highlightSearch(m.rightRaw)
 		m.right.SetContent(m.rightRaw)
-		m.right.GotoBottom()
+		m.noteDisplayed(m.entries[m.selected])
+		switch {
+		case len(m.rightMarks) > 0:
+			m.right.SetYOffset(m.rightMarks[0] - 2)
+		case m.showsThreePanes():
+			m.right.GotoTop()
+		default:
+			m.right.GotoBottom()
+		}
+		return m, m.startCountdown(msg.id, msg.retry)
+
+	case searchProgressMsg:
+		if msg.search != m.search {
+			return m, nil
+		}
+		m.search.done++
+		if msg.hit {
+			m.search.hits[msg.id] = true
+		}
+		m.status = m.search.String()
+		return m, waitForSearch(m.search.updates)
+
+	case searchDoneMsg:
+		if msg.search == m.search {
+			m.search.running = false
+			m.status = m.search.String()
+		}
 		return m, nil
 
-	case errorMsg:
-		m.err = msg
+	case actionDoneMsg:
+		return m, m.actionDone(msg)
+
+	case bulkProgressMsg:
+		if m.bulk != nil {
+			m.bulk.done = msg.done
+			m.status = fmt.Sprintf("%s: %s", m.bulk.action, msg)
+			return m, waitForBulk(m.bulk.updates)
+		}
 		return m, nil
 
-	case tea.KeyMsg:
-		// 1) Dialog "really delete?"
-		if m.showDeleteDialog {
-			switch strings.ToLower(msg.String()) {
-			case "y":
-				// postsuper -d
-				m.showDeleteDialog = false
-				return m, m.deleteQueueID()
-
-			case "n", "enter", "esc", "ctrl+c":
-				m.showDeleteDialog = false
+	case bulkDoneMsg:
+		if m.bulk != nil {
+			m.noteFailedDeletes(msg.result, m.bulk.ids)
+		}
+		var hook tea.Cmd
+		if m.bulk != nil {
+			hook = tea.Batch(m.deleteHook(msg.result, m.bulk.ids), m.bulkErrorHook(msg.result, m.bulk.ids))
+		}
+		m.bulk = nil
+		if msg.result.action == actionDelete {
+			m.deletesConfirmed += msg.result.affected
+		}
+		m.recordChange(msg.result.action, msg.result.affected)
+		if m.shutdownSignal != nil || m.quitAfterBulk {
+			m.exitReport = msg.result.String()
+			if m.shutdownSignal != nil {
+				m.exitReport = fmt.Sprintf("interrupted by %s: %s", m.shutdownSignal, m.exitReport)
 			}
+			return m, tea.Quit
+		}
+		m.status = msg.result.String()
+		if msg.result.action == actionRequeue && !msg.result.failed() {
+			m.status += " — press 'f' to flush the queue now"
+		}
+		return m, tea.Batch(runMailqCmd, hook)
+
+	case tea.ResumeMsg:
+		// the terminal may have been resized while we were stopped
+		return m, tea.Batch(tea.WindowSize(), m.resumeTitle())
+
+	case tea.MouseMsg:
+		return m, m.updateMouse(msg)
+
+	case tea.FocusMsg:
+		// only reported with refresh_on_focus; a quick switch back and
+		// forth does not list the queue each time
+		if m.cfg.RefreshOnFocus && !m.showWarning && time.Since(m.listedAt) >= focusRefreshAfter {
+			m.status = "listing the queue…"
+			return m, runMailqCmd
+		}
+		return m, nil
+
+	case shutdownMsg:
+		return m.beginShutdown(msg.sig)
+
+	case shutdownTimeoutMsg:
+		if m.shutdownSignal == nil && !m.quitAfterBulk {
 			return m, nil
 		}
+		if m.bulk != nil {
+			m.exitReport = fmt.Sprintf("%s did not stop in time after %d of %d messages, the remaining result is unknown",
+				m.bulk.action, m.bulk.done, m.bulk.total)
+		}
+		return m, tea.Quit
 
-		// 2) Allgemeine Eingaben
-		switch msg.String() {
-		case "q", "esc", "ctrl+c":
-			return m, tea.Quit
-		case "tab":
-			m.focus = 1 - m.focus
+	case headerCompareMsg:
+		if msg.cmp != m.hdrCompare {
+			return m, nil
+		}
+		msg.cmp.headers[msg.id] = msg.header
+		m.status = fmt.Sprintf("comparing headers: %d/%d messages", len(msg.cmp.headers), len(msg.cmp.ids))
+		return m, waitForSearch(msg.cmp.updates)
+
+	case headerCompareDoneMsg:
+		if msg.cmp != m.hdrCompare {
+			return m, nil
+		}
+		msg.cmp.buildRows()
+		m.focus = 1
+		m.showHeaderCompare()
+		m.status = fmt.Sprintf("headers of %d messages compared", len(msg.cmp.ids))
+		return m, nil
+
+	case diffMsg:
+		m.showDiff(msg)
+		return m, nil
+
+	case countdownMsg:
+		return m, m.tickCountdown(msg)
+
+	case keySeqTimeoutMsg:
+		return m, m.keySequenceTimeout(msg)
+
+	case parseTickMsg:
+		return m, m.updateParseProgress(msg)
+
+	case deferredRefreshMsg:
+		return m, m.deferredRefresh(msg)
+
+	case autoRefreshMsg:
+		return m, m.autoRefresh(msg)
+
+	case hostsFailedMsg:
+		m.status = hostsFailedStatus(listFailures(msg))
+		return m, nil
+
+	case hookFailedMsg:
+		m.logError(msg.err)
+		m.status = "hook failed: " + msg.err.Error() + m.errorLogHint()
+		return m, nil
+
+	case errorMsg:
+		var ce *cmdError
+		if errors.As(msg, &ce) && ce.program() == "postcat" {
+			m.doneLoading(m.loadingID)
+		}
+		return m, m.handleError(msg)
+
+	case tea.KeyMsg:
+		// 0) suspend works everywhere, open dialogs are repainted on resume
+		if msg.String() == "ctrl+z" {
+			return m, m.suspend()
+		}
+
+		// 1) Dialog "really delete?"
+		if m.preview != nil {
+			return m, m.updateBulkPreview(msg.String())
+		}
+		if m.dialog != nil {
+			return m.updateDialog(msg)
+		}
+
+		if m.showSearchPrompt {
+			return m.updateSearchPrompt(msg)
+		}
+		if m.showPresetPrompt {
+			return m.updatePresetPrompt(msg)
+		}
+		if m.showPastePrompt {
+			return m.updatePasteIDsPrompt(msg)
+		}
+		if m.exportID != "" {
+			return m.updateExportPrompt(msg)
+		}
+		if m.showPalette {
+			return m.updatePalette(msg)
+		}
+		if m.chooser != nil {
+			return m.updateColumnChooser(msg)
+		}
+		if m.settingsPanel != nil {
+			return m.updateSettings(msg)
+		}
+		if m.serverInfo != nil {
+			m.updateServerInfo(msg.String())
+			return m, nil
+		}
+		if m.entryMenu != nil {
+			return m, m.updateEntryMenu(msg.String())
+		}
+		if m.peek != nil && m.updatePeek(msg.String()) {
+			return m, nil
+		}
+		if m.mailLog != nil && m.updateMailLog(msg.String()) {
 			return m, nil
-		case "d":
-			m.showDeleteDialog = true
+		}
+		if m.errLogView != nil {
+			m.updateErrorLog(msg.String())
 			return m, nil
 		}
 
+		// 2) Allgemeine Eingaben, siehe commands
+		if m.triage != nil {
+			if handled, cmd := m.updateTriage(msg.String()); handled {
+				return m, cmd
+			}
+		}
+		if m.zoomed {
+			if handled, cmd := m.updateZoomed(msg.String()); handled {
+				return m, cmd
+			}
+		}
+		if m.visual && msg.String() == "esc" {
+			m.leaveVisual()
+			return m, nil
+		}
+		if len(m.entries) == 0 && m.filter.active() && msg.String() == "esc" {
+			return m, m.clearEmptyFilter()
+		}
+		if handled, cmd := m.handleKeySequence(msg.String()); handled {
+			return m, cmd
+		}
+		if c, ok := commandForKey(msg.String()); ok {
+			cmd := m.runCommand(c)
+			return m, cmd
+		}
+
 		// 3) Ggf. Warnfenster wegklicken
 		if m.showWarning {
 			m.showWarning = false
@@ -251,25 +1284,49 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.focus == 0 {
 			switch msg.String() {
 			case "up":
-				if m.selected > 0 {
-					m.selected--
-					m.syncLeft()
-					return m, runPostcatCmd(m.entries[m.selected])
-				}
+				return m, m.stepSelection(-1)
 			case "down":
-				if m.selected < len(m.entries)-1 {
-					m.selected++
-					m.syncLeft()
-					return m, runPostcatCmd(m.entries[m.selected])
-				}
+				return m, m.stepSelection(1)
 			case "pgup":
 				scrollHalfUp(&m.left, m.leftRaw)
 			case "pgdown":
 				scrollHalfDown(&m.left, m.leftRaw)
+			case "enter":
+				m.openEntryMenu()
 			}
 			return m, nil
 		} else {
+			if m.subjectView != nil {
+				if handled, cmd := m.updateSubjectClusters(msg.String()); handled {
+					return m, cmd
+				}
+			}
+			if m.destView != nil {
+				if handled, cmd := m.updateDestinations(msg.String()); handled {
+					return m, cmd
+				}
+			}
+			if m.hdrCompare != nil && m.hdrCompare.rows != nil {
+				if handled, cmd := m.updateHeaderCompare(msg.String()); handled {
+					return m, cmd
+				}
+			}
+			if m.focus == focusHeaders {
+				switch msg.String() {
+				case "up":
+					m.mid.LineUp(1)
+				case "down":
+					m.mid.LineDown(1)
+				case "pgup":
+					scrollHalfUp(&m.mid, m.midRaw)
+				case "pgdown":
+					scrollHalfDown(&m.mid, m.midRaw)
+				}
+				return m, nil
+			}
 			switch msg.String() {
+			case "enter":
+				m.toggleZoom()
 			case "up":
 				m.right.LineUp(1)
 			case "down":
@@ -286,6 +1343,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) View() string {
+	defer m.recoverCrash()
 	if m.err != nil {
 		return fmt.Sprintf("Error:\n%v\n(q to quit)", m.err)
 	}
@@ -297,70 +1355,270 @@ func (m model) View() string {
 		return fmt.Sprintf("%s\n(q to quit, any other key to continue)", warningBox)
 	}
 	if !m.ready {
+		if m.parseStatus != "" {
+			return "Please wait… " + m.parseStatus
+		}
 		return "Please wait…"
 	}
 
 	// Hauptlayout
+	header := m.headerLine()
+	entry := m.entryLines()
+	if len(entry) > 1 {
+		// the panes give up a row to the wrapped entry line
+		m.left.Height--
+		m.right.Height--
+		m.mid.Height--
+	}
+	status := m.statusLine()
+	if footer := m.commandFooter(); footer != "" {
+		status += "\n" + footer
+		m.left.Height--
+		m.right.Height--
+		m.mid.Height--
+	}
+	if trash := m.trashPane(); trash != "" {
+		entry = append([]string{trash}, entry...)
+		m.left.Height -= lipgloss.Height(trash)
+		m.right.Height -= lipgloss.Height(trash)
+		m.mid.Height -= lipgloss.Height(trash)
+	}
+	if m.cfg.Plain {
+		return m.plainLayout(header, strings.Join(entry, "\n"), status)
+	}
 	leftStyle := borderStyle
+	midStyle := borderStyle
 	rightStyle := borderStyle
-	if m.focus == 0 {
+	switch m.focus {
+	case 0:
 		leftStyle = leftStyle.BorderForeground(focusBorderColor)
-	} else {
+	case focusHeaders:
+		midStyle = midStyle.BorderForeground(focusBorderColor)
+	default:
 		rightStyle = rightStyle.BorderForeground(focusBorderColor)
 	}
-	leftView := leftStyle.Render(m.left.View())
-	rightView := rightStyle.Render(m.right.View())
-	mainLayout := lipgloss.JoinHorizontal(lipgloss.Top, leftView, rightView)
+	leftView := leftStyle.Render(m.listHeader() + "\n" + m.left.View())
+	rightView := rightStyle.Render(lipgloss.JoinHorizontal(lipgloss.Top, m.right.View(), renderMinimap(m.right, m.rightMarks)))
+	if len(m.entries) == 0 {
+		leftView = leftStyle.Render(m.listHeader() + "\n" + m.emptyList())
+		rightView = rightStyle.Render(m.emptyDetails())
+	}
+	panes := []string{leftView}
+	if m.zoomed {
+		panes = nil
+	}
+	if m.showsThreePanes() {
+		if m.selected >= len(m.entries) || m.entries[m.selected].ID != m.midID {
+			m.mid.SetContent("") // the headers of another message
+		}
+		panes = append(panes, midStyle.Render(m.mid.View()))
+	}
+	mainLayout := lipgloss.JoinHorizontal(lipgloss.Top, append(panes, rightView)...)
 
 	background := lipgloss.Place(
 		m.termWidth, m.termHeight,
 		lipgloss.Left, lipgloss.Top,
-		mainLayout+"\n[TAB] to switch focus, 'd' to delete, 'q' to quit.",
+		header+"\n"+mainLayout+"\n"+strings.Join(entry, "\n")+"\n"+status+"\n"+m.queueChips()+"\n"+m.keyHints(),
 	)
 
-	if !m.showDeleteDialog {
-		return background
+	if popup := m.popupView(); popup != "" {
+		return overlayStrings(background, popup)
 	}
+	return background
+}
 
-	// "really delete?" overlay
-	dialogBox := dialogBoxStyle.Render("really delete [y/N]?")
-	foreground := lipgloss.Place(
-		m.termWidth, m.termHeight,
-		lipgloss.Center, lipgloss.Center,
-		dialogBox,
-	)
+// popupView renders the open dialog or popup, "" when there is none.
+func (m model) popupView() string {
+	switch {
+	case m.showPalette:
+		return m.paletteView()
+	case m.chooser != nil:
+		return m.columnChooserView()
+	case m.settingsPanel != nil:
+		return m.settingsView()
+	case m.serverInfo != nil:
+		return m.serverInfoPopup()
+	case m.entryMenu != nil:
+		return m.entryMenuView()
+	case m.peek != nil:
+		return m.peekPopup()
+	case m.mailLog != nil:
+		return m.mailLogPopup()
+	case m.errLogView != nil:
+		return m.errorLogPopup()
+	case m.preview != nil:
+		return m.bulkPreviewView()
+	case m.dialog != nil:
+		return m.dialogView()
+	}
+	return ""
+}
 
-	return overlayStrings(background, foreground)
+// confirmYes runs the confirmed action.
+func (m *model) confirmYes() tea.Cmd {
+	m.dialog = nil
+	if m.confirmIDs == nil && m.confirmAction.perEntry() {
+		if err := m.reselectConfirmed(); err != nil {
+			m.confirmTrash = false
+			m.status = err.Error()
+			return nil
+		}
+		if expr := m.entryProtection(); m.confirmAction == actionDelete && !m.confirmTrash && expr != "" {
+			m.openProtectedDialog(expr)
+			return nil
+		}
+	}
+	if m.confirmTrash {
+		m.addToTrash(m.confirmedIDs()...)
+		m.confirmTrash = false
+	}
+	if ids := m.confirmIDs; ids != nil {
+		m.confirmIDs, m.confirmSummary, m.confirmShown = nil, "", ""
+		m.status = fmt.Sprintf("%s: 0/%d messages", m.confirmAction, len(ids))
+		return m.startBulk(m.confirmAction, listedTargets(m.allEntries, ids))
+	}
+	return m.runAction(m.confirmAction)
 }
 
-// syncWarningViewport sets the text for the initial root/postfix warning.
+// visibleEntries returns the entries currently shown in the list, in display order.
+func (m model) visibleEntries() []queueEntry {
+	return m.entries
+}
+
+// syncWarningViewport sets the text of the warning screen, see warning.go.
 func (m *model) syncWarningViewport() {
-	warnText := `
-WARNING!
+	m.warningView.SetContent(m.cfg.Warning.content())
+}
 
-Usually this program should be run as "root" or "postfix" so that "mailq" and "postcat" work properly.
+// selectAfterAction moves the selection behind the entry the last action
+// ran on: to the next entry if it is still listed (held, requeued), or to
+// the one that took its place (deleted).
+func (m *model) selectAfterAction() {
+	pos := m.advancePos
+	for i, e := range m.entries {
+		if e.ID == m.advanceFrom {
+			pos = i + 1
+			break
+		}
+	}
+	m.advanceFrom = ""
+	if pos >= len(m.entries) {
+		pos = len(m.entries) - 1
+	}
+	m.selected = maxInt(pos, 0)
+	m.syncLeft()
+}
 
-You are NOT root/postfix. Some functions may fail.
+// resizePanes fits the list and the details to the terminal, see
+// listWidth.
+func (m *model) resizePanes() {
+	leftWidth := m.listWidth()
+	rightWidth := max(m.termWidth-leftWidth-8, minimapWidth+10)
+	if m.zoomed {
+		// the list is hidden, its border too
+		rightWidth = max(m.termWidth-6, minimapWidth+10)
+	}
 
-Press any key (except q/esc) to continue, or 'q'/'esc' to cancel.
-`
-	m.warningView.SetContent(strings.TrimSpace(warnText))
+	m.left.Width = leftWidth
+	m.left.Height = m.termHeight - 10 // one row for listHeader, one for headerLine
+	m.right.Width = rightWidth - minimapWidth
+	m.right.Height = m.termHeight - 9 // one row for entryLine, one for headerLine
+	if m.showsThreePanes() {
+		// the headers take two fifths of the details, and a border
+		m.mid.Width = rightWidth * 2 / 5
+		m.mid.Height = m.right.Height
+		m.right.Width -= m.mid.Width + 2
+	}
 }
 
-// syncLeft rebuilds the list of queue IDs in leftRaw.
+// syncLeft rebuilds the list of queue IDs and their queues in leftRaw.
 func (m *model) syncLeft() {
 	var sb strings.Builder
-	for i, id := range m.entries {
-		line := id
-		if i == m.selected {
-			line = selectedStyle.Render("> " + line)
-		} else {
-			line = "  " + line
+	now := time.Now()
+	cols, widths := m.fitColumns()
+	anyNoRecipients := m.anyNoRecipients()
+	for i, e := range m.entries {
+		line := m.listRow(e, cols, widths, now)
+		switch {
+		case e.noRecipients():
+			line = "∅" + line // no recipients, see noRecipients
+		case anyNoRecipients:
+			line = " " + line
+		}
+		mark := " "
+		if m.marked[e.ID] {
+			mark = "*"
+		}
+		line = m.bookmarkColumn(e.ID) + m.reviewColumn(e.ID) + m.tagChip(e.ID) + m.attachChip(e.ID) + line
+		switch {
+		case i == m.selected && e.ID == m.loadingID:
+			line = m.renderSelected("…" + mark + line)
+		case i == m.selected:
+			line = m.renderSelected(">" + mark + line)
+		case m.inVisualRange(i):
+			line = " " + mark + visualStyle.Render(line)
+		default:
+			line = " " + mark + line
 		}
 		sb.WriteString(line + "\n")
 	}
-	m.leftRaw = sb.String()
-	m.left.SetContent(m.leftRaw)
+	if left := sb.String(); left != m.leftRaw {
+		// re-setting unchanged content would only cause a full redraw
+		m.leftRaw = left
+		m.left.SetContent(m.leftRaw)
+	}
+	m.centerSelection()
+}
+
+// stepSelection moves the selection by dir within the shown entries. At
+// the ends it wraps around, except in visual mode where that would select
+// the whole list.
+func (m *model) stepSelection(dir int) tea.Cmd {
+	n := len(m.entries)
+	i := m.selected + dir
+	if i < 0 || i >= n {
+		if m.visual || n == 0 {
+			return nil
+		}
+		i = (i + n) % n
+	}
+	return m.selectEntry(i)
+}
+
+// selectEntry selects the shown entry i and loads its details.
+func (m *model) selectEntry(i int) tea.Cmd {
+	if i == m.selected {
+		return nil
+	}
+	if i-m.selected > 1 || m.selected-i > 1 {
+		m.recordJump()
+	}
+	m.selected = i
+	cmd := m.runPostcatCmd(m.entries[i].ID)
+	m.syncLeft()
+	if m.visual {
+		m.status = m.visualStatus()
+	}
+	return tea.Batch(cmd, m.fetchContentTypes(), m.fetchPreviews(), m.fetchScores())
+}
+
+// doneLoading clears the "…" of id once its details are in, or failed.
+func (m *model) doneLoading(id string) {
+	if m.loadingID == id {
+		m.loadingID = ""
+		m.syncLeft()
+	}
+}
+
+// centerSelection scrolls the list so the selected entry sits in the middle
+// of the pane, like an editor's cursor; near the ends of the list the
+// offset is clamped so no empty space is scrolled in.
+func (m *model) centerSelection() {
+	offset := m.selected - m.left.Height/2
+	if maxOffset := len(m.entries) - m.left.Height; offset > maxOffset {
+		offset = maxOffset
+	}
+	m.left.SetYOffset(maxInt(offset, 0))
 }
 
 // scrollHalfUp / scrollHalfDown => halbe Seite scrollen, oder Jump to Top/Bottom
@@ -441,26 +1699,284 @@ func maxInt(a, b int) int {
 	return b
 }
 
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func main() {
-	currentUser, err := user.Current()
+	flags := parseFlags()
+	if flag.Arg(0) == "init" {
+		os.Exit(runSetup(flags.configPath, false))
+	}
+	if flag.Arg(0) == "compare" && flag.NArg() == 3 {
+		// two snapshots, no mail server needed
+		os.Exit(cliCompare(flag.Args()[1:]))
+	}
+	if firstRun(flags, flag.Args()) {
+		if code := runSetup(flags.configPath, true); code != exitOK {
+			os.Exit(code)
+		}
+	}
+	cfg, err := loadConfig(flags.configPath)
+	if err == nil {
+		err = cfg.addSavedPresets(savedPresetsPath(flags.configPath))
+	}
+	if err == nil {
+		err = cfg.addSavedLayout(savedLayoutPath(flags.configPath))
+	}
+	if err == nil {
+		err = cfg.addSavedSettings(savedSettingsPath(flags.configPath))
+	}
+	// with -check every error before the check is its UNKNOWN status
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Warning: cannot retrieve current user:", err)
+		fatal(flags.check, exitError, err)
+	}
+	if err := flags.apply(&cfg); err != nil {
+		fatal(flags.check, exitUsage, err)
+	}
+	if flags.warnCount < 0 || flags.critCount < 0 || flags.warnAge < 0 {
+		fatal(flags.check, exitUsage, "-warn-count, -crit-count and -warn-age must not be negative")
+	}
+	if flags.warnCount > 0 && flags.critCount > 0 && flags.critCount < flags.warnCount {
+		fatal(flags.check, exitUsage, "-crit-count must not be below -warn-count")
+	}
+	if flags.sort != "" {
+		if _, err := parseSortOrder(flags.sort); err != nil {
+			fatal(flags.check, exitUsage, "-sort:", err)
+		}
+	}
+	if flags.queue != "" {
+		if _, err := parseQueueView(flags.queue); err != nil {
+			if flags.check {
+				fatal(true, exitUsage, "-queue:", err)
+			}
+			fmt.Fprintln(os.Stderr, "Error: -queue:", err)
+			flag.Usage()
+			os.Exit(exitUsage)
+		}
+	}
+
+	var snapshot *queueSnapshot
+	if flags.snapshot != "" {
+		if snapshot, err = loadSnapshot(flags.snapshot); err != nil {
+			fatal(flags.check, exitError, "-snapshot:", err)
+		}
 	}
 
-	showWarn := true
-	if currentUser != nil {
-		if currentUser.Username == "root" || currentUser.Username == "postfix" {
-			showWarn = false
+	if flags.printConfig {
+		fmt.Printf("# effective configuration: defaults < %s < flags\n", flags.configPath)
+		if err := cfg.print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(exitError)
 		}
+		os.Exit(exitOK)
 	}
 
+	if flags.logFile == "" {
+		flags.logFile = cfg.LogFile
+	}
+	if flags.debug || flags.logFile != "" {
+		path, level := flags.logFile, slog.LevelInfo
+		if flags.debug {
+			level = slog.LevelDebug
+		}
+		if flags.debugPath != "" {
+			path = flags.debugPath
+		}
+		if path == "" {
+			path = defaultLogPath()
+		}
+		logFile, err := openLog(path, level)
+		if err != nil {
+			fatal(flags.check, exitError, "cannot open the log file:", err)
+		}
+		defer logFile.Close()
+	}
+
+	postfixDir = cfg.PostfixDir
+	listCommand = cfg.ListCommand
+	commandTimeouts = cfg.Timeouts
+	queueHosts = cfg.Hosts
+	safeDelete = cfg.SafeDelete
+	protectExprs = cfg.Protect
+	backend, _ = selectMTA(cfg.MTA, len(queueHosts) > 0) // validated with the config
+	logger.Debug("mail server", "mta", backend.name)
+	hostname = shortHostname()
+	if backend.name == "postfix" && len(queueHosts) == 0 {
+		// failures leave the conservative defaults of detectedPostfix
+		if info := detectPostfix(); info.err == nil {
+			detectedPostfix = info
+		} else {
+			detectedPostfix.err = info.err
+			logger.Info("postfix version unknown", "err", info.err)
+		}
+	}
+	if flags.check {
+		// a missing tool is reported like any other listing failure
+		os.Exit(runCheck(checkThresholds{warnCount: flags.warnCount, critCount: flags.critCount, warnAge: flags.warnAge}))
+	}
+	if len(queueHosts) > 0 {
+		// the mail server's tools run on the hosts, only ssh is needed here
+		if _, err := exec.LookPath("ssh"); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -hosts needs ssh:", err)
+			os.Exit(exitNoPostfix)
+		}
+	} else if problems := checkTools(); len(problems) > 0 {
+		fmt.Fprint(os.Stderr, missingToolsReport(problems, flags.configPath))
+		os.Exit(exitNoPostfix)
+	}
+
+	if flag.NArg() > 0 {
+		os.Exit(runCLI(cfg, flags, flag.Args()))
+	}
+	if flags.serve != "" {
+		if flags.serveInterval <= 0 {
+			fmt.Fprintln(os.Stderr, "Error: -serve-interval must be positive")
+			os.Exit(exitUsage)
+		}
+		if err := startServer(cfg, flags); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -serve:", err)
+			os.Exit(exitError)
+		}
+		if !isTerminal(os.Stdout) || !isTerminal(os.Stdin) {
+			// no interface, only the server
+			fmt.Fprintf(os.Stderr, "postdel: serving the queue on %s\n", flags.serve)
+			select {}
+		}
+	}
+	if !isTerminal(os.Stdout) || !isTerminal(os.Stdin) {
+		// the TUI would only write escape sequences into a pipe or log
+		fmt.Fprintln(os.Stderr, "postdel: not running on a terminal, printing the queue instead (see 'postdel list')")
+		os.Exit(cliList(cfg, flags, nil))
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: cannot retrieve current user:", err)
+	}
+
+	// with -hosts the privileges that count are those of the ssh logins
+	unprivileged := len(queueHosts) == 0 && !privileged(currentUser)
+	showWarn := cfg.Warning.shows(currentUser, unprivileged)
+
 	m := model{
 		showWarning: showWarn,
+		flags:       flags,
+		cfg:         cfg,
+		pool:        newPool(cfg.Workers),
+		startedAt:   time.Now(),
+
+		columns:      cfg.Columns,
+		split:        cfg.Split,
+		threePanes:   cfg.ThreePanes,
+		showCommands: cfg.ShowCommands,
+		snapshot:     snapshot,
+	}
+	if cfg.ContentTypeColumn && !slices.Contains(m.columns, "type") {
+		m.columns = append(slices.Clone(m.columns), "type")
+	}
+	if flags.sort != "" {
+		// checked right after the flags were parsed
+		m.sort, _ = parseSortOrder(flags.sort)
+	}
+	if flags.queue != "" {
+		m.hiddenQueues, _ = parseQueueView(flags.queue)
+	}
+	terms, err := boundTerms(sizeBounds(flags.minSize, flags.maxSize))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitUsage)
+	}
+	if flags.preset != "" {
+		f, err := cfg.presetFilter(flags.preset)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: -preset:", err)
+			os.Exit(exitUsage)
+		}
+		if len(terms) > 0 {
+			f.expr = "(" + f.expr + ")"
+		}
+		terms = append(terms, f.expr)
+	}
+	// the terms are checked, so is their combination
+	m.filter, _, _ = parseFilterInput(strings.Join(terms, " "))
+	switch {
+	case unprivileged:
+		m.readOnly, m.readOnlyReason = true, readOnlyNoPrivileges
+	case cfg.ReadOnly:
+		m.readOnly, m.readOnlyReason = true, readOnlyRequested
 	}
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
-	if err := p.Start(); err != nil {
+	detectedColors = lipgloss.ColorProfile()
+	if cfg.Plain {
+		usePlainStyles()
+	} else {
+		setColors(cfg.Colors)
+	}
+	logger.Debug("terminal", "colors", colorDepth())
+	opts := []tea.ProgramOption{tea.WithoutSignalHandler()}
+	if !flags.noAltScreen {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	if cfg.RefreshOnFocus {
+		opts = append(opts, tea.WithReportFocus())
+	}
+	if cfg.Mouse && !cfg.Plain {
+		opts = append(opts, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(m, opts...)
+	handleSignals(p)
+	listParse.notify = p.Send
+	commandEcho = func(msg any) { p.Send(msg) }
+	echoCommands.Store(cfg.ShowCommands)
+	measureLatency.Store(cfg.ShowLatency)
+	if cfg.TerminalTitle {
+		saveTerminalTitle()
+	}
+	final, err := p.Run()
+	if cfg.TerminalTitle {
+		restoreTerminalTitle()
+	}
+	if crashPath != "" {
+		fmt.Fprintln(os.Stderr, "postdel: crashed, what it was doing is in", crashPath)
+		os.Exit(exitError)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error launching program: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+	fm, ok := final.(model)
+	if !ok {
+		return
+	}
+	logLatencies()
+	fmt.Print(fm.sessionSummary())
+	if tally := fm.senderTally(); tally != "" {
+		fmt.Fprint(os.Stderr, tally)
+		fm.logSenderTally()
+	}
+	if report := fm.reviewReport(); report != "" {
+		fmt.Fprint(os.Stderr, report)
+		if fm.cfg.ReviewFile != "" {
+			if err := fm.writeReview(fm.cfg.ReviewFile); err != nil {
+				fmt.Fprintln(os.Stderr, "postdel: review list not written:", err)
+			} else {
+				fmt.Fprintln(os.Stderr, "postdel: review list written to", fm.cfg.ReviewFile)
+			}
+		}
+	}
+	if fm.exitReport != "" {
+		fmt.Fprintln(os.Stderr, "postdel:", fm.exitReport)
+	}
+	if fm.shutdownSignal != nil {
+		os.Exit(exitCodeForSignal(fm.shutdownSignal))
+	}
+	if fm.err != nil {
+		// the session ended on an unrecoverable error, tell wrapper scripts
+		fmt.Fprintln(os.Stderr, "postdel: error:", fm.err)
+		os.Exit(exitError)
 	}
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseMailqNoRecipients(t *testing.T) {
+	out, err := os.ReadFile("testdata/mailq-no-recipients.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 11, 12, 0, 0, 0, time.Local)
+	entries := parseMailqAt(out, now)
+	want := []struct {
+		id, queue    string
+		recipients   []string
+		noRecipients bool
+	}{
+		{"4ABC123DEF", "active", []string{"bob@example.net"}, false},
+		{"4ABC124DEF", "deferred", nil, true},
+		{"4ABC125DEF", "deferred", nil, true},
+		{"4ABC126DEF", "hold", []string{"erin@example.net", "frank@example.net"}, false},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.ID != w.id || e.Queue != w.queue || !slices.Equal(e.Recipients, w.recipients) || e.noRecipients() != w.noRecipients {
+			t.Errorf("entry %d: got %s %s %q (no recipients: %v), want %s %s %q (%v)",
+				i, e.ID, e.Queue, e.Recipients, e.noRecipients(), w.id, w.queue, w.recipients, w.noRecipients)
+		}
+	}
+	if got := entries[2].Reason; got != "delivery temporarily suspended: connect to mx.example.net[192.0.2.1]:25: Connection refused" {
+		t.Errorf("reason of the entry without recipients: %q", got)
+	}
+}
+
+func TestParseMailqNullSender(t *testing.T) {
+	out, err := os.ReadFile("testdata/mailq-null-sender.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := parseMailqAt(out, time.Date(2026, 3, 11, 12, 0, 0, 0, time.Local))
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+	bounce, other := entries[0], entries[1]
+	if bounce.ID != "4ABC201DEF" || bounce.Sender != "" || !slices.Equal(bounce.Recipients, []string{"bounced@example.net"}) {
+		t.Errorf("bounce: got %s sender %q recipients %q", bounce.ID, bounce.Sender, bounce.Recipients)
+	}
+	if got := bounce.senderLabel(); got != nullSenderLabel {
+		t.Errorf("senderLabel of MAILER-DAEMON = %q, want %q", got, nullSenderLabel)
+	}
+	if got := other.senderLabel(); got != "alice@example.com" {
+		t.Errorf("senderLabel = %q, want alice@example.com", got)
+	}
+}
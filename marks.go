@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Multi-select: marked entries are kept by queue ID so the marks survive
+// filtering and refreshes. Actions on single messages apply to all marked
+// entries when there are any.
+
+// toggleMark marks or unmarks the selected entry and moves to the next one.
+func (m *model) toggleMark() tea.Cmd {
+	id := m.entries[m.selected].ID
+	if m.marked[id] {
+		delete(m.marked, id)
+	} else {
+		if m.marked == nil {
+			m.marked = map[string]bool{}
+		}
+		m.marked[id] = true
+	}
+	if m.selected < len(m.entries)-1 {
+		m.selected++
+		m.syncLeft()
+		m.status = m.markStatus()
+		return m.runPostcatCmd(m.entries[m.selected].ID)
+	}
+	m.syncLeft()
+	m.status = m.markStatus()
+	return nil
+}
+
+// markAll marks every visible entry; entries hidden by the queue filter
+// keep their state.
+func (m *model) markAll() {
+	if m.marked == nil {
+		m.marked = make(map[string]bool, len(m.entries))
+	}
+	for _, e := range m.entries {
+		m.marked[e.ID] = true
+	}
+	m.syncLeft()
+	m.status = m.markStatus()
+}
+
+// clearMarks unmarks everything, visible or not.
+func (m *model) clearMarks() {
+	m.marked = nil
+	m.syncLeft()
+	m.status = m.markStatus()
+}
+
+// invertMarks flips the marks of the visible entries. Marks on entries the
+// filter or the hidden queues leave out are dropped, so that "select the
+// ones to keep, invert, delete" never reaches beyond the list.
+func (m *model) invertMarks() {
+	next := map[string]bool{}
+	for _, e := range m.entries {
+		if !m.marked[e.ID] {
+			next[e.ID] = true
+		}
+	}
+	hidden := len(m.marked)
+	for _, e := range m.entries {
+		if m.marked[e.ID] {
+			hidden--
+		}
+	}
+	m.marked = next
+	m.syncLeft()
+	m.status = m.markStatus()
+	if hidden > 0 {
+		m.status += fmt.Sprintf(" (%d hidden by the filter unselected)", hidden)
+	}
+}
+
+// markedIDs returns the marked queue IDs in list order.
+func (m model) markedIDs() []string {
+	var ids []string
+	for _, e := range m.allEntries {
+		if m.marked[e.ID] {
+			ids = append(ids, e.ID)
+		}
+	}
+	return ids
+}
+
+func (m model) markStatus() string {
+	if len(m.marked) == 0 {
+		return "selection cleared"
+	}
+	return "selected: " + m.selectionSummary()
+}
+
+// selectionSummary describes the marked entries: "37 msgs, 48.2 MiB,
+// oldest 5d". The confirmation of a bulk action shows the same text.
+func (m model) selectionSummary() string {
+	var n int
+	var size int64
+	var oldest time.Time
+	for _, e := range m.allEntries {
+		if !m.marked[e.ID] {
+			continue
+		}
+		n++
+		size += e.Size
+		if !e.Arrival.IsZero() && (oldest.IsZero() || e.Arrival.Before(oldest)) {
+			oldest = e.Arrival
+		}
+	}
+	s := fmt.Sprintf("%d msgs, %s", n, formatSize(size))
+	if !oldest.IsZero() {
+		s += ", oldest " + formatAge(time.Since(oldest))
+	}
+	return s
+}
+
+// pruneMarks drops the marks of messages that are no longer in the queue.
+func (m *model) pruneMarks() {
+	if len(m.marked) == 0 {
+		return
+	}
+	present := make(map[string]bool, len(m.allEntries))
+	for _, e := range m.allEntries {
+		present[e.ID] = true
+	}
+	for id := range m.marked {
+		if !present[id] {
+			delete(m.marked, id)
+		}
+	}
+}
+
+// formatSize renders a byte count with binary units, "48.2 MiB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatAge renders a duration coarsely: "5d", "3h", "12m".
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	}
+}
+
+// requestMarkedAction runs a on all marked entries after a confirmation.
+func (m *model) requestMarkedAction(a action) tea.Cmd {
+	if m.bulk != nil {
+		m.status = "a bulk operation is already running"
+		return nil
+	}
+	return m.askOrRun(a, m.markedIDs(), m.selectionSummary())
+}
+
+// selectIDs replaces the selection with ids, moves the cursor to the first
+// of them in the list and shows status.
+func (m *model) selectIDs(ids []string, status string) tea.Cmd {
+	m.marked = map[string]bool{}
+	for _, id := range ids {
+		m.marked[id] = true
+	}
+	m.focus = 0
+	m.recordJump()
+	for i, e := range m.entries {
+		if m.marked[e.ID] {
+			m.selected = i
+			break
+		}
+	}
+	m.syncLeft()
+	m.status = status
+	if m.selected < len(m.entries) {
+		return m.runPostcatCmd(m.entries[m.selected].ID)
+	}
+	return nil
+}
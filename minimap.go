@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// minimapWidth is the number of columns the scrollbar takes from the right pane.
+const minimapWidth = 1
+
+var (
+	minimapTrackStyle = lipgloss.NewStyle().Foreground(colorSubtle)
+	minimapThumbStyle = lipgloss.NewStyle().Foreground(colorHighlight)
+	minimapMarkStyle  = lipgloss.NewStyle().Foreground(colorWarning)
+)
+
+// renderMinimap draws a one column scrollbar for v: the thumb shows the
+// visible part of the content, marks (content line numbers, e.g. search
+// hits) are drawn on top. Content that fits the viewport gets an empty column.
+func renderMinimap(v viewport.Model, marks []int) string {
+	h := v.Height
+	if h <= 0 {
+		return ""
+	}
+	total := v.TotalLineCount()
+	rows := make([]string, h)
+	if total <= h {
+		for i := range rows {
+			rows[i] = " "
+		}
+		return strings.Join(rows, "\n")
+	}
+
+	thumbStart := v.YOffset * h / total
+	thumbSize := maxInt(1, h*h/total)
+	if thumbStart+thumbSize > h {
+		thumbStart = h - thumbSize
+	}
+
+	marked := make([]bool, h)
+	for _, line := range marks {
+		if line >= 0 && line < total {
+			marked[line*h/total] = true
+		}
+	}
+
+	for i := range rows {
+		switch {
+		case marked[i]:
+			rows[i] = minimapMarkStyle.Render("◆")
+		case i >= thumbStart && i < thumbStart+thumbSize:
+			rows[i] = minimapThumbStyle.Render("┃")
+		default:
+			rows[i] = minimapTrackStyle.Render("│")
+		}
+	}
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// With -mouse (mouse = true) the terminal reports the mouse: dragging the
+// border between the list and the details resizes the list like '<' and
+// '>', following the pointer, and the new split is saved to the layout
+// file when the button is let go. The wheel moves through the list or
+// scrolls the details, whichever it is over. It is off by default because
+// a terminal reporting the mouse no longer selects text with it (most
+// still do with shift held). A click on a column in the header row of the
+// list sorts by it, a second click the other way, as alt+h does from the
+// keyboard.
+
+// onDivider reports whether column x is the border between the list and
+// the details: the right border of the list or the left one of the
+// details, on the rows of the panes.
+func (m model) onDivider(x, y int) bool {
+	right := m.left.Width + 3 // border and padding on both sides of the list
+	return (x == right || x == right+1) && y >= 1 && y <= m.left.Height+3
+}
+
+// onListHeader reports whether row y is the header row of the list, below
+// the header line and the top border of the list.
+func (m model) onListHeader(y int) bool {
+	return y == lipgloss.Height(m.headerLine())+1
+}
+
+// updateMouse handles a mouse event.
+func (m *model) updateMouse(msg tea.MouseMsg) tea.Cmd {
+	if m.cfg.Plain || m.zoomed || m.popupView() != "" {
+		return nil
+	}
+	switch {
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft && m.onListHeader(msg.Y):
+		return m.sortByColumn(m.headerColumnAt(msg.X - 2))
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft:
+		m.dragging = m.onDivider(msg.X, msg.Y)
+	case msg.Action == tea.MouseActionMotion && m.dragging:
+		m.dragSplit(msg.X)
+	case msg.Action == tea.MouseActionRelease && m.dragging:
+		m.dragging = false
+		m.status = fmt.Sprintf("list pane %d%% of the width", m.split)
+		if err := m.saveLayout(); err != nil {
+			m.status += " (not saved: " + err.Error() + ")"
+		} else {
+			m.status += ", saved to " + savedLayoutPath(m.flags.configPath)
+		}
+	case msg.Button == tea.MouseButtonWheelUp || msg.Button == tea.MouseButtonWheelDown:
+		dir := 1
+		if msg.Button == tea.MouseButtonWheelUp {
+			dir = -1
+		}
+		if msg.X <= m.left.Width+3 {
+			return m.stepSelection(dir)
+		}
+		if dir < 0 {
+			m.right.LineUp(3)
+		} else {
+			m.right.LineDown(3)
+		}
+	}
+	return nil
+}
+
+// dragSplit puts the border of the list at column x.
+func (m *model) dragSplit(x int) {
+	if m.termWidth == 0 {
+		return
+	}
+	split := min(max((x-3)*100/m.termWidth, minSplit), maxSplit)
+	if split == m.split {
+		return
+	}
+	m.split = split
+	m.resizePanes()
+	m.syncLeft()
+	m.status = fmt.Sprintf("list pane %d%% of the width", m.split)
+}
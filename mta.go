@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// postdel was written for Postfix, but listing, reading and acting on a
+// queue is much the same elsewhere. An mta is the set of commands that do
+// it for one mail server; -mta or the mta setting picks one, by default
+// the one whose tools are installed.
+
+// mta is a mail server postdel can manage.
+type mta struct {
+	name    string   // for -mta
+	title   string   // for messages
+	tools   []string // the programs it runs, checked at startup
+	actions []action // the actions it supports
+	logs    []string // the usual mail log files, see maillog.go
+
+	// list returns the queue of host ("" for this machine).
+	list func(host string) ([]queueEntry, error)
+	// show reads a message, only its headers if headersOnly (the full
+	// message is fine too, the callers cut it).
+	show func(ctx context.Context, host, id string, headersOnly bool) *exec.Cmd
+	// act runs a, one of actions, on id.
+	act func(ctx context.Context, host string, a action, id string) *exec.Cmd
+	// bulk runs a on many ids in one command; count reads the number of
+	// messages it affected from its output.
+	bulk  func(ctx context.Context, host string, a action, ids []string) *exec.Cmd
+	count func(out []byte, a action, ids []string) map[string]int
+	// deliver schedules id for delivery now ('i'); nil where requeueing
+	// does that, see deliver.go.
+	deliver func(ctx context.Context, host, id string) *exec.Cmd
+	// deleteMaildrop deletes id from the maildrop queue, which act does
+	// not look in; nil without such a queue, see maildrop.go.
+	deleteMaildrop func(ctx context.Context, host, id string) *exec.Cmd
+	// queueID reports whether s has the form of a queue ID.
+	queueID func(s string) bool
+}
+
+// backend is the mail server of the session, chosen at startup.
+var backend = postfixMTA
+
+// mtas are the known mail servers, the default first.
+var mtas = []mta{postfixMTA, eximMTA}
+
+// supports reports whether the mail server can run a. Postfix expires
+// messages from 3.5 on; with -hosts that cannot be checked here.
+func (b mta) supports(a action) bool {
+	if a == actionExpire && b.name == "postfix" && len(currentSettings().hosts) == 0 && !detectedPostfix.hasExpire() {
+		return false
+	}
+	return slices.Contains(b.actions, a)
+}
+
+// mtaNames lists the names -mta accepts.
+func mtaNames() []string {
+	var names []string
+	for _, b := range mtas {
+		names = append(names, b.name)
+	}
+	return names
+}
+
+// selectMTA returns the mail server called name, or for "" the first one
+// whose tools are all found. With remote hosts nothing can be detected
+// here and Postfix is assumed.
+func selectMTA(name string, remote bool) (mta, error) {
+	if name == "" {
+		if remote {
+			return postfixMTA, nil
+		}
+		for _, b := range mtas {
+			if !slices.ContainsFunc(b.tools, func(t string) bool { return findTool(t) != nil }) {
+				return b, nil
+			}
+		}
+		return postfixMTA, nil
+	}
+	for _, b := range mtas {
+		if b.name == name {
+			return b, nil
+		}
+	}
+	return mta{}, fmt.Errorf("unknown mail server %q (known: %s)", name, strings.Join(mtaNames(), ", "))
+}
+
+// unsupported is the status when the mail server cannot run a.
+func unsupported(a action) string {
+	if a == actionExpire && backend.name == "postfix" {
+		if detectedPostfix.version == "" {
+			return "expire needs postsuper -e of Postfix 3.5 or later, the version here is unknown ('?')"
+		}
+		return "expire needs postsuper -e of Postfix 3.5 or later, this is " + detectedPostfix.version
+	}
+	return fmt.Sprintf("%s is not available with %s", a, backend.title)
+}
+
+var postfixMTA = mta{
+	name:    "postfix",
+	title:   "Postfix",
+	tools:   []string{"postcat", "postsuper", "postqueue"}, // mailq is optional, see listCommands
+	actions: allActions,
+	logs:    []string{"/var/log/mail.log", "/var/log/maillog"},
+	list:    listHostQueue,
+	show: func(ctx context.Context, host, id string, headersOnly bool) *exec.Cmd {
+		if headersOnly {
+			return hostCommand(ctx, host, "postcat", "-q", "-h", id)
+		}
+		return hostCommand(ctx, host, "postcat", "-q", id)
+	},
+	act: func(ctx context.Context, host string, a action, id string) *exec.Cmd {
+		switch a {
+		case actionDelete:
+			return hostCommand(ctx, host, "postsuper", "-d", id)
+		case actionHold:
+			return hostCommand(ctx, host, "postsuper", "-h", id)
+		case actionRelease:
+			return hostCommand(ctx, host, "postsuper", "-H", id)
+		case actionRequeue:
+			return hostCommand(ctx, host, "postsuper", "-r", id)
+		case actionExpire:
+			return hostCommand(ctx, host, "postsuper", "-e", id)
+		case actionFlush:
+			return hostCommand(ctx, host, "postqueue", "-f")
+		case actionClearCorrupt:
+			// postsuper does not handle the corrupt queue
+			return exec.CommandContext(ctx, "find", corruptDir(), "-type", "f", "-delete")
+		}
+		return nil
+	},
+	deliver: func(ctx context.Context, host, id string) *exec.Cmd {
+		return hostCommand(ctx, host, "postqueue", "-i", id)
+	},
+	deleteMaildrop: func(ctx context.Context, host, id string) *exec.Cmd {
+		return hostCommand(ctx, host, "postsuper", "-d", id, "maildrop")
+	},
+	queueID: postfixQueueID,
+	bulk: func(ctx context.Context, host string, a action, ids []string) *exec.Cmd {
+		cmd := hostCommand(ctx, host, "postsuper", postsuperFlags[a], "-")
+		cmd.Stdin = strings.NewReader(strings.Join(ids, "\n") + "\n")
+		return cmd
+	},
+	count: func(out []byte, a action, ids []string) map[string]int {
+		counts := map[string]int{}
+		for _, m := range postsuperSummary.FindAllStringSubmatch(string(out), -1) {
+			n, _ := strconv.Atoi(m[2])
+			counts[m[1]] += n
+		}
+		return counts
+	},
+}
+
+// Exim keeps no separate queues: a frozen message is shown as on hold,
+// every other one as deferred. Its corrupt messages are not listed, so
+// clear-corrupt does not apply.
+
+// eximFlags maps the actions to their exim switch; they all take many
+// message IDs at once.
+var eximFlags = map[action]string{
+	actionDelete:  "-Mrm",
+	actionHold:    "-Mf",
+	actionRelease: "-Mt",
+	actionRequeue: "-M",
+	actionExpire:  "-Mg",
+}
+
+// eximVerbs name what happened to the messages, for the bulk summary.
+var eximVerbs = map[action]string{
+	actionDelete:  "Deleted",
+	actionHold:    "Frozen",
+	actionRelease: "Thawed",
+	actionRequeue: "Delivery attempted",
+	actionExpire:  "Bounced",
+}
+
+var eximMTA = mta{
+	name:    "exim",
+	title:   "Exim",
+	tools:   []string{"exim"},
+	actions: []action{actionDelete, actionHold, actionRelease, actionRequeue, actionExpire, actionFlush},
+	logs:    []string{"/var/log/exim4/mainlog", "/var/log/exim/main.log"},
+	list:    listEximQueue,
+	show: func(ctx context.Context, host, id string, headersOnly bool) *exec.Cmd {
+		// -Mvh shows the headers in spool format, -Mvc the message as sent
+		return hostCommand(ctx, host, "exim", "-Mvc", id)
+	},
+	act: func(ctx context.Context, host string, a action, id string) *exec.Cmd {
+		if a == actionFlush {
+			return hostCommand(ctx, host, "exim", "-qf")
+		}
+		if flag, ok := eximFlags[a]; ok {
+			return hostCommand(ctx, host, "exim", flag, id)
+		}
+		return nil
+	},
+	bulk: func(ctx context.Context, host string, a action, ids []string) *exec.Cmd {
+		return hostCommand(ctx, host, "exim", append([]string{eximFlags[a]}, ids...)...)
+	},
+	queueID: eximQueueID.MatchString,
+	count: func(out []byte, a action, ids []string) map[string]int {
+		// exim reports per message; those it could not handle say so
+		failed := 0
+		for _, id := range ids {
+			for _, line := range strings.Split(string(out), "\n") {
+				if strings.Contains(line, id) && (strings.Contains(line, "not found") || strings.Contains(line, "failed") || strings.Contains(line, "is not")) {
+					failed++
+					break
+				}
+			}
+		}
+		return map[string]int{eximVerbs[a]: len(ids) - failed}
+	},
+}
+
+// eximQueueID matches an Exim message ID, old ("1i8Xyz-000123-AB") and
+// new style ("1i8XyzA-000000123A-AbCd").
+var eximQueueID = regexp.MustCompile(`^[0-9A-Za-z]{6,7}-[0-9A-Za-z]{6,11}-[0-9A-Za-z]{2,4}$`)
+
+// listEximQueue runs "exim -bp" on host and parses its output.
+func listEximQueue(host string) ([]queueEntry, error) {
+	ctx, cancel := commandContext(context.Background(), "exim")
+	defer cancel()
+	cmd := hostCommand(ctx, host, "exim", "-bp")
+	out, err := runOutput(cmd)
+	if err := commandError(ctx, cmd, err, out); err != nil {
+		return nil, err
+	}
+	entries := parseEximQueue(out, time.Now())
+	for i := range entries {
+		entries[i].Host = host
+	}
+	logger.Debug("queue listed", "host", host, "entries", len(entries))
+	return entries, nil
+}
+
+// parseEximQueue parses "exim -bp": a line per message with its age,
+// size, ID and sender, "*** frozen ***" at the end if frozen, followed by
+// the indented recipients, "D " in front of those already delivered.
+//
+//	25m  2.9K 1i8Xyz-000123-AB <alice@example.com> *** frozen ***
+//	          bob@example.net
+//	        D carol@example.org
+func parseEximQueue(out []byte, now time.Time) []queueEntry {
+	var entries []queueEntry
+	listParse.begin(len(out))
+	defer listParse.end()
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		listParse.advance(len(sc.Bytes()) + 1)
+		fields := strings.Fields(sc.Text())
+		switch {
+		case len(fields) >= 4 && eximQueueID.MatchString(fields[2]):
+			e := queueEntry{ID: fields[2], Queue: "deferred", Size: parseEximSize(fields[1])}
+			if age, ok := parseEximAge(fields[0]); ok {
+				e.Arrival = now.Add(-age)
+			}
+			e.Sender = strings.TrimSuffix(strings.TrimPrefix(fields[3], "<"), ">")
+			if strings.Contains(sc.Text(), "*** frozen ***") {
+				e.Queue = "hold"
+			}
+			entries = append(entries, e)
+		case len(entries) == 0 || len(fields) == 0:
+		case len(fields) == 1:
+			last := &entries[len(entries)-1]
+			last.Recipients = append(last.Recipients, fields[0])
+		default:
+			// "D rcpt" delivered, "+D rcpt" delivered to a generated address
+			logger.Debug("exim recipient skipped", "id", entries[len(entries)-1].ID, "line", sc.Text())
+		}
+	}
+	return entries
+}
+
+// parseEximAge reads the age column of "exim -bp": "45s", "25m", "4h",
+// "2d" or "3w".
+func parseEximAge(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil {
+		return 0, false
+	}
+	unit := map[byte]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}[s[len(s)-1]]
+	return time.Duration(n) * unit, unit != 0
+}
+
+// parseEximSize reads the size column of "exim -bp": bytes, or with a K
+// or M suffix and one decimal.
+func parseEximSize(s string) int64 {
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1024, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1024*1024, strings.TrimSuffix(s, "M")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(f * mult)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseEximQueue(t *testing.T) {
+	out, err := os.ReadFile("testdata/exim-bp.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC)
+	want := []queueEntry{
+		{ID: "1i8Xyz-000123-AB", Queue: "deferred", Size: 2969, Arrival: now.Add(-25 * time.Minute),
+			Sender: "alice@example.com", Recipients: []string{"bob@example.net"}},
+		{ID: "1i8Xzz-000456-CD", Queue: "hold", Size: 812, Arrival: now.Add(-4 * time.Hour),
+			Sender: "", Recipients: []string{"postmaster@example.com"}},
+		{ID: "1tQhOa-00000003Rk-1tZX", Queue: "deferred", Size: 1258291, Arrival: now.Add(-48 * time.Hour),
+			Sender: "bulk@lists.example.org", Recipients: []string{"dave@example.net", "erin@example.net"}},
+	}
+	got := parseEximQueue(out, now)
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.ID != w.ID || g.Queue != w.Queue || g.Size != w.Size || !g.Arrival.Equal(w.Arrival) ||
+			g.Sender != w.Sender || !slices.Equal(g.Recipients, w.Recipients) {
+			t.Errorf("entry %d:\n got %+v\nwant %+v", i, g, w)
+		}
+	}
+}
+
+func TestParseEximAgeAndSize(t *testing.T) {
+	ages := map[string]time.Duration{
+		"45s": 45 * time.Second,
+		"25m": 25 * time.Minute,
+		"4h":  4 * time.Hour,
+		"2d":  48 * time.Hour,
+		"3w":  21 * 24 * time.Hour,
+	}
+	for s, want := range ages {
+		if got, ok := parseEximAge(s); !ok || got != want {
+			t.Errorf("parseEximAge(%q) = %v, %v; want %v", s, got, ok, want)
+		}
+	}
+	for _, s := range []string{"", "5", "5y", "xm"} {
+		if _, ok := parseEximAge(s); ok {
+			t.Errorf("parseEximAge(%q) accepted", s)
+		}
+	}
+	sizes := map[string]int64{"812": 812, "2.9K": 2969, "1.0M": 1 << 20, "huge": 0}
+	for s, want := range sizes {
+		if got := parseEximSize(s); got != want {
+			t.Errorf("parseEximSize(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestQueueID(t *testing.T) {
+	tests := []struct {
+		id            string
+		postfix, exim bool
+	}{
+		{"4ABC123DEF", true, false},
+		{"4XyzQd1Hb7zJ8kL", true, false},
+		{"1i8Xyz-000123-AB", false, true},
+		{"1tQhOa-00000003Rk-1tZX", false, true},
+		{"ab", false, false},
+		{"not-an-id", false, false},
+	}
+	for _, tt := range tests {
+		if got := postfixMTA.queueID(tt.id); got != tt.postfix {
+			t.Errorf("Postfix: queueID(%q) = %v, want %v", tt.id, got, tt.postfix)
+		}
+		if got := eximMTA.queueID(tt.id); got != tt.exim {
+			t.Errorf("Exim: queueID(%q) = %v, want %v", tt.id, got, tt.exim)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// receivedTLS matches the TLS note Postfix adds to its Received header,
+// "(using TLSv1.3 with cipher TLS_AES_256_GCM_SHA384 (256/256 bits) …)".
+var receivedTLS = regexp.MustCompile(`using (\S+) with cipher (\S+)(?: \(([^)]*)\))?`)
+
+// envelopeAttributes returns the named attributes ("named_attribute:
+// name=value") of the envelope records in postcat output. Postfix stores
+// the client, HELO and SASL details of the reception there.
+func envelopeAttributes(postcat string) map[string]string {
+	attrs := map[string]string{}
+	if i := strings.Index(postcat, "*** MESSAGE CONTENTS"); i >= 0 {
+		postcat = postcat[:i]
+	}
+	for _, line := range strings.Split(postcat, "\n") {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), "named_attribute: ")
+		if !ok {
+			continue
+		}
+		if name, value, ok := strings.Cut(rest, "="); ok {
+			attrs[name] = value
+		}
+	}
+	return attrs
+}
+
+// originSummary renders how the message was received: client, HELO,
+// protocol, SASL login and TLS. It returns "" if the queue file carries
+// none of it, e.g. for mail Postfix generated itself.
+func originSummary(postcat string) string {
+	attrs := envelopeAttributes(postcat)
+	var sb strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&sb, "%-8s %s\n", label+":", value)
+		}
+	}
+
+	client := attrs["log_client_name"]
+	if addr := attrs["log_client_address"]; addr != "" {
+		client = strings.TrimSpace(client + " [" + addr + "]")
+	}
+	if client == "" {
+		client = attrs["log_message_origin"]
+	}
+	line("Client", client)
+	line("HELO", attrs["log_helo_name"])
+	line("Proto", attrs["log_protocol_name"])
+	if user := attrs["sasl_username"]; user != "" {
+		line("Auth", user+" ("+attrs["sasl_method"]+")")
+	} else if client != "" {
+		line("Auth", "none")
+	}
+
+	if h := messageHeaders(postcat); h != nil {
+		// the topmost Received header is the one of this server
+		if rcvd := h["Received"]; len(rcvd) > 0 {
+			if m := receivedTLS.FindStringSubmatch(rcvd[0]); m != nil {
+				tls := m[1] + " " + m[2]
+				if m[3] != "" {
+					tls += " (" + m[3] + ")"
+				}
+				line("TLS", tls)
+			}
+		}
+	}
+
+	if sb.Len() == 0 {
+		return ""
+	}
+	sb.WriteString(strings.Repeat("─", 40) + "\n")
+	return sb.String()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// paletteRows is how many matching commands the palette shows at once.
+const paletteRows = 12
+
+var disabledStyle = lipgloss.NewStyle().Faint(true)
+
+// openPalette shows the command palette with an empty filter.
+func (m *model) openPalette() {
+	m.paletteInput = textinput.New()
+	m.paletteInput.Prompt = "> "
+	m.paletteInput.Focus()
+	m.paletteSelected = 0
+	m.palettePresets = false
+	m.showPalette = true
+}
+
+// paletteMatches returns the commands matching the palette filter. The
+// filter matches fuzzily: its letters must appear in order in the title.
+// The filter presets are listed after the commands, or alone when the
+// palette was opened as the preset picker.
+func (m model) paletteMatches() []command {
+	filter := strings.ToLower(strings.TrimSpace(m.paletteInput.Value()))
+	all := append(slices.Clone(commands), m.presetCommands()...)
+	if m.palettePresets {
+		all = m.presetCommands()
+	}
+	var matches []command
+	for _, c := range all {
+		if c.title == "command palette" {
+			continue
+		}
+		if fuzzyMatch(strings.ToLower(c.title), filter) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+// fuzzyMatch reports whether the runes of pattern occur in s in order.
+func fuzzyMatch(s, pattern string) bool {
+	for _, r := range pattern {
+		if r == ' ' {
+			continue
+		}
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
+
+// updatePalette handles keys while the palette is open.
+func (m model) updatePalette(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	matches := m.paletteMatches()
+	switch msg.String() {
+	case "esc", "ctrl+c", "ctrl+p":
+		m.showPalette = false
+		return m, nil
+	case "up", "ctrl+k":
+		if m.paletteSelected > 0 {
+			m.paletteSelected--
+		}
+		return m, nil
+	case "down", "ctrl+j":
+		if m.paletteSelected < len(matches)-1 {
+			m.paletteSelected++
+		}
+		return m, nil
+	case "enter":
+		if m.paletteSelected >= len(matches) {
+			return m, nil
+		}
+		c := matches[m.paletteSelected]
+		if reason := m.disabledReason(c); reason != "" {
+			m.status = reason
+			return m, nil
+		}
+		m.showPalette = false
+		return m, m.runCommand(c)
+	}
+	var cmd tea.Cmd
+	m.paletteInput, cmd = m.paletteInput.Update(msg)
+	m.paletteSelected = 0
+	return m, cmd
+}
+
+// paletteView renders the palette centered on the screen. Commands that
+// cannot run right now are shown dimmed with the reason.
+func (m model) paletteView() string {
+	matches := m.paletteMatches()
+	var sb strings.Builder
+	sb.WriteString(m.paletteInput.View() + "\n\n")
+	start := 0
+	if m.paletteSelected >= paletteRows {
+		start = m.paletteSelected - paletteRows + 1
+	}
+	for i := start; i < len(matches) && i < start+paletteRows; i++ {
+		c := matches[i]
+		line := padRight(c.title, 32) + " " + strings.Join(c.keys, " ")
+		if reason := m.disabledReason(c); reason != "" {
+			line = disabledStyle.Render(line + "  (" + reason + ")")
+		}
+		if i == m.paletteSelected {
+			line = selectedStyle.Render("> ") + line
+		} else {
+			line = "  " + line
+		}
+		sb.WriteString(line + "\n")
+	}
+	if len(matches) == 0 {
+		sb.WriteString("  no matching command\n")
+	}
+	return m.dialogBox(72, lipgloss.Top, strings.TrimRight(sb.String(), "\n"))
+}
+
+// padRight pads s with spaces to width cells.
+func padRight(s string, width int) string {
+	if w := lipgloss.Width(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
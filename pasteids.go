@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// 'I' selects the messages of a list of queue IDs pasted into a prompt, as
+// a monitoring mail or a ticket has them, or read from a file given as
+// @PATH. The IDs may be separated by blanks, newlines (a paste arrives as
+// one line) or commas and carry mailq's '*' and '!' markers. The usual
+// keys then act on the selection.
+
+// openPasteIDsPrompt opens the prompt for the queue IDs.
+func (m *model) openPasteIDsPrompt() {
+	m.pasteInput = textinput.New()
+	m.pasteInput.Prompt = "select queue IDs (paste them, or @FILE): "
+	m.pasteInput.Focus()
+	m.showPastePrompt = true
+}
+
+// updatePasteIDsPrompt handles keys while the prompt is open.
+func (m model) updatePasteIDsPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.showPastePrompt = false
+		return m, nil
+	case "enter":
+		m.showPastePrompt = false
+		text := strings.TrimSpace(m.pasteInput.Value())
+		if path, ok := strings.CutPrefix(text, "@"); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				m.status = "no queue IDs read: " + err.Error()
+				return m, nil
+			}
+			text = string(data)
+		}
+		return m, m.selectPastedIDs(text)
+	}
+	var cmd tea.Cmd
+	m.pasteInput, cmd = m.pasteInput.Update(msg)
+	return m, cmd
+}
+
+// pastedIDs returns the queue IDs in text, each once, in their order;
+// words that are no queue ID are counted in skipped.
+func pastedIDs(text string) (ids []string, skipped int) {
+	seen := map[string]bool{}
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || strings.ContainsRune(" \t\r\n", r)
+	})
+	for _, w := range words {
+		id := strings.TrimRight(w, "*!")
+		switch {
+		case !looksLikeQueueID(id):
+			skipped++
+		case !seen[id]:
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, skipped
+}
+
+// selectPastedIDs selects the messages of the list whose IDs are in text
+// and reports how many were found.
+func (m *model) selectPastedIDs(text string) tea.Cmd {
+	ids, skipped := pastedIDs(text)
+	if len(ids) == 0 {
+		m.status = "no queue IDs found in the input"
+		return nil
+	}
+	listed := map[string]bool{}
+	for _, e := range m.entries {
+		listed[e.ID] = true
+	}
+	var found, unknown []string
+	for _, id := range ids {
+		if listed[id] {
+			found = append(found, id)
+		} else {
+			unknown = append(unknown, id)
+		}
+	}
+	status := fmt.Sprintf("selected %d of %d queue IDs", len(found), len(ids))
+	if len(unknown) > 0 {
+		status += fmt.Sprintf(", %d not in the list (%s)", len(unknown), truncate(strings.Join(unknown, " "), 40))
+	}
+	if skipped > 0 {
+		status += fmt.Sprintf(", %d other words skipped", skipped)
+	}
+	if len(found) == 0 {
+		m.status = status
+		return nil
+	}
+	return m.selectIDs(found, status+" — d, h or r act on them")
+}
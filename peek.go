@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// 'p' peeks at the selected message: its envelope and headers in a popup
+// over the list, for a quick look when the list pane is wide and the
+// details narrow. Only the headers are read (postcat -h). esc or 'p'
+// closes it; any other key closes it too and does what it always does, so
+// 'd' deletes the message just looked at.
+
+// peekView is the open popup.
+type peekView struct {
+	id    string
+	entry queueEntry
+	view  viewport.Model
+}
+
+// peekMsg delivers the headers of a peeked message.
+type peekMsg struct {
+	id, text string
+	err      error
+}
+
+// openPeek opens the popup for the selected message and fetches its
+// headers.
+func (m *model) openPeek() tea.Cmd {
+	if m.selected >= len(m.entries) {
+		return nil
+	}
+	e := m.entries[m.selected]
+	width := max(min(m.termWidth-8, 96), 20)
+	height := max(min(m.termHeight-10, 24), 3)
+	m.peek = &peekView{id: e.ID, entry: e, view: m.popupViewport(width, height)}
+	m.peek.view.SetContent("Loading headers…")
+	if e.Queue == "corrupt" {
+		m.peek.view.SetContent("corrupt queue file, 'l' inspects it in the details")
+		return nil
+	}
+	id, host := e.ID, e.Host
+	return m.pool.submit(id, prioSelected, func(ctx context.Context) tea.Msg {
+		ctx, cancel := commandContext(ctx, "postcat")
+		defer cancel()
+		cmd := backend.show(ctx, host, id, true)
+		out, err := runOutput(cmd)
+		return peekMsg{id: id, text: string(out), err: commandError(ctx, cmd, err, nil)}
+	})
+}
+
+// showPeek fills the popup with the fetched headers.
+func (m *model) showPeek(msg peekMsg) {
+	if m.peek == nil || m.peek.id != msg.id {
+		return
+	}
+	if msg.err != nil {
+		m.peek.view.SetContent(warningStyle.Render("cannot read the headers: " + firstLine(msg.err.Error())))
+		return
+	}
+	var lines []string
+	for _, line := range strings.Split(msg.text, "\n") {
+		if !strings.HasPrefix(line, "*** ") {
+			lines = append(lines, line)
+		}
+	}
+	text := envelopeSummary(m.peek.entry) + "\n" + strings.TrimSpace(strings.Join(lines, "\n"))
+	m.peek.view.SetContent(lipgloss.NewStyle().Width(m.peek.view.Width).Render(text))
+}
+
+// updatePeek handles a key while the popup is open. Keys other than
+// scrolling close it and are not handled.
+func (m *model) updatePeek(key string) bool {
+	switch key {
+	case "up":
+		m.peek.view.LineUp(1)
+	case "down":
+		m.peek.view.LineDown(1)
+	case "pgup":
+		m.peek.view.HalfViewUp()
+	case "pgdown":
+		m.peek.view.HalfViewDown()
+	case "esc", "p":
+		m.peek = nil
+	default:
+		m.peek = nil
+		return false
+	}
+	return true
+}
+
+// peekPopup renders the popup centered over the list.
+func (m model) peekPopup() string {
+	title := fmt.Sprintf("%s — up/down scroll, esc closes", m.peek.id)
+	return m.dialogBox(m.peek.view.Width+4, lipgloss.Center, title+"\n\n"+m.peek.view.View())
+}
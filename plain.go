@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+// -plain (plain = true) draws the interface as bare text for dumb
+// terminals and flaky ssh links: no colors, no borders around the panes,
+// the list and the details side by side with a "|" between them, and the
+// selected entry in inverse video. Popups and dialogs take the place of
+// the details instead of being drawn over the panes, as bare text without
+// a frame, wrapped to the width of the details. The keys are the
+// same; the three panes of '|' fall back to two.
+
+// plainPaneGap separates the list from the details.
+const plainPaneGap = " | "
+
+// usePlainStyles makes every lipgloss style render its text only, and
+// the warning and the dialogs go without their frame.
+func usePlainStyles() {
+	lipgloss.SetColorProfile(termenv.Ascii)
+	warningBorder = lipgloss.NewStyle()
+	dialogBoxStyle = lipgloss.NewStyle()
+}
+
+// dialogBox renders text as a popup of width cells, framed and placed on
+// the screen at vpos (lipgloss.Top leaves two lines above it); in plain
+// mode it is a plainDialog.
+func (m model) dialogBox(width int, vpos lipgloss.Position, text string) string {
+	if m.cfg.Plain {
+		return m.plainDialog(text)
+	}
+	box := dialogBoxStyle.Copy().Width(width).Render(text)
+	if vpos == lipgloss.Top {
+		box = "\n\n" + box
+	}
+	return lipgloss.Place(m.termWidth, m.termHeight, lipgloss.Center, vpos, box)
+}
+
+// popupViewport is the scrolled area of a popup, width by height cells;
+// in plain mode it fills the details column below the popup's title.
+func (m model) popupViewport(width, height int) viewport.Model {
+	if m.cfg.Plain {
+		return viewport.New(max(m.right.Width, 20), max(m.left.Height-1, 3))
+	}
+	return viewport.New(width, height)
+}
+
+// plainDialog is a popup in plain mode: its bare text, wrapped to the
+// details column it takes the place of and cut to the height of the panes.
+func (m model) plainDialog(text string) string {
+	lines := strings.Split(lipgloss.NewStyle().Width(max(m.right.Width, 20)).Render(text), "\n")
+	if h := m.left.Height + 1; len(lines) > h {
+		lines = lines[:h]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderSelected renders the selected row of the list: inverse video in
+// plain mode, where the styles render nothing.
+func (m model) renderSelected(line string) string {
+	if m.cfg.Plain {
+		return "\x1b[7m" + line + "\x1b[0m"
+	}
+	return selectedStyle.Render(line)
+}
+
+// plainLayout is the main layout of View in plain mode.
+func (m model) plainLayout(header, entry, status string) string {
+	list := m.listHeader() + "\n" + m.left.View()
+	details := m.right.View()
+	if len(m.entries) == 0 {
+		list = m.listHeader() + "\n" + m.emptyList()
+		details = m.emptyDetails()
+	}
+	if popup := m.popupView(); popup != "" {
+		details = popup
+	}
+	body := details
+	if !m.zoomed {
+		body = joinPlain(list, m.left.Width+2, details)
+	}
+	return header + "\n" + body + "\n" + entry + "\n" + status + "\n" + m.queueChips() + "\n" + m.keyHints()
+}
+
+// joinPlain puts left, padded or cut to width cells, and right side by
+// side, line by line.
+func joinPlain(left string, width int, right string) string {
+	l, r := strings.Split(left, "\n"), strings.Split(right, "\n")
+	lines := make([]string, max(len(l), len(r)))
+	for i := range lines {
+		var a, b string
+		if i < len(l) {
+			a = ansi.Truncate(l[i], width, "")
+		}
+		if i < len(r) {
+			b = r[i]
+		}
+		lines[i] = a + strings.Repeat(" ", max(width-ansi.StringWidth(a), 0)) + plainPaneGap + b
+	}
+	return strings.Join(lines, "\n")
+}
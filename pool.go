@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Job priorities of the worker pool; lower runs first.
+const (
+	prioSelected   = iota // the entry the user is looking at
+	prioPrefetch          // speculative background work near the selection
+	prioBackground        // queue-wide scans, not tied to the selection
+)
+
+// prefetchWindow is how far (in list entries) a prefetch may be away from
+// the selection before it is cancelled.
+const prefetchWindow = 20
+
+// pool runs background commands (postcat and friends) with bounded
+// concurrency so a busy session cannot spawn dozens of child processes.
+type pool struct {
+	mu      sync.Mutex
+	limit   int
+	running map[*poolJob]struct{}
+	queue   []*poolJob
+	seq     uint64
+}
+
+type poolJob struct {
+	key    string // usually the queue ID the job belongs to
+	prio   int
+	seq    uint64
+	run    func(ctx context.Context) tea.Msg
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan tea.Msg
+}
+
+func newPool(limit int) *pool {
+	if limit < 1 {
+		limit = 1
+	}
+	return &pool{limit: limit, running: map[*poolJob]struct{}{}}
+}
+
+// submit queues run and returns a command that waits for its result.
+// A cancelled job yields no message.
+func (p *pool) submit(key string, prio int, run func(ctx context.Context) tea.Msg) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &poolJob{key: key, prio: prio, run: run, ctx: ctx, cancel: cancel, done: make(chan tea.Msg, 1)}
+
+	p.mu.Lock()
+	p.seq++
+	j.seq = p.seq
+	p.queue = append(p.queue, j)
+	p.dispatchLocked()
+	p.mu.Unlock()
+
+	return func() tea.Msg {
+		return <-j.done
+	}
+}
+
+// dispatchLocked starts queued jobs, best priority and oldest first, until
+// the limit is reached.
+func (p *pool) dispatchLocked() {
+	for len(p.running) < p.limit && len(p.queue) > 0 {
+		best := 0
+		for i, j := range p.queue {
+			b := p.queue[best]
+			if j.prio < b.prio || (j.prio == b.prio && j.seq < b.seq) {
+				best = i
+			}
+		}
+		j := p.queue[best]
+		p.queue = append(p.queue[:best], p.queue[best+1:]...)
+		p.running[j] = struct{}{}
+		go p.work(j)
+	}
+}
+
+func (p *pool) work(j *poolJob) {
+	msg := j.run(j.ctx)
+	if j.ctx.Err() != nil {
+		msg = nil
+	}
+	j.cancel()
+	j.done <- msg
+
+	p.mu.Lock()
+	delete(p.running, j)
+	p.dispatchLocked()
+	p.mu.Unlock()
+}
+
+// cancelWhere cancels every queued or running job whose key and priority
+// match drop.
+func (p *pool) cancelWhere(drop func(key string, prio int) bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	kept := p.queue[:0]
+	for _, j := range p.queue {
+		if drop(j.key, j.prio) {
+			j.cancel()
+			j.done <- nil
+			continue
+		}
+		kept = append(kept, j)
+	}
+	p.queue = kept
+	for j := range p.running {
+		if drop(j.key, j.prio) {
+			j.cancel()
+		}
+	}
+}
+
+// cancel drops all jobs belonging to key.
+func (p *pool) cancel(key string) {
+	p.cancelWhere(func(k string, _ int) bool { return k == key })
+}
+
+// setLimit changes the concurrency limit. Running jobs are not interrupted
+// when it shrinks.
+func (p *pool) setLimit(limit int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limit = maxInt(limit, 1)
+	p.dispatchLocked()
+}
+
+// stats returns the number of running and queued jobs and the limit.
+func (p *pool) stats() (running, queued, limit int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.running), len(p.queue), p.limit
+}
+
+// statusLine returns the status text plus, in debug mode, the pool statistics.
+func (m model) statusLine() string {
+	if m.showSearchPrompt {
+		if m.searchErr != "" {
+			return m.searchInput.View() + "  " + warningStyle.Render(m.searchErr)
+		}
+		if m.searchPreview != "" {
+			return m.searchInput.View() + "  " + disabledStyle.Render(m.searchPreview)
+		}
+		return m.searchInput.View()
+	}
+	if m.showPresetPrompt {
+		return m.presetInput.View()
+	}
+	if m.showPastePrompt {
+		return m.pasteInput.View()
+	}
+	if m.exportID != "" {
+		return m.exportInput.View()
+	}
+	status := m.status
+	if m.parseStatus != "" {
+		status = strings.TrimSuffix(m.parseStatus+" — "+status, " — ")
+	}
+	if len(m.marked) > 0 && !strings.HasPrefix(status, "selected: ") {
+		status = "[selected: " + m.selectionSummary() + "] " + status
+	}
+	if len(m.bookmarks) > 0 {
+		status = fmt.Sprintf("[%d bookmarks] ", len(m.bookmarks)) + status
+	}
+	if len(m.review) > 0 {
+		status = fmt.Sprintf("[%d for review] ", len(m.review)) + status
+	}
+	status = latencyStatus() + status
+	if !m.showDebug {
+		return status
+	}
+	running, queued, limit := m.pool.stats()
+	return fmt.Sprintf("[pool %d/%d running, %d queued] %s", running, limit, queued, status)
+}
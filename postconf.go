@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Some features depend on the Postfix version: postqueue -j came with 3.1,
+// postsuper -e with 3.5. postconf is asked once at startup; when it cannot
+// tell, postdel assumes an old Postfix, tries the listing commands in turn
+// and leaves expire off.
+
+// postfixInfo is what postconf tells about the local Postfix.
+type postfixInfo struct {
+	version      string // mail_version, "" if unknown
+	major, minor int
+	longIDs      bool  // enable_long_queue_ids
+	longIDsKnown bool  // whether longIDs was read
+	err          error // why the version is unknown
+
+	configDir string // config_directory, "" if unknown
+	instance  string // multi_instance_name, "" for none
+	queueDir  string // queue_directory, "" if unknown
+}
+
+// detectedPostfix is the local Postfix, see detectPostfix. It stays
+// unknown with -hosts, whose Postfix versions may differ.
+var detectedPostfix = postfixInfo{err: errors.New("not detected")}
+
+// detectPostfix asks postconf for the version and the queue ID format.
+func detectPostfix() postfixInfo {
+	var info postfixInfo
+	ctx, cancel := commandContext(context.Background(), "postconf")
+	defer cancel()
+	cmd := postfixCommand(ctx, "postconf", "-d", "mail_version")
+	out, err := runOutput(cmd)
+	if err != nil {
+		info.err = commandError(ctx, cmd, err, nil)
+		return info
+	}
+	_, version, _ := strings.Cut(strings.TrimSpace(string(out)), "=")
+	info.version = strings.TrimSpace(version)
+	if info.major, info.minor, err = parseMailVersion(info.version); err != nil {
+		info.version, info.err = "", err
+		return info
+	}
+
+	out, err = runOutput(postfixCommand(ctx, "postconf", "-h", "enable_long_queue_ids"))
+	if err == nil {
+		info.longIDs = strings.TrimSpace(string(out)) == "yes"
+		info.longIDsKnown = true
+	}
+	out, err = runOutput(postfixCommand(ctx, "postconf", "-h", "config_directory", "multi_instance_name", "queue_directory"))
+	if err == nil {
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		info.configDir = strings.TrimSpace(lines[0])
+		if len(lines) > 1 {
+			info.instance = strings.TrimSpace(lines[1])
+		}
+		if len(lines) > 2 {
+			info.queueDir = strings.TrimSpace(lines[2])
+		}
+	}
+	logger.Info("postfix detected", "version", info.version, "long_queue_ids", info.longIDs, "config_directory", info.configDir, "instance", info.instance)
+	return info
+}
+
+// parseMailVersion reads the major and minor number of a mail_version
+// like "3.7.2" or "3.10-20240826".
+func parseMailVersion(v string) (major, minor int, err error) {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("unknown mail_version %q", v)
+	}
+	minorPart, _, _ := strings.Cut(parts[1], "-")
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(minorPart)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("unknown mail_version %q", v)
+	}
+	return major, minor, nil
+}
+
+// atLeast reports whether the version is known and major.minor or later.
+func (p postfixInfo) atLeast(major, minor int) bool {
+	return p.version != "" && (p.major > major || p.major == major && p.minor >= minor)
+}
+
+// hasJSON reports whether "postqueue -j" may be tried: not when the
+// version is known to be older than 3.1.
+func (p postfixInfo) hasJSON() bool {
+	return p.version == "" || p.atLeast(3, 1)
+}
+
+// hasExpire reports whether "postsuper -e" exists.
+func (p postfixInfo) hasExpire() bool {
+	return p.atLeast(3, 5)
+}
+
+// hasDeliver reports whether "postqueue -i" exists.
+func (p postfixInfo) hasDeliver() bool {
+	return p.atLeast(2, 4)
+}
+
+// String describes the detected Postfix for the help screen.
+func (p postfixInfo) String() string {
+	if p.version == "" {
+		return "Postfix, version unknown (" + p.err.Error() + "), assuming an old one"
+	}
+	s := "Postfix " + p.version
+	if p.configDir != "" {
+		s += ", " + strings.TrimPrefix(p.where(), "Postfix ")
+	}
+	if p.longIDsKnown {
+		if p.longIDs {
+			s += ", long queue IDs"
+		} else {
+			s += ", short queue IDs"
+		}
+	}
+	return s
+}
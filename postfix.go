@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exitNoPostfix is the exit code when the Postfix tools cannot be run.
+const exitNoPostfix = 3
+
+// postfixFallbackDirs are searched after $PATH; the sbin directories are
+// often missing from the PATH of ordinary users.
+var postfixFallbackDirs = []string{"/usr/sbin", "/usr/local/sbin", "/usr/bin"}
+
+// postfixDir is the configured directory of the Postfix tools ("" means
+// search $PATH and the fallback directories). Set from the config at
+// startup and on reload.
+var postfixDir string
+
+// timeoutConfig limits how long Postfix programs may run before they are
+// killed, so a huge or locked queue file cannot hang the UI. 0 means no
+// limit.
+type timeoutConfig struct {
+	Postcat  time.Duration `toml:"postcat"`  // postcat, per message
+	Commands time.Duration `toml:"commands"` // mailq, postqueue and postsuper
+}
+
+// commandTimeouts is the configured timeoutConfig, set like postfixDir.
+var commandTimeouts timeoutConfig
+
+// commandContext derives the context to run the Postfix program name
+// with, bounded by its configured timeout.
+func commandContext(parent context.Context, name string) (context.Context, context.CancelFunc) {
+	timeouts := currentSettings().timeouts
+	d := timeouts.Commands
+	if name == "postcat" {
+		d = timeouts.Postcat
+	}
+	if d <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, d)
+}
+
+// postfixPath returns the path to run the Postfix program name with.
+func postfixPath(name string) string {
+	if dir := currentSettings().postfixDir; dir != "" {
+		return filepath.Join(dir, name)
+	}
+	if p, err := exec.LookPath(name); err == nil {
+		return p
+	}
+	for _, dir := range postfixFallbackDirs {
+		p := filepath.Join(dir, name)
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() && fi.Mode()&0o111 != 0 {
+			return p
+		}
+	}
+	return name
+}
+
+// postfixCommand is exec.CommandContext for a Postfix program.
+func postfixCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, postfixPath(name), args...)
+	cmd.Args[0] = name // keep messages short: "postsuper -d ID"
+	return cmd
+}
+
+// toolProblem describes why one Postfix program cannot be run.
+type toolProblem struct {
+	name   string
+	path   string // where it was found, "" if nowhere
+	reason string
+}
+
+// checkTools looks for every program of the mail server and reports the
+// ones that are missing or not executable.
+func checkTools() []toolProblem {
+	var problems []toolProblem
+	for _, name := range backend.tools {
+		if p := findTool(name); p != nil {
+			problems = append(problems, *p)
+		}
+	}
+	return problems
+}
+
+func findTool(name string) *toolProblem {
+	candidates := []string{filepath.Join(postfixDir, name)}
+	if postfixDir == "" {
+		candidates = nil
+		for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+		for _, dir := range postfixFallbackDirs {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+	var found string
+	for _, p := range candidates {
+		fi, err := os.Stat(p)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		if fi.Mode()&0o111 != 0 {
+			return nil
+		}
+		if found == "" {
+			found = p
+		}
+	}
+	if found != "" {
+		return &toolProblem{name: name, path: found, reason: "exists but is not executable"}
+	}
+	return &toolProblem{name: name, reason: "not found"}
+}
+
+// missingToolsReport explains the result of checkTools and how to point
+// postdel at a mail server installed elsewhere.
+func missingToolsReport(problems []toolProblem, configPath string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "postdel needs the %s command line tools, but cannot run:\n\n", backend.title)
+	notExec := false
+	for _, p := range problems {
+		if p.path != "" {
+			fmt.Fprintf(&sb, "  %-10s %s %s\n", p.name, p.path, p.reason)
+			notExec = true
+			continue
+		}
+		fmt.Fprintf(&sb, "  %-10s %s\n", p.name, p.reason)
+	}
+	sb.WriteString("\n")
+	if postfixDir != "" {
+		fmt.Fprintf(&sb, "Searched: %s (postfix_dir)\n", postfixDir)
+	} else {
+		fmt.Fprintf(&sb, "Searched: $PATH=%s, then %s\n", os.Getenv("PATH"), strings.Join(postfixFallbackDirs, ", "))
+	}
+	if notExec {
+		who := "the current user"
+		if u, err := user.Current(); err == nil {
+			who = "user " + u.Username
+		}
+		fmt.Fprintf(&sb, "Some programs exist but are not executable by %s; check their permissions.\n", who)
+	}
+	fmt.Fprintf(&sb, "If %s is installed elsewhere, set postfix_dir in %s\nor pass -postfix-dir DIR.\n", backend.title, configPath)
+	return sb.String()
+}
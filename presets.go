@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Filter presets are named filter expressions for the cleanups that come
+// back every week. They are defined under [presets] in the config file;
+// the ones saved from a session go to presets.toml next to it, which
+// postdel only appends to so the config file keeps its comments.
+
+// presetName is what a preset may be called: a bare TOML key.
+var presetName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// savedPresetsPath is the file "save filter as preset" appends to.
+func savedPresetsPath(configPath string) string {
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "presets.toml")
+}
+
+// parsePreset parses the expression of preset name. Presets are filters
+// only, plain words to search for are rejected.
+func parsePreset(name, expr string) (listFilter, error) {
+	f, text, err := parseFilterInput(expr)
+	if err == nil && text != "" {
+		err = fmt.Errorf("%q is no filter term", text)
+	}
+	if err == nil && !f.active() {
+		err = fmt.Errorf("empty expression")
+	}
+	if err != nil {
+		return listFilter{}, fmt.Errorf("preset %s: %w", name, err)
+	}
+	return f, nil
+}
+
+// addSavedPresets merges the presets saved in path into c; those of the
+// config file win. A missing file is not an error.
+func (c *config) addSavedPresets(path string) error {
+	if path == "" {
+		return nil
+	}
+	saved := map[string]string{}
+	_, err := toml.DecodeFile(path, &saved)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("presets %s: %w", path, err)
+	}
+	for name, expr := range saved {
+		if _, ok := c.Presets[name]; ok {
+			continue
+		}
+		if _, err := parsePreset(name, expr); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if c.Presets == nil {
+			c.Presets = map[string]string{}
+		}
+		c.Presets[name] = expr
+	}
+	return nil
+}
+
+// presetNames returns the preset names of c, sorted.
+func (c config) presetNames() []string {
+	var names []string
+	for name := range c.Presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// presetFilter looks up and parses preset name, naming the existing ones
+// if there is no such preset.
+func (c config) presetFilter(name string) (listFilter, error) {
+	expr, ok := c.Presets[name]
+	if !ok {
+		if len(c.Presets) == 0 {
+			return listFilter{}, fmt.Errorf("no preset %q, none are defined", name)
+		}
+		return listFilter{}, fmt.Errorf("no preset %q, expected one of %s", name, strings.Join(c.presetNames(), ", "))
+	}
+	return parsePreset(name, expr)
+}
+
+// presetCommands are the palette entries applying the presets.
+func (m model) presetCommands() []command {
+	var cmds []command
+	for _, name := range m.cfg.presetNames() {
+		cmds = append(cmds, command{
+			title: "filter preset " + name,
+			run:   func(m *model) tea.Cmd { return m.applyPreset(name) },
+		})
+	}
+	return cmds
+}
+
+// applyPreset applies preset name as if its expression had been typed.
+func (m *model) applyPreset(name string) tea.Cmd {
+	f, err := m.cfg.presetFilter(name)
+	if err != nil {
+		m.status = err.Error()
+		return nil
+	}
+	m.searchHistory.add(historyItem{f.expr, m.searchOpts})
+	return m.applyPromptInput(f, "")
+}
+
+// activePreset returns the name of the preset the filter is, "" if it is
+// none or was changed since.
+func (m model) activePreset() string {
+	if !m.filter.active() {
+		return ""
+	}
+	for _, name := range m.cfg.presetNames() {
+		if f, err := m.cfg.presetFilter(name); err == nil && f.expr == m.filter.expr {
+			return name
+		}
+	}
+	return ""
+}
+
+// openPresetPicker shows the palette with only the presets.
+func (m *model) openPresetPicker() {
+	if len(m.cfg.Presets) == 0 {
+		m.status = "no filter presets, define them under [presets] in the config file or save the filter with alt+f"
+		return
+	}
+	m.openPalette()
+	m.palettePresets = true
+}
+
+// openSavePresetPrompt asks for the name to save the current filter under.
+func (m *model) openSavePresetPrompt() {
+	if !m.filter.active() {
+		m.status = "no filter to save, set one with '/'"
+		return
+	}
+	m.presetInput = textinput.New()
+	m.presetInput.Prompt = "save filter as preset: "
+	m.presetInput.Focus()
+	m.showPresetPrompt = true
+}
+
+// updatePresetPrompt handles keys while the save-preset prompt is open.
+func (m model) updatePresetPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.showPresetPrompt = false
+		return m, nil
+	case "enter":
+		name := strings.TrimSpace(m.presetInput.Value())
+		if err := m.savePreset(name); err != nil {
+			m.status = "preset not saved: " + err.Error()
+		} else {
+			m.status = fmt.Sprintf("saved preset %s to %s", name, savedPresetsPath(m.flags.configPath))
+		}
+		m.showPresetPrompt = false
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.presetInput, cmd = m.presetInput.Update(msg)
+	return m, cmd
+}
+
+// savePreset appends the current filter as preset name to the presets file
+// and makes it available right away.
+func (m *model) savePreset(name string) error {
+	switch {
+	case !presetName.MatchString(name):
+		return fmt.Errorf("a name consists of letters, digits, - and _")
+	case m.cfg.Presets[name] != "":
+		return fmt.Errorf("%s exists already", name)
+	}
+	path := savedPresetsPath(m.flags.configPath)
+	if path == "" {
+		return fmt.Errorf("no config directory")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	err = toml.NewEncoder(f).Encode(map[string]string{name: m.filter.expr})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if m.cfg.Presets == nil {
+		m.cfg.Presets = map[string]string{}
+	}
+	m.cfg.Presets[name] = m.filter.expr
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// 'v' in the confirmation of a bulk action previews it instead: every
+// message it would act on with its age, size, sender, first recipient and
+// subject, the ones in the active queue (which 's' leaves out) listed
+// apart. A bulk delete (selection, range, alt+d, the trash, 'X') opens
+// with it, unless bulk.preview is off. The subjects are read from the
+// headers in the background like those of 'J', only for the lines in
+// view, and filled in as they come; what is still being read when the
+// report closes is dropped.
+// The report is planned from the IDs the confirmation holds the way the
+// bulk operation plans them (see planBulk), so the protected messages a
+// delete leaves out and the messages in maildrop the other actions leave
+// out are listed in sections of their own, with their count. 'y' plans
+// again on the listing of that moment, which a refresh since the report
+// was drawn may have changed; 'n' cancels, 'w' writes the messages acted
+// on to a file as TSV.
+
+// previewRefresh is how many subjects arrive between two redraws of the
+// report; redrawing it for each would be slow for thousands of messages.
+const previewRefresh = 50
+
+// bulkPreview is the open report.
+type bulkPreview struct {
+	view    viewport.Model
+	lines   []queueEntry    // the message on each line, zero on the headings
+	acted   int             // messages the action runs on
+	pending map[string]bool // subjects being read for the report
+	arrived int             // subjects fetched since the last redraw
+}
+
+// openBulkPreview opens the report for the confirmation's messages and
+// fetches the missing subjects of those in view.
+func (m *model) openBulkPreview() tea.Cmd {
+	width := max(m.termWidth-8, 20)
+	height := max(m.termHeight-10, 3)
+	m.preview = &bulkPreview{view: m.popupViewport(width, height), pending: map[string]bool{}}
+	m.renderBulkPreview()
+	return m.fetchPreviewSubjects()
+}
+
+// closeBulkPreview closes the report and cancels the subjects still being
+// read for it.
+func (m *model) closeBulkPreview() {
+	p := m.preview
+	m.preview = nil
+	if len(p.pending) == 0 {
+		return
+	}
+	m.pool.cancelWhere(func(key string, prio int) bool { return prio == prioBackground && p.pending[key] })
+	for id := range p.pending {
+		if m.subjects[id] == subjectPending {
+			// a cancelled job sends nothing; read it again when asked
+			delete(m.subjects, id)
+		}
+	}
+}
+
+// fetchPreviewSubjects fetches the missing subjects of the messages on the
+// lines in view.
+func (m *model) fetchPreviewSubjects() tea.Cmd {
+	p := m.preview
+	end := min(p.view.YOffset+p.view.Height, len(p.lines))
+	var missing []queueEntry
+	for _, e := range p.lines[min(p.view.YOffset, end):end] {
+		if _, known := m.subjects[e.ID]; e.ID != "" && !known {
+			missing = append(missing, e)
+		}
+	}
+	cmd := m.fetchSubjectsOf(missing)
+	for _, e := range missing {
+		if m.subjects[e.ID] == subjectPending {
+			p.pending[e.ID] = true
+		}
+	}
+	return cmd
+}
+
+// notePreviewSubject redraws the report now and then while the subjects of
+// id arrive, and once the last one is in.
+func (m *model) notePreviewSubject(id string) {
+	p := m.preview
+	if !p.pending[id] {
+		return
+	}
+	delete(p.pending, id)
+	p.arrived++
+	if p.arrived < previewRefresh && len(p.pending) > 0 {
+		return
+	}
+	p.arrived = 0
+	m.renderBulkPreview()
+}
+
+// previewEntries splits the listed messages the confirmation acts on into
+// those in the active queue and the others, and returns the plan for the
+// ones it leaves out.
+func (m model) previewEntries() (others, active []queueEntry, plan bulkPlan) {
+	plan = m.planBulk(m.confirmAction, listedTargets(m.allEntries, m.confirmedIDs()))
+	activeIDs := map[string]bool{}
+	for _, id := range m.activeTargets() {
+		activeIDs[id] = true
+	}
+	for _, e := range plan.acted() {
+		if activeIDs[e.ID] {
+			active = append(active, e)
+		} else {
+			others = append(others, e)
+		}
+	}
+	return others, active, plan
+}
+
+// renderBulkPreview fills the report.
+func (m *model) renderBulkPreview() {
+	others, active, plan := m.previewEntries()
+	now := time.Now()
+	row := func(e queueEntry) string {
+		age, size := "?", "?"
+		if !e.Arrival.IsZero() {
+			age = formatAge(now.Sub(e.Arrival))
+		}
+		if e.Size > 0 {
+			size = formatSize(e.Size)
+		}
+		sender := e.Sender
+		if sender == "" {
+			sender = "<>"
+		}
+		rcpt := ""
+		if len(e.Recipients) > 0 {
+			rcpt = e.Recipients[0]
+			if len(e.Recipients) > 1 {
+				rcpt += fmt.Sprintf(" +%d", len(e.Recipients)-1)
+			}
+		}
+		subject, known := m.subjects[e.ID]
+		if !known || subject == subjectPending {
+			subject = "…"
+		}
+		return truncate(fmt.Sprintf("%-16s %-9s %5s %10s  %-24s %-24s  %s", e.ID, e.Queue, age, size,
+			truncate(sender, 24), truncate(rcpt, 24), subject), m.preview.view.Width)
+	}
+	p := m.preview
+	var lines []string
+	p.lines = p.lines[:0]
+	section := func(heading string, entries []queueEntry) {
+		if len(entries) == 0 {
+			return
+		}
+		if heading != "" {
+			lines = append(lines, "", warningStyle.Render(heading))
+			p.lines = append(p.lines, queueEntry{}, queueEntry{})
+		}
+		for _, e := range entries {
+			lines = append(lines, row(e))
+			p.lines = append(p.lines, e)
+		}
+	}
+	section("", others)
+	section(fmt.Sprintf("in the active queue, being delivered now (%d; 's' leaves them out):", len(active)), active)
+	section(fmt.Sprintf("protected, left out (%d; see protect in the config):", len(plan.protected)), plan.protected)
+	section(fmt.Sprintf("in maildrop, left out (%d; only delete works there):", len(plan.leftOut)), plan.leftOut)
+	p.acted = len(others) + len(active)
+	p.view.SetContent(strings.Join(lines, "\n"))
+}
+
+// updateBulkPreview handles a key while the report is open.
+func (m *model) updateBulkPreview(key string) tea.Cmd {
+	switch key {
+	case "up", "k":
+		m.preview.view.LineUp(1)
+	case "down", "j":
+		m.preview.view.LineDown(1)
+	case "pgup":
+		m.preview.view.HalfViewUp()
+	case "pgdown", " ":
+		m.preview.view.HalfViewDown()
+	case "s":
+		if !m.skipActiveTargets() {
+			m.closeBulkPreview()
+			m.cancelAction()
+			return nil
+		}
+		m.renderBulkPreview()
+	case "w":
+		m.writeBulkPreview()
+	case "y":
+		m.closeBulkPreview()
+		return m.confirmYes()
+	case "n", "ctrl+c":
+		m.closeBulkPreview()
+		m.cancelAction()
+		return nil
+	case "esc", "v":
+		// back to the confirmation
+		m.closeBulkPreview()
+		return nil
+	}
+	return m.fetchPreviewSubjects()
+}
+
+// writeBulkPreview writes the report as TSV to a file in the current
+// directory.
+func (m *model) writeBulkPreview() {
+	others, active, _ := m.previewEntries()
+	name := fmt.Sprintf("postdel-%s-preview-%s.tsv", m.confirmAction, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(name, []byte(entriesTSV(append(others, active...))), 0o600); err != nil {
+		m.status = "cannot write the preview: " + err.Error()
+		return
+	}
+	m.status = fmt.Sprintf("%d messages written to %s", len(others)+len(active), name)
+}
+
+// bulkPreviewView renders the report centered on the screen.
+func (m model) bulkPreviewView() string {
+	title := fmt.Sprintf("%s %d messages? — y runs it, n cancels, w writes them to a file, esc back", m.confirmAction, m.preview.acted)
+	if n := len(m.preview.pending); n > 0 {
+		title += fmt.Sprintf(" (reading %d subjects)", n)
+	}
+	return m.dialogBox(m.preview.view.Width+4, lipgloss.Center, title+"\n\n"+m.preview.view.View())
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Protected messages are those matching one of the filter expressions
+// under protect in the config file, e.g. mail to the monitoring address.
+// Bulk deletes leave them out and say how many; a single delete of one
+// asks a second time, naming the expression, and triage refuses it. A
+// term that cannot tell (a size not known yet) counts as a match, so
+// nothing is deleted for lack of information.
+
+// protectExprs are the protect expressions in effect, set like postfixDir,
+// for -serve; the interface reads its own cfg.Protect.
+var protectExprs []string
+
+// parseProtect parses the protect expressions of the config.
+func parseProtect(exprs []string) ([]listFilter, error) {
+	var filters []listFilter
+	for _, expr := range exprs {
+		f, text, err := parseFilterInput(expr)
+		if err == nil && text != "" {
+			err = fmt.Errorf("%q is no filter term", text)
+		}
+		if err == nil && !f.active() {
+			err = fmt.Errorf("empty expression")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", expr, err)
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// protectedBy returns the expression of filters that e matches, "" if it
+// matches none.
+func protectedBy(filters []listFilter, env filterEnv, e queueEntry) string {
+	for _, f := range filters {
+		if f.eval(env, e) != filterNo {
+			return f.expr
+		}
+	}
+	return ""
+}
+
+// protectEnv is what the protect expressions are evaluated against.
+func (m model) protectEnv() filterEnv {
+	return filterEnv{tags: m.tags, attachments: m.attachments, retries: m.retryTimes, now: time.Now()}
+}
+
+// entryProtection returns the protect expression the selected message
+// matches, "" if it is not protected.
+func (m model) entryProtection() string {
+	if m.selected >= len(m.entries) {
+		return ""
+	}
+	filters, _ := parseProtect(m.cfg.Protect) // validated with the config
+	return protectedBy(filters, m.protectEnv(), m.entries[m.selected])
+}
+
+// openProtectedDialog asks a second time before deleting the selected,
+// protected message.
+func (m *model) openProtectedDialog(expr string) {
+	m.confirmEntry = m.entries[m.selected]
+	m.dialog = &confirmDialog{
+		question: func(m model) string {
+			return warningStyle.Render(fmt.Sprintf("%s is protected (%s).", m.confirmEntry.ID, expr)) +
+				"\ndelete it anyway [y/N]?"
+		},
+		options: []dialogOption{
+			{keys: []string{"y"}, run: func(m *model) tea.Cmd {
+				if err := m.reselectConfirmed(); err != nil {
+					m.status = err.Error()
+					return nil
+				}
+				return m.runAction(actionDelete)
+			}},
+			{keys: []string{"n", "enter", "esc", "ctrl+c"}, run: func(m *model) tea.Cmd {
+				m.cancelAction()
+				m.status = "not deleted: it is protected"
+				return nil
+			}},
+		},
+	}
+}
+
+// protectedWarning is the line the confirmation of a delete adds for
+// protected messages, "" when there are none.
+func (m model) protectedWarning() string {
+	if m.confirmAction != actionDelete || m.confirmTrash || len(m.cfg.Protect) == 0 {
+		return ""
+	}
+	if m.confirmIDs == nil {
+		if expr := m.entryProtection(); expr != "" {
+			return warningStyle.Render("protected (" + expr + "): you will be asked again")
+		}
+		return ""
+	}
+	protected := m.planBulk(actionDelete, listedTargets(m.allEntries, m.confirmIDs)).protected
+	if len(protected) == 0 {
+		return ""
+	}
+	return warningStyle.Render(fmt.Sprintf("%d of them protected (%s), they are left out", len(protected), strings.Join(m.cfg.Protect, "; ")))
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// queueNames are the Postfix queues in the order of their filter keys 1-6.
+var queueNames = []string{"incoming", "active", "deferred", "hold", "corrupt", "maildrop"}
+
+var hiddenChipStyle = lipgloss.NewStyle().Faint(true).Strikethrough(true)
+
+// parseQueueView parses the -queue flag, "all" or a comma separated list
+// of queue names, into the queues to hide like the keys 1-6 hide them.
+func parseQueueView(s string) (map[string]bool, error) {
+	hidden := map[string]bool{}
+	if s == "all" {
+		return hidden, nil
+	}
+	for _, name := range queueNames {
+		hidden[name] = true
+	}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if !hidden[name] {
+			return nil, fmt.Errorf("unknown queue %q, want all or %s", name, strings.Join(queueNames, ", "))
+		}
+		delete(hidden, name)
+	}
+	return hidden, nil
+}
+
+// postqueueRecord is one line of "postqueue -j" output.
+type postqueueRecord struct {
+	QueueName   string `json:"queue_name"`
+	QueueID     string `json:"queue_id"`
+	ArrivalTime int64  `json:"arrival_time"`
+	MessageSize int64  `json:"message_size"`
+	Sender      string `json:"sender"`
+	Recipients  []struct {
+		Address     string `json:"address"`
+		DelayReason string `json:"delay_reason"`
+	} `json:"recipients"`
+}
+
+// listCommands are the ways to list a Postfix queue, by their config
+// name, in the order they are tried: the showq socket needs no subprocess
+// (only on this machine, see showq.go), "postqueue -j" tells the queues
+// apart, the mailq wrapper is missing on some minimal installs,
+// "postqueue -p" prints the same as mailq.
+var listCommands = []string{"showq", "json", "mailq", "postqueue"}
+
+// listCommandLines are the commands behind listCommands.
+var listCommandLines = map[string]string{"showq": "the showq socket", "json": "postqueue -j", "mailq": "mailq", "postqueue": "postqueue -p"}
+
+// listCommand is the configured listing command, "" to try listCommands
+// in turn. Set from the config at startup and on reload.
+var listCommand string
+
+// listedWith remembers per host the command that listed the queue last,
+// so a change of it is logged once, not on every refresh.
+var listedWith = struct {
+	sync.Mutex
+	byHost map[string]string
+}{byHost: map[string]string{}}
+
+// listPostfixQueue lists the queue of host with listCommand, or with the
+// first of listCommands that works.
+func listPostfixQueue(host string) ([]queueEntry, error) {
+	forced := currentSettings().listCommand
+	var tries []string
+	for _, name := range listCommands {
+		switch {
+		case name == "showq" && (host != "" || showqPath() == ""):
+		case name == "json" && host == "" && !detectedPostfix.hasJSON():
+		default:
+			tries = append(tries, name)
+		}
+	}
+	if forced != "" {
+		tries = []string{forced}
+	}
+	var err error
+	for _, name := range tries {
+		var entries []queueEntry
+		switch name {
+		case "showq":
+			if host != "" {
+				err = fmt.Errorf("showq: only on this machine, not on %s", host)
+				break
+			}
+			entries, err = listShowq()
+		case "json":
+			entries, err = listPostqueueJSON(host)
+		case "mailq":
+			entries, err = listMailq(host, "mailq")
+		case "postqueue":
+			entries, err = listMailq(host, "postqueue", "-p")
+		}
+		if err != nil {
+			logger.Debug("queue listing failed", "host", host, "command", listCommandLines[name], "err", err)
+			continue
+		}
+		listedWith.Lock()
+		if listedWith.byHost[host] != name {
+			listedWith.byHost[host] = name
+			logger.Info("listing the queue with "+listCommandLines[name], "host", host, "forced", forced != "")
+		}
+		listedWith.Unlock()
+		return entries, nil
+	}
+	return nil, err
+}
+
+// listPostqueueJSON lists the queue with "postqueue -j" (Postfix 3.1 and
+// later), which unlike mailq names the queue of every message.
+func listPostqueueJSON(host string) ([]queueEntry, error) {
+	ctx, cancel := commandContext(context.Background(), "postqueue")
+	defer cancel()
+	cmd := hostCommand(ctx, host, "postqueue", "-j")
+	out, err := runOutput(cmd)
+	if err != nil {
+		return nil, commandError(ctx, cmd, err, nil)
+	}
+	return parsePostqueueJSON(out)
+}
+
+// parsePostqueueJSON parses the JSON lines of "postqueue -j".
+func parsePostqueueJSON(out []byte) ([]queueEntry, error) {
+	var entries []queueEntry
+	var stats parseStats
+	listParse.begin(len(out))
+	defer listParse.end()
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1<<20) // messages with many recipients make long lines
+	for scanner.Scan() {
+		stats.lines++
+		listParse.advance(len(scanner.Bytes()) + 1)
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var r postqueueRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			logger.Debug("postqueue -j: unparsable line", "line", string(line))
+			return nil, fmt.Errorf("%w: postqueue -j: %v", ErrParse, err)
+		}
+		e := queueEntry{ID: r.QueueID, Queue: r.QueueName, Size: r.MessageSize, Sender: r.Sender}
+		if r.ArrivalTime > 0 {
+			e.Arrival = time.Unix(r.ArrivalTime, 0)
+		}
+		for _, rcpt := range r.Recipients {
+			e.Recipients = append(e.Recipients, rcpt.Address)
+			if e.Reason == "" {
+				e.Reason = rcpt.DelayReason
+			}
+		}
+		entries = append(entries, e)
+		stats.recipients += len(e.Recipients)
+	}
+	stats.entries = len(entries)
+	stats.log("postqueue -j")
+	return entries, scanner.Err()
+}
+
+// sameListing reports whether two listings show the same messages in the
+// same queues and order, i.e. the list would look the same.
+func sameListing(a, b []queueEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID || a[i].Queue != b[i].Queue || a[i].Host != b[i].Host ||
+			a[i].Sender != b[i].Sender || a[i].Size != b[i].Size || len(a[i].Recipients) != len(b[i].Recipients) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyFilter rebuilds the visible entries from allEntries, the hidden
+// queues, the actionable-only toggle and the filter terms, in the order of
+// the sort. The selection stays on the same message if it is still
+// visible.
+func (m *model) applyFilter() {
+	selectedID := ""
+	if m.selected < len(m.entries) {
+		selectedID = m.entries[m.selected].ID
+	}
+	m.entries = m.entries[:0:0]
+	m.selected = 0
+	m.unknown = 0
+	if m.filter.uses("retry") {
+		m.wantRetryTimes()
+	}
+	if !m.attemptsEstimated && m.usesAttempts() {
+		m.estimateAttempts()
+	}
+	if m.scores != nil {
+		stampScores(m.allEntries, m.scores)
+	}
+	now := time.Now()
+	for _, e := range m.allEntries {
+		if m.hiddenQueues[e.Queue] || (m.actionableOnly && !m.canActOn(e)) {
+			continue
+		}
+		if ok, known := m.matches(m.filter, e, now); !ok {
+			if !known {
+				m.unknown++
+			}
+			continue
+		}
+		m.entries = append(m.entries, e)
+	}
+	sortEntries(m.entries, m.sort, m.cfg.sortThen())
+	for i, e := range m.entries {
+		if e.ID == selectedID {
+			m.selected = i
+		}
+	}
+	m.syncLeft()
+}
+
+// toggleQueue shows or hides the queue with filter key n (1-6) and loads
+// the details of the entry that ends up selected.
+func (m *model) toggleQueue(n int) tea.Cmd {
+	name := queueNames[n-1]
+	if m.hiddenQueues == nil {
+		m.hiddenQueues = map[string]bool{}
+	}
+	m.hiddenQueues[name] = !m.hiddenQueues[name]
+	before := ""
+	if m.selected < len(m.entries) {
+		before = m.entries[m.selected].ID
+	}
+	m.applyFilter()
+	if m.hiddenQueues[name] {
+		m.status = fmt.Sprintf("%s queue hidden, %d of %d messages shown", name, len(m.entries), len(m.allEntries))
+	} else {
+		m.status = fmt.Sprintf("%s queue shown, %d of %d messages shown", name, len(m.entries), len(m.allEntries))
+	}
+	if len(m.entries) == 0 {
+		m.rightRaw = ""
+		m.right.SetContent(m.rightRaw)
+		return nil
+	}
+	if id := m.entries[m.selected].ID; id != before {
+		m.rightRaw = "Loading details…"
+		m.right.SetContent(m.rightRaw)
+		return m.runPostcatCmd(id)
+	}
+	return nil
+}
+
+// queueChips renders the filter chips, one per queue with its message
+// count; hidden queues are struck through.
+func (m model) queueChips() string {
+	counts := map[string]int{}
+	for _, e := range m.allEntries {
+		counts[e.Queue]++
+	}
+	chips := make([]string, len(queueNames))
+	for i, name := range queueNames {
+		chip := fmt.Sprintf("[%d %s %d]", i+1, name, counts[name])
+		if m.hiddenQueues[name] {
+			chip = hiddenChipStyle.Render(chip)
+		}
+		chips[i] = chip
+	}
+	if m.actionableOnly {
+		chips = append(chips, "[A actionable only]")
+	}
+	if m.filter.active() {
+		chip := "[" + truncate(m.filter.String(), filterShown)
+		if name := m.activePreset(); name != "" {
+			chip = "[preset " + name
+		}
+		if m.unknown > 0 {
+			chip += fmt.Sprintf(", %d unknown %s", m.unknown, m.filter.unknownNoun())
+		}
+		chips = append(chips, chip+"]")
+	}
+	if ind := m.sort.indicator(); ind != "" {
+		chips = append(chips, "[sort "+ind+"]")
+	}
+	if chip := m.unlistedChip(); chip != "" {
+		chips = append(chips, chip)
+	}
+	return strings.Join(chips, " ")
+}
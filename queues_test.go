@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParsePostqueueJSONNoRecipients(t *testing.T) {
+	out, err := os.ReadFile("testdata/postqueue-j-no-recipients.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := parsePostqueueJSON(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"4ABC123DEF": false, "4ABC124DEF": true, "4ABC125DEF": true}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for _, e := range entries {
+		if e.noRecipients() != want[e.ID] {
+			t.Errorf("%s (%s): no recipients %v, want %v", e.ID, e.Queue, e.noRecipients(), want[e.ID])
+		}
+	}
+	if e := entries[0]; e.Reason == "" || len(e.Recipients) != 1 {
+		t.Errorf("%s: recipients %q, reason %q", e.ID, e.Recipients, e.Reason)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Values of config.ConfirmQuit.
+const (
+	confirmQuitNever   = "never"
+	confirmQuitAlways  = "always"
+	confirmQuitChanges = "changes"
+)
+
+var confirmQuitModes = []string{confirmQuitNever, confirmQuitAlways, confirmQuitChanges}
+
+// requestQuit quits right away unless a bulk operation is in flight, in
+// which case the user has to decide what happens to it, or confirm_quit
+// asks first.
+func (m model) requestQuit() (tea.Model, tea.Cmd) {
+	switch {
+	case m.bulk != nil:
+		m.dialog = &confirmDialog{
+			question: model.bulkQuitQuestion,
+			width:    48,
+			options: []dialogOption{
+				{keys: []string{"c"}, run: (*model).quitAfterCancel},
+				{keys: []string{"w"}, run: func(m *model) tea.Cmd {
+					// let the operation finish, then quit
+					m.quitAfterBulk = true
+					m.status = "quitting when the operation has finished…"
+					return nil
+				}},
+				{keys: []string{"ctrl+c"}, run: (*model).quitNow},
+				{keys: []string{"n", "esc", "q"}},
+			},
+		}
+	case m.quitNeedsConfirm():
+		m.dialog = &confirmDialog{
+			question: model.quitQuestion,
+			width:    48,
+			options: []dialogOption{
+				{keys: []string{"y", "q", "ctrl+c"}, run: func(*model) tea.Cmd { return tea.Quit }},
+				{keys: []string{"n", "esc"}},
+			},
+		}
+	default:
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// quitNeedsConfirm reports whether confirm_quit asks before quitting.
+func (m model) quitNeedsConfirm() bool {
+	switch m.cfg.ConfirmQuit {
+	case confirmQuitAlways:
+		return true
+	case confirmQuitChanges:
+		return len(m.changes) > 0 || len(m.marked) > 0
+	}
+	return false
+}
+
+// quitAfterCancel cancels the running bulk operation and quits once it has
+// stopped.
+func (m *model) quitAfterCancel() tea.Cmd {
+	m.quitAfterBulk = true
+	if m.bulk != nil {
+		m.bulk.cancel()
+		m.status = fmt.Sprintf("%s: cancelling, quitting afterwards…", m.bulk.action)
+	}
+	return tea.Tick(shutdownGrace, func(time.Time) tea.Msg { return shutdownTimeoutMsg{} })
+}
+
+// quitNow is the second ctrl+c: the escape hatch, it does not wait for
+// anything.
+func (m *model) quitNow() tea.Cmd {
+	if m.bulk != nil {
+		m.bulk.cancel()
+		m.exitReport = fmt.Sprintf("%s cancelled after %d of %d messages, the last batch may have been applied",
+			m.bulk.action, m.bulk.done, m.bulk.total)
+	}
+	return tea.Quit
+}
+
+// bulkQuitQuestion asks what happens to the running bulk operation on
+// quit.
+func (m model) bulkQuitQuestion() string {
+	progress := ""
+	if m.bulk != nil {
+		progress = fmt.Sprintf("%s in progress: %d of %d messages done.\n\n", m.bulk.action, m.bulk.done, m.bulk.total)
+	}
+	return progress +
+		"Quit anyway?\n\n" +
+		"c  cancel the operation and quit\n" +
+		"w  wait for it to finish, then quit\n" +
+		"n  keep running (esc)\n" +
+		"ctrl+c  quit immediately"
+}
+
+// quitQuestion asks whether to quit, saying what the session changed and
+// what is still selected.
+func (m model) quitQuestion() string {
+	var sb strings.Builder
+	if len(m.changes) > 0 {
+		var done []string
+		for _, a := range allActions {
+			if n := m.changes[a]; n > 0 {
+				done = append(done, fmt.Sprintf("%s %d", a, n))
+			}
+		}
+		sb.WriteString("Changed this session: " + strings.Join(done, ", ") + ".\n")
+	}
+	if len(m.marked) > 0 {
+		fmt.Fprintf(&sb, "%d messages are still selected.\n", len(m.marked))
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Quit postdel? [y/N] (q again quits too)")
+	return sb.String()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// With -raw-files, 'w' shows the queue file of the selected message as it
+// is on disk, record by record, without postcat in between: for when
+// postcat itself is in doubt. Reading the spool needs root.
+
+// rawFileMsg is the dump of a queue file, or why it could not be read.
+type rawFileMsg struct {
+	id   string
+	text string
+	err  error
+}
+
+// recordNames are the record types of a queue file that come up most,
+// from Postfix's rec_type.h.
+var recordNames = map[byte]string{
+	'C': "size",
+	'T': "time",
+	'S': "sender",
+	'R': "recipient",
+	'O': "orig rcpt",
+	'D': "done rcpt",
+	'A': "attribute",
+	'M': "content",
+	'N': "line",
+	'X': "extracted",
+	'E': "end",
+}
+
+// showRawFile reads the queue file of the selected message in the
+// background.
+func (m *model) showRawFile() tea.Cmd {
+	if !m.flags.rawFiles {
+		m.status = "reading queue files is off, start postdel with -raw-files"
+		return nil
+	}
+	if backend.name != "postfix" {
+		m.status = "raw queue files are only read for Postfix"
+		return nil
+	}
+	e := m.entries[m.selected]
+	if e.Host != "" {
+		m.status = "the queue files of " + e.Host + " cannot be read from here"
+		return nil
+	}
+	m.rightRaw = "Reading the queue file…"
+	m.right.SetContent(m.rightRaw)
+	return m.pool.submit(e.ID, prioSelected, func(ctx context.Context) tea.Msg {
+		path := queueFile(queueDirectory(), e)
+		if path == "" {
+			return rawFileMsg{id: e.ID, err: fmt.Errorf("no queue file for %s in %s (not root?)", e.ID, e.Queue)}
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return rawFileMsg{id: e.ID, err: err}
+		}
+		return rawFileMsg{id: e.ID, text: fmt.Sprintf("raw queue file %s, %d bytes\n\n%s", path, len(raw), dumpRecords(raw))}
+	})
+}
+
+// dumpRecords renders the records of a queue file, one per line with
+// offset, type and length. A record is a type byte, the length in 7-bit
+// groups (least significant first, the high bit set on all but the last)
+// and the data. Where the records stop making sense the rest follows as a
+// hex dump.
+func dumpRecords(raw []byte) string {
+	var sb strings.Builder
+	off := 0
+	for off < len(raw) {
+		typ, pos := raw[off], off+1
+		length, shift := 0, 0
+		for pos < len(raw) && shift < 32 {
+			c := raw[pos]
+			pos++
+			length |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		if pos+length > len(raw) || shift >= 32 || typ < ' ' || typ > '~' {
+			fmt.Fprintf(&sb, "\nno valid record at offset %d, the rest as is:\n\n%s", off, hex.Dump(raw[off:]))
+			return sb.String()
+		}
+		name := recordNames[typ]
+		if name == "" {
+			name = "?"
+		}
+		fmt.Fprintf(&sb, "%8d %c %-10s %5d  %s\n", off, typ, name, length, strings.ReplaceAll(printableDump(raw[pos:pos+length]), "\n", "."))
+		off = pos + length
+	}
+	return sb.String()
+}
+
+// updateRawFile shows a queue file dump if its message is still selected.
+func (m *model) updateRawFile(msg rawFileMsg) {
+	if m.selected >= len(m.entries) || m.entries[m.selected].ID != msg.id {
+		return
+	}
+	if msg.err != nil {
+		m.rightRaw = "Cannot read the queue file: " + msg.err.Error()
+		m.status = "'l' shows the message again"
+	} else {
+		m.rightRaw = msg.text
+		m.status = "raw queue file of " + msg.id + ", 'l' shows the message again"
+	}
+	m.right.SetContent(m.rightRaw)
+	m.right.GotoTop()
+}
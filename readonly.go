@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os/user"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Reasons for a read-only session.
+const (
+	readOnlyNoPrivileges = "read-only: insufficient privileges"
+	readOnlyRequested    = "read-only mode (--read-only)"
+)
+
+// privileged reports whether u may change the queue with postsuper, which
+// Postfix reserves for root and the mail owner.
+func privileged(u *user.User) bool {
+	return u != nil && (u.Uid == "0" || u.Username == "root" || u.Username == "postfix")
+}
+
+// refuseReadOnly reports whether the session is read-only and, if so, says
+// why in the status line. Destructive keys call it before doing anything.
+func (m *model) refuseReadOnly() bool {
+	if m.readOnly {
+		m.status = m.readOnlyReason
+	}
+	return m.readOnly
+}
+
+// explainPostcatDenied shows why the details of the selected message cannot
+// be displayed instead of ending the session: without privileges postcat
+// is usually not allowed to read queue files.
+func (m *model) explainPostcatDenied(err error) tea.Cmd {
+	m.rightRaw = warningStyle.Render("postcat was denied access to the queue file.") +
+		"\n\nQueue files are readable by root and the postfix user only; postdel\n" +
+		"is running read-only as another user. The list itself still works.\n\n" +
+		err.Error()
+	m.right.SetContent(m.rightRaw)
+	m.rightMarks = nil
+	return nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Several hosts: with -hosts (or hosts in the config file) postdel lists
+// the queues of those machines over ssh and merges them into one list.
+// Every entry remembers the host it was listed on, and everything done to
+// it (postcat, postsuper) runs there.
+
+// queueHosts are the hosts whose queues are shown, empty for this machine.
+// Set like postfixDir.
+var queueHosts []string
+
+// listedHosts returns the hosts to list, "" standing for this machine.
+func listedHosts() []string {
+	if len(queueHosts) == 0 {
+		return []string{""}
+	}
+	return queueHosts
+}
+
+// plainShellWord matches arguments the remote shell takes literally.
+var plainShellWord = regexp.MustCompile(`^[A-Za-z0-9_./=:-]+$`)
+
+// shellWord quotes s for the remote shell unless it is plain.
+func shellWord(s string) string {
+	if plainShellWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// hostCommand is postfixCommand on host: "" runs the program here, any
+// other host through ssh. ssh has to log in without asking (keys or an
+// agent); BatchMode makes it fail instead of prompting under the TUI.
+// postfix_dir, if set, applies to the hosts as well.
+func hostCommand(ctx context.Context, host, name string, args ...string) *exec.Cmd {
+	if host == "" {
+		return postfixCommand(ctx, name, args...)
+	}
+	prog := name
+	if dir := currentSettings().postfixDir; dir != "" {
+		prog = filepath.Join(dir, name)
+	}
+	return sshCommand(ctx, host, prog, args...)
+}
+
+// sshCommand runs prog on host through ssh, the way hostCommand does.
+func sshCommand(ctx context.Context, host, prog string, args ...string) *exec.Cmd {
+	sshArgs := []string{"-o", "BatchMode=yes", host, "--", shellWord(prog)}
+	for _, a := range args {
+		sshArgs = append(sshArgs, shellWord(a))
+	}
+	return exec.CommandContext(ctx, "ssh", sshArgs...)
+}
+
+// listFailures are the hosts whose queue could not be listed, with the
+// reason.
+type listFailures map[string]error
+
+func (f listFailures) Error() string {
+	hosts := make([]string, 0, len(f))
+	for h := range f {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	parts := make([]string, len(hosts))
+	for i, h := range hosts {
+		parts[i] = h + ": " + firstLine(f[h].Error())
+	}
+	return "cannot list " + strings.Join(parts, "; ")
+}
+
+// hostsFailedMsg reports hosts left out of an otherwise successful listing.
+type hostsFailedMsg listFailures
+
+// listError is a failure to list the queue of this machine, of whichever
+// of the listing commands was tried last. Listing again at once would only
+// fail again, so it counts as fatal however the command failed, see
+// continueAfter.
+type listError struct {
+	err error
+}
+
+func (e listError) Error() string { return e.err.Error() }
+
+func (e listError) Unwrap() error { return e.err }
+
+// listQueue lists the queue of this machine, or with several hosts all of
+// their queues in the order of the hosts. A failure on this machine is
+// returned as a listError; hosts that cannot be listed are returned as
+// listFailures along with the entries of the others.
+func listQueue() ([]queueEntry, error) {
+	hosts := currentSettings().hosts
+	if len(hosts) == 0 {
+		entries, err := backend.list("")
+		if err != nil {
+			return nil, listError{err}
+		}
+		return entries, nil
+	}
+	lists := make([][]queueEntry, len(hosts))
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lists[i], errs[i] = backend.list(host)
+		}()
+	}
+	wg.Wait()
+
+	var entries []queueEntry
+	failed := listFailures{}
+	for i, host := range hosts {
+		if errs[i] != nil {
+			failed[host] = errs[i]
+			continue
+		}
+		entries = append(entries, lists[i]...)
+	}
+	if len(failed) > 0 {
+		return entries, failed
+	}
+	return entries, nil
+}
+
+// partialListing reports whether err only says that some of several hosts
+// could not be listed, while the others could.
+func partialListing(err error) bool {
+	var failed listFailures
+	return errors.As(err, &failed) && len(failed) < len(currentSettings().hosts)
+}
+
+// hostsFailedStatus is the status line for hosts missing from the list.
+func hostsFailedStatus(f listFailures) string {
+	return fmt.Sprintf("%d of %d hosts not listed — %v", len(f), len(queueHosts), f)
+}
+
+// shortHost is host without its domain, for the list.
+func shortHost(host string) string {
+	if i := strings.IndexByte(host, '@'); i >= 0 {
+		host = host[i+1:]
+	}
+	if net.ParseIP(host) == nil {
+		host, _, _ = strings.Cut(host, ".")
+	}
+	return host
+}
+
+// entryHost returns the host of the entry with the given ID: the selected
+// one if it has that ID, as the same ID can be in the queue of two hosts.
+func (m model) entryHost(id string) string {
+	if m.selected < len(m.entries) && m.entries[m.selected].ID == id {
+		return m.entries[m.selected].Host
+	}
+	for _, e := range m.allEntries {
+		if e.ID == id {
+			return e.Host
+		}
+	}
+	return ""
+}
+
+// entryHosts maps the queue IDs of all entries to their host, for
+// background work that cannot look at the model.
+func (m model) entryHosts() map[string]string {
+	hosts := make(map[string]string, len(m.allEntries))
+	for _, e := range m.allEntries {
+		hosts[e.ID] = e.Host
+	}
+	return hosts
+}
+
+// hostGroups splits the IDs of targets by the host of each entry, hosts
+// in the order they appear. An ID in the queue of two hosts is in both
+// groups when both entries are targets, and only in its own otherwise.
+func hostGroups(targets []queueEntry) ([]string, map[string][]string) {
+	var hosts []string
+	groups := map[string][]string{}
+	for _, e := range targets {
+		if _, ok := groups[e.Host]; !ok {
+			hosts = append(hosts, e.Host)
+		}
+		groups[e.Host] = append(groups[e.Host], e.ID)
+	}
+	return hosts, groups
+}
+
+// listedTargets are the entries with one of ids, on whichever host.
+func listedTargets(entries []queueEntry, ids []string) []queueEntry {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var targets []queueEntry
+	for _, e := range entries {
+		if wanted[e.ID] {
+			targets = append(targets, e)
+		}
+	}
+	return targets
+}
+
+// runBulkHosts is runBulk for IDs on several hosts: one run per host, one
+// after the other, with the progress counted over all of them.
+func runBulkHosts(ctx context.Context, a action, hosts []string, groups map[string][]string, pacing bulkConfig, progress func(bulkProgressMsg)) bulkResult {
+	run := runBulk
+	if a == actionDelete && currentSettings().safeDelete {
+		run = runSafeDelete
+	}
+	if len(hosts) == 1 {
+		return run(ctx, hosts[0], a, groups[hosts[0]], pacing, progress)
+	}
+	res := bulkResult{action: a}
+	for _, h := range hosts {
+		res.total += len(groups[h])
+	}
+	done := 0
+	for _, h := range hosts {
+		r := run(ctx, h, a, groups[h], pacing, func(p bulkProgressMsg) {
+			if progress != nil {
+				p.done += done
+				p.total = res.total
+				progress(p)
+			}
+		})
+		done += len(groups[h])
+		res.affected += r.affected
+		res.failedIDs += r.failedIDs
+		for _, s := range r.summary {
+			res.summary = append(res.summary, h+": "+s)
+		}
+		for _, f := range r.failures {
+			res.failures = append(res.failures, fmt.Errorf("%s: %w", h, f))
+		}
+		if r.err != nil {
+			res.err = fmt.Errorf("%s: %w", h, r.err)
+			break
+		}
+	}
+	return res
+}
+
+// waitForHosts turns a partial listing into the list followed by the
+// report of the missing hosts.
+func waitForHosts(entries []queueEntry, f listFailures) tea.Msg {
+	return tea.Sequence(
+		func() tea.Msg { return mailqIDsMsg(entries) },
+		func() tea.Msg { return hostsFailedMsg(f) },
+	)()
+}
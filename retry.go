@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// When some batches of a bulk delete fail (permissions, a message locked
+// by the queue manager, a timeout), the listing after it shows which of
+// the messages are still there. Those are selected, and 'X' deletes them
+// again.
+
+// noteFailedDeletes remembers the messages of a bulk delete that failed in
+// part; the listing that follows narrows them down, see selectFailedDeletes.
+func (m *model) noteFailedDeletes(res bulkResult, ids []string) {
+	m.retryIDs, m.retryPending = nil, false
+	if res.action == actionDelete && res.failed() {
+		m.retryIDs, m.retryPending = ids, true
+	}
+}
+
+// selectFailedDeletes keeps the messages of the failed delete that are
+// still listed in entries and selects them.
+func (m *model) selectFailedDeletes(entries []queueEntry) {
+	if !m.retryPending {
+		return
+	}
+	m.retryPending = false
+	m.retryIDs = stillListed(entries, m.retryIDs)
+	if len(m.retryIDs) == 0 {
+		return
+	}
+	m.marked = map[string]bool{}
+	for _, id := range m.retryIDs {
+		m.marked[id] = true
+	}
+	m.syncLeft()
+	m.status += fmt.Sprintf(" — %d messages were not deleted and are selected, 'X' tries again", len(m.retryIDs))
+}
+
+// stillListed returns the ids that are in entries.
+func stillListed(entries []queueEntry, ids []string) []string {
+	listed := map[string]bool{}
+	for _, e := range entries {
+		listed[e.ID] = true
+	}
+	var left []string
+	for _, id := range ids {
+		if listed[id] {
+			left = append(left, id)
+		}
+	}
+	return left
+}
+
+// retryFailedDeletes deletes the messages of the last failed bulk delete
+// that are still listed, after the usual confirmation.
+func (m *model) retryFailedDeletes() tea.Cmd {
+	if m.refuseReadOnly() {
+		return nil
+	}
+	if m.bulk != nil {
+		m.status = "a bulk operation is already running"
+		return nil
+	}
+	ids := stillListed(m.allEntries, m.retryIDs)
+	if len(ids) == 0 {
+		m.status = "no failed deletes to retry"
+		return nil
+	}
+	m.confirmTrash = false
+	return m.askOrRun(actionDelete, ids, "")
+}
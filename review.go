@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// 'M' puts the message (or the selected ones) on the review list: messages
+// that need a human decision later, by a colleague or in a follow-up,
+// without acting on them now. Unlike the selection nothing acts on the
+// list, and unlike bookmarks it is meant to leave the session: on quit it
+// is printed to stderr and, with review_file (-review-file), written to
+// that file as TSV like 'c' copies the list. The list shows a ⚑ in front
+// of such messages; 'M' again takes them off. A message that leaves the
+// queue stays on the list, marked as gone.
+
+// reviewChar flags a message on the review list.
+const reviewChar = "⚑"
+
+// toggleReview puts the selected messages, or the one under the cursor,
+// on the review list, or takes them off if they all are on it.
+func (m *model) toggleReview() {
+	var targets []queueEntry
+	for _, e := range m.entries {
+		if m.marked[e.ID] {
+			targets = append(targets, e)
+		}
+	}
+	if len(targets) == 0 && m.selected < len(m.entries) {
+		targets = m.entries[m.selected : m.selected+1]
+	}
+	if len(targets) == 0 {
+		return
+	}
+	if m.review == nil {
+		m.review = map[string]queueEntry{}
+	}
+	onList := !slices.ContainsFunc(targets, func(e queueEntry) bool { _, ok := m.review[e.ID]; return !ok })
+	for _, e := range targets {
+		if onList {
+			delete(m.review, e.ID)
+		} else {
+			m.review[e.ID] = e
+		}
+	}
+	verb := "put on"
+	if onList {
+		verb = "taken off"
+	}
+	if len(targets) == 1 {
+		m.status = fmt.Sprintf("%s %s the review list (%d on it)", targets[0].ID, verb, len(m.review))
+	} else {
+		m.status = fmt.Sprintf("%d messages %s the review list (%d on it)", len(targets), verb, len(m.review))
+	}
+	m.syncLeft()
+}
+
+// reviewColumn is the list column showing whether id is on the review
+// list; empty while the list is empty so the list keeps its width.
+func (m model) reviewColumn(id string) string {
+	_, ok := m.review[id]
+	switch {
+	case len(m.review) == 0:
+		return ""
+	case ok:
+		return reviewChar
+	}
+	return " "
+}
+
+// reviewEntries are the messages on the review list in queue ID order, and
+// the IDs of those no longer listed.
+func (m model) reviewEntries() ([]queueEntry, map[string]bool) {
+	listed := make(map[string]bool, len(m.allEntries))
+	for _, e := range m.allEntries {
+		listed[e.ID] = true
+	}
+	entries := make([]queueEntry, 0, len(m.review))
+	gone := map[string]bool{}
+	for id, e := range m.review {
+		entries = append(entries, e)
+		if !listed[id] {
+			gone[id] = true
+		}
+	}
+	slices.SortFunc(entries, func(a, b queueEntry) int { return strings.Compare(a.ID, b.ID) })
+	return entries, gone
+}
+
+// reviewReport is the review list for stderr on quit, "" when empty.
+func (m model) reviewReport() string {
+	if len(m.review) == 0 {
+		return ""
+	}
+	entries, gone := m.reviewEntries()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "postdel: %d messages to review:\n", len(entries))
+	for _, e := range entries {
+		rcpt := strings.Join(e.Recipients, ", ")
+		line := fmt.Sprintf("  %-16s %-9s %s → %s", e.ID, e.Queue, e.senderLabel(), rcpt)
+		if gone[e.ID] {
+			line += " (no longer in the queue)"
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+// writeReview writes the review list to path as TSV, with a column telling
+// whether the message is still in the queue.
+func (m model) writeReview(path string) error {
+	entries, gone := m.reviewEntries()
+	rows := strings.Split(strings.TrimSuffix(entriesTSV(entries), "\n"), "\n")
+	rows[0] += "\tstate"
+	for i, e := range entries {
+		state := "queued"
+		if gone[e.ID] {
+			state = "gone"
+		}
+		rows[i+1] += "\t" + state
+	}
+	return os.WriteFile(path, []byte(strings.Join(rows, "\n")+"\n"), 0o600)
+}
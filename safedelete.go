@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Safe delete: instead of "postsuper -d" right away, the messages are put
+// on hold first, which stops any further delivery attempt, the queue is
+// listed again to see which of them really are on hold now, and only those
+// are deleted. A message being delivered at that very moment is not held
+// and is left alone rather than deleted under the delivery agent. Whatever
+// the hold step held, or holds once its delivery attempt ends, and that
+// was not deleted is released again; messages that were on hold before are
+// left on hold.
+
+// safeDelete makes every delete go through runSafeDelete, see
+// config.SafeDelete.
+var safeDelete bool
+
+// runSafeDelete deletes the ids on host in the three steps described above.
+// It has the signature of runBulk so runBulkHosts can use either; a is
+// always actionDelete.
+func runSafeDelete(ctx context.Context, host string, a action, ids []string, pacing bulkConfig, progress func(bulkProgressMsg)) bulkResult {
+	res := bulkResult{action: a, total: len(ids)}
+
+	before, err := backend.list(host)
+	if err != nil {
+		res.failures = append(res.failures, fmt.Errorf("nothing deleted, cannot list the queue: %w", err))
+		res.failedIDs = len(ids)
+		return res
+	}
+	heldBefore := map[string]bool{}
+	for _, e := range before {
+		if e.Queue == "hold" {
+			heldBefore[e.ID] = true
+		}
+	}
+	// release releases those of ids that were not on hold before
+	release := func(ids []string) {
+		var held []string
+		for _, id := range ids {
+			if !heldBefore[id] {
+				held = append(held, id)
+			}
+		}
+		releaseHeld(ctx, host, held, pacing, &res)
+	}
+
+	hold := runBulk(ctx, host, actionHold, ids, pacing, progress)
+	res.failures = append(res.failures, hold.failures...)
+	if hold.err != nil {
+		res.err = fmt.Errorf("nothing deleted, hold step %w", hold.err)
+		res.failedIDs = len(ids)
+		release(ids)
+		return res
+	}
+
+	entries, err := backend.list(host)
+	if err != nil {
+		res.failures = append(res.failures, fmt.Errorf("nothing deleted, cannot verify the hold: %w", err))
+		res.failedIDs = len(ids)
+		release(ids)
+		return res
+	}
+	queueOf := map[string]string{}
+	for _, e := range entries {
+		queueOf[e.ID] = e.Queue
+	}
+	var held, notHeld []string
+	gone := 0
+	for _, id := range ids {
+		switch queueOf[id] {
+		case "hold":
+			held = append(held, id)
+		case "":
+			gone++
+		default:
+			notHeld = append(notHeld, id)
+		}
+	}
+	logger.Info("safe delete verified", "host", host, "held", len(held), "not_held", len(notHeld), "gone", gone)
+
+	// being delivered, they go on hold once the attempt ends
+	left := notHeld
+	if len(held) > 0 {
+		del := runBulk(ctx, host, actionDelete, held, pacing, progress)
+		res.affected, res.summary, res.err = del.affected, del.summary, del.err
+		res.failures = append(res.failures, del.failures...)
+		res.failedIDs = del.failedIDs
+		if del.affected < len(held) {
+			// which of them are left is not known; releasing a deleted
+			// ID does nothing
+			left = append(left, held...)
+		}
+	}
+	// left alone, so not deleted; the gone ones count as missing
+	res.failedIDs += len(notHeld)
+	if len(notHeld) > 0 {
+		res.summary = append(res.summary, fmt.Sprintf("%d not on hold after the hold step, left alone", len(notHeld)))
+	}
+	if gone > 0 {
+		res.summary = append(res.summary, fmt.Sprintf("%d gone before the delete", gone))
+	}
+	release(left)
+	return res
+}
+
+// releaseHeld releases ids on host again and adds to res how many were.
+// It runs even when ctx was cancelled, as the messages were not on hold
+// before.
+func releaseHeld(ctx context.Context, host string, ids []string, pacing bulkConfig, res *bulkResult) {
+	if len(ids) == 0 {
+		return
+	}
+	rel := runBulk(context.WithoutCancel(ctx), host, actionRelease, ids, pacing, nil)
+	logger.Info("safe delete released", "host", host, "ids", len(ids), "released", rel.affected)
+	if rel.affected > 0 {
+		res.summary = append(res.summary, fmt.Sprintf("%d released from hold again", rel.affected))
+	}
+	for _, f := range rel.failures {
+		res.failures = append(res.failures, fmt.Errorf("not released from hold again: %w", f))
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchWorkers is the number of messages scanned in parallel. The pool
+// bounds this anyway; keeping it below the pool limit leaves room for the
+// fetch of the selected entry.
+const searchWorkers = 2
+
+var searchHitStyle = lipgloss.NewStyle().Reverse(true)
+
+// searchProgressMsg reports a scanned message of a queue-wide search.
+type searchProgressMsg struct {
+	search *search
+	id     string
+	hit    bool
+}
+
+// searchDoneMsg is sent when a queue-wide search has scanned everything.
+type searchDoneMsg struct {
+	search *search
+}
+
+// search is a "find in all messages" run over the postcat output of every
+// entry. Only the matching IDs are kept, not the message contents.
+type search struct {
+	term    string
+	pattern *regexp.Regexp // term, quoted, with (?i) unless case-sensitive
+	hits    map[string]bool
+	done    int
+	total   int
+	running bool
+	updates chan tea.Msg
+	cancel  context.CancelFunc
+}
+
+// searchOptions are the matching options toggled in the search prompt.
+// They stay set for the session.
+type searchOptions struct {
+	caseSensitive bool // tab/ctrl+i, otherwise case is ignored
+	wholeWord     bool // alt+w: the term must not continue a word
+}
+
+// searchPattern compiles term for matching with opts.
+func searchPattern(term string, opts searchOptions) *regexp.Regexp {
+	expr := regexp.QuoteMeta(term)
+	if opts.wholeWord {
+		// \b only where the term itself starts or ends with a word
+		// character, so "@example" still finds "a@example"
+		if r, _ := utf8.DecodeRuneInString(term); isWordRune(r) {
+			expr = `\b` + expr
+		}
+		if r, _ := utf8.DecodeLastRuneInString(term); isWordRune(r) {
+			expr += `\b`
+		}
+	}
+	if !opts.caseSensitive {
+		expr = "(?i)" + expr
+	}
+	return regexp.MustCompile(expr)
+}
+
+// isWordRune reports whether regexp's \b counts r as a word character.
+func isWordRune(r rune) bool {
+	return r == '_' || r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// String renders opts for the prompt: "Aa" when matching case-sensitively,
+// "aa" when not, and "word" for whole words.
+func (o searchOptions) String() string {
+	s := "aa"
+	if o.caseSensitive {
+		s = "Aa"
+	}
+	if o.wholeWord {
+		s += " word"
+	}
+	return s
+}
+
+// searchPrompt is the prompt text with the options.
+func (m model) searchPrompt() string {
+	return "find in all messages [" + m.searchOpts.String() + "]: "
+}
+
+// openSearchPrompt shows the search prompt in the status line.
+func (m *model) openSearchPrompt() {
+	m.searchInput = textinput.New()
+	m.searchInput.Prompt = m.searchPrompt()
+	m.searchInput.Focus()
+	input := m.filter.String()
+	if m.search != nil {
+		input = strings.TrimSpace(input + " " + m.search.term)
+	}
+	m.searchInput.SetValue(input)
+	m.searchHistory.open()
+	m.showSearchPrompt = true
+	m.previewFilter()
+}
+
+// previewIDs is how many matching queue IDs the prompt preview names.
+const previewIDs = 3
+
+// previewFilter checks the filter expression as it is typed: a syntax
+// error, such as an invalid /regular expression/, is shown next to the
+// prompt right away, otherwise how many and which messages it matches, so
+// a pattern too broad shows before ctrl+a and 'd' act on the result.
+func (m *model) previewFilter() {
+	m.searchErr, m.searchPreview = "", ""
+	f, _, err := parseFilterInput(m.searchInput.Value())
+	var syntaxErr *filterSyntaxError
+	if errors.As(err, &syntaxErr) {
+		m.searchErr = syntaxErr.msg
+		return
+	}
+	if err != nil || f.root == nil {
+		return
+	}
+	if f.uses("retry") {
+		m.wantRetryTimes()
+	}
+	var ids []string
+	n, now := 0, time.Now()
+	for _, e := range m.allEntries {
+		if ok, _ := m.matches(f, e, now); ok {
+			if n < previewIDs {
+				ids = append(ids, e.ID)
+			}
+			n++
+		}
+	}
+	switch {
+	case n == 0:
+		m.searchPreview = fmt.Sprintf("matches none of %d messages", len(m.allEntries))
+	case n > len(ids):
+		m.searchPreview = fmt.Sprintf("matches %d of %d: %s, …", n, len(m.allEntries), strings.Join(ids, ", "))
+	default:
+		m.searchPreview = fmt.Sprintf("matches %d of %d: %s", n, len(m.allEntries), strings.Join(ids, ", "))
+	}
+}
+
+// updateSearchPrompt handles keys while the search prompt is open.
+func (m model) updateSearchPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.showSearchPrompt = false
+		return m, nil
+	case "tab": // also ctrl+i
+		m.searchOpts.caseSensitive = !m.searchOpts.caseSensitive
+		m.searchInput.Prompt = m.searchPrompt()
+		return m, nil
+	case "alt+w":
+		m.searchOpts.wholeWord = !m.searchOpts.wholeWord
+		m.searchInput.Prompt = m.searchPrompt()
+		return m, nil
+	case "up", "down":
+		var item historyItem
+		var ok bool
+		if msg.String() == "up" {
+			item, ok = m.searchHistory.prev(historyItem{m.searchInput.Value(), m.searchOpts})
+		} else {
+			item, ok = m.searchHistory.next()
+		}
+		if ok {
+			m.searchInput.SetValue(item.text)
+			m.searchInput.CursorEnd()
+			m.searchOpts = item.opts
+			m.searchInput.Prompt = m.searchPrompt()
+			m.previewFilter()
+		}
+		return m, nil
+	case "enter":
+		f, text, err := parseFilterInput(m.searchInput.Value())
+		var syntaxErr *filterSyntaxError
+		if errors.As(err, &syntaxErr) {
+			// keep the prompt open with the cursor on the offending spot
+			m.searchErr = syntaxErr.msg
+			m.searchInput.SetCursor(syntaxErr.col - 1)
+			return m, nil
+		}
+		m.showSearchPrompt = false
+		m.searchHistory.add(historyItem{strings.TrimSpace(m.searchInput.Value()), m.searchOpts})
+		return m, m.applyPromptInput(f, text)
+	}
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	m.previewFilter()
+	return m, tea.Batch(cmd, m.fetchRetryTimes())
+}
+
+// applyPromptInput sets the filter f and searches for text in what it
+// leaves, as entered in the search prompt.
+func (m *model) applyPromptInput(f listFilter, text string) tea.Cmd {
+	changed := f.expr != m.filter.expr
+	var fetch tea.Cmd
+	if changed {
+		m.setFilter(f)
+		fetch = tea.Batch(m.fetchAttachments(), m.fetchRetryTimes(), m.fetchContentTypes(), m.fetchScores())
+	}
+	if text == "" && (m.search == nil || changed) {
+		// only the filter changed; drop a search of the old list
+		if m.search != nil {
+			m.search.cancel()
+			m.search = nil
+		}
+		return fetch
+	}
+	return tea.Batch(fetch, m.startSearch(text))
+}
+
+// startSearch cancels a previous search and scans all entries for term.
+// An empty term just clears the search.
+func (m *model) startSearch(term string) tea.Cmd {
+	if m.search != nil {
+		m.search.cancel()
+		m.search = nil
+	}
+	m.rightMarks = nil
+	term = strings.TrimSpace(term)
+	if term == "" {
+		m.status = "search cleared"
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &search{
+		term:    term,
+		pattern: searchPattern(term, m.searchOpts),
+		hits:    map[string]bool{},
+		total:   len(m.entries),
+		running: true,
+		updates: make(chan tea.Msg, searchWorkers),
+		cancel:  cancel,
+	}
+	m.search = s
+
+	entries, p, hosts := m.entries, m.pool, m.entryHosts()
+	ids := make(chan string)
+	go func() {
+		defer close(ids)
+		for _, e := range entries {
+			select {
+			case ids <- e.ID:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < searchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				res := p.submit(id, prioBackground, func(jctx context.Context) tea.Msg {
+					jctx, cancel := commandContext(jctx, "postcat")
+					defer cancel()
+					out, err := runOutput(backend.show(jctx, hosts[id], id, false))
+					return err == nil && s.pattern.Match(out)
+				})()
+				hit, _ := res.(bool)
+				select {
+				case s.updates <- searchProgressMsg{search: s, id: id, hit: hit}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		if ctx.Err() == nil {
+			s.updates <- searchDoneMsg{search: s}
+		}
+		close(s.updates)
+	}()
+
+	m.status = fmt.Sprintf("searching %q in %d messages…", term, s.total)
+	return waitForSearch(s.updates)
+}
+
+// waitForSearch returns the next update of a running search.
+func waitForSearch(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-updates
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// String renders the search progress for the status line.
+func (s *search) String() string {
+	if s.running {
+		return fmt.Sprintf("searching %q: %d/%d messages scanned, %d found — n/N to step", s.term, s.done, s.total, len(s.hits))
+	}
+	return fmt.Sprintf("%q found in %d of %d messages — n/N to step", s.term, len(s.hits), s.total)
+}
+
+// stepSearch selects the next (dir 1) or previous (dir -1) entry containing
+// the search term, wrapping around at the ends of the list.
+func (m *model) stepSearch(dir int) tea.Cmd {
+	if m.search == nil || len(m.entries) == 0 {
+		m.status = "no search, press / to search all messages"
+		return nil
+	}
+	if len(m.search.hits) == 0 {
+		m.status = m.search.String()
+		return nil
+	}
+	n := len(m.entries)
+	for step := 1; step <= n; step++ {
+		i := ((m.selected+dir*step)%n + n) % n
+		if m.search.hits[m.entries[i].ID] {
+			m.recordJump()
+			m.selected = i
+			m.syncLeft()
+			m.rightRaw = "Loading details…"
+			m.right.SetContent(m.rightRaw)
+			return m.runPostcatCmd(m.entries[i].ID)
+		}
+	}
+	m.status = "no search hit is shown, the filter hides them all"
+	return nil
+}
+
+// highlightSearch marks every occurrence of the search term in text and
+// returns the result with the numbers of the lines that matched.
+func (m model) highlightSearch(text string) (string, []int) {
+	if m.search == nil {
+		return text, nil
+	}
+	var marks []int
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		found := m.search.pattern.FindAllStringIndex(line, -1)
+		if found == nil {
+			continue
+		}
+		marks = append(marks, i)
+		var sb strings.Builder
+		prev := 0
+		for _, f := range found {
+			sb.WriteString(line[prev:f[0]])
+			sb.WriteString(searchHitStyle.Render(line[f[0]:f[1]]))
+			prev = f[1]
+		}
+		sb.WriteString(line[prev:])
+		lines[i] = sb.String()
+	}
+	return strings.Join(lines, "\n"), marks
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// The session counts the messages it deleted per envelope sender, for the
+// report after an incident ("1240 messages from x, 96 from y"). The
+// entries are taken when the delete is issued, since they are gone from
+// the queue afterwards. A single delete counts when postsuper succeeds; the
+// messages of a bulk delete once it is done and a listing no longer has
+// them, since a batch that failed leaves its messages listed, up to the
+// number postsuper reported deleted.
+
+// senderCount is what the session deleted of one sender.
+type senderCount struct {
+	messages int
+	bytes    int64
+}
+
+// addDeleted counts e as deleted.
+func (m *model) addDeleted(e queueEntry) {
+	if m.deletedBySender == nil {
+		m.deletedBySender = map[string]*senderCount{}
+	}
+	c := m.deletedBySender[e.senderLabel()]
+	if c == nil {
+		c = &senderCount{}
+		m.deletedBySender[e.senderLabel()] = c
+	}
+	c.messages++
+	c.bytes += e.Size
+}
+
+// captureDeletes notes targets as being deleted in bulk.
+func (m *model) captureDeletes(targets ...queueEntry) {
+	if m.pendingDeletes == nil {
+		m.pendingDeletes = map[string]queueEntry{}
+	}
+	for _, e := range targets {
+		m.pendingDeletes[entryKey(e)] = e
+	}
+}
+
+// countDeletes counts the captured entries missing from a new listing once
+// the bulk deletes that captured them are done: as many of them as
+// postsuper reported deleted (deletesConfirmed), since a message can also
+// leave the queue by being delivered. A listing while one runs changes
+// nothing.
+func (m *model) countDeletes(listing []queueEntry) {
+	if len(m.pendingDeletes) == 0 || (m.bulk != nil && m.bulk.action == actionDelete) {
+		return
+	}
+	listed := map[string]bool{}
+	for _, e := range listing {
+		listed[entryKey(e)] = true
+	}
+	for _, key := range slices.Sorted(maps.Keys(m.pendingDeletes)) {
+		if !listed[key] && m.deletesConfirmed > 0 {
+			m.addDeleted(m.pendingDeletes[key])
+			m.deletesConfirmed--
+		}
+	}
+	m.pendingDeletes, m.deletesConfirmed = nil, 0
+}
+
+// senderTally renders the deletes per sender, most messages first, and
+// the totals; "" if nothing was deleted.
+func (m model) senderTally() string {
+	if len(m.deletedBySender) == 0 {
+		return ""
+	}
+	senders := make([]string, 0, len(m.deletedBySender))
+	total := senderCount{}
+	for s, c := range m.deletedBySender {
+		senders = append(senders, s)
+		total.messages += c.messages
+		total.bytes += c.bytes
+	}
+	slices.SortFunc(senders, func(a, b string) int {
+		if c := cmp.Compare(m.deletedBySender[b].messages, m.deletedBySender[a].messages); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "deleted %d messages (%s) from %d senders:\n", total.messages, formatSize(total.bytes), len(senders))
+	for _, s := range senders {
+		c := m.deletedBySender[s]
+		fmt.Fprintf(&sb, "  %7d  %10s  %s\n", c.messages, formatSize(c.bytes), s)
+	}
+	return sb.String()
+}
+
+// logSenderTally writes the deletes per sender to the log file.
+func (m model) logSenderTally() {
+	for s, c := range m.deletedBySender {
+		logger.Info("deleted this session", "sender", s, "messages", c.messages, "bytes", c.bytes)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// With -serve the parsed queue is available as JSON over HTTP, listed
+// again every -serve-interval, so dashboards can poll postdel instead of
+// parsing mailq themselves. It runs next to the interface, or alone when
+// postdel is not started on a terminal. Acting on messages over HTTP needs
+// -serve-actions as well, and a token (serve_token or $POSTDEL_SERVE_TOKEN)
+// that every action request carries as "Authorization: Bearer TOKEN".
+// Requests a browser sends for another site, with a foreign Origin or as a
+// form, are refused, so a web page cannot act on the queue even when the
+// browser can reach the server. An address without a host (":8080")
+// listens on the loopback interface only.
+
+// serveTokenEnv names the environment variable holding the action token,
+// which wins over serve_token.
+const serveTokenEnv = "POSTDEL_SERVE_TOKEN"
+
+// serveToken is the token of the action requests, "" if none is set.
+func serveToken(cfg config) string {
+	if t := os.Getenv(serveTokenEnv); t != "" {
+		return t
+	}
+	return cfg.ServeToken
+}
+
+// serveAddr is the address to listen on for -serve addr: without a host
+// the loopback interface.
+func serveAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// servedEntry is a message as served, named like the TSV columns.
+type servedEntry struct {
+	ID         string     `json:"id"`
+	Queue      string     `json:"queue"`
+	Size       int64      `json:"size"`
+	Arrival    *time.Time `json:"arrival,omitempty"`
+	Sender     string     `json:"sender"` // "" for the null sender
+	Recipients []string   `json:"recipients"`
+	Reason     string     `json:"reason,omitempty"`
+	Host       string     `json:"host,omitempty"`
+}
+
+// servedEntryOf returns e as served.
+func servedEntryOf(e queueEntry) servedEntry {
+	se := servedEntry{ID: e.ID, Queue: e.Queue, Size: e.Size, Sender: e.Sender, Recipients: e.Recipients, Reason: e.Reason, Host: e.Host}
+	if !e.Arrival.IsZero() {
+		se.Arrival = &e.Arrival
+	}
+	if se.Recipients == nil {
+		se.Recipients = []string{}
+	}
+	return se
+}
+
+// entry returns the message served as se.
+func (se servedEntry) entry() queueEntry {
+	e := queueEntry{ID: se.ID, Queue: se.Queue, Size: se.Size, Sender: se.Sender, Recipients: se.Recipients, Reason: se.Reason, Host: se.Host}
+	if se.Arrival != nil {
+		e.Arrival = *se.Arrival
+	}
+	return e
+}
+
+// servedQueue is the answer to GET /queue.
+type servedQueue struct {
+	MTA      string         `json:"mta"`
+	ListedAt time.Time      `json:"listed_at"`
+	Error    string         `json:"error,omitempty"` // of the last listing; the messages are then older
+	Counts   map[string]int `json:"counts"`
+	Messages []servedEntry  `json:"messages"`
+}
+
+// queueServer keeps the last listing for the HTTP handlers.
+type queueServer struct {
+	cfg      config
+	interval time.Duration
+	actions  bool   // -serve-actions
+	token    string // what action requests must carry
+	refresh  chan struct{}
+
+	mu       sync.RWMutex
+	entries  []queueEntry
+	listedAt time.Time
+	err      error
+}
+
+// startServer listens on -serve and serves the queue in the background.
+// Listening errors are returned right away.
+func startServer(cfg config, flags cliFlags) error {
+	token := serveToken(cfg)
+	if flags.serveActions && token == "" {
+		return fmt.Errorf("-serve-actions needs a token: set serve_token or $%s", serveTokenEnv)
+	}
+	addr, err := serveAddr(flags.serve)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s := &queueServer{cfg: cfg, interval: flags.serveInterval, actions: flags.serveActions, token: token, refresh: make(chan struct{}, 1)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /queue", s.handleQueue)
+	mux.HandleFunc("POST /messages/{id}/{action}", s.handleAction)
+	go s.listLoop()
+	go func() {
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		if err := srv.Serve(ln); err != nil {
+			logger.Error("http server stopped", "err", err)
+		}
+	}()
+	logger.Info("serving the queue", "addr", ln.Addr().String(), "actions", s.actions)
+	return nil
+}
+
+// listLoop lists the queue every interval, and right after an action.
+func (s *queueServer) listLoop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		entries, err := listQueue()
+		partial := partialListing(err)
+		s.mu.Lock()
+		if err == nil || partial {
+			s.entries, s.listedAt = entries, time.Now()
+		}
+		s.err = err
+		s.mu.Unlock()
+		select {
+		case <-ticker.C:
+		case <-s.refresh:
+		}
+	}
+}
+
+// handleQueue serves the last listing.
+func (s *queueServer) handleQueue(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	q := servedQueue{MTA: backend.name, ListedAt: s.listedAt, Counts: map[string]int{}, Messages: []servedEntry{}}
+	if s.err != nil {
+		q.Error = s.err.Error()
+	}
+	for _, e := range s.entries {
+		q.Counts[e.Queue]++
+		q.Messages = append(q.Messages, servedEntryOf(e))
+	}
+	s.mu.RUnlock()
+
+	if q.ListedAt.IsZero() {
+		// not listed successfully yet
+		http.Error(w, "queue not listed: "+q.Error, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(q); err != nil {
+		logger.Warn("http: writing the queue", "err", err)
+	}
+}
+
+// authorize checks that r carries the token and does not come from a web
+// page of another site; it answers r and returns false when not.
+func (s *queueServer) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+		http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+		return false
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		// what a form or a simple cross-site request sends
+		switch mt, _, _ := mime.ParseMediaType(ct); mt {
+		case "application/x-www-form-urlencoded", "multipart/form-data", "text/plain", "":
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+			return false
+		}
+	}
+	want := "Bearer " + s.token
+	got := r.Header.Get("Authorization")
+	if s.token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// protection tells why the message id on host must not be deleted: it
+// matches one of the protect expressions exprs, or it is not in the last
+// listing and so cannot be checked. "" when it may be deleted.
+func (s *queueServer) protection(exprs []string, host, id string) string {
+	filters, _ := parseProtect(exprs) // validated with the config
+	if len(filters) == 0 {
+		return ""
+	}
+	s.mu.RLock()
+	i := slices.IndexFunc(s.entries, func(e queueEntry) bool { return e.ID == id && e.Host == host })
+	var e queueEntry
+	if i >= 0 {
+		e = s.entries[i]
+	}
+	s.mu.RUnlock()
+	if i < 0 {
+		return id + " is not in the last listing, its protection cannot be checked"
+	}
+	if expr := protectedBy(filters, filterEnv{now: time.Now()}, e); expr != "" {
+		return id + " is protected (" + expr + ")"
+	}
+	return ""
+}
+
+// handleAction runs a per-message action, e.g. POST /messages/ABC123/hold;
+// ?host= names the host with -hosts.
+func (s *queueServer) handleAction(w http.ResponseWriter, r *http.Request) {
+	id, host := r.PathValue("id"), r.URL.Query().Get("host")
+	a, ok := parseAction(r.PathValue("action"))
+	g := currentSettings()
+	switch {
+	case !s.actions:
+		http.Error(w, "actions are off, start postdel with -serve-actions", http.StatusForbidden)
+		return
+	case !s.authorize(w, r):
+		return
+	case s.cfg.ReadOnly:
+		http.Error(w, "postdel is read-only", http.StatusForbidden)
+		return
+	case !ok || !a.perEntry():
+		http.Error(w, "unknown action", http.StatusNotFound)
+		return
+	case !backend.supports(a):
+		http.Error(w, unsupported(a), http.StatusNotImplemented)
+		return
+	case !looksLikeQueueID(id):
+		http.Error(w, "not a queue ID", http.StatusBadRequest)
+		return
+	case len(g.hosts) > 0 && !slices.Contains(g.hosts, host), len(g.hosts) == 0 && host != "":
+		http.Error(w, "host must be one of -hosts", http.StatusBadRequest)
+		return
+	}
+	if a == actionDelete {
+		if reason := s.protection(g.protect, host, id); reason != "" {
+			http.Error(w, reason, http.StatusForbidden)
+			return
+		}
+	}
+	logger.Info("http action", "action", a, "id", id, "host", host, "remote", r.RemoteAddr)
+
+	var err error
+	found := true
+	if a == actionDelete && g.safeDelete {
+		res := runSafeDelete(r.Context(), host, a, []string{id}, s.cfg.Bulk, nil)
+		found = res.affected > 0 || res.failed()
+		if res.failed() {
+			err = fmt.Errorf("%s", res)
+		}
+	} else {
+		ctx, cancel := commandContext(context.Background(), "postsuper")
+		cmd := a.command(ctx, host, id)
+		out, runErr := runCombinedOutput(cmd)
+		err = commandError(ctx, cmd, runErr, out)
+		cancel()
+		if errors.Is(err, ErrNotFound) || err == nil && backend.name == "postfix" && countSum(backend.count(out, a, []string{id})) == 0 {
+			// postsuper exits 0 for IDs it does not find
+			found, err = false, nil
+		}
+	}
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+	}
+	switch {
+	case !found:
+		http.Error(w, id+": no such message", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	fmt.Fprintf(w, "%s: %s\n", a, id)
+}
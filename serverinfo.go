@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// 'P' shows which Postfix postdel works on: the version, the queue
+// directory and the settings that shape the queue, as postconf prints them,
+// for this machine or for each of -hosts.
+
+// queueParameters are the postconf parameters 'P' shows.
+var queueParameters = []string{
+	"mail_version",
+	"queue_directory",
+	"config_directory",
+	"multi_instance_name",
+	"enable_long_queue_ids",
+	"hash_queue_depth",
+	"hash_queue_names",
+	"queue_run_delay",
+	"minimal_backoff_time",
+	"maximal_backoff_time",
+	"maximal_queue_lifetime",
+	"bounce_queue_lifetime",
+	"qmgr_message_active_limit",
+	"message_size_limit",
+}
+
+// serverInfoView is the open popup; hosts are filled in as postconf
+// answers.
+type serverInfoView struct {
+	hosts []string
+	text  map[string]string
+	view  viewport.Model
+}
+
+// serverInfoMsg is the postconf output for host ("" for this machine).
+type serverInfoMsg struct {
+	host, text string
+	err        error
+}
+
+// openServerInfo opens the popup and asks postconf on every host.
+func (m *model) openServerInfo() tea.Cmd {
+	if backend.name != "postfix" {
+		m.status = "no postconf with " + backend.title
+		return nil
+	}
+	hosts := queueHosts
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	width := max(min(m.termWidth-8, 80), 20)
+	height := max(min(m.termHeight-10, 24), 3)
+	m.serverInfo = &serverInfoView{hosts: hosts, text: map[string]string{}, view: m.popupViewport(width, height)}
+	var cmds []tea.Cmd
+	for _, host := range hosts {
+		cmds = append(cmds, m.pool.submit("postconf "+host, prioSelected, func(ctx context.Context) tea.Msg {
+			ctx, cancel := commandContext(ctx, "postconf")
+			defer cancel()
+			cmd := hostCommand(ctx, host, "postconf", queueParameters...)
+			out, err := runOutput(cmd)
+			return serverInfoMsg{host: host, text: string(out), err: commandError(ctx, cmd, err, nil)}
+		}))
+	}
+	m.syncServerInfo()
+	return tea.Batch(cmds...)
+}
+
+// noteServerInfo adds the answer of a host to the popup.
+func (m *model) noteServerInfo(msg serverInfoMsg) {
+	if m.serverInfo == nil {
+		return
+	}
+	text := strings.TrimSpace(msg.text)
+	if msg.err != nil {
+		text = warningStyle.Render("postconf failed: " + firstLine(msg.err.Error()))
+	}
+	m.serverInfo.text[msg.host] = text
+	m.syncServerInfo()
+}
+
+// syncServerInfo renders the answers so far, one block per host.
+func (m *model) syncServerInfo() {
+	var sb strings.Builder
+	for i, host := range m.serverInfo.hosts {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if host != "" {
+			sb.WriteString(titleNameStyle.Render(host) + "\n")
+		}
+		text, ok := m.serverInfo.text[host]
+		if !ok {
+			text = "Asking postconf…"
+		}
+		sb.WriteString(text + "\n")
+	}
+	m.serverInfo.view.SetContent(lipgloss.NewStyle().Width(m.serverInfo.view.Width).Render(sb.String()))
+}
+
+// updateServerInfo handles keys while the popup is open; all keys but
+// scrolling close it.
+func (m *model) updateServerInfo(key string) {
+	switch key {
+	case "up":
+		m.serverInfo.view.LineUp(1)
+	case "down":
+		m.serverInfo.view.LineDown(1)
+	case "pgup":
+		m.serverInfo.view.HalfViewUp()
+	case "pgdown":
+		m.serverInfo.view.HalfViewDown()
+	default:
+		m.serverInfo = nil
+	}
+}
+
+// serverInfoPopup renders the popup centered on the screen.
+func (m model) serverInfoPopup() string {
+	title := "Postfix settings (postconf) — up/down scroll, esc closes"
+	return m.dialogBox(m.serverInfo.view.Width+4, lipgloss.Center, title+"\n\n"+m.serverInfo.view.View())
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 'S' answers "is the queue recovering?" over the whole session: the first
+// listing is kept and compared with the current one, message by message.
+
+// queueSnapshot is a listing kept for comparison.
+type queueSnapshot struct {
+	at      time.Time
+	entries map[string]queueEntry // by entryKey
+}
+
+// entryKey identifies a message, also across hosts.
+func entryKey(e queueEntry) string {
+	return e.Host + "/" + e.ID
+}
+
+// newSnapshot keeps entries as listed at.
+func newSnapshot(entries []queueEntry, at time.Time) *queueSnapshot {
+	s := &queueSnapshot{at: at, entries: map[string]queueEntry{}}
+	for _, e := range entries {
+		s.entries[entryKey(e)] = e
+	}
+	return s
+}
+
+// showSessionDiff shows in the details what changed in the queue since
+// the first listing of the session.
+func (m *model) showSessionDiff() {
+	if m.firstListing == nil {
+		m.status = "the queue has not been listed yet"
+		return
+	}
+	m.rightRaw = sessionDiff(m.firstListing, m.allEntries, time.Now())
+	if tally := m.senderTally(); tally != "" {
+		m.rightRaw += "\n" + tally
+	}
+	m.rightMarks = nil
+	m.right.SetContent(m.rightRaw)
+	m.right.GotoTop()
+	m.status = "changes since the session started, select a message to leave"
+}
+
+// sessionDiff renders the differences between then and the entries listed
+// now: the counts per queue, the messages that arrived, those that are gone
+// (delivered or removed) and those that moved to another queue.
+func sessionDiff(then *queueSnapshot, now []queueEntry, at time.Time) string {
+	var arrived, gone, moved []string
+	before, after := map[string]int{}, map[string]int{}
+	current := map[string]bool{}
+	for _, e := range then.entries {
+		before[e.Queue]++
+	}
+	for _, e := range now {
+		after[e.Queue]++
+		current[entryKey(e)] = true
+		old, ok := then.entries[entryKey(e)]
+		switch {
+		case !ok:
+			arrived = append(arrived, fmt.Sprintf("  %-14s %-8s %s", e.ID, e.Queue, e.senderLabel()))
+		case old.Queue != e.Queue:
+			moved = append(moved, fmt.Sprintf("  %-14s %s → %s", e.ID, old.Queue, e.Queue))
+		}
+	}
+	for key, e := range then.entries {
+		if !current[key] {
+			gone = append(gone, fmt.Sprintf("  %-14s %-8s %s", e.ID, e.Queue, e.senderLabel()))
+		}
+	}
+	slices.Sort(arrived)
+	slices.Sort(gone)
+	slices.Sort(moved)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Since %s (%s ago)\n\n", then.at.Format("15:04:05"), formatAge(at.Sub(then.at)))
+	fmt.Fprintf(&sb, "%-10s %6d → %d\n", "messages", len(then.entries), len(now))
+	for _, q := range queueNames {
+		if before[q] > 0 || after[q] > 0 {
+			fmt.Fprintf(&sb, "  %-8s %6d → %d\n", q, before[q], after[q])
+		}
+	}
+	section := func(title string, lines []string) {
+		fmt.Fprintf(&sb, "\n%s: %d\n", title, len(lines))
+		for _, l := range lines {
+			sb.WriteString(l + "\n")
+		}
+	}
+	section("arrived", arrived)
+	section("gone (delivered or removed)", gone)
+	section("moved to another queue", moved)
+	return sb.String()
+}
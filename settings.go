@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ',' opens the settings: the options that can change while postdel runs,
+// one per line with their value. up/down choose one, space, enter or
+// right switch it to the next value and left back, and the change applies
+// at once. 's' saves them to settings.toml next to the config file, which
+// is read after the config file and layout.toml and wins over them (the
+// flags still win over all three); without 's' a change lasts for the
+// session. The columns line opens the column chooser ('L'), which saves
+// them to layout.toml as before.
+
+// setting is one line of the settings.
+type setting struct {
+	title  string
+	values []string // cycled through; nil opens a dialog instead
+	get    func(m model) string
+	set    func(m *model, v string) tea.Cmd
+}
+
+// settingsPanel is the open settings.
+type settingsPanel struct {
+	cursor  int
+	changed bool // since the last save
+}
+
+// onOff are the values of a switch.
+var onOff = []string{"on", "off"}
+
+// onOffValue writes b as a value of a switch.
+func onOffValue(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// settings lists the lines of the settings for this session.
+func (m model) settings() []setting {
+	list := []setting{
+		{title: "auto refresh", values: autoRefreshValues,
+			get: func(m model) string { return formatInterval(m.cfg.AutoRefresh) },
+			set: func(m *model, v string) tea.Cmd {
+				d, _ := time.ParseDuration(v) // "off" is 0
+				m.cfg.AutoRefresh = d
+				return m.restartAutoRefresh()
+			}},
+		{title: "autoload", values: autoloadModes,
+			get: func(m model) string { return string(m.cfg.Autoload) },
+			set: func(m *model, v string) tea.Cmd {
+				m.cfg.Autoload = autoloadMode(v)
+				return nil
+			}},
+	}
+	for _, a := range allActions {
+		if !a.perEntry() || !backend.supports(a) {
+			continue
+		}
+		name := a.String()
+		list = append(list, setting{title: "confirm " + name, values: confirmPolicies,
+			get: func(m model) string {
+				if p := m.cfg.Confirm[name]; p != "" {
+					return string(p)
+				}
+				return string(confirmSingle)
+			},
+			set: func(m *model, v string) tea.Cmd {
+				m.cfg.Confirm = maps.Clone(m.cfg.Confirm)
+				if m.cfg.Confirm == nil {
+					m.cfg.Confirm = map[string]confirmPolicy{}
+				}
+				m.cfg.Confirm[name] = confirmPolicy(v)
+				return nil
+			}})
+	}
+	if !m.cfg.Plain {
+		list = append(list, setting{title: "colors", values: colorModes,
+			get: func(m model) string { return m.cfg.Colors },
+			set: func(m *model, v string) tea.Cmd {
+				m.cfg.Colors = v
+				setColors(v)
+				return nil
+			}})
+	}
+	list = append(list,
+		setting{title: "three panes", values: onOff,
+			get: func(m model) string { return onOffValue(m.threePanes) },
+			set: func(m *model, v string) tea.Cmd {
+				m.cfg.ThreePanes = v == "on"
+				if m.threePanes == m.cfg.ThreePanes {
+					return nil
+				}
+				return m.toggleThreePanes()
+			}},
+		setting{title: "show commands", values: onOff,
+			get: func(m model) string { return onOffValue(m.showCommands) },
+			set: func(m *model, v string) tea.Cmd {
+				m.cfg.ShowCommands = v == "on"
+				if m.showCommands != m.cfg.ShowCommands {
+					m.toggleShowCommands()
+				}
+				return nil
+			}},
+		setting{title: "show latency", values: onOff,
+			get: func(m model) string { return onOffValue(m.cfg.ShowLatency) },
+			set: func(m *model, v string) tea.Cmd {
+				m.cfg.ShowLatency = v == "on"
+				measureLatency.Store(m.cfg.ShowLatency)
+				return nil
+			}},
+		setting{title: "stable order", values: onOff,
+			get: func(m model) string { return onOffValue(m.cfg.StableOrder) },
+			set: func(m *model, v string) tea.Cmd {
+				m.cfg.StableOrder = v == "on"
+				return nil
+			}},
+		setting{title: "columns",
+			get: func(m model) string { return strings.Join(m.columns, ", ") }},
+	)
+	return list
+}
+
+// openSettings shows the settings.
+func (m *model) openSettings() {
+	m.settingsPanel = &settingsPanel{}
+}
+
+// updateSettings handles keys while the settings are open.
+func (m model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.settingsPanel
+	list := m.settings()
+	s := list[p.cursor]
+	step := 0
+	switch msg.String() {
+	case "esc", "ctrl+c", ",":
+		m.settingsPanel = nil
+	case "up", "k":
+		p.cursor = max(p.cursor-1, 0)
+	case "down", "j":
+		p.cursor = min(p.cursor+1, len(list)-1)
+	case "space", " ", "enter", "right", "l":
+		step = 1
+	case "left", "h":
+		step = -1
+	case "s":
+		if err := m.saveSettings(); err != nil {
+			m.status = "settings not saved: " + err.Error()
+		} else {
+			p.changed = false
+			m.status = "settings saved to " + savedSettingsPath(m.flags.configPath)
+		}
+	}
+	if step == 0 {
+		return m, nil
+	}
+	if s.values == nil {
+		m.settingsPanel = nil
+		m.openColumnChooser()
+		return m, nil
+	}
+	i := slices.Index(s.values, s.get(m))
+	if i < 0 && step < 0 {
+		i = 0 // a configured value not offered, e.g. auto_refresh = "45s"
+	}
+	v := s.values[(i+step+len(s.values))%len(s.values)]
+	cmd := s.set(&m, v)
+	p.changed = true
+	m.status = s.title + ": " + v + ", 's' in the settings saves it"
+	return m, cmd
+}
+
+// settingsView renders the settings centered on the screen.
+func (m model) settingsView() string {
+	p := m.settingsPanel
+	var sb strings.Builder
+	sb.WriteString("settings\n\n")
+	for i, s := range m.settings() {
+		line := padRight(s.title, 18) + " " + s.get(m)
+		if s.values == nil {
+			line += " …"
+		}
+		if i == p.cursor {
+			line = selectedStyle.Render("> ") + line
+		} else {
+			line = "  " + line
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\nspace/→ next value, ← previous, s save, esc close")
+	if p.changed {
+		sb.WriteString("\n(changed for this session, not saved)")
+	}
+	return m.dialogBox(60, lipgloss.Top, sb.String())
+}
+
+// settingsFile is the content of settings.toml, in the config file
+// format.
+type settingsFile struct {
+	AutoRefresh  string                   `toml:"auto_refresh"`
+	Autoload     autoloadMode             `toml:"autoload"`
+	Colors       string                   `toml:"colors"`
+	ThreePanes   bool                     `toml:"three_panes"`
+	ShowCommands bool                     `toml:"show_commands"`
+	ShowLatency  bool                     `toml:"show_latency"`
+	StableOrder  bool                     `toml:"stable_order"`
+	Confirm      map[string]confirmPolicy `toml:"confirm"`
+}
+
+// savedSettingsPath is the settings file next to the config file, "" if
+// there is no config path.
+func savedSettingsPath(configPath string) string {
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "settings.toml")
+}
+
+// addSavedSettings applies the settings saved in path to c; they are the
+// later choice and win over the config file. A missing file is not an
+// error.
+func (c *config) addSavedSettings(path string) error {
+	if path == "" {
+		return nil
+	}
+	_, err := toml.DecodeFile(path, c)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err == nil {
+		err = c.validate()
+	}
+	if err != nil {
+		return fmt.Errorf("settings %s: %w", path, err)
+	}
+	return nil
+}
+
+// saveSettings writes the settings of the session to the settings file.
+func (m model) saveSettings() error {
+	path := savedSettingsPath(m.flags.configPath)
+	if path == "" {
+		return fmt.Errorf("no config directory")
+	}
+	saved := settingsFile{
+		AutoRefresh:  m.cfg.AutoRefresh.String(),
+		Autoload:     m.cfg.Autoload,
+		Colors:       m.cfg.Colors,
+		ThreePanes:   m.threePanes,
+		ShowCommands: m.showCommands,
+		ShowLatency:  m.cfg.ShowLatency,
+		StableOrder:  m.cfg.StableOrder,
+		Confirm:      map[string]confirmPolicy{},
+	}
+	for _, a := range allActions {
+		if p := m.cfg.Confirm[a.String()]; p != "" {
+			saved.Confirm[a.String()] = p
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = toml.NewEncoder(f).Encode(saved)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
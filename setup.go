@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// "postdel init", and the first start without a config file on a terminal,
+// walk through the settings new users trip over one at a time: where the
+// Postfix tools are, whether the session may change the queue, the header
+// label, how deletes are confirmed and the log file. Enter takes the
+// default of every question, so pressing it throughout gives a working
+// config. The file is written with comments and read back before postdel
+// goes on; an existing file is only replaced after asking.
+
+// setupAnswers are the settings the setup asks for.
+type setupAnswers struct {
+	postfixDir    string
+	readOnly      bool
+	label         string
+	labelColor    string
+	confirmDelete confirmPolicy
+	logFile       string
+}
+
+// firstRun reports whether the setup should be offered before the TUI:
+// the default config file does not exist and a user is at the terminal.
+func firstRun(flags cliFlags, args []string) bool {
+	if flags.configPath == "" || flags.configPath != defaultConfigPath() || len(args) > 0 ||
+		flags.check || flags.printConfig || flags.serve != "" {
+		return false
+	}
+	if _, err := os.Stat(flags.configPath); !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// runSetup asks for the settings and writes the config file to path. With
+// firstStart the user may skip it; "never" writes a config file of
+// comments only so the question does not come again.
+func runSetup(path string, firstStart bool) int {
+	in := bufio.NewReader(os.Stdin)
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "init: no config file path, give one with -config")
+		return exitUsage
+	}
+	if firstStart {
+		switch ask(in, fmt.Sprintf("No config file at %s. Set one up now (yes, no, never)?", path), "yes") {
+		case "no", "n":
+			return exitOK
+		case "never":
+			if err := writeConfigFile(path, "# postdel config file; see README for the settings\n"); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				return exitError
+			}
+			return exitOK
+		}
+	} else if _, err := os.Stat(path); err == nil {
+		if ask(in, path+" exists. Replace it (yes/no)?", "no") != "yes" {
+			fmt.Println("Left as it is.")
+			return exitOK
+		}
+	}
+	fmt.Println("Enter takes the [default] of each question.")
+
+	a := askSetup(in)
+	if err := writeConfigFile(path, a.configText()); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitError
+	}
+	if _, err := loadConfig(path); err != nil {
+		// the answers are checked, so this is a bug; say where the file is
+		fmt.Fprintln(os.Stderr, "Error: the config written does not load:", err)
+		return exitError
+	}
+	fmt.Printf("\nWrote %s.\n", path)
+	return exitOK
+}
+
+// askSetup asks the questions of the setup, reading the answers from in.
+func askSetup(in *bufio.Reader) setupAnswers {
+	var a setupAnswers
+
+	fmt.Println("\n1. Postfix tools")
+	postfixDir = ""
+	problems := checkTools()
+	if len(problems) == 0 {
+		fmt.Printf("   found %s\n", strings.Join(backend.tools, ", "))
+		if info := detectPostfix(); info.err == nil {
+			fmt.Printf("   %s\n", info)
+		}
+	} else {
+		for _, p := range problems {
+			fmt.Printf("   %s: %s\n", p.name, p.reason)
+		}
+	}
+	for {
+		dir := ask(in, "   directory of the Postfix tools (empty: search $PATH and "+strings.Join(postfixFallbackDirs, ", ")+")", "")
+		if dir == "" {
+			break
+		}
+		postfixDir = dir
+		if problems := checkTools(); len(problems) > 0 {
+			fmt.Printf("   %s: %s; another directory, or enter to keep searching\n", problems[0].name, problems[0].reason)
+			postfixDir = ""
+			continue
+		}
+		a.postfixDir = dir
+		break
+	}
+
+	fmt.Println("\n2. Privileges")
+	u, _ := user.Current()
+	if privileged(u) {
+		fmt.Printf("   you are %s: postdel runs postsuper itself (there is no sudo mode) and may change the queue\n", u.Username)
+	} else {
+		fmt.Println("   postdel runs postsuper itself, there is no sudo mode: as a user other than root or postfix the")
+		fmt.Println("   session is read-only by itself; start postdel with sudo to change the queue")
+	}
+	a.readOnly = askYesNo(in, "   start read-only even as root, never changing the queue", false)
+
+	fmt.Println("\n3. Header")
+	a.label = ask(in, "   label on a badge in the header line, e.g. PROD-MX1 (empty: none)", "")
+	if a.label != "" {
+		a.labelColor = ask(in, "   its background, a terminal color number or #rrggbb", defaultLabelColor)
+	}
+
+	fmt.Println("\n4. Confirmation")
+	for {
+		p := ask(in, "   when d asks before deleting: always, single (only for several messages) or never", string(confirmAlways))
+		if slices.Contains(confirmPolicies, p) {
+			a.confirmDelete = confirmPolicy(p)
+			break
+		}
+	}
+
+	fmt.Println("\n5. Log")
+	fmt.Println("   a log file records every change of the queue with its queue IDs, for later questions")
+	if askYesNo(in, "   keep a log file", false) {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			dir = os.TempDir()
+		}
+		a.logFile = ask(in, "   path", filepath.Join(dir, "postdel", "postdel.log"))
+	}
+	return a
+}
+
+// configText renders the answers as a commented config file.
+func (a setupAnswers) configText() string {
+	var sb strings.Builder
+	sb.WriteString("# postdel config file, written by postdel init; see README for all settings\n\n")
+	sb.WriteString("# where the Postfix tools are; empty searches $PATH, then /usr/sbin, …\n")
+	fmt.Fprintf(&sb, "postfix_dir = %q\n\n", a.postfixDir)
+	sb.WriteString("# never change the queue; the same as -read-only\n")
+	fmt.Fprintf(&sb, "read_only = %t\n\n", a.readOnly)
+	sb.WriteString("# a badge in the header line telling sessions apart, and its background\n")
+	fmt.Fprintf(&sb, "label = %q\n", a.label)
+	if a.labelColor != "" {
+		fmt.Fprintf(&sb, "label_color = %q\n", a.labelColor)
+	}
+	sb.WriteString("\n# records every change of the queue; empty: no log, -log-file overrides it\n")
+	fmt.Fprintf(&sb, "log_file = %q\n\n", a.logFile)
+	sb.WriteString("[confirm]\n")
+	sb.WriteString("# when an action asks first: \"always\", \"single\" (one message right\n")
+	sb.WriteString("# away, several after asking) or \"never\"\n")
+	fmt.Fprintf(&sb, "delete = %q\n", a.confirmDelete)
+	return sb.String()
+}
+
+// ask prints question with def and returns the answer, def for an empty
+// one or at the end of the input.
+func ask(in *bufio.Reader, question, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	line, err := in.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if err != nil && line == "" {
+		fmt.Println()
+	}
+	if line == "" {
+		return def
+	}
+	return line
+}
+
+// askYesNo asks a yes/no question.
+func askYesNo(in *bufio.Reader, question string, def bool) bool {
+	d := "no"
+	if def {
+		d = "yes"
+	}
+	answer := strings.ToLower(ask(in, question+" (yes/no)", d))
+	return answer == "yes" || answer == "y"
+}
+
+// writeConfigFile writes text to path, creating its directory.
+func writeConfigFile(path, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(text), 0o600)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// alt+d deletes every message the list shows: what the filter, the hidden
+// queues and 'A' left of the listing, not the marks and never the rest of
+// the queue. The IDs are taken when asking, so a listing coming in while
+// the dialog is open changes nothing; it always asks, whatever [confirm]
+// says.
+
+// requestDeleteShown asks to delete the shown messages.
+func (m *model) requestDeleteShown() tea.Cmd {
+	if m.refuseReadOnly() {
+		return nil
+	}
+	if m.bulk != nil {
+		m.status = "a bulk operation is already running"
+		return nil
+	}
+	a := actionDelete
+	m.confirmTrash = false
+	if m.cfg.Trash {
+		a, m.confirmTrash = actionHold, true
+	}
+	ids := make([]string, 0, len(m.visibleEntries()))
+	for _, e := range m.visibleEntries() {
+		ids = append(ids, e.ID)
+	}
+	m.confirmAction = a
+	m.confirmIDs, m.confirmSummary = ids, ""
+	m.confirmShown = m.shownScope()
+	return m.openActionDialog()
+}
+
+// shownScope tells what limits the list to the shown messages, for the
+// question of alt+d.
+func (m model) shownScope() string {
+	var scope []string
+	if m.filter.active() {
+		scope = append(scope, fmt.Sprintf("filter '%s'", truncate(m.filter.expr, filterShown)))
+	}
+	var hidden []string
+	for _, q := range queueNames {
+		if m.hiddenQueues[q] {
+			hidden = append(hidden, q)
+		}
+	}
+	if len(hidden) > 0 {
+		scope = append(scope, strings.Join(hidden, ", ")+" hidden")
+	}
+	if m.actionableOnly {
+		scope = append(scope, "only those you can act on")
+	}
+	if len(scope) == 0 {
+		scope = append(scope, "nothing hidden: the whole queue")
+	}
+	return fmt.Sprintf("%s; %d listed", strings.Join(scope, ", "), len(m.allEntries))
+}
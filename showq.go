@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// The showq service of Postfix is what postqueue itself asks for the
+// queue: on this machine postdel connects to its socket, public/showq in
+// the queue directory, and reads the messages as attributes, without a
+// subprocess and without parsing text meant for people. The socket is
+// usually only open to root and the postdrop group; where it cannot be
+// reached the listing falls back to postqueue -j and mailq.
+
+// showqSocket is the socket of the showq service below the queue directory.
+const showqSocket = "public/showq"
+
+// showq attribute names, see Postfix's mail_proto.h.
+const (
+	showqQueue     = "queue_name"
+	showqID        = "queue_id"
+	showqTime      = "time"
+	showqSize      = "size"
+	showqSender    = "sender"
+	showqRecipient = "recipient"
+	showqReason    = "reason"
+)
+
+// showqPath returns the path of the showq socket, "" when the queue
+// directory is not known.
+func showqPath() string {
+	dir := detectedPostfix.queueDir
+	if dir == "" && currentSettings().listCommand == "showq" {
+		dir = queueDirectory()
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, showqSocket)
+}
+
+// listShowq lists the queue of this machine from the showq socket.
+func listShowq() ([]queueEntry, error) {
+	path := showqPath()
+	if path == "" {
+		return nil, fmt.Errorf("showq: queue directory unknown")
+	}
+	ctx, cancel := commandContext(context.Background(), "postqueue")
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	start := time.Now()
+	out, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("showq: %w", err)
+	}
+	logger.Debug("showq read", "bytes", len(out), "took", time.Since(start))
+	return parseShowq(out)
+}
+
+// parseShowq parses what the showq service sends: name and value of each
+// attribute end in a NUL, and an empty name ends a group of them. A
+// message starts with its queue name, ID, arrival time, size and sender,
+// followed by a recipient and reason pair per recipient. Attributes of
+// other versions, such as forced_expire, are skipped.
+func parseShowq(out []byte) ([]queueEntry, error) {
+	var entries []queueEntry
+	var stats parseStats
+	listParse.begin(len(out))
+	defer listParse.end()
+	var e *queueEntry
+	done := func() {
+		if e != nil {
+			entries = append(entries, *e)
+			stats.recipients += len(e.Recipients)
+			e = nil
+		}
+	}
+	for len(out) > 0 {
+		name, rest, ok := bytes.Cut(out, []byte{0})
+		if !ok {
+			return nil, fmt.Errorf("%w: showq: attribute name without end", ErrParse)
+		}
+		listParse.advance(len(name) + 1)
+		out = rest
+		if len(name) == 0 {
+			continue // the end of a group
+		}
+		value, rest, ok := bytes.Cut(out, []byte{0})
+		if !ok {
+			return nil, fmt.Errorf("%w: showq: value of %s without end", ErrParse, name)
+		}
+		listParse.advance(len(value) + 1)
+		out = rest
+		stats.lines++
+
+		if string(name) == showqQueue {
+			done()
+			e = &queueEntry{Queue: string(value)}
+			continue
+		}
+		if e == nil {
+			continue // e.g. the protocol announcement of newer versions
+		}
+		switch string(name) {
+		case showqID:
+			e.ID = string(value)
+		case showqTime:
+			if t, err := strconv.ParseInt(string(value), 10, 64); err == nil && t > 0 {
+				e.Arrival = time.Unix(t, 0)
+			}
+		case showqSize:
+			e.Size, _ = strconv.ParseInt(string(value), 10, 64)
+		case showqSender:
+			e.Sender = string(value)
+		case showqRecipient:
+			e.Recipients = append(e.Recipients, string(value))
+		case showqReason:
+			if e.Reason == "" {
+				e.Reason = string(value)
+			}
+		}
+	}
+	done()
+	for _, e := range entries {
+		if e.ID == "" {
+			return nil, fmt.Errorf("%w: showq: message without queue ID", ErrParse)
+		}
+	}
+	stats.entries = len(entries)
+	stats.log("showq")
+	return entries, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseShowq(t *testing.T) {
+	out, err := os.ReadFile("testdata/showq.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := parseShowq(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []queueEntry{
+		{ID: "4F2C31A0E2B", Queue: "deferred", Size: 2316, Arrival: time.Unix(1760598000, 0), Sender: "alice@example.com",
+			Recipients: []string{"bob@example.net", "carol@example.net"},
+			Reason:     "connect to mx.example.net[192.0.2.25]:25: Connection timed out"},
+		{ID: "4F2C41A0E3C", Queue: "hold", Size: 18734, Arrival: time.Unix(1760601600, 0), Sender: "",
+			Recipients: []string{"dave@example.org"}},
+		{ID: "4F2C51A0E4D", Queue: "active", Size: 512, Arrival: time.Unix(1760605200, 0), Sender: "erin@example.com",
+			Recipients: []string{"frank@example.org"}},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(want))
+	}
+	for i, e := range entries {
+		w := want[i]
+		if e.ID != w.ID || e.Queue != w.Queue || e.Size != w.Size || !e.Arrival.Equal(w.Arrival) || e.Sender != w.Sender || e.Reason != w.Reason {
+			t.Errorf("entry %d:\n got %+v\nwant %+v", i, e, w)
+		}
+		if !slices.Equal(e.Recipients, w.Recipients) {
+			t.Errorf("%s: recipients %q, want %q", e.ID, e.Recipients, w.Recipients)
+		}
+	}
+}
+
+func TestParseShowqTruncated(t *testing.T) {
+	out, err := os.ReadFile("testdata/showq.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cut in the middle of the sender of the first message
+	if _, err := parseShowq(out[:120]); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v, want a parse error", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// shutdownGrace is how long in-flight operations may take to wind down
+// after a SIGINT/SIGTERM before the program quits anyway.
+const shutdownGrace = 3 * time.Second
+
+// shutdownMsg asks the TUI to shut down cleanly because of sig.
+type shutdownMsg struct {
+	sig os.Signal
+}
+
+// shutdownTimeoutMsg fires when the grace period is over.
+type shutdownTimeoutMsg struct{}
+
+// handleSignals turns the first SIGINT/SIGTERM into a clean shutdown of p.
+// A second signal kills the program right away (the terminal is still
+// restored by p.Kill).
+func handleSignals(p *tea.Program) {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		p.Send(shutdownMsg{sig: sig})
+		<-sigs
+		p.Kill()
+		fmt.Fprintln(os.Stderr, "postdel: killed by second signal, in-flight operations may be incomplete")
+		os.Exit(exitCodeForSignal(sig))
+	}()
+}
+
+// exitCodeForSignal follows the shell convention of 128+signal number.
+func exitCodeForSignal(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return exitError
+}
+
+// beginShutdown cancels everything in flight and quits once the running bulk
+// operation has reported back, or after shutdownGrace.
+func (m model) beginShutdown(sig os.Signal) (tea.Model, tea.Cmd) {
+	m.shutdownSignal = sig
+	m.pool.cancelWhere(func(string, int) bool { return true })
+	if m.bulk == nil {
+		return m, tea.Quit
+	}
+	m.bulk.cancel()
+	m.status = fmt.Sprintf("received %s, waiting for %s to stop…", sig, m.bulk.action)
+	return m, tea.Tick(shutdownGrace, func(time.Time) tea.Msg { return shutdownTimeoutMsg{} })
+}
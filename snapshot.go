@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// alt+s keeps the current listing as a snapshot and saves it to a file,
+// alt+c compares the latest listing with it: the messages departed since
+// (delivered, expired or removed), the new ones and those still queued,
+// each with its count and total size. Departed messages are shown as the
+// snapshot recorded them. The file is the JSON of -serve's GET /queue, so
+// a snapshot taken during an incident can be loaded later with -snapshot,
+// or compared without a mail server by "postdel compare OLD NEW".
+
+// snapshotDiff is a snapshot compared with a later listing.
+type snapshotDiff struct {
+	departed, arrived, persisting []queueEntry
+}
+
+// compareSnapshot compares then with the entries listed now; persisting
+// messages are the ones listed now, as they may have moved queue.
+func compareSnapshot(then *queueSnapshot, now []queueEntry) snapshotDiff {
+	var d snapshotDiff
+	current := map[string]bool{}
+	for _, e := range now {
+		current[entryKey(e)] = true
+		if _, ok := then.entries[entryKey(e)]; ok {
+			d.persisting = append(d.persisting, e)
+		} else {
+			d.arrived = append(d.arrived, e)
+		}
+	}
+	for key, e := range then.entries {
+		if !current[key] {
+			d.departed = append(d.departed, e)
+		}
+	}
+	return d
+}
+
+// render renders the comparison of then with the listing of at, with a
+// row per message; sorted puts each section in order.
+func (d snapshotDiff) render(then *queueSnapshot, at time.Time, sorted func([]queueEntry), row func(queueEntry) string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Snapshot of %s compared with the listing of %s (%s later)\n",
+		then.at.Format("2006-01-02 15:04:05"), at.Format("2006-01-02 15:04:05"), formatAge(at.Sub(then.at)))
+	section := func(title string, entries []queueEntry) {
+		var size int64
+		for _, e := range entries {
+			size += e.Size
+		}
+		fmt.Fprintf(&sb, "\n%s: %d, %s\n", title, len(entries), formatSize(size))
+		sorted(entries)
+		for _, e := range entries {
+			sb.WriteString(row(e) + "\n")
+		}
+	}
+	section("departed (delivered, expired or removed), as in the snapshot", d.departed)
+	section("new", d.arrived)
+	section("persisting", d.persisting)
+	return sb.String()
+}
+
+// byID sorts entries by queue ID and host.
+func byID(entries []queueEntry) {
+	slices.SortFunc(entries, func(a, b queueEntry) int {
+		return strings.Compare(entryKey(a), entryKey(b))
+	})
+}
+
+// saveSnapshot writes s to a new file in the cache directory and returns
+// its path.
+func saveSnapshot(s *queueSnapshot) (string, error) {
+	q := servedQueue{MTA: backend.name, ListedAt: s.at, Counts: map[string]int{}, Messages: []servedEntry{}}
+	for _, e := range s.entries {
+		q.Counts[e.Queue]++
+		q.Messages = append(q.Messages, servedEntryOf(e))
+	}
+	slices.SortFunc(q.Messages, func(a, b servedEntry) int {
+		return strings.Compare(a.Host+"/"+a.ID, b.Host+"/"+b.ID)
+	})
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "snapshot-"+s.at.Format("20060102-150405")+".json")
+	return path, os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// loadSnapshot reads a snapshot saved by alt+s, or the queue as served by
+// -serve.
+func loadSnapshot(path string) (*queueSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var q servedQueue
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if q.ListedAt.IsZero() {
+		return nil, fmt.Errorf("%s: no listed_at, not a snapshot", path)
+	}
+	entries := make([]queueEntry, 0, len(q.Messages))
+	for _, se := range q.Messages {
+		entries = append(entries, se.entry())
+	}
+	return newSnapshot(entries, q.ListedAt), nil
+}
+
+// takeSnapshot keeps the current listing for alt+c and saves it.
+func (m *model) takeSnapshot() {
+	if m.listedAt.IsZero() {
+		m.status = "the queue has not been listed yet"
+		return
+	}
+	m.snapshot = newSnapshot(m.allEntries, m.listedAt)
+	path, err := saveSnapshot(m.snapshot)
+	if err != nil {
+		m.status = fmt.Sprintf("snapshot of %d messages kept, not saved: %v", len(m.allEntries), err)
+		return
+	}
+	m.status = fmt.Sprintf("snapshot of %d messages saved to %s; alt+c compares", len(m.allEntries), path)
+}
+
+// showSnapshotDiff shows in the details how the latest listing differs from
+// the snapshot, with the rows of the list.
+func (m *model) showSnapshotDiff() {
+	if m.snapshot == nil {
+		m.status = "no snapshot: take one with alt+s or load one with -snapshot"
+		return
+	}
+	if m.listedAt.IsZero() {
+		m.status = "the queue has not been listed yet"
+		return
+	}
+	cols, widths := m.fitColumns()
+	now := time.Now()
+	sorted := func(entries []queueEntry) {
+		byID(entries)
+		sortEntries(entries, m.sort, m.cfg.sortThen())
+	}
+	row := func(e queueEntry) string {
+		return "  " + m.listRow(e, cols, widths, now)
+	}
+	m.rightRaw = compareSnapshot(m.snapshot, m.allEntries).render(m.snapshot, m.listedAt, sorted, row)
+	m.rightMarks = nil
+	m.right.SetContent(m.rightRaw)
+	m.right.GotoTop()
+	m.status = "changes since the snapshot, select a message to leave"
+}
+
+// cliCompare implements "postdel compare OLD [NEW]": it compares the
+// snapshot OLD with the snapshot NEW, or with the queue listed now.
+func cliCompare(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: postdel compare OLD.json [NEW.json]")
+		return exitUsage
+	}
+	then, err := loadSnapshot(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "compare:", err)
+		return exitError
+	}
+	var now []queueEntry
+	at := time.Now()
+	if len(args) == 2 {
+		later, err := loadSnapshot(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "compare:", err)
+			return exitError
+		}
+		for _, e := range later.entries {
+			now = append(now, e)
+		}
+		at = later.at
+	} else {
+		var ok bool
+		if now, ok = cliListQueue(); !ok {
+			return exitError
+		}
+	}
+	row := func(e queueEntry) string {
+		return fmt.Sprintf("  %-14s %-8s %8s  %s", e.ID, e.Queue, formatSize(e.Size), e.senderLabel())
+	}
+	fmt.Print(compareSnapshot(then, now).render(then, at, byID, row))
+	return exitOK
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sortColumns are the list columns showing the value of a sort key.
+var sortColumns = map[string]string{"arrival": "age", "age": "age", "size": "size", "sender": "sender", "tries": "tries", "score": "score"}
+
+// sortKeys are the orders of the list, in the order 'o' cycles through
+// them. -sort takes the same names, with "-" in front for descending, and
+// score, which 'o' offers last when a classifier is configured.
+var sortKeys = []string{"arrival", "age", "size", "sender", "tries"}
+
+// sortComparators compare two entries in ascending order. Entries without
+// the value sort last either way, see sortEntries.
+var sortComparators = map[string]func(a, b queueEntry) int{
+	"arrival": func(a, b queueEntry) int { return a.Arrival.Compare(b.Arrival) },
+	"age":     func(a, b queueEntry) int { return b.Arrival.Compare(a.Arrival) },
+	"size":    func(a, b queueEntry) int { return cmp.Compare(a.Size, b.Size) },
+	"sender": func(a, b queueEntry) int {
+		return strings.Compare(strings.ToLower(a.Sender), strings.ToLower(b.Sender))
+	},
+	"tries": func(a, b queueEntry) int { return cmp.Compare(a.Attempts, b.Attempts) },
+	"score": func(a, b queueEntry) int { return cmp.Compare(a.Score, b.Score) },
+}
+
+// sortOrder is how the list is sorted; the zero value keeps the order of
+// the listing, with desc reversing it.
+type sortOrder struct {
+	key  string
+	desc bool
+}
+
+// parseSortOrder parses "size" or "-size".
+func parseSortOrder(s string) (sortOrder, error) {
+	o := sortOrder{key: strings.TrimPrefix(s, "-"), desc: strings.HasPrefix(s, "-")}
+	if _, ok := sortComparators[o.key]; !ok {
+		return sortOrder{}, fmt.Errorf("invalid sort %q, expected one of %s, with - in front for descending", s, strings.Join(sortKeys, ", "))
+	}
+	return o, nil
+}
+
+// String renders o like -sort takes it.
+func (o sortOrder) String() string {
+	if o.desc {
+		return "-" + o.key
+	}
+	return o.key
+}
+
+// known reports whether e has the value o sorts by.
+func (o sortOrder) known(e queueEntry) bool {
+	switch o.key {
+	case "arrival", "age":
+		return !e.Arrival.IsZero()
+	case "size":
+		return e.Size > 0
+	case "tries":
+		return e.attemptsKnown
+	case "score":
+		return e.scoreKnown
+	}
+	return true
+}
+
+// compare compares two entries by o, those without the value last.
+func (o sortOrder) compare(a, b queueEntry) int {
+	if ka, kb := o.known(a), o.known(b); ka != kb {
+		if ka {
+			return -1
+		}
+		return 1
+	}
+	if o.desc {
+		a, b = b, a
+	}
+	return sortComparators[o.key](a, b)
+}
+
+// sortEntries sorts entries by o, equal ones by the orders of then in turn
+// (sort_then in the config file) and last by queue ID and host, so the
+// order does not depend on the listing and stays put across refreshes.
+func sortEntries(entries []queueEntry, o sortOrder, then []sortOrder) {
+	if o.key == "" {
+		if o.desc {
+			slices.Reverse(entries)
+		}
+		return
+	}
+	chain := append([]sortOrder{o}, then...)
+	slices.SortFunc(entries, func(a, b queueEntry) int {
+		for _, k := range chain {
+			if c := k.compare(a, b); c != 0 {
+				return c
+			}
+		}
+		return cmp.Or(strings.Compare(a.ID, b.ID), strings.Compare(a.Host, b.Host))
+	})
+}
+
+// sortThen parses SortThen, which validate has checked.
+func (c config) sortThen() []sortOrder {
+	var then []sortOrder
+	for _, s := range c.SortThen {
+		o, _ := parseSortOrder(s)
+		then = append(then, o)
+	}
+	return then
+}
+
+// cycleSort switches to the next sort key, after the last one back to the
+// listing order, keeping the direction. 'O' reverses the direction.
+func (m *model) cycleSort() {
+	keys := sortKeys
+	if m.cfg.Classify.enabled() {
+		keys = append(keys[:len(keys):len(keys)], "score")
+	}
+	next := ""
+	if i := slices.Index(keys, m.sort.key); i < len(keys)-1 {
+		next = keys[i+1]
+	}
+	m.sort.key = next
+	if m.ready {
+		m.resizePanes()
+	}
+	m.applyFilter()
+	m.status = "sorted by " + m.sortDescription()
+}
+
+// columnSortKey is the sort key a list column shows, the first of
+// sortKeys for the age column; "" for a column no key sorts by.
+func (m model) columnSortKey(name string) string {
+	keys := sortKeys
+	if m.cfg.Classify.enabled() {
+		keys = append(keys[:len(keys):len(keys)], "score")
+	}
+	for _, k := range keys {
+		if sortColumns[k] == name {
+			return k
+		}
+	}
+	return ""
+}
+
+// sortByColumn is a click on the header of a column: the list is sorted by
+// the key the column shows, ascending, or in the other direction if it is
+// sorted by that column already. Columns no key sorts by do nothing.
+func (m *model) sortByColumn(name string) tea.Cmd {
+	key := m.columnSortKey(name)
+	switch {
+	case key == "":
+		return nil
+	case sortColumns[m.sort.key] == name:
+		m.reverseSort()
+		return nil
+	}
+	m.sort = sortOrder{key: key}
+	if m.ready {
+		m.resizePanes()
+	}
+	m.applyFilter()
+	m.status = "sorted by " + m.sortDescription()
+	return tea.Batch(m.fetchRetryTimes(), m.fetchScores())
+}
+
+// nextSortColumn is alt+h, the header row from the keyboard: the sorted
+// column of the header is sorted the other way, from descending on to the
+// next column to its right, ascending, and after the last back to the
+// queue order.
+func (m *model) nextSortColumn() tea.Cmd {
+	var names []string
+	cols, widths := m.fitColumns()
+	for i, c := range cols {
+		if widths[i] > 0 && m.columnSortKey(c.name) != "" {
+			names = append(names, c.name)
+		}
+	}
+	i := slices.Index(names, sortColumns[m.sort.key])
+	switch {
+	case i >= 0 && !m.sort.desc:
+		m.reverseSort()
+		return nil
+	case i+1 < len(names):
+		return m.sortByColumn(names[i+1])
+	}
+	m.sort = sortOrder{}
+	if m.ready {
+		m.resizePanes()
+	}
+	m.applyFilter()
+	m.status = "sorted by " + m.sortDescription()
+	return nil
+}
+
+// reverseSort flips the direction of the current sort, or of the listing
+// order if there is none. The direction stays when 'o' picks the next key.
+func (m *model) reverseSort() {
+	m.sort.desc = !m.sort.desc
+	m.applyFilter()
+	m.status = "sorted by " + m.sortDescription()
+}
+
+// sortDescription names the current sort for the status line.
+func (m model) sortDescription() string {
+	switch {
+	case m.sort.key == "" && m.sort.desc:
+		return "queue order, reversed"
+	case m.sort.key == "":
+		return "queue order"
+	case m.sort.desc:
+		return m.sort.key + ", descending"
+	}
+	return m.sort.key
+}
+
+// indicator is the chip text below the list, "size ▼"; "" for the plain
+// listing order.
+func (o sortOrder) indicator() string {
+	name := o.key
+	if name == "" {
+		if !o.desc {
+			return ""
+		}
+		name = "queue"
+	}
+	return name + " " + o.arrow()
+}
+
+// arrow points down for descending, up for ascending.
+func (o sortOrder) arrow() string {
+	if o.desc {
+		return "▼"
+	}
+	return "▲"
+}
+
+// shortSize renders a byte count in at most six cells, "512B", "40K",
+// "12.5M".
+func shortSize(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%dB", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%dK", n>>10)
+	case n < 1<<30:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+)
+
+// mailq lists the messages in the order it finds them in the hashed queue
+// directories, which changes as the queue manager moves files around, so
+// in the queue order (no 'o' sort) a refresh could reshuffle the list and
+// the selected message land elsewhere on the screen. With stable_order
+// (the default) the messages keep the place they had in the previous
+// listing and new ones are added at the end, and a refresh keeps the
+// selected message selected, or, if it is gone, the position. The sort
+// keys break ties by queue ID and are stable anyway.
+
+// stableOrder orders next like prev: the messages both list in the order
+// of prev, then those new in next in the order of next.
+func stableOrder(prev, next []queueEntry) []queueEntry {
+	if len(prev) == 0 {
+		return next
+	}
+	type key struct{ host, id string }
+	rank := make(map[key]int, len(prev))
+	for i, e := range prev {
+		rank[key{e.Host, e.ID}] = i
+	}
+	ordered := slices.Clone(next)
+	slices.SortStableFunc(ordered, func(a, b queueEntry) int {
+		ra, okA := rank[key{a.Host, a.ID}]
+		rb, okB := rank[key{b.Host, b.ID}]
+		switch {
+		case okA && okB:
+			return cmp.Compare(ra, rb)
+		case okA:
+			return -1
+		case okB:
+			return 1
+		}
+		return 0
+	})
+	return ordered
+}
+
+// selectionKept is the selection before a new listing, to keep it.
+type selectionKept struct {
+	id, host string
+	pos      int
+}
+
+// keepSelection remembers the selected message before the list is
+// rebuilt.
+func (m model) keepSelection() selectionKept {
+	if m.selected >= len(m.entries) {
+		return selectionKept{}
+	}
+	e := m.entries[m.selected]
+	return selectionKept{id: e.ID, host: e.Host, pos: m.selected}
+}
+
+// restoreSelection selects the message kept again, or the one that took
+// its position when it left the list.
+func (m *model) restoreSelection(k selectionKept) {
+	if k.id == "" || len(m.entries) == 0 {
+		return
+	}
+	if i := slices.IndexFunc(m.entries, func(e queueEntry) bool { return e.ID == k.id && e.Host == k.host }); i >= 0 {
+		m.selected = i
+	} else {
+		m.selected = min(k.pos, len(m.entries)-1)
+	}
+	m.syncLeft()
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// 'J' clusters the queue by subject: a spam run or a bounce storm shows up
+// as one subject with hundreds of messages, whatever their senders. The
+// subjects come from the headers ("postcat -h"), fetched in the background
+// and kept by queue ID for the session like the content types. At most
+// subjectJobs of them wait in the worker pool at a time, the others in a
+// backlog, and the table is rebuilt about twenty times while they come.
+
+// subjectPending marks a subject that is being fetched.
+const subjectPending = "\x00"
+
+// subjectJobs is how many subjects are in the worker pool at most; the
+// pool would otherwise hold a job and a goroutine for every message.
+const subjectJobs = 64
+
+// subjectsRefresh is how many subjects arrive at least between two
+// rebuilds of the table; with more messages it is a twentieth of them.
+const subjectsRefresh = 200
+
+// subjectMsg delivers the decoded subject of a message; cancelled when its
+// job was dropped from the pool.
+type subjectMsg struct {
+	id, subject string
+	cancelled   bool
+}
+
+// subjectClusters is the cluster table in the details pane.
+type subjectClusters struct {
+	rows    []subjectCluster
+	unique  int // messages whose subject no other message has
+	fetched int // messages whose subject is known
+	total   int
+	cursor  int
+	arrived int // subjects fetched since the last rebuild
+}
+
+// subjectCluster is the messages sharing a normalized subject.
+type subjectCluster struct {
+	subject string
+	ids     []string
+}
+
+// replyPrefix matches the reply and forward markers mail clients put in
+// front of a subject, in a few languages.
+var replyPrefix = regexp.MustCompile(`(?i)^\s*(re|fwd?|aw|wg|sv|tr|vs)(\[\d+\])?\s*:\s*`)
+
+// digitRun matches the numbers that vary within one campaign.
+var digitRun = regexp.MustCompile(`\d+`)
+
+// normalizeSubject reduces a decoded subject to its cluster key: reply and
+// forward prefixes removed, whitespace collapsed, lower case, and numbers
+// replaced by "#" so "Invoice 1234" and "Invoice 5678" end up together.
+func normalizeSubject(s string) string {
+	for {
+		t := replyPrefix.ReplaceAllString(s, "")
+		if t == s {
+			break
+		}
+		s = t
+	}
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	return digitRun.ReplaceAllString(s, "#")
+}
+
+// openSubjectClusters shows the clusters and fetches the missing subjects.
+func (m *model) openSubjectClusters() tea.Cmd {
+	m.subjectView = &subjectClusters{}
+	m.destView = nil
+	m.hdrCompare = nil
+	m.focus = 1
+	cmd := m.fetchSubjects()
+	m.buildSubjectClusters()
+	m.showSubjectClusters()
+	return cmd
+}
+
+// fetchSubjects drops the subjects of messages that left the queue and
+// fetches those not known yet, at background priority.
+func (m *model) fetchSubjects() tea.Cmd {
+	m.pruneSubjects()
+	return m.fetchSubjectsOf(m.allEntries)
+}
+
+// pruneSubjects drops the subjects of messages that left the queue.
+func (m *model) pruneSubjects() {
+	listed := map[string]bool{}
+	for _, e := range m.allEntries {
+		listed[e.ID] = true
+	}
+	for id := range m.subjects {
+		if !listed[id] {
+			delete(m.subjects, id)
+		}
+	}
+}
+
+// fetchSubjectsOf fetches the subjects of entries not known yet: they are
+// marked pending and put on the backlog, which feeds the pool.
+func (m *model) fetchSubjectsOf(entries []queueEntry) tea.Cmd {
+	if m.subjects == nil {
+		m.subjects = map[string]string{}
+	}
+	hits, misses := 0, 0
+	for _, e := range entries {
+		if _, ok := m.subjects[e.ID]; ok || e.Queue == "corrupt" {
+			hits++
+			continue
+		}
+		m.subjects[e.ID] = subjectPending
+		m.subjectBacklog = append(m.subjectBacklog, e)
+		misses++
+	}
+	logCache("subjects", hits, misses)
+	return m.submitSubjects()
+}
+
+// submitSubjects moves subjects from the backlog to the pool, up to
+// subjectJobs at a time. Those no longer pending, because their message
+// left the queue or the report that wanted them closed, are dropped.
+func (m *model) submitSubjects() tea.Cmd {
+	var cmds []tea.Cmd
+	for m.subjectJobs < subjectJobs && len(m.subjectBacklog) > 0 {
+		e := m.subjectBacklog[0]
+		m.subjectBacklog = m.subjectBacklog[1:]
+		if m.subjects[e.ID] != subjectPending {
+			continue
+		}
+		m.subjectJobs++
+		id, host := e.ID, e.Host
+		cmd := m.pool.submit(id, prioBackground, func(ctx context.Context) tea.Msg {
+			ctx, cancel := commandContext(ctx, "postcat")
+			defer cancel()
+			out, err := runOutput(backend.show(ctx, host, id, true))
+			if err != nil {
+				return subjectMsg{id: id}
+			}
+			return subjectMsg{id: id, subject: decodeHeader(postcatHeaders(string(out)).Get("Subject"))}
+		})
+		cmds = append(cmds, func() tea.Msg {
+			if msg := cmd(); msg != nil {
+				return msg
+			}
+			return subjectMsg{id: id, cancelled: true}
+		})
+	}
+	if len(m.subjectBacklog) == 0 {
+		m.subjectBacklog = nil
+	}
+	return tea.Batch(cmds...)
+}
+
+// updateSubject keeps a fetched subject, submits the next from the
+// backlog and refreshes the table now and then while it is shown;
+// rebuilding it for every message would be slow on a large queue.
+func (m *model) updateSubject(msg subjectMsg) tea.Cmd {
+	m.subjectJobs--
+	next := m.submitSubjects()
+	if m.subjects[msg.id] != subjectPending {
+		return next // left the queue meanwhile
+	}
+	if msg.cancelled {
+		// read it again when asked
+		delete(m.subjects, msg.id)
+		return next
+	}
+	m.subjects[msg.id] = msg.subject
+	if m.preview != nil {
+		m.notePreviewSubject(msg.id)
+	}
+	v := m.subjectView
+	if v == nil {
+		return next
+	}
+	v.fetched++
+	v.arrived++
+	if v.fetched == v.total || v.arrived >= max(subjectsRefresh, v.total/20) {
+		v.arrived = 0
+		m.buildSubjectClusters()
+		m.showSubjectClusters()
+	}
+	return next
+}
+
+// buildSubjectClusters groups the listed messages with a known subject,
+// the largest clusters first.
+func (m *model) buildSubjectClusters() {
+	v := m.subjectView
+	byKey := map[string][]string{}
+	v.fetched, v.total, v.unique = 0, 0, 0
+	for _, e := range m.allEntries {
+		if e.Queue == "corrupt" {
+			continue
+		}
+		v.total++
+		s, ok := m.subjects[e.ID]
+		if !ok || s == subjectPending {
+			continue
+		}
+		v.fetched++
+		key := normalizeSubject(s)
+		byKey[key] = append(byKey[key], e.ID)
+	}
+	v.rows = nil
+	for key, ids := range byKey {
+		if len(ids) == 1 {
+			v.unique++
+			continue
+		}
+		v.rows = append(v.rows, subjectCluster{subject: key, ids: ids})
+	}
+	slices.SortFunc(v.rows, func(a, b subjectCluster) int {
+		if len(a.ids) != len(b.ids) {
+			return len(b.ids) - len(a.ids)
+		}
+		return strings.Compare(a.subject, b.subject)
+	})
+	v.cursor = min(v.cursor, max(len(v.rows)-1, 0))
+}
+
+// showSubjectClusters renders the table in the details pane.
+func (m *model) showSubjectClusters() {
+	v := m.subjectView
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Subjects of %d/%d messages — up/down choose a cluster, enter selects its messages\n\n", v.fetched, v.total)
+	for i, c := range v.rows {
+		subject := c.subject
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		line := fmt.Sprintf("  %5d× %s", len(c.ids), subject)
+		if i == v.cursor {
+			line = selectedStyle.Render(">" + line[1:])
+		}
+		sb.WriteString(line + "\n")
+	}
+	if len(v.rows) == 0 && v.fetched == v.total {
+		sb.WriteString("  no two messages share a subject\n")
+	}
+	if v.unique > 0 {
+		fmt.Fprintf(&sb, "\n  %d messages with a subject of their own\n", v.unique)
+	}
+	m.rightRaw = sb.String()
+	m.rightMarks = nil
+	m.right.SetContent(m.rightRaw)
+	if line := v.cursor + 2; line >= m.right.YOffset+m.right.Height || line < m.right.YOffset {
+		m.right.SetYOffset(line - m.right.Height/2)
+	}
+}
+
+// updateSubjectClusters handles the details-pane keys while the table is
+// shown.
+func (m *model) updateSubjectClusters(key string) (bool, tea.Cmd) {
+	v := m.subjectView
+	switch key {
+	case "up":
+		v.cursor = max(v.cursor-1, 0)
+	case "down":
+		v.cursor = min(v.cursor+1, max(len(v.rows)-1, 0))
+	case "enter":
+		if v.cursor >= len(v.rows) {
+			return true, nil
+		}
+		c := v.rows[v.cursor]
+		m.subjectView = nil
+		return true, m.selectIDs(c.ids, fmt.Sprintf("selected the %d messages with subject %q, d/h/u/r act on all of them", len(c.ids), c.subject))
+	default:
+		return false, nil
+	}
+	m.showSubjectClusters()
+	return true, nil
+}
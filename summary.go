@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// When the TUI closes, postdel leaves a summary of the queue and of what
+// the session changed in the terminal, to be pasted into a change log. The
+// format is stable: the same lines in the same order, zero counts included,
+// so it can be compared and parsed.
+
+// recordChange counts n messages changed by a (for flush and clear-corrupt:
+// runs) for the summary.
+func (m *model) recordChange(a action, n int) {
+	if m.changes == nil {
+		m.changes = map[action]int{}
+	}
+	m.changes[a] += n
+}
+
+// sessionSummary renders the summary, or "" if the queue was never listed.
+func (m model) sessionSummary() string {
+	if m.listedAt.IsZero() {
+		return ""
+	}
+	counts := map[string]int{}
+	for _, e := range m.allEntries {
+		counts[e.Queue]++
+	}
+	var sb strings.Builder
+	line := func(indent, key string, value any) {
+		fmt.Fprintf(&sb, "%s%-*s %v\n", indent, 16-len(indent), key+":", value)
+	}
+	sb.WriteString("postdel summary\n")
+	line("", "started", m.startedAt.Format(time.RFC3339))
+	line("", "ended", time.Now().Format(time.RFC3339))
+	line("", "listed", m.listedAt.Format(time.RFC3339))
+	if len(queueHosts) > 0 {
+		line("", "hosts", strings.Join(queueHosts, ","))
+	}
+	line("", "messages", len(m.allEntries))
+	for _, q := range queueNames {
+		line("  ", q, counts[q])
+	}
+	sb.WriteString("changes:\n")
+	for _, a := range allActions {
+		line("  ", a.String(), m.changes[a])
+	}
+	return sb.String()
+}
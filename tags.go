@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Session tags: lightweight labels like "spam" or "ask-customer" put on
+// entries while investigating. Like marks they are kept by queue ID, so
+// they survive refreshes, and they are forgotten when postdel exits.
+
+// tagStyle returns the chip style of tag.
+func (m model) tagStyle(tag string) lipgloss.Style {
+	for i, t := range m.cfg.Tags {
+		if t == tag {
+			return lipgloss.NewStyle().Reverse(true).Foreground(tagColors[i%len(tagColors)])
+		}
+	}
+	return lipgloss.NewStyle().Reverse(true)
+}
+
+// tagChip is the one-cell chip in the list row: the first letter of the
+// tag in its color.
+func (m model) tagChip(id string) string {
+	if len(m.tags) == 0 {
+		return ""
+	}
+	tag, ok := m.tags[id]
+	if !ok {
+		return "  "
+	}
+	r, _ := utf8.DecodeRuneInString(tag)
+	return m.tagStyle(tag).Render(string(r)) + " "
+}
+
+// cycleTag gives the selected entry the next configured tag, and after the
+// last one no tag. With marked entries, all of them get the tag that
+// follows the selected entry's.
+func (m *model) cycleTag() {
+	if len(m.cfg.Tags) == 0 {
+		m.status = "no tags configured"
+		return
+	}
+	id := m.entries[m.selected].ID
+	next := m.cfg.Tags[0]
+	if cur, ok := m.tags[id]; ok {
+		next = ""
+		for i, t := range m.cfg.Tags {
+			if t == cur && i+1 < len(m.cfg.Tags) {
+				next = m.cfg.Tags[i+1]
+			}
+		}
+	}
+	ids := m.markedIDs()
+	if len(ids) == 0 {
+		ids = []string{id}
+	}
+	if m.tags == nil {
+		m.tags = map[string]string{}
+	}
+	for _, id := range ids {
+		if next == "" {
+			delete(m.tags, id)
+		} else {
+			m.tags[id] = next
+		}
+	}
+	switch {
+	case next == "":
+		m.status = fmt.Sprintf("untagged %d messages", len(ids))
+	case len(ids) == 1:
+		m.status = fmt.Sprintf("tagged %s as %s", id, next)
+	default:
+		m.status = fmt.Sprintf("tagged %d messages as %s", len(ids), next)
+	}
+	if m.filter.uses("tag") {
+		m.applyFilter()
+		return
+	}
+	m.syncLeft()
+}
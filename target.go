@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// An action on one message acts on the message the details show. Usually
+// that is the selected one, but the two part while the details of a newly
+// selected message are loading, and other views (zoom, three panes) may
+// keep showing a message the cursor has left. Before 'd', 'h', 'u', 'r' or
+// 'e' the message shown is selected again, and if it is no longer listed
+// nothing is done rather than acting on whatever took its place. When the
+// details show something else (help, a tally, "Loading details…") the
+// selected message is the target. The confirmation names the queue ID.
+
+// displayedMessage is the message whose details the right pane shows.
+type displayedMessage struct {
+	entry queueEntry
+	raw   string // the pane's content when it was shown
+}
+
+// noteDisplayed records that the details of e are in the right pane.
+func (m *model) noteDisplayed(e queueEntry) {
+	m.displayed = displayedMessage{entry: e, raw: m.rightRaw}
+}
+
+// displayedEntry returns the message the right pane shows, false when it
+// shows no message or something else since.
+func (m model) displayedEntry() (queueEntry, bool) {
+	d := m.displayed
+	if d.entry.ID == "" || d.raw != m.rightRaw {
+		return queueEntry{}, false
+	}
+	return d.entry, true
+}
+
+// targetDisplayed selects the message shown in the details for an action
+// on it. It fails when that message is no longer in the list.
+func (m *model) targetDisplayed() error {
+	e, ok := m.displayedEntry()
+	if !ok || (m.selected < len(m.entries) && m.entries[m.selected].ID == e.ID && m.entries[m.selected].Host == e.Host) {
+		return nil
+	}
+	i := slices.IndexFunc(m.entries, func(l queueEntry) bool { return l.ID == e.ID && l.Host == e.Host })
+	if i < 0 {
+		return fmt.Errorf("%s, the message shown, is no longer listed; nothing was done", e.ID)
+	}
+	m.selected = i
+	m.syncLeft()
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The terminal title names the session, "postdel — mx1 — 1,234 deferred",
+// so that tabs of several sessions are told apart without switching to
+// them. It is set at every listing. The title the terminal had before is
+// pushed on the xterm title stack at startup and popped again on exit and
+// while suspended. Inside tmux the same escape sets the pane title, which
+// pane-border-format and set-titles can show. terminal_title = false turns
+// it off for terminals that print the escape instead.
+
+const (
+	titlePush = "\x1b[22;0t" // save the window and icon title
+	titlePop  = "\x1b[23;0t" // restore them
+)
+
+// saveTerminalTitle pushes the title of the terminal, see restoreTerminalTitle.
+func saveTerminalTitle() {
+	fmt.Fprint(os.Stdout, titlePush)
+}
+
+// restoreTerminalTitle gives the terminal back the title it had when
+// saveTerminalTitle ran.
+func restoreTerminalTitle() {
+	fmt.Fprint(os.Stdout, titlePop)
+}
+
+// terminalTitle is the title for a listing of entries: the label or the
+// machine, and how many messages are deferred.
+func terminalTitle(label string, entries []queueEntry) string {
+	where := hostname
+	switch {
+	case label != "":
+		where = label
+	case len(queueHosts) > 0:
+		where = strings.Join(queueHosts, ", ")
+	case detectedPostfix.instance != "":
+		where = hostname + " (" + detectedPostfix.instance + ")"
+	}
+	deferred := 0
+	for _, e := range entries {
+		if e.Queue == "deferred" {
+			deferred++
+		}
+	}
+	return fmt.Sprintf("postdel — %s — %s deferred", where, groupThousands(deferred))
+}
+
+// groupThousands writes n with commas between groups of three digits.
+func groupThousands(n int) string {
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// updateTitle sets the terminal title for a listing of entries, when it
+// changed and terminal_title is on.
+func (m *model) updateTitle(entries []queueEntry) tea.Cmd {
+	if !m.cfg.TerminalTitle {
+		return nil
+	}
+	title := terminalTitle(m.cfg.Label, entries)
+	if title == m.title {
+		return nil
+	}
+	m.title = title
+	return tea.SetWindowTitle(title)
+}
+
+// suspend gives the terminal its own title back before stopping, the
+// tea.ResumeMsg sets ours again.
+func (m *model) suspend() tea.Cmd {
+	if !m.cfg.TerminalTitle {
+		return tea.Suspend
+	}
+	m.title = ""
+	return tea.Sequence(func() tea.Msg {
+		restoreTerminalTitle()
+		return nil
+	}, tea.Suspend)
+}
+
+// resumeTitle saves the title of the terminal again after a suspend and
+// sets ours.
+func (m *model) resumeTitle() tea.Cmd {
+	if !m.cfg.TerminalTitle {
+		return nil
+	}
+	saveTerminalTitle()
+	return m.updateTitle(m.allEntries)
+}
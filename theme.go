@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// The colors of the interface are given for each color depth: lipgloss
+// detects what the terminal supports from TERM, COLORTERM and NO_COLOR and
+// picks the value for it. True-color terminals get the exact RGB of the
+// xterm palette, 256-color terminals its index, and 16-color terminals (the
+// Linux console, serial lines, old screen) a named ANSI color chosen to
+// keep the meaning: yellow for the selection, red for alerts, grey for what
+// is in the background. Approximating the 256 indices there turned the
+// selection white and the warnings red. -plain and a terminal without
+// colors draw none; colors (or the settings, ',') overrides the depth.
+
+// themeColor is one color of the interface at every depth.
+func themeColor(trueColor, ansi256, ansi string) lipgloss.CompleteColor {
+	return lipgloss.CompleteColor{TrueColor: trueColor, ANSI256: ansi256, ANSI: ansi}
+}
+
+// The colors of the interface.
+var (
+	colorHighlight = themeColor("#ffffaf", "229", "11") // selection, focused pane
+	colorAlert     = themeColor("#ff0000", "196", "9")  // the warning screen
+	colorWarning   = themeColor("#ff8700", "208", "3")  // failures, search marks
+	colorBadge     = themeColor("#d70000", "160", "1")  // label and disk badges
+	colorBadgeText = themeColor("#ffffff", "15", "15")
+	colorTitleBar  = themeColor("#303030", "236", "0")
+	colorTitleText = themeColor("#d0d0d0", "252", "7")
+	colorSubtle    = themeColor("#585858", "240", "8") // rules, the minimap track
+	colorMuted     = themeColor("#8a8a8a", "245", "8") // explanations
+)
+
+// tagColors are the chip colors, by position of the tag in the config.
+var tagColors = []lipgloss.CompleteColor{
+	themeColor("#ff0000", "196", "9"),
+	themeColor("#00af00", "34", "2"),
+	themeColor("#ffaf00", "214", "3"),
+	themeColor("#00afff", "39", "6"),
+	themeColor("#d75fd7", "170", "5"),
+	themeColor("#8a8a8a", "245", "8"),
+}
+
+// colorModes are the values of colors: "auto" takes the depth detected,
+// the others force one, e.g. for a terminal that claims less than it has.
+var colorModes = []string{"auto", "truecolor", "256", "16", "none"}
+
+// detectedColors is the color support lipgloss detected, for "auto".
+var detectedColors termenv.Profile
+
+// setColors draws the interface with the color depth of mode.
+func setColors(mode string) {
+	profiles := map[string]termenv.Profile{
+		"truecolor": termenv.TrueColor,
+		"256":       termenv.ANSI256,
+		"16":        termenv.ANSI,
+		"none":      termenv.Ascii,
+	}
+	p, ok := profiles[mode]
+	if !ok {
+		p = detectedColors
+	}
+	lipgloss.SetColorProfile(p)
+}
+
+// colorDepth names the color support lipgloss detected, for the log.
+func colorDepth() string {
+	switch lipgloss.ColorProfile() {
+	case termenv.TrueColor:
+		return "true color"
+	case termenv.ANSI256:
+		return "256 colors"
+	case termenv.ANSI:
+		return "16 colors"
+	}
+	return "no colors"
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// When Postfix defers a message it sets the time stamps of the queue file
+// to the time of the next attempt, waiting as long as the message is old,
+// bounded by minimal_backoff_time and maximal_backoff_time. The last
+// attempt is not recorded, but follows from those: next = last + backoff.
+
+var (
+	backoffOnce            sync.Once
+	minBackoff, maxBackoff = 300 * time.Second, 4000 * time.Second // Postfix defaults
+)
+
+// postfixBackoff returns minimal_backoff_time and maximal_backoff_time,
+// asking postconf once.
+func postfixBackoff() (min, max time.Duration) {
+	backoffOnce.Do(func() {
+		out, err := runOutput(postfixCommand(context.Background(), "postconf", "-h", "minimal_backoff_time", "maximal_backoff_time"))
+		lines := strings.Fields(string(out))
+		if err != nil || len(lines) != 2 {
+			return
+		}
+		if d, ok := parsePostfixTime(lines[0]); ok {
+			minBackoff = d
+		}
+		if d, ok := parsePostfixTime(lines[1]); ok {
+			maxBackoff = d
+		}
+	})
+	return minBackoff, maxBackoff
+}
+
+// parsePostfixTime parses a Postfix time value like "300s" or "1h"; a
+// number without unit is seconds.
+func parsePostfixTime(s string) (time.Duration, bool) {
+	units := map[byte]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	unit := time.Second
+	if n := len(s); n > 0 {
+		if u, ok := units[s[n-1]]; ok {
+			unit, s = u, s[:n-1]
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return time.Duration(v) * unit, true
+}
+
+// lastAttempt solves next = last + clamp(last-arrival, min, max) for last.
+func lastAttempt(arrival, next time.Time, min, max time.Duration) time.Time {
+	if last := next.Add(-min); last.Sub(arrival) <= min {
+		return last
+	}
+	if last := next.Add(-max); last.Sub(arrival) >= max {
+		return last
+	}
+	// backoff = last - arrival
+	return arrival.Add(next.Sub(arrival) / 2)
+}
+
+// timingSummary renders the arrival and, for deferred messages of this
+// machine, the last and next delivery attempt. It returns "" when there is
+// nothing to tell. The attempts are read from the Postfix queue file.
+func timingSummary(e queueEntry, now time.Time) string {
+	if e.Arrival.IsZero() {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-8s %s (%s ago)\n", "Arrived:", e.Arrival.Format("2006-01-02 15:04:05"), formatAge(now.Sub(e.Arrival)))
+	next, ok := localRetry(e)
+	if !ok {
+		return sb.String()
+	}
+	min, max := postfixBackoff()
+	last := lastAttempt(e.Arrival, next, min, max)
+	if n, ok := attemptsUntil(e.Arrival, last, min, max); ok {
+		fmt.Fprintf(&sb, "%-8s %s ago, about %d times (estimated from the backoff)\n", "Tried:", formatAge(now.Sub(last)), n)
+	} else {
+		fmt.Fprintf(&sb, "%-8s %s ago, how often cannot be estimated from the backoff\n", "Tried:", formatAge(now.Sub(last)))
+	}
+	if next.After(now) {
+		fmt.Fprintf(&sb, "%-8s in %s\n", "Retry:", formatAge(next.Sub(now)))
+	} else {
+		fmt.Fprintf(&sb, "%-8s due, waiting for the queue manager\n", "Retry:")
+	}
+	return sb.String()
+}
+
+// localRetry returns the next delivery attempt of e when it is a deferred
+// message of this machine's Postfix.
+func localRetry(e queueEntry) (time.Time, bool) {
+	if e.Queue != "deferred" || e.Host != "" || backend.name != "postfix" {
+		return time.Time{}, false
+	}
+	return nextRetry(queueDirectory(), e)
+}
+
+// nextRetry returns the time of the next delivery attempt of a deferred
+// message of this machine, from its queue file in dir.
+func nextRetry(dir string, e queueEntry) (time.Time, bool) {
+	path := queueFile(dir, e)
+	if path == "" {
+		return time.Time{}, false
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), true
+}
+
+// retryTimesMsg holds the next attempts read for the listing of listedAt,
+// nil if the read was cancelled.
+type retryTimesMsg struct {
+	listedAt time.Time
+	times    map[string]time.Time
+}
+
+// wantRetryTimes notes that the retry: term or the attempts need the next
+// attempts of this listing; it reports whether they are read.
+func (m *model) wantRetryTimes() bool {
+	if m.retryTimes == nil {
+		m.retryWanted = true
+	}
+	return m.retryTimes != nil
+}
+
+// fetchRetryTimes reads the next attempts in the background once they are
+// wanted, one file lookup per deferred message, too slow to wait for with
+// thousands. Until they arrive retry: is unknown and so are the attempts.
+func (m *model) fetchRetryTimes() tea.Cmd {
+	if !m.retryWanted || m.retryTimes != nil || m.retryLoading {
+		return nil
+	}
+	m.retryLoading = true
+	entries, listedAt := slices.Clone(m.allEntries), m.listedAt
+	cmd := m.pool.submit("retry-times", prioBackground, func(context.Context) tea.Msg {
+		return retryTimesMsg{listedAt: listedAt, times: retryTimesOf(entries)}
+	})
+	return func() tea.Msg {
+		if msg := cmd(); msg != nil {
+			return msg
+		}
+		return retryTimesMsg{listedAt: listedAt}
+	}
+}
+
+// noteRetryTimes takes the next attempts of msg if they are those of the
+// listing shown, and applies them to the list; else they are read again.
+func (m *model) noteRetryTimes(msg retryTimesMsg) tea.Cmd {
+	m.retryLoading = false
+	if msg.times == nil || !msg.listedAt.Equal(m.listedAt) {
+		return m.fetchRetryTimes()
+	}
+	m.retryTimes, m.retryWanted = msg.times, false
+	m.attemptsEstimated = false
+	m.applyFilter()
+	if m.cleanup != nil && m.cleanup.step == cleanupCriteria {
+		m.matchCleanup()
+	}
+	if m.showSearchPrompt {
+		m.previewFilter()
+	}
+	return nil
+}
+
+// retryTimesOf reads the next attempt of the deferred messages of this
+// machine among entries, by queue ID.
+func retryTimesOf(entries []queueEntry) map[string]time.Time {
+	times := map[string]time.Time{}
+	if backend.name != "postfix" {
+		return times
+	}
+	dir := queueDirectory()
+	for _, e := range entries {
+		if e.Queue != "deferred" || e.Host != "" {
+			continue
+		}
+		if next, ok := nextRetry(dir, e); ok {
+			times[e.ID] = next
+		}
+	}
+	return times
+}
+
+// attemptsUntil counts the attempts of the backoff schedule from the first
+// one at arrival up to the one at last. It is false when the schedule
+// tells nothing: with a minimal_backoff_time of 0 it never moves on from
+// arrival.
+func attemptsUntil(arrival, last time.Time, min, max time.Duration) (int, bool) {
+	if min <= 0 {
+		return 0, false
+	}
+	n := 1
+	for t := arrival; n < 10000; n++ {
+		t = t.Add(clampDuration(t.Sub(arrival), min, max))
+		if t.After(last.Add(time.Second)) {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// clampDuration bounds d to lo..hi.
+func clampDuration(d, lo, hi time.Duration) time.Duration {
+	return max(lo, min(d, hi))
+}
+
+// estimateAttempts sets the delivery attempts of the listed entries for
+// the tries column and sort.
+func (m *model) estimateAttempts() {
+	if !m.wantRetryTimes() {
+		return
+	}
+	estimateAttempts(m.allEntries, m.retryTimes)
+	m.attemptsEstimated = true
+}
+
+// estimateAttempts sets the delivery attempts of entries, given their next
+// attempts: none for a message still in incoming, the backoff schedule up
+// to the last attempt for deferred messages of this machine; the others,
+// and all with a minimal_backoff_time of 0, stay unknown.
+func estimateAttempts(entries []queueEntry, retries map[string]time.Time) {
+	lo, hi := postfixBackoff()
+	for i := range entries {
+		e := &entries[i]
+		switch next, ok := retries[e.ID]; {
+		case e.Queue == "incoming":
+			e.Attempts, e.attemptsKnown = 0, true
+		case e.Queue == "deferred" && ok && !e.Arrival.IsZero():
+			e.Attempts, e.attemptsKnown = attemptsUntil(e.Arrival, lastAttempt(e.Arrival, next, lo, hi), lo, hi)
+		}
+	}
+}
+
+// usesAttempts reports whether the list shows or sorts by the attempts.
+func (m model) usesAttempts() bool {
+	return m.showsColumn("tries") || sortsByAttempts(m.sort, m.cfg.sortThen())
+}
+
+// sortsByAttempts reports whether o or then sort by the attempts.
+func sortsByAttempts(o sortOrder, then []sortOrder) bool {
+	return o.key == "tries" || slices.ContainsFunc(then, func(o sortOrder) bool { return o.key == "tries" })
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// With show_commands (-show-commands, ctrl+o) every program postdel runs
+// for the user is echoed: the line below the status line shows the last
+// one with its exit code and duration, and alt+o lists the last
+// commandHistorySize in the details. Only command lines are shown, never
+// their output; they hold queue IDs and addresses but no message content.
+
+// commandHistorySize is how many commands alt+o lists.
+const commandHistorySize = 100
+
+// echoCommands is whether finished commands are sent to the TUI. It is
+// read by the workers, so it is not a model field.
+var echoCommands atomic.Bool
+
+// commandEcho delivers a finished command to the TUI; set to p.Send in
+// main.
+var commandEcho func(msg any)
+
+// commandRunMsg is a finished command.
+type commandRunMsg struct {
+	at     time.Time
+	args   []string
+	exit   int // -1 if it did not run or was killed
+	took   time.Duration
+	failed bool
+}
+
+// String renders the run on one line.
+func (r commandRunMsg) String() string {
+	return fmt.Sprintf("$ %s · exit %d · %s", strings.Join(r.args, " "), r.exit, r.took.Round(time.Millisecond))
+}
+
+// echoCommand sends a finished command to the TUI if show_commands is on.
+func echoCommand(r commandRunMsg) {
+	if !echoCommands.Load() || commandEcho == nil {
+		return
+	}
+	// commands also run inside Update, which must not wait for itself
+	go commandEcho(r)
+}
+
+// noteCommand records a finished command for the footer and the history.
+func (m *model) noteCommand(r commandRunMsg) {
+	if len(m.ranCommands) == commandHistorySize {
+		m.ranCommands = m.ranCommands[1:]
+	}
+	m.ranCommands = append(m.ranCommands, r)
+}
+
+// toggleShowCommands switches the echo of commands on and off.
+func (m *model) toggleShowCommands() {
+	m.showCommands = !m.showCommands
+	echoCommands.Store(m.showCommands)
+	if m.showCommands {
+		m.status = "showing the commands run, alt+o lists them"
+	} else {
+		m.status = "commands no longer shown"
+	}
+}
+
+// commandFooter is the line below the status line, "" when not shown.
+func (m model) commandFooter() string {
+	if !m.showCommands {
+		return ""
+	}
+	if len(m.ranCommands) == 0 {
+		return disabledStyle.Render("no command run yet")
+	}
+	r := m.ranCommands[len(m.ranCommands)-1]
+	line := truncate(r.at.Format("15:04:05")+" "+r.String(), m.termWidth)
+	if r.failed {
+		return warningStyle.Render(line)
+	}
+	return disabledStyle.Render(line)
+}
+
+// showCommandHistory lists the commands run, newest first, in the details.
+func (m *model) showCommandHistory() {
+	if len(m.ranCommands) == 0 {
+		m.status = "no commands recorded, ctrl+o starts recording them"
+		return
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The last %d commands, newest first:\n\n", len(m.ranCommands))
+	for i := len(m.ranCommands) - 1; i >= 0; i-- {
+		r := m.ranCommands[i]
+		sb.WriteString(r.at.Format("15:04:05") + " " + r.String() + "\n")
+	}
+	m.rightRaw = sb.String()
+	m.rightMarks = nil
+	m.right.SetContent(m.rightRaw)
+	m.right.GotoTop()
+	m.status = "commands run, select a message to leave"
+}
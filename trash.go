@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// In trash mode (-trash) 'd' puts messages on hold and into the session's
+// trash instead of deleting them; 'D' deletes what is in the trash, 'b'
+// shows it. Releasing a message ('u') takes it out again: the trash only
+// keeps the messages that are still on hold.
+
+// trashRows is how many messages the trash pane shows at most.
+const trashRows = 5
+
+// addToTrash records ids as held for deletion.
+func (m *model) addToTrash(ids ...string) {
+	if m.trash == nil {
+		m.trash = map[string]bool{}
+	}
+	for _, id := range ids {
+		m.trash[id] = true
+	}
+}
+
+// pruneTrash drops the messages that left the queue or the hold queue.
+// While a bulk operation runs the hold may not have happened yet.
+func (m *model) pruneTrash() {
+	if len(m.trash) == 0 || m.bulk != nil {
+		return
+	}
+	held := map[string]bool{}
+	for _, e := range m.allEntries {
+		if e.Queue == "hold" {
+			held[e.ID] = true
+		}
+	}
+	for id := range m.trash {
+		if !held[id] {
+			delete(m.trash, id)
+		}
+	}
+}
+
+// trashEntries are the listed messages in the trash, in queue order.
+func (m model) trashEntries() []queueEntry {
+	var entries []queueEntry
+	for _, e := range m.allEntries {
+		if m.trash[e.ID] {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// toggleTrash shows or hides the trash pane.
+func (m *model) toggleTrash() {
+	m.showTrash = !m.showTrash
+	switch {
+	case !m.showTrash:
+		m.status = "trash hidden"
+	case len(m.trash) == 0 && !m.cfg.Trash:
+		m.status = "the trash is empty; start postdel with -trash to have 'd' fill it"
+	default:
+		m.status = fmt.Sprintf("%d messages in the trash, 'D' deletes them, 'u' on one restores it", len(m.trashEntries()))
+	}
+}
+
+// requestEmptyTrash asks to delete every message in the trash.
+func (m *model) requestEmptyTrash() tea.Cmd {
+	if m.refuseReadOnly() {
+		return nil
+	}
+	if m.bulk != nil {
+		m.status = "a bulk operation is already running"
+		return nil
+	}
+	var ids []string
+	for _, e := range m.trashEntries() {
+		ids = append(ids, e.ID)
+	}
+	if len(ids) == 0 {
+		m.status = "the trash is empty"
+		return nil
+	}
+	m.confirmTrash = false
+	return m.askOrRun(actionDelete, ids, "")
+}
+
+// trashPane renders the trash below the panes, "" when hidden.
+func (m model) trashPane() string {
+	if !m.showTrash {
+		return ""
+	}
+	entries := m.trashEntries()
+	lines := []string{fmt.Sprintf("Trash: %d messages on hold, 'D' deletes them, 'u' restores one", len(entries))}
+	for i, e := range entries {
+		if i == trashRows {
+			lines = append(lines, fmt.Sprintf("  … and %d more", len(entries)-trashRows))
+			break
+		}
+		lines = append(lines, truncate(fmt.Sprintf("  %-14s %7s  %s", e.ID, formatSize(e.Size), e.senderLabel()), m.termWidth-4))
+	}
+	if len(entries) == 0 {
+		lines = append(lines, disabledStyle.Render("  empty"))
+	}
+	return borderStyle.Width(m.termWidth - 2).Render(strings.Join(lines, "\n"))
+}
+
+// confirmedIDs are the IDs the confirmation dialog is about: its range,
+// or the selected entry.
+func (m model) confirmedIDs() []string {
+	if m.confirmIDs != nil {
+		return slices.Clone(m.confirmIDs)
+	}
+	if m.confirmEntry.ID != "" {
+		return []string{m.confirmEntry.ID}
+	}
+	if m.selected < len(m.entries) {
+		return []string{m.entries[m.selected].ID}
+	}
+	return nil
+}
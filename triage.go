@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Triage ('ctrl+t') goes through the shown messages one at a time, zoomed
+// to the whole terminal, for clearing a queue after an incident: 'd'
+// deletes the message, 'h' holds it, 'k' keeps it, and the next one
+// follows right away. 'd' and 'h' go through the same checks as outside
+// of it: they ask when confirm says so for a single message (delete does
+// by default), with -trash 'd' puts the message into the trash, and a
+// protected message is not deleted. esc ends it early.
+
+// triageState is the running triage.
+type triageState struct {
+	total               int             // messages shown when it started
+	reviewed            map[string]bool // decided on, by ID
+	deleted, held, kept int
+	wasZoomed           bool
+	deciding            string // the message 'd' or 'h' was pressed on, until it ran
+}
+
+// startTriage zooms in on the selected message and starts the triage.
+func (m *model) startTriage() tea.Cmd {
+	if len(m.entries) == 0 {
+		m.status = "no messages to triage"
+		return nil
+	}
+	m.triage = &triageState{total: len(m.entries), reviewed: map[string]bool{}, wasZoomed: m.zoomed}
+	if !m.zoomed {
+		m.toggleZoom()
+	}
+	m.status = m.triageStatus()
+	return m.runPostcatCmd(m.entries[m.selected].ID)
+}
+
+// triageStatus shows the progress.
+func (m model) triageStatus() string {
+	t := m.triage
+	return fmt.Sprintf("triage: %d of %d reviewed (%d deleted, %d held, %d kept) — d delete, h hold, k keep, esc stop",
+		len(t.reviewed), t.total, t.deleted, t.held, t.kept)
+}
+
+// updateTriage handles the keys of the triage; the others work as when
+// zoomed.
+func (m *model) updateTriage(key string) (bool, tea.Cmd) {
+	switch key {
+	case "d":
+		return true, m.triageDecide(actionDelete)
+	case "h":
+		return true, m.triageDecide(actionHold)
+	case "k":
+		if m.selected < len(m.entries) {
+			m.triage.reviewed[m.entries[m.selected].ID] = true
+			m.triage.kept++
+		}
+		return true, m.triageAdvance()
+	case "esc", "q", "ctrl+t":
+		m.endTriage()
+		return true, nil
+	}
+	return false, nil
+}
+
+// triageDecide requests a on the message shown like 'd' and 'h' do; once
+// it ran (see noteTriaged) the listing after it moves on to the next one,
+// see triageAdvance.
+func (m *model) triageDecide(a action) tea.Cmd {
+	if m.selected >= len(m.entries) {
+		return nil
+	}
+	e := m.entries[m.selected]
+	if len(m.marked) > 0 || m.visual {
+		m.status = "triage decides on the message shown, not on the selection: '-' clears it"
+		return nil
+	}
+	if expr := m.entryProtection(); a == actionDelete && !m.cfg.Trash && expr != "" {
+		m.status = fmt.Sprintf("%s is protected (%s): triage does not delete it, 'd' outside of it asks", e.ID, expr)
+		return nil
+	}
+	m.triage.deciding = e.ID
+	return m.requestAction(a)
+}
+
+// noteTriaged counts a on id if triage asked for it, when it has run.
+func (m *model) noteTriaged(a action, id string) {
+	t := m.triage
+	if t == nil || t.deciding != id {
+		return
+	}
+	t.deciding = ""
+	t.reviewed[id] = true
+	if a == actionDelete {
+		t.deleted++
+	} else {
+		t.held++
+	}
+	m.status = m.triageStatus()
+}
+
+// triageAdvance selects the next message not yet reviewed, from the
+// selected one on, and loads it; the triage ends when there is none.
+func (m *model) triageAdvance() tea.Cmd {
+	m.justDeleted = false
+	for i := m.selected; i < len(m.entries); i++ {
+		if !m.triage.reviewed[m.entries[i].ID] {
+			m.selected = i
+			m.syncLeft()
+			m.status = m.triageStatus()
+			return m.runPostcatCmd(m.entries[i].ID)
+		}
+	}
+	m.endTriage()
+	return nil
+}
+
+// endTriage leaves the triage and says what was done.
+func (m *model) endTriage() {
+	t := m.triage
+	m.triage = nil
+	if m.zoomed && !t.wasZoomed {
+		m.toggleZoom()
+	}
+	m.status = fmt.Sprintf("triage done: %d of %d reviewed, %d deleted, %d held, %d kept",
+		len(t.reviewed), t.total, t.deleted, t.held, t.kept)
+}
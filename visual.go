@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var visualStyle = lipgloss.NewStyle().Reverse(true)
+
+// visualRange returns the entries covered by visual mode as the half-open
+// range [from, to) of the list as shown.
+func (m model) visualRange() (from, to int) {
+	from, to = m.visualAnchor, m.selected
+	if from > to {
+		from, to = to, from
+	}
+	return from, minInt(to+1, len(m.entries))
+}
+
+// inVisualRange reports whether list position i is part of the range.
+func (m model) inVisualRange(i int) bool {
+	if !m.visual {
+		return false
+	}
+	from, to := m.visualRange()
+	return i >= from && i < to
+}
+
+// toggleVisual enters visual mode anchored at the selected entry, or leaves it.
+func (m *model) toggleVisual() {
+	if m.visual {
+		m.leaveVisual()
+		return
+	}
+	m.visual = true
+	m.visualAnchor = m.selected
+	m.syncLeft()
+	m.status = m.visualStatus()
+}
+
+// leaveVisual ends visual mode without acting on the range.
+func (m *model) leaveVisual() {
+	m.visual = false
+	m.status = ""
+	m.syncLeft()
+}
+
+func (m model) visualStatus() string {
+	from, to := m.visualRange()
+	return fmt.Sprintf("-- VISUAL -- %d messages; d/h/u/r act on them, esc leaves", to-from)
+}
+
+// extendVisual moves the cursor by dir while extending the range, starting
+// visual mode first if needed (shift+up/down).
+func (m *model) extendVisual(dir int) tea.Cmd {
+	if len(m.entries) == 0 {
+		return nil
+	}
+	if !m.visual {
+		m.toggleVisual()
+	}
+	next := m.selected + dir
+	if next < 0 || next >= len(m.entries) {
+		return nil
+	}
+	m.selected = next
+	m.syncLeft()
+	m.status = m.visualStatus()
+	return m.runPostcatCmd(m.entries[m.selected].ID)
+}
+
+// requestRangeAction runs a on the visual range after a confirmation and
+// leaves visual mode.
+func (m *model) requestRangeAction(a action) tea.Cmd {
+	from, to := m.visualRange()
+	m.visual = false
+	m.syncLeft()
+	return m.confirmRange(a, from, to)
+}
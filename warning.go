@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"slices"
+	"strings"
+)
+
+// Before the queue is listed postdel can show a screen to acknowledge:
+// by default the warning that it runs read-only because the user is not
+// root or postfix. [warning] replaces its text with a site banner and
+// says when it appears: always, never, or only for unprivileged users,
+// except the users listed in skip_users (service accounts). Whether the
+// session is read-only does not depend on it.
+
+// Values of warning.show.
+const (
+	warningUnprivileged = "unprivileged" // the default
+	warningAlways       = "always"
+	warningNever        = "never"
+)
+
+// warningModes are the valid values of warning.show.
+var warningModes = []string{warningUnprivileged, warningAlways, warningNever}
+
+// warningConfig is the [warning] section.
+type warningConfig struct {
+	Show      string   `toml:"show"`
+	Text      string   `toml:"text"`       // "" for the built-in text
+	SkipUsers []string `toml:"skip_users"` // never shown to these users
+}
+
+// defaultWarningText is shown to unprivileged users without warning.text.
+const defaultWarningText = `
+WARNING!
+
+Usually this program should be run as "root" or "postfix" so that "mailq" and "postcat" work properly.
+
+You are NOT root/postfix, so postdel runs READ-ONLY: delete, hold,
+release, requeue and flush are disabled. Showing message contents may be
+refused by postcat as well.
+`
+
+// warningKeys ends every warning text.
+const warningKeys = "Press any key (except q/esc) to continue, or 'q'/'esc' to cancel."
+
+// validate checks the [warning] section.
+func (w warningConfig) validate() error {
+	if !slices.Contains(warningModes, w.Show) {
+		return fmt.Errorf("warning.show must be one of %s", strings.Join(warningModes, ", "))
+	}
+	if w.Show == warningAlways && strings.TrimSpace(w.Text) == "" {
+		return fmt.Errorf("warning.show = %q needs warning.text", warningAlways)
+	}
+	return nil
+}
+
+// shows reports whether the warning appears for u, who may or may not
+// change the queue.
+func (w warningConfig) shows(u *user.User, unprivileged bool) bool {
+	if u != nil && slices.Contains(w.SkipUsers, u.Username) {
+		return false
+	}
+	switch w.Show {
+	case warningAlways:
+		return true
+	case warningNever:
+		return false
+	}
+	return unprivileged
+}
+
+// content is the text of the warning screen.
+func (w warningConfig) content() string {
+	text := w.Text
+	if strings.TrimSpace(text) == "" {
+		text = defaultWarningText
+	}
+	return strings.TrimSpace(text) + "\n\n" + warningKeys
+}
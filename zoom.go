@@ -0,0 +1,40 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// 'Z' (or enter on the details) zooms the details to the whole width of
+// the terminal, for reading; the list is hidden until 'Z' or esc. up and
+// down still go to the previous and next message, so the queue can be
+// read through zoomed.
+
+// toggleZoom zooms the details in or out; the focus returns to where it
+// was.
+func (m *model) toggleZoom() {
+	m.zoomed = !m.zoomed
+	if m.zoomed {
+		m.zoomFocus, m.focus = m.focus, 1
+		m.status = "zoomed: up/down next message, pgup/pgdown scroll, 'Z' or esc back"
+	} else {
+		m.focus = m.zoomFocus
+		if m.focus == focusHeaders && !m.showsThreePanes() {
+			m.focus = 1
+		}
+		m.status = ""
+	}
+	m.resizePanes()
+	m.syncLeft()
+}
+
+// updateZoomed handles the keys that act differently while zoomed.
+func (m *model) updateZoomed(key string) (bool, tea.Cmd) {
+	switch key {
+	case "Z", "esc":
+		m.toggleZoom()
+		return true, nil
+	case "up":
+		return true, m.stepSelection(-1)
+	case "down":
+		return true, m.stepSelection(1)
+	}
+	return false, nil
+}